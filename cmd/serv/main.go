@@ -1,7 +1,30 @@
 package main
 
-import "github.com/Starktomy/smshub/internal"
+import (
+	"fmt"
+	"os"
+
+	"github.com/Starktomy/smshub/internal"
+)
+
+const configPath = "./config.yaml"
+
+// commands 命令行子命令，不带子命令时启动服务
+var commands = map[string]func(defaultConfigPath string, args []string) error{
+	"migrate": internal.Migrate,
+	"backup":  internal.Backup,
+	"restore": internal.Restore,
+}
 
 func main() {
-	internal.Run("./config.yaml")
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(configPath, os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	internal.Run(configPath)
 }
@@ -1,16 +1,58 @@
 package config
 
 type AppConfig struct {
-	JWT    JWTConfig         `json:"JWT"`
-	Users  map[string]string `json:"Users"`  // 用户名 -> bcrypt加密的密码
-	Serial SerialConfig      `json:"Serial"` // 串口配置
-	OIDC   *OIDCConfig       `json:"OIDC"`   // OIDC配置（可选）
+	JWT     JWTConfig         `json:"JWT"`
+	Users   map[string]string `json:"Users"`   // 用户名 -> bcrypt加密的密码，仅在首次启动用户表为空时导入为管理员
+	Serial  SerialConfig      `json:"Serial"`  // 串口配置
+	OIDC    *OIDCConfig       `json:"OIDC"`    // OIDC配置（可选）
+	Backup  BackupConfig      `json:"Backup"`  // 备份配置
+	Login   LoginConfig       `json:"Login"`   // 登录保护配置
+	Metrics MetricsConfig     `json:"Metrics"` // Prometheus 指标接口配置
+	Health  HealthConfig      `json:"Health"`  // 就绪检查配置
+	Tracing TracingConfig     `json:"Tracing"` // OpenTelemetry 链路追踪配置
 }
 
 // JWTConfig JWT配置
 type JWTConfig struct {
-	Secret       string `json:"Secret"`
-	ExpiresHours int    `json:"ExpiresHours"`
+	Secret             string `json:"Secret"`             // 签名密钥，为空时自动生成并保存到数据库
+	ExpiresHours       int    `json:"ExpiresHours"`       // 会话（刷新令牌）有效期，默认 168 小时
+	AccessTokenMinutes int    `json:"AccessTokenMinutes"` // 访问令牌有效期，默认 15 分钟
+}
+
+// LoginConfig 登录保护配置：失败次数限制、指数退避锁定和两步验证
+type LoginConfig struct {
+	IPMaxAttempts       int    `json:"IPMaxAttempts"`       // 同一 IP 在统计窗口内允许的失败次数，默认 20，小于 0 时不限制
+	UsernameMaxAttempts int    `json:"UsernameMaxAttempts"` // 同一用户名在统计窗口内允许的失败次数，默认 5，小于 0 时不限制
+	WindowMinutes       int    `json:"WindowMinutes"`       // 失败次数统计窗口，默认 15 分钟
+	LockoutSeconds      int    `json:"LockoutSeconds"`      // 首次锁定时长，之后每次锁定翻倍，默认 60 秒
+	MaxLockoutMinutes   int    `json:"MaxLockoutMinutes"`   // 最长锁定时长，默认 60 分钟
+	TOTPIssuer          string `json:"TOTPIssuer"`          // 验证器应用中显示的发行方，默认 SMSHub
+}
+
+// MetricsConfig /metrics 接口配置，Token 和用户名密码都为空时不需要认证
+type MetricsConfig struct {
+	Token    string `json:"Token"`    // Bearer Token
+	Username string `json:"Username"` // Basic 认证用户名
+	Password string `json:"Password"` // Basic 认证密码
+}
+
+// HealthConfig /health/ready 就绪检查配置
+// 检查项：database、devices、serial、scheduler、notifications
+type HealthConfig struct {
+	MinOnlineDevices       int      `json:"MinOnlineDevices"`       // 至少在线的设备数量，默认 0 不要求
+	MaxNotificationBacklog int      `json:"MaxNotificationBacklog"` // 正在发送的通知数量上限，默认 100
+	Optional               []string `json:"Optional"`               // 不影响就绪状态的检查项，失败时只在结果中标记
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置，通过 OTLP 导出到 Collector
+type TracingConfig struct {
+	Enabled     bool              `json:"Enabled"`     // 是否启用
+	Endpoint    string            `json:"Endpoint"`    // Collector 地址（host:port），默认 grpc 为 localhost:4317，http 为 localhost:4318
+	Protocol    string            `json:"Protocol"`    // grpc 或 http，默认 grpc
+	Insecure    bool              `json:"Insecure"`    // 不使用 TLS
+	Headers     map[string]string `json:"Headers"`     // 导出时附加的请求头，如认证信息
+	ServiceName string            `json:"ServiceName"` // 服务名称，默认 smshub
+	SampleRatio float64           `json:"SampleRatio"` // 采样比例（0-1），默认 1 全部采样
 }
 
 // SerialConfig 串口配置
@@ -23,6 +65,41 @@ type OIDCConfig struct {
 	Enabled      bool   `json:"Enabled"`      // 是否启用OIDC
 	Issuer       string `json:"Issuer"`       // OIDC Provider的Issuer URL
 	ClientID     string `json:"ClientID"`     // Client ID
-	ClientSecret string `json:"ClientSecret"` // Client Secret
+	ClientSecret string `json:"ClientSecret"` // Client Secret，启用 PKCE 的公共客户端可以为空
 	RedirectURL  string `json:"RedirectURL"`  // 回调URL
+
+	Scopes []string `json:"Scopes"` // 请求的 scope，默认 openid、profile、email，openid 总是包含在内
+	PKCE   bool     `json:"PKCE"`   // 使用 PKCE（S256）交换授权码
+
+	GroupsClaim string            `json:"GroupsClaim"` // 分组所在的 claim，默认 groups，支持 realm_access.roles 形式的嵌套路径
+	RoleMapping map[string]string `json:"RoleMapping"` // 分组 -> 角色（admin/operator/viewer），匹配多个时取最高角色
+	DefaultRole string            `json:"DefaultRole"` // 没有匹配分组时的角色，默认 viewer
+
+	AllowedDomains []string `json:"AllowedDomains"` // 允许登录的邮箱域名，为空不限制；设置后要求 email_verified 为 true
+	RequiredGroups []string `json:"RequiredGroups"` // 必须属于其中至少一个分组才能登录，为空不限制
+
+	RPLogout              bool   `json:"RPLogout"`              // 退出登录时同时退出 IdP 的会话（RP-initiated logout）
+	PostLogoutRedirectURL string `json:"PostLogoutRedirectURL"` // 退出 IdP 会话后跳转回的地址
+}
+
+// BackupConfig 数据库备份配置（仅支持 SQLite）
+type BackupConfig struct {
+	Dir        string   `json:"Dir"`        // 本地备份目录，未启用 S3 时备份保存在这里
+	Schedule   string   `json:"Schedule"`   // 定时备份 cron 表达式，为空则不启用
+	Keep       int      `json:"Keep"`       // 保留最近的备份数量
+	Gzip       bool     `json:"Gzip"`       // 是否 gzip 压缩
+	Passphrase string   `json:"Passphrase"` // 加密口令，设置后使用 AES-256-GCM 加密
+	S3         S3Config `json:"S3"`         // S3 兼容存储（可选）
+}
+
+// S3Config S3 兼容对象存储配置
+type S3Config struct {
+	Enabled   bool   `json:"Enabled"`   // 是否上传到 S3
+	Endpoint  string `json:"Endpoint"`  // 服务地址，如 https://s3.amazonaws.com
+	Region    string `json:"Region"`    // 区域，如 us-east-1
+	Bucket    string `json:"Bucket"`    // 存储桶
+	Prefix    string `json:"Prefix"`    // 对象前缀，如 smshub/
+	AccessKey string `json:"AccessKey"` // Access Key
+	SecretKey string `json:"SecretKey"` // Secret Key
+	PathStyle bool   `json:"PathStyle"` // 使用路径风格访问（MinIO 等需要开启）
 }
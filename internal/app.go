@@ -2,24 +2,29 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Starktomy/smshub/config"
 	"github.com/Starktomy/smshub/internal/handler"
+	"github.com/Starktomy/smshub/internal/metrics"
 	"github.com/Starktomy/smshub/internal/middleware"
+	"github.com/Starktomy/smshub/internal/migration"
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/repo"
 	"github.com/Starktomy/smshub/internal/service"
+	"github.com/Starktomy/smshub/internal/tracing"
 	"github.com/Starktomy/smshub/internal/version"
 	"github.com/Starktomy/smshub/web"
 	"github.com/go-orz/orz"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
 // Handlers 所有Handler的集合
@@ -30,6 +35,15 @@ type Handlers struct {
 	Serial        *handler.SerialHandler
 	ScheduledTask *handler.ScheduledTaskHandler
 	Device        *handler.DeviceHandler
+	Retention     *handler.RetentionHandler
+	Backup        *handler.BackupHandler
+	Contact       *handler.ContactHandler
+	KeepAlive     *handler.KeepAliveHandler
+	Campaign      *handler.CampaignHandler
+	User          *handler.UserHandler
+	Audit         *handler.AuditHandler
+	Health        *handler.HealthHandler
+	Telemetry     *handler.TelemetryHandler
 }
 
 func Run(configPath string) {
@@ -43,8 +57,8 @@ func setup(app *orz.App) error {
 	logger := app.Logger()
 	db := app.GetDatabase()
 
-	// 1. 数据库迁移
-	if err := autoMigrate(db, logger); err != nil {
+	// 1. 数据库迁移（执行所有未执行的版本化迁移）
+	if _, err := migration.New(logger, db).Up(context.Background()); err != nil {
 		logger.Error("数据库迁移失败", zap.Error(err))
 		return err
 	}
@@ -62,14 +76,28 @@ func setup(app *orz.App) error {
 	// 3. 设置默认值
 	setDefaultConfig(&appConfig, logger)
 
+	// 链路追踪，启用后为 HTTP 请求和数据库写操作生成 span
+	shutdownTracing, err := tracing.Setup(context.Background(), appConfig.Tracing, logger)
+	if err != nil {
+		logger.Error("初始化链路追踪失败", zap.Error(err))
+		return err
+	}
+	if appConfig.Tracing.Enabled {
+		if err := db.Use(tracing.GormPlugin{}); err != nil {
+			logger.Error("注册数据库链路追踪失败", zap.Error(err))
+			return err
+		}
+	}
+
 	// 4. 初始化 Repository
 	textMessageRepo := repo.NewTextMessageRepo(db)
+	conversationStateRepo := repo.NewConversationStateRepo(db)
 	deviceRepo := repo.NewDeviceRepo(db)
 
 	// 5. 初始化 Service
 	propertyService := service.NewPropertyService(logger, db)
 	notifier := service.NewNotifier(logger)
-	textMessageService := service.NewTextMessageService(logger, textMessageRepo)
+	textMessageService := service.NewTextMessageService(logger, textMessageRepo, conversationStateRepo)
 
 	// 初始化默认配置
 	ctx := context.Background()
@@ -77,6 +105,25 @@ func setup(app *orz.App) error {
 		logger.Error("初始化默认配置失败", zap.Error(err))
 	}
 
+	// 未配置 JWT 密钥时使用自动生成并保存到数据库的密钥，重启后无需重新登录
+	if appConfig.JWT.Secret == "" {
+		secret, err := propertyService.EnsureJWTSecret(ctx)
+		if err != nil {
+			logger.Error("读取JWT密钥失败", zap.Error(err))
+			return err
+		}
+		appConfig.JWT.Secret = secret
+		logger.Info("未配置JWT密钥，使用数据库中保存的密钥")
+	}
+
+	// 初始化短信全文索引，并为历史短信识别验证码
+	if err := textMessageService.InitSearchIndex(ctx); err != nil {
+		logger.Error("初始化短信搜索失败", zap.Error(err))
+	}
+	if err := textMessageService.BackfillOTPCodes(ctx); err != nil {
+		logger.Error("识别历史短信验证码失败", zap.Error(err))
+	}
+
 	// 6. 初始化设备管理器
 	deviceManager := service.NewDeviceManager(
 		logger,
@@ -102,20 +149,74 @@ func setup(app *orz.App) error {
 		serialService,
 		deviceManager,
 	)
-	serialService.SetScheduledTaskStatusUpdater(schedulerService.UpdateLastRunStatusByMsgId)
-	deviceManager.SetScheduledTaskStatusUpdater(schedulerService.UpdateLastRunStatusByMsgId)
+	schedulerService.SetNotifier(notifier, propertyService)
 
-	// 9. 初始化 OIDC 和 Account Service
+	// 后台批量发送
+	batchJobService := service.NewBatchJobService(logger, db, deviceManager)
+
+	// 定时群发
+	campaignService := service.NewCampaignService(logger, db, serialService, deviceManager)
+
+	// 短信发送结果同时更新定时任务执行记录和群发收件人状态
+	smsStatusUpdater := func(ctx context.Context, msgID string, status models.LastRunStatus) error {
+		return errors.Join(
+			schedulerService.UpdateLastRunStatusByMsgId(ctx, msgID, status),
+			campaignService.UpdateStatusByMsgId(ctx, msgID, status),
+		)
+	}
+	serialService.SetScheduledTaskStatusUpdater(smsStatusUpdater)
+	deviceManager.SetScheduledTaskStatusUpdater(smsStatusUpdater)
+
+	// SIM 卡保号，生成的定时任务在最近有真实发送记录时跳过
+	keepAliveService := service.NewKeepAliveService(logger, db, schedulerService)
+
+	// 短信保留策略与自动清理
+	retentionService := service.NewRetentionService(logger, db, propertyService)
+
+	// 设备遥测数据，状态上报时采样并定时汇总
+	telemetryService := service.NewTelemetryService(logger, db)
+	deviceManager.SetTelemetryRecorder(telemetryService.Record)
+
+	// 数据库备份
+	backupService := service.NewBackupService(logger, db, appConfig.Backup)
+
+	// 9. 初始化用户、OIDC 和 Account Service，首次启动时从配置文件导入管理员
+	userService := service.NewUserService(logger, db)
+	if err := userService.Bootstrap(ctx, appConfig.Users); err != nil {
+		logger.Error("导入配置文件用户失败", zap.Error(err))
+		return err
+	}
+	accessService := service.NewAccessService(logger, db)
+	auditService := service.NewAuditService(logger, db)
 	oidcService := service.NewOIDCService(logger, &appConfig)
-	accountService := service.NewAccountService(logger, oidcService, &appConfig)
+	sessionService := service.NewSessionService(logger, db, appConfig.JWT)
+	totpService := service.NewTOTPService(logger, db, appConfig.Login.TOTPIssuer)
+	loginGuard := service.NewLoginGuard(logger, appConfig.Login)
+	accountService := service.NewAccountService(logger, oidcService, userService, sessionService, totpService, loginGuard)
 
 	// 10. 初始化 Handler
-	authHandler := handler.NewAuthHandler(logger, accountService)
+	authHandler := handler.NewAuthHandler(logger, accountService, userService, sessionService, totpService, auditService)
 	propertyHandler := handler.NewPropertyHandler(logger, propertyService, notifier)
 	textMessageHandler := handler.NewTextMessageHandler(logger, textMessageService, textMessageRepo)
 	serialHandler := handler.NewSerialHandler(logger, serialService)
 	scheduledTaskHandler := handler.NewScheduledTaskHandler(logger, schedulerService)
-	deviceHandler := handler.NewDeviceHandler(logger, deviceManager)
+	deviceHandler := handler.NewDeviceHandler(logger, deviceManager, batchJobService)
+	retentionHandler := handler.NewRetentionHandler(logger, retentionService)
+	backupHandler := handler.NewBackupHandler(logger, backupService)
+	contactHandler := handler.NewContactHandler(logger, service.NewContactService(db))
+	keepAliveHandler := handler.NewKeepAliveHandler(logger, keepAliveService)
+	campaignHandler := handler.NewCampaignHandler(logger, campaignService)
+	userHandler := handler.NewUserHandler(logger, userService, accessService, sessionService, totpService)
+	auditHandler := handler.NewAuditHandler(logger, auditService)
+	telemetryHandler := handler.NewTelemetryHandler(logger, telemetryService)
+
+	// 单设备模式的串口也参与就绪检查
+	var healthSerial *service.SerialService
+	if appConfig.Serial.Port != "" {
+		healthSerial = serialService
+	}
+	healthService := service.NewHealthService(logger, db, appConfig.Health, deviceManager, healthSerial, schedulerService, notifier)
+	healthHandler := handler.NewHealthHandler(logger, healthService)
 
 	handlers := &Handlers{
 		Auth:          authHandler,
@@ -124,10 +225,29 @@ func setup(app *orz.App) error {
 		Serial:        serialHandler,
 		ScheduledTask: scheduledTaskHandler,
 		Device:        deviceHandler,
+		Retention:     retentionHandler,
+		Backup:        backupHandler,
+		Contact:       contactHandler,
+		KeepAlive:     keepAliveHandler,
+		Campaign:      campaignHandler,
+		User:          userHandler,
+		Audit:         auditHandler,
+		Health:        healthHandler,
+		Telemetry:     telemetryHandler,
 	}
 
 	// 11. 设置 API 路由
-	setupApi(app, handlers, &appConfig, logger)
+	if appConfig.Tracing.Enabled {
+		app.GetEcho().Use(otelecho.Middleware(tracing.ServiceName(appConfig.Tracing), otelecho.WithSkipper(func(c echo.Context) bool {
+			return !strings.HasPrefix(c.Request().URL.Path, "/api")
+		})))
+	}
+	setupApi(app, handlers, sessionService, accessService, auditService, logger)
+
+	// Prometheus 指标，设备状态在每次采集时读取
+	metrics.Registry.MustRegister(metrics.NewDeviceCollector(deviceManager.DeviceStates))
+	app.GetEcho().GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})),
+		middleware.MetricsAuth(appConfig.Metrics))
 
 	// 12. 启动后台服务
 	background := context.Background()
@@ -151,6 +271,31 @@ func setup(app *orz.App) error {
 		logger.Info("定时任务服务启动成功")
 	}
 
+	// 继续处理未完成的批量发送任务
+	if err := batchJobService.Start(background); err != nil {
+		logger.Error("启动批量发送服务失败", zap.Error(err))
+	}
+
+	// 启动定时群发
+	if err := campaignService.Start(background); err != nil {
+		logger.Error("启动群发服务失败", zap.Error(err))
+	}
+
+	// 启动短信自动清理
+	if err := retentionService.Start(background); err != nil {
+		logger.Error("启动短信清理服务失败", zap.Error(err))
+	}
+
+	// 启动遥测数据汇总
+	if err := telemetryService.Start(background); err != nil {
+		logger.Error("启动设备遥测服务失败", zap.Error(err))
+	}
+
+	// 启动定时备份
+	if err := backupService.Start(background); err != nil {
+		logger.Error("启动定时备份失败", zap.Error(err))
+	}
+
 	// 13. 注册优雅关闭钩子
 	e := app.GetEcho()
 	e.Server.RegisterOnShutdown(func() {
@@ -158,6 +303,11 @@ func setup(app *orz.App) error {
 
 		// 停止定时任务
 		schedulerService.Stop()
+		campaignService.Stop()
+		batchJobService.Stop()
+		retentionService.Stop()
+		telemetryService.Stop()
+		backupService.Stop()
 
 		// 停止串口服务（单设备模式）
 		if appConfig.Serial.Port != "" {
@@ -167,6 +317,13 @@ func setup(app *orz.App) error {
 		// 停止设备管理器（内部会停止所有设备的串口服务）
 		deviceManager.Stop()
 
+		// 导出剩余的 span
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Warn("关闭链路追踪失败", zap.Error(err))
+		}
+
 		logger.Info("优雅关闭完成")
 	})
 
@@ -176,50 +333,17 @@ func setup(app *orz.App) error {
 
 // setDefaultConfig 设置默认配置
 func setDefaultConfig(appConfig *config.AppConfig, logger *zap.Logger) {
-	// JWT 默认值
-	if appConfig.JWT.Secret == "" {
-		appConfig.JWT.Secret = uuid.NewString()
-		logger.Warn("未配置JWT密钥，使用随机UUID")
-	}
+	// JWT 默认值，未配置密钥时在初始化 Service 后使用数据库中保存的密钥
 	if appConfig.JWT.ExpiresHours == 0 {
 		appConfig.JWT.ExpiresHours = 168 // 7天
 	}
-}
-
-// autoMigrate 数据库迁移
-func autoMigrate(db *gorm.DB, logger *zap.Logger) error {
-	if err := db.AutoMigrate(
-		&models.Property{},
-		&models.TextMessage{},
-		&models.ScheduledTask{},
-		&models.Device{},
-	); err != nil {
-		return err
-	}
-
-	// 数据迁移：将旧的 from/to 字段数据迁移到新字段 from_number/to_number
-	// 记录错误而不是忽略，因为如果旧列不存在（全新安装），SQL 会执行失败
-	if err := db.Exec("UPDATE text_messages SET from_number = `from` WHERE (from_number IS NULL OR from_number = '') AND `from` IS NOT NULL").Error; err != nil {
-		logger.Warn("数据迁移 from 字段失败", zap.Error(err))
-	}
-	if err := db.Exec("UPDATE text_messages SET to_number = `to` WHERE (to_number IS NULL OR to_number = '') AND `to` IS NOT NULL").Error; err != nil {
-		logger.Warn("数据迁移 to 字段失败", zap.Error(err))
-	}
-
-	// 数据迁移：从 icc_id 迁移到 iccid
-	var hasIccId int64
-	db.Raw("SELECT COUNT(*) FROM pragma_table_info('devices') WHERE name = 'icc_id'").Scan(&hasIccId)
-	if hasIccId > 0 {
-		if err := db.Exec("UPDATE devices SET iccid = icc_id WHERE (iccid IS NULL OR iccid = '') AND icc_id IS NOT NULL AND icc_id != ''").Error; err != nil {
-			logger.Warn("数据迁移 icc_id 字段失败", zap.Error(err))
-		}
+	if appConfig.JWT.AccessTokenMinutes == 0 {
+		appConfig.JWT.AccessTokenMinutes = 15
 	}
-
-	return nil
 }
 
 // setupApi 设置API路由
-func setupApi(app *orz.App, handlers *Handlers, appConfig *config.AppConfig, logger *zap.Logger) {
+func setupApi(app *orz.App, handlers *Handlers, sessionService *service.SessionService, accessService *service.AccessService, auditService *service.AuditService, logger *zap.Logger) {
 	e := app.GetEcho()
 
 	e.Use(echomiddleware.StaticWithConfig(echomiddleware.StaticConfig{
@@ -231,6 +355,9 @@ func setupApi(app *orz.App, handlers *Handlers, appConfig *config.AppConfig, log
 			if strings.HasPrefix(c.Request().RequestURI, "/health") {
 				return true
 			}
+			if strings.HasPrefix(c.Request().RequestURI, "/metrics") {
+				return true
+			}
 			return false
 		},
 		Index:      "index.html",
@@ -245,64 +372,148 @@ func setupApi(app *orz.App, handlers *Handlers, appConfig *config.AppConfig, log
 	e.GET("/api/auth/config", handlers.Auth.GetAuthConfig)
 	e.GET("/api/auth/oidc/url", handlers.Auth.GetOIDCAuthURL)
 	e.POST("/api/auth/oidc/callback", handlers.Auth.OIDCCallback)
+	e.POST("/api/auth/refresh", handlers.Auth.Refresh)
 
-	// API 路由组（需要认证）
+	// API 路由组（需要认证），每个路由按角色检查权限，非管理员只能访问已授权的设备，修改类请求记录审计日志
 	api := e.Group("/api")
-	api.Use(middleware.JWTMiddleware(appConfig.JWT.Secret, logger))
+	api.Use(middleware.JWTMiddleware(sessionService.Authenticate, logger))
+	api.Use(middleware.DeviceScope(accessService.ScopeContext, logger))
+	api.Use(middleware.Audit(auditService.Record))
+	viewer := middleware.RequireRole(models.RoleViewer)
+	operator := middleware.RequireRole(models.RoleOperator)
+	admin := middleware.RequireRole(models.RoleAdmin)
+	allDevices := middleware.RequireAllDevices()
 
 	// Version
 	api.GET("/version", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, echo.Map{
 			"version": version.GetVersion(),
 		})
-	})
-
-	// Property API
-	api.GET("/properties/:id", handlers.Property.GetProperty)
-	api.PUT("/properties/:id", handlers.Property.SetProperty)
-	api.POST("/notifications/:type/test", handlers.Property.TestNotificationChannel)
+	}, viewer)
+
+	// Account API（当前用户）
+	api.GET("/account", handlers.Auth.GetAccount, viewer)
+	api.PUT("/account/password", handlers.Auth.ChangePassword, viewer)
+	api.GET("/account/sessions", handlers.Auth.ListSessions, viewer)
+	api.DELETE("/account/sessions/:id", handlers.Auth.RevokeSession, viewer)
+	api.POST("/logout", handlers.Auth.Logout, viewer)
+	api.POST("/account/totp/setup", handlers.Auth.SetupTOTP, viewer)
+	api.POST("/account/totp/enable", handlers.Auth.EnableTOTP, viewer)
+	api.POST("/account/totp/disable", handlers.Auth.DisableTOTP, viewer)
+	api.POST("/account/totp/recovery-codes", handlers.Auth.RegenerateRecoveryCodes, viewer)
+
+	// User API
+	api.GET("/users", handlers.User.List, admin)
+	api.POST("/users", handlers.User.Create, admin)
+	api.GET("/users/:id", handlers.User.Get, admin)
+	api.PUT("/users/:id", handlers.User.Update, admin)
+	api.DELETE("/users/:id", handlers.User.Delete, admin)
+	api.POST("/users/:id/password", handlers.User.ResetPassword, admin)
+	api.GET("/users/:id/grants", handlers.User.ListGrants, admin)
+	api.POST("/users/:id/grants", handlers.User.Grant, admin)
+	api.DELETE("/users/:id/grants/:grantId", handlers.User.Revoke, admin)
+	api.GET("/users/:id/sessions", handlers.User.ListSessions, admin)
+	api.DELETE("/users/:id/sessions", handlers.User.RevokeSessions, admin)
+	api.DELETE("/users/:id/totp", handlers.User.ResetTOTP, admin)
+
+	// Audit API
+	api.GET("/audit", handlers.Audit.List, admin)
+	api.GET("/audit/export", handlers.Audit.Export, admin)
+
+	// Property API（系统设置和通知渠道）
+	api.GET("/properties/:id", handlers.Property.GetProperty, admin)
+	api.PUT("/properties/:id", handlers.Property.SetProperty, admin)
+	api.POST("/notifications/:type/test", handlers.Property.TestNotificationChannel, admin)
 
 	// TextMessage API
-	api.GET("/messages/stats", handlers.TextMessage.GetStats)
-	api.GET("/messages/conversations", handlers.TextMessage.GetConversations)
-	api.GET("/messages/conversations/:peer/messages", handlers.TextMessage.GetConversationMessages)
-	api.DELETE("/messages/conversations/:peer", handlers.TextMessage.DeleteConversation)
-	api.DELETE("/messages/:id", handlers.TextMessage.Delete)
-	api.DELETE("/messages", handlers.TextMessage.Clear)
-
-	// Serial API
-	api.POST("/serial/sms", handlers.Serial.SendSMS)
-	api.GET("/serial/status", handlers.Serial.GetStatus) // 包含移动网络信息
-	api.POST("/serial/flymode", handlers.Serial.SetFlymode)
-	api.POST("/serial/reboot", handlers.Serial.RebootMcu)
+	api.GET("/messages/stats", handlers.TextMessage.GetStats, viewer)
+	api.GET("/messages/search", handlers.TextMessage.Search, viewer)
+	api.GET("/messages/export", handlers.TextMessage.Export, viewer)
+	api.POST("/messages/import", handlers.TextMessage.Import, operator)
+	api.GET("/messages/conversations", handlers.TextMessage.GetConversations, viewer)
+	api.GET("/messages/unread", handlers.TextMessage.GetUnreadCounts, viewer)
+	api.GET("/messages/retention/preview", handlers.Retention.Preview, viewer)
+	api.POST("/messages/retention/run", handlers.Retention.Run, admin)
+	api.POST("/messages/read-all", handlers.TextMessage.MarkAllRead, operator)
+	api.GET("/messages/conversations/:peer/messages", handlers.TextMessage.GetConversationMessages, viewer)
+	api.POST("/messages/conversations/:peer/read", handlers.TextMessage.MarkConversationRead, operator)
+	api.PUT("/messages/conversations/:peer/state", handlers.TextMessage.UpdateConversationState, operator)
+	api.DELETE("/messages/conversations/:peer", handlers.TextMessage.DeleteConversation, operator)
+	api.POST("/messages/conversations/:peer/restore", handlers.TextMessage.RestoreConversation, operator)
+	api.GET("/messages/trash", handlers.TextMessage.GetTrash, viewer)
+	api.POST("/messages/trash/restore", handlers.TextMessage.RestoreAll, operator)
+	api.DELETE("/messages/trash", handlers.TextMessage.EmptyTrash, admin)
+	api.DELETE("/messages/:id", handlers.TextMessage.Delete, operator)
+	api.POST("/messages/:id/restore", handlers.TextMessage.Restore, operator)
+	api.POST("/messages/clear-token", handlers.TextMessage.IssueClearToken, admin)
+	api.DELETE("/messages", handlers.TextMessage.Clear, admin)
+
+	// Backup API
+	api.GET("/backups", handlers.Backup.List, admin)
+	api.POST("/backups", handlers.Backup.Create, admin)
+	api.GET("/backups/:name", handlers.Backup.Download, admin)
+	api.POST("/backups/:name/verify", handlers.Backup.Verify, admin)
+	api.DELETE("/backups/:name", handlers.Backup.Delete, admin)
+
+	// Serial API（单设备模式，不区分设备，受设备授权限制的用户不能访问）
+	api.POST("/serial/sms", handlers.Serial.SendSMS, operator, allDevices)
+	api.GET("/serial/status", handlers.Serial.GetStatus, viewer, allDevices) // 包含移动网络信息
+	api.POST("/serial/flymode", handlers.Serial.SetFlymode, operator, allDevices)
+	api.POST("/serial/reboot", handlers.Serial.RebootMcu, operator, allDevices)
 
 	// ScheduledTask API (RESTful)
-	api.GET("/scheduled-tasks", handlers.ScheduledTask.List)
-	api.GET("/scheduled-tasks/:id", handlers.ScheduledTask.Get)
-	api.POST("/scheduled-tasks", handlers.ScheduledTask.Create)
-	api.PUT("/scheduled-tasks/:id", handlers.ScheduledTask.Update)
-	api.DELETE("/scheduled-tasks/:id", handlers.ScheduledTask.Delete)
-	api.POST("/scheduled-tasks/:id/trigger", handlers.ScheduledTask.Trigger)
+	api.GET("/scheduled-tasks", handlers.ScheduledTask.List, viewer)
+	api.GET("/scheduled-tasks/:id", handlers.ScheduledTask.Get, viewer)
+	api.POST("/scheduled-tasks", handlers.ScheduledTask.Create, operator)
+	api.PUT("/scheduled-tasks/:id", handlers.ScheduledTask.Update, operator)
+	api.DELETE("/scheduled-tasks/:id", handlers.ScheduledTask.Delete, operator)
+	api.POST("/scheduled-tasks/:id/trigger", handlers.ScheduledTask.Trigger, operator)
+	api.GET("/scheduled-tasks/:id/runs", handlers.ScheduledTask.Runs, viewer)
+
+	// Contact API
+	api.GET("/contacts", handlers.Contact.List, viewer)
+	api.GET("/contacts/groups", handlers.Contact.Groups, viewer)
+	api.POST("/contacts", handlers.Contact.Create, operator)
+	api.PUT("/contacts/:id", handlers.Contact.Update, operator)
+	api.DELETE("/contacts/:id", handlers.Contact.Delete, operator)
+
+	// KeepAlive API
+	api.GET("/keep-alive", handlers.KeepAlive.Dashboard, viewer)
+	api.GET("/devices/:id/keep-alive", handlers.KeepAlive.Get, viewer)
+	api.PUT("/devices/:id/keep-alive", handlers.KeepAlive.Save, operator)
+	api.DELETE("/devices/:id/keep-alive", handlers.KeepAlive.Delete, operator)
 
 	// Device API
-	api.GET("/devices", handlers.Device.List)
-	api.GET("/devices/discover", handlers.Device.Discover)
-	api.GET("/devices/groups", handlers.Device.GetGroups)
-	api.GET("/devices/stats", handlers.Device.GetStats)
-	api.POST("/devices", handlers.Device.Create)
-	api.GET("/devices/:id", handlers.Device.Get)
-	api.PUT("/devices/:id", handlers.Device.Update)
-	api.DELETE("/devices/:id", handlers.Device.Delete)
-	api.POST("/devices/:id/enable", handlers.Device.Enable)
-	api.POST("/devices/:id/disable", handlers.Device.Disable)
-	api.POST("/devices/:id/flymode", handlers.Device.SetFlymode)
-	api.POST("/devices/:id/reboot", handlers.Device.Reboot)
-	api.GET("/devices/:id/status", handlers.Device.GetStatus)
-	api.POST("/devices/:id/sms", handlers.Device.SendSMS)
+	api.GET("/devices", handlers.Device.List, viewer)
+	api.GET("/devices/discover", handlers.Device.Discover, admin)
+	api.GET("/devices/groups", handlers.Device.GetGroups, viewer)
+	api.GET("/devices/stats", handlers.Device.GetStats, viewer)
+	api.POST("/devices", handlers.Device.Create, admin)
+	api.GET("/devices/:id", handlers.Device.Get, viewer)
+	api.PUT("/devices/:id", handlers.Device.Update, admin)
+	api.DELETE("/devices/:id", handlers.Device.Delete, admin)
+	api.POST("/devices/:id/enable", handlers.Device.Enable, operator)
+	api.POST("/devices/:id/disable", handlers.Device.Disable, operator)
+	api.POST("/devices/:id/flymode", handlers.Device.SetFlymode, operator)
+	api.POST("/devices/:id/reboot", handlers.Device.Reboot, operator)
+	api.GET("/devices/:id/status", handlers.Device.GetStatus, viewer)
+	api.GET("/devices/:id/telemetry", handlers.Telemetry.Get, viewer)
+	api.POST("/devices/:id/sms", handlers.Device.SendSMS, operator)
 
 	// SMS API (enhanced)
-	api.POST("/sms/send", handlers.Device.AutoSendSMS)
-	api.POST("/sms/batch", handlers.Device.BatchSendSMS)
+	api.POST("/sms/send", handlers.Device.AutoSendSMS, operator)
+	api.POST("/sms/batch", handlers.Device.BatchSendSMS, operator)
+	api.GET("/sms/batch/:id", handlers.Device.GetBatchJob, viewer)
+
+	// Campaign API
+	api.GET("/campaigns", handlers.Campaign.List, viewer)
+	api.POST("/campaigns", handlers.Campaign.Create, operator)
+	api.GET("/campaigns/:id", handlers.Campaign.Get, viewer)
+	api.DELETE("/campaigns/:id", handlers.Campaign.Delete, operator)
+	api.GET("/campaigns/:id/recipients", handlers.Campaign.Recipients, viewer)
+	api.POST("/campaigns/:id/pause", handlers.Campaign.Pause, operator)
+	api.POST("/campaigns/:id/resume", handlers.Campaign.Resume, operator)
+	api.POST("/campaigns/:id/cancel", handlers.Campaign.Cancel, operator)
 
 	// 健康检查接口（无需认证）
 	e.GET("/health", func(c echo.Context) error {
@@ -310,4 +521,6 @@ func setupApi(app *orz.App, handlers *Handlers, appConfig *config.AppConfig, log
 			"status": "ok",
 		})
 	})
+	e.GET("/health/live", handlers.Health.Live)
+	e.GET("/health/ready", handlers.Health.Ready)
 }
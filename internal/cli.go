@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/migration"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/go-orz/orz"
+)
+
+// loadCLIApp 为命令行读取配置、初始化日志，withDB 时连接数据库
+func loadCLIApp(configPath string, withDB bool) (*orz.App, *config.AppConfig, error) {
+	app := orz.NewApp()
+	if err := app.LoadConfigFromFile(configPath); err != nil {
+		return nil, nil, fmt.Errorf("读取配置失败: %w", err)
+	}
+	if err := app.EnableLogger(); err != nil {
+		return nil, nil, err
+	}
+
+	var appConfig config.AppConfig
+	if err := app.GetConfig().App.Unmarshal(&appConfig); err != nil {
+		return nil, nil, fmt.Errorf("读取配置失败: %w", err)
+	}
+	if withDB {
+		if err := app.EnableDatabase(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return app, &appConfig, nil
+}
+
+const migrateUsage = `用法: smshub migrate [-config ./config.yaml] <command>
+
+命令:
+  up                 执行所有未执行的迁移
+  down [-steps N]    回滚最近执行的 N 个迁移（默认 1）
+  status             查看迁移状态
+`
+
+// Migrate 执行数据库迁移命令（smshub migrate up/down/status）
+func Migrate(defaultConfigPath string, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), migrateUsage) }
+	configPath := fs.String("config", defaultConfigPath, "配置文件路径")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("缺少迁移命令")
+	}
+	command, rest := fs.Arg(0), fs.Args()[1:]
+
+	app, _, err := loadCLIApp(*configPath, true)
+	if err != nil {
+		return err
+	}
+	migrator := migration.New(app.Logger(), app.GetDatabase())
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		count, err := migrator.Up(ctx)
+		fmt.Printf("已执行 %d 个迁移\n", count)
+		return err
+	case "down":
+		downFs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := downFs.Int("steps", 1, "回滚的迁移数量")
+		if err := downFs.Parse(rest); err != nil {
+			return err
+		}
+		if *steps <= 0 {
+			return errors.New("steps 必须大于 0")
+		}
+		count, err := migrator.Down(ctx, *steps)
+		fmt.Printf("已回滚 %d 个迁移\n", count)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(os.Stdout, statuses)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("未知的迁移命令: %s", command)
+	}
+}
+
+// printMigrationStatus 以表格形式输出迁移状态
+func printMigrationStatus(w io.Writer, statuses []migration.Status) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, status := range statuses {
+		state, appliedAt := "pending", "-"
+		if status.Applied {
+			state = "applied"
+			appliedAt = time.UnixMilli(status.AppliedAt).Format(time.DateTime)
+		}
+		if status.Unknown {
+			state = "unknown"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+	}
+	tw.Flush()
+}
+
+const backupUsage = `用法: smshub backup [-config ./config.yaml] [list]
+
+不带参数时立即备份数据库，list 列出已有备份
+`
+
+// Backup 执行数据库备份命令（smshub backup）
+func Backup(defaultConfigPath string, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), backupUsage) }
+	configPath := fs.String("config", defaultConfigPath, "配置文件路径")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	app, appConfig, err := loadCLIApp(*configPath, true)
+	if err != nil {
+		return err
+	}
+	backupService := service.NewBackupService(app.Logger(), app.GetDatabase(), appConfig.Backup)
+	ctx := context.Background()
+
+	switch fs.Arg(0) {
+	case "":
+		file, err := backupService.Create(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("备份完成: %s (%d 字节)\n", file.Name, file.Size)
+		return nil
+	case "list":
+		files, err := backupService.List(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tSIZE\tCREATED AT")
+		for _, file := range files {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", file.Name, file.Size, time.UnixMilli(file.CreatedAt).Format(time.DateTime))
+		}
+		return tw.Flush()
+	default:
+		fs.Usage()
+		return fmt.Errorf("未知的备份命令: %s", fs.Arg(0))
+	}
+}
+
+const restoreUsage = `用法: smshub restore [-config ./config.yaml] [-verify] <备份名称|文件路径>
+
+恢复前请先停止服务。备份会先解密、解压并校验完整性，通过后替换数据库文件，
+原数据库文件重命名为 <文件名>.before-restore-<时间> 保留。-verify 只校验不恢复。
+`
+
+// Restore 执行数据库恢复命令（smshub restore）
+func Restore(defaultConfigPath string, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), restoreUsage) }
+	configPath := fs.String("config", defaultConfigPath, "配置文件路径")
+	verifyOnly := fs.Bool("verify", false, "只校验备份，不恢复")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("缺少备份名称或文件路径")
+	}
+	source := fs.Arg(0)
+
+	// 恢复时不连接数据库，避免占用将被替换的文件
+	app, appConfig, err := loadCLIApp(*configPath, false)
+	if err != nil {
+		return err
+	}
+	dbConfig := app.GetConfig().Database
+	if dbConfig.Type != orz.DatabaseSqlite {
+		return service.ErrBackupUnsupported
+	}
+	backupService := service.NewBackupService(app.Logger(), nil, appConfig.Backup)
+	ctx := context.Background()
+
+	if *verifyOnly {
+		info, err := backupService.Verify(ctx, source)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("校验通过: 迁移版本 %d，短信 %d 条\n", info.SchemaVersion, info.Messages)
+		return nil
+	}
+
+	info, err := backupService.Restore(ctx, source, sqlitePath(dbConfig))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("恢复完成: 迁移版本 %d，短信 %d 条\n", info.SchemaVersion, info.Messages)
+	if info.Previous != "" {
+		fmt.Printf("原数据库已保存为: %s\n", info.Previous)
+	}
+	return nil
+}
+
+// sqlitePath 从数据库配置中解析 SQLite 文件路径（url 优先，去掉 file: 前缀和查询参数）
+func sqlitePath(cfg orz.DatabaseConfig) string {
+	if cfg.URL == "" {
+		return cfg.Sqlite.Path
+	}
+	path := strings.TrimPrefix(cfg.URL, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
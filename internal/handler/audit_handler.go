@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// AuditHandler 审计日志API处理器
+type AuditHandler struct {
+	logger       *zap.Logger
+	auditService *service.AuditService
+}
+
+// NewAuditHandler 创建审计日志Handler实例
+func NewAuditHandler(logger *zap.Logger, auditService *service.AuditService) *AuditHandler {
+	return &AuditHandler{
+		logger:       logger,
+		auditService: auditService,
+	}
+}
+
+// List 按时间倒序分页查询审计日志
+// GET /api/audit?actor=&action=&target=&ip=&success=&start=&end=&cursor=&limit=
+func (h *AuditHandler) List(c echo.Context) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	page, err := h.auditService.Query(c.Request().Context(), filter, c.QueryParam("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("查询审计日志失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "查询审计日志失败",
+		})
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// Export 按查询条件导出审计日志为 CSV
+// GET /api/audit/export?actor=&action=&target=&ip=&success=&start=&end=
+func (h *AuditHandler) Export(c echo.Context) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	filename := fmt.Sprintf("smshub-audit-%s.csv", time.Now().Format("20060102150405"))
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	resp.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	resp.WriteHeader(http.StatusOK)
+
+	// 响应头已发送，出错时只能记录日志并中断输出
+	if _, err := h.auditService.ExportCSV(c.Request().Context(), filter, resp); err != nil {
+		h.logger.Error("导出审计日志失败", zap.Error(err))
+	}
+	return nil
+}
+
+// parseAuditFilter 解析审计日志查询条件
+func parseAuditFilter(c echo.Context) (repo.AuditLogFilter, error) {
+	filter := repo.AuditLogFilter{
+		Actor:  c.QueryParam("actor"),
+		Action: c.QueryParam("action"),
+		Target: c.QueryParam("target"),
+		IP:     c.QueryParam("ip"),
+	}
+	if v := c.QueryParam("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("success 参数格式错误")
+		}
+		filter.Success = &success
+	}
+	var err error
+	if filter.StartAt, err = parseInt64Query(c, "start"); err != nil {
+		return filter, errors.New("start 参数格式错误")
+	}
+	if filter.EndAt, err = parseInt64Query(c, "end"); err != nil {
+		return filter, errors.New("end 参数格式错误")
+	}
+	return filter, nil
+}
@@ -1,24 +1,40 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/Starktomy/smshub/internal/middleware"
+	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/service"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // AuthHandler 认证处理器
 type AuthHandler struct {
 	logger         *zap.Logger
 	accountService *service.AccountService
+	userService    *service.UserService
+	sessionService *service.SessionService
+	totpService    *service.TOTPService
+	auditService   *service.AuditService
 }
 
 // NewAuthHandler 创建认证处理器
-func NewAuthHandler(logger *zap.Logger, accountService *service.AccountService) *AuthHandler {
+func NewAuthHandler(logger *zap.Logger, accountService *service.AccountService, userService *service.UserService,
+	sessionService *service.SessionService, totpService *service.TOTPService, auditService *service.AuditService) *AuthHandler {
 	return &AuthHandler{
 		logger:         logger,
 		accountService: accountService,
+		userService:    userService,
+		sessionService: sessionService,
+		totpService:    totpService,
+		auditService:   auditService,
 	}
 }
 
@@ -26,13 +42,37 @@ func NewAuthHandler(logger *zap.Logger, accountService *service.AccountService)
 type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
+	Code     string `json:"code"` // 两步验证码或恢复码，启用两步验证的用户需要提供
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Token     string `json:"token"`
-	Username  string `json:"username"`
-	ExpiresAt int64  `json:"expiresAt"`
+	Token            string          `json:"token"`
+	Username         string          `json:"username"`
+	Role             models.UserRole `json:"role"`
+	ExpiresAt        int64           `json:"expiresAt"`
+	RefreshToken     string          `json:"refreshToken"`
+	RefreshExpiresAt int64           `json:"refreshExpiresAt"`
+}
+
+// newLoginResponse 从登录结果生成响应
+func newLoginResponse(resp *service.LoginResponse) LoginResponse {
+	return LoginResponse{
+		Token:            resp.Token,
+		Username:         resp.User.Username,
+		Role:             resp.User.Role,
+		ExpiresAt:        resp.ExpiresAt,
+		RefreshToken:     resp.RefreshToken,
+		RefreshExpiresAt: resp.RefreshExpiresAt,
+	}
+}
+
+// clientInfo 读取客户端 IP 和 User-Agent，记录到会话中
+func clientInfo(c echo.Context) service.ClientInfo {
+	return service.ClientInfo{
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+	}
 }
 
 // Login 处理登录请求
@@ -54,31 +94,204 @@ func (h *AuthHandler) Login(c echo.Context) error {
 
 	// 使用 AccountService 进行登录
 	ctx := c.Request().Context()
-	loginResp, err := h.accountService.Login(ctx, req.Username, req.Password)
+	loginResp, err := h.accountService.Login(ctx, req.Username, req.Password, req.Code, clientInfo(c))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "用户名或密码错误",
+		var locked *service.LoginLockedError
+		switch {
+		case errors.As(err, &locked):
+			c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			h.recordLoginFailure(c, req.Username, http.StatusTooManyRequests, locked.Error())
+			return c.JSON(http.StatusTooManyRequests, map[string]string{
+				"error": locked.Error(),
+			})
+		case errors.Is(err, service.ErrTOTPRequired):
+			// 密码正确，等待客户端提交验证码，不记为失败；不使用 401，避免客户端当作登录失效处理
+			return c.JSON(http.StatusForbidden, map[string]any{
+				"error":        err.Error(),
+				"totpRequired": true,
+			})
+		case errors.Is(err, service.ErrInvalidTOTP):
+			h.recordLoginFailure(c, req.Username, http.StatusBadRequest, err.Error())
+			return c.JSON(http.StatusBadRequest, map[string]any{
+				"error":        err.Error(),
+				"totpRequired": true,
+			})
+		case errors.Is(err, service.ErrInvalidCredentials):
+			h.recordLoginFailure(c, req.Username, http.StatusBadRequest, err.Error())
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "用户名或密码错误",
+			})
+		}
+		h.logger.Error("登录失败", zap.String("username", req.Username), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "登录失败",
 		})
 	}
 
 	// 返回 token 和用户信息
-	return c.JSON(http.StatusOK, LoginResponse{
-		Token:     loginResp.Token,
-		Username:  loginResp.User.Username,
-		ExpiresAt: loginResp.ExpiresAt,
+	return c.JSON(http.StatusOK, newLoginResponse(loginResp))
+}
+
+// recordLoginFailure 记录登录失败的审计日志，登录接口不经过审计中间件
+func (h *AuthHandler) recordLoginFailure(c echo.Context, username string, status int, reason string) {
+	req := c.Request()
+	h.auditService.Record(context.WithoutCancel(req.Context()), &models.AuditLog{
+		Actor:  username,
+		Action: req.Method + " " + c.Path(),
+		Target: username,
+		IP:     c.RealIP(),
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Status: status,
+		Error:  reason,
+	})
+}
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌，刷新令牌同时更换
+// POST /api/auth/refresh
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil || req.RefreshToken == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	loginResp, err := h.accountService.Refresh(c.Request().Context(), req.RefreshToken, clientInfo(c))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("刷新令牌失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "刷新令牌失败",
+		})
+	}
+	return c.JSON(http.StatusOK, newLoginResponse(loginResp))
+}
+
+// Logout 登出，撤销当前会话
+// POST /api/logout
+func (h *AuthHandler) Logout(c echo.Context) error {
+	user := middleware.GetUser(c)
+	logoutURL, err := h.accountService.Logout(c.Request().Context(), user.ID, middleware.GetSessionID(c))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		h.logger.Error("登出失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "登出失败",
+		})
+	}
+	resp := map[string]string{
+		"message": "已登出",
+	}
+	// OIDC 用户需要跳转到 IdP 退出其会话
+	if logoutURL != "" {
+		resp["logoutUrl"] = logoutURL
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// sessionView 会话信息，标记是否为当前会话
+type sessionView struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions 获取当前用户的登录会话
+// GET /api/account/sessions
+func (h *AuthHandler) ListSessions(c echo.Context) error {
+	sessions, err := h.sessionService.List(c.Request().Context(), middleware.GetUser(c).ID)
+	if err != nil {
+		h.logger.Error("获取会话列表失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取会话列表失败",
+		})
+	}
+	current := middleware.GetSessionID(c)
+	views := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, sessionView{Session: session, Current: session.ID == current})
+	}
+	return c.JSON(http.StatusOK, views)
+}
+
+// RevokeSession 撤销当前用户的某个会话，例如在其他设备上退出登录
+// DELETE /api/account/sessions/:id
+func (h *AuthHandler) RevokeSession(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.sessionService.Revoke(c.Request().Context(), middleware.GetUser(c).ID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "会话不存在",
+			})
+		}
+		h.logger.Error("撤销会话失败", zap.String("id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "撤销会话失败",
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "会话已撤销",
 	})
 }
 
 // GetAuthConfig 获取认证配置
 func (h *AuthHandler) GetAuthConfig(c echo.Context) error {
-	config := h.accountService.GetAuthConfig()
+	config := h.accountService.GetAuthConfig(c.Request().Context())
 	return c.JSON(http.StatusOK, config)
 }
 
+// GetAccount 获取当前登录用户
+// GET /api/account
+func (h *AuthHandler) GetAccount(c echo.Context) error {
+	user, err := h.userService.GetByUsername(c.Request().Context(), middleware.GetUsername(c))
+	if err != nil {
+		return userError(c, h.logger, "", "获取当前用户失败", err)
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+// ChangePassword 修改当前用户的密码
+// PUT /api/account/password
+func (h *AuthHandler) ChangePassword(c echo.Context) error {
+	var req ChangePasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	username := middleware.GetUsername(c)
+	if err := h.userService.ChangePassword(c.Request().Context(), username, middleware.GetSessionID(c), req.OldPassword, req.NewPassword); err != nil {
+		return userError(c, h.logger, username, "修改密码失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "密码已修改",
+	})
+}
+
 // GetOIDCAuthURL 获取 OIDC 认证 URL
 func (h *AuthHandler) GetOIDCAuthURL(c echo.Context) error {
-	authURL, err := h.accountService.GetOIDCAuthURL()
+	authURL, err := h.accountService.GetOIDCAuthURL(c.Request().Context())
 	if err != nil {
+		if errors.Is(err, service.ErrOIDCUnavailable) {
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": err.Error(),
 		})
@@ -109,18 +322,117 @@ func (h *AuthHandler) OIDCCallback(c echo.Context) error {
 
 	// 使用 AccountService 处理 OIDC 登录
 	ctx := c.Request().Context()
-	loginResp, err := h.accountService.LoginWithOIDC(ctx, req.Code, req.State)
+	loginResp, err := h.accountService.LoginWithOIDC(ctx, req.Code, req.State, clientInfo(c))
 	if err != nil {
 		h.logger.Error("OIDC 登录失败", zap.Error(err))
+		switch {
+		case errors.Is(err, service.ErrOIDCForbidden):
+			h.recordLoginFailure(c, "", http.StatusForbidden, err.Error())
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error(),
+			})
+		case errors.Is(err, service.ErrOIDCUnavailable):
+			return c.JSON(http.StatusServiceUnavailable, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.recordLoginFailure(c, "", http.StatusUnauthorized, err.Error())
 		return c.JSON(http.StatusUnauthorized, map[string]string{
 			"error": "OIDC 认证失败",
 		})
 	}
 
 	// 返回 token 和用户信息
-	return c.JSON(http.StatusOK, LoginResponse{
-		Token:     loginResp.Token,
-		Username:  loginResp.User.Username,
-		ExpiresAt: loginResp.ExpiresAt,
+	return c.JSON(http.StatusOK, newLoginResponse(loginResp))
+}
+
+// TOTPCodeRequest 两步验证码请求
+type TOTPCodeRequest struct {
+	Code string `json:"code"` // 验证器应用中的 6 位验证码，关闭两步验证和重新生成恢复码时也可以使用恢复码
+}
+
+// DisableTOTPRequest 关闭两步验证请求
+type DisableTOTPRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// SetupTOTP 生成两步验证密钥，提交验证码确认后才会启用
+// POST /api/account/totp/setup
+func (h *AuthHandler) SetupTOTP(c echo.Context) error {
+	setup, err := h.totpService.Setup(c.Request().Context(), middleware.GetUser(c).ID)
+	if err != nil {
+		return totpError(c, h.logger, "生成两步验证密钥失败", err)
+	}
+	return c.JSON(http.StatusOK, setup)
+}
+
+// EnableTOTP 使用验证码确认并启用两步验证，返回只显示一次的恢复码
+// POST /api/account/totp/enable
+func (h *AuthHandler) EnableTOTP(c echo.Context) error {
+	var req TOTPCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+	codes, err := h.totpService.Enable(c.Request().Context(), middleware.GetUser(c).ID, req.Code)
+	if err != nil {
+		return totpError(c, h.logger, "启用两步验证失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"recoveryCodes": codes,
+	})
+}
+
+// DisableTOTP 验证密码和验证码后关闭两步验证
+// POST /api/account/totp/disable
+func (h *AuthHandler) DisableTOTP(c echo.Context) error {
+	var req DisableTOTPRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+	if err := h.totpService.Disable(c.Request().Context(), middleware.GetUser(c).ID, req.Password, req.Code); err != nil {
+		return totpError(c, h.logger, "关闭两步验证失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "两步验证已关闭",
 	})
 }
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部失效
+// POST /api/account/totp/recovery-codes
+func (h *AuthHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	var req TOTPCodeRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+	codes, err := h.totpService.RegenerateRecoveryCodes(c.Request().Context(), middleware.GetUser(c).ID, req.Code)
+	if err != nil {
+		return totpError(c, h.logger, "生成恢复码失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"recoveryCodes": codes,
+	})
+}
+
+// totpError 两步验证相关错误转换为 HTTP 响应
+func totpError(c echo.Context, logger *zap.Logger, msg string, err error) error {
+	switch {
+	case errors.Is(err, service.ErrTOTPRequired), errors.Is(err, service.ErrInvalidTOTP),
+		errors.Is(err, service.ErrTOTPNotSetup), errors.Is(err, service.ErrTOTPNotLocalUser),
+		errors.Is(err, service.ErrWrongPassword):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrTOTPEnabled), errors.Is(err, service.ErrTOTPNotEnabled):
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	return userError(c, logger, "", msg, err)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -8,10 +9,14 @@ import (
 	"testing"
 
 	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
 	"github.com/Starktomy/smshub/internal/service"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 func setupAuthHandler(t *testing.T) *AuthHandler {
@@ -30,8 +35,22 @@ func setupAuthHandler(t *testing.T) *AuthHandler {
 		},
 	}
 
-	accSvc := service.NewAccountService(logger, &service.OIDCService{}, appConfig)
-	return NewAuthHandler(logger, accSvc)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.Session{}, &models.AuditLog{}); err != nil {
+		t.Fatalf("数据库迁移失败: %v", err)
+	}
+	userSvc := service.NewUserService(logger, db)
+	if err := userSvc.Bootstrap(context.Background(), appConfig.Users); err != nil {
+		t.Fatalf("导入用户失败: %v", err)
+	}
+
+	sessionSvc := service.NewSessionService(logger, db, appConfig.JWT)
+	totpSvc := service.NewTOTPService(logger, db, "")
+	accSvc := service.NewAccountService(logger, &service.OIDCService{}, userSvc, sessionSvc, totpSvc, service.NewLoginGuard(logger, appConfig.Login))
+	return NewAuthHandler(logger, accSvc, userSvc, sessionSvc, totpSvc, service.NewAuditService(logger, db))
 }
 
 func TestAuthHandlerLogin(t *testing.T) {
@@ -106,3 +125,43 @@ func TestAuthHandlerGetAuthConfig(t *testing.T) {
 		t.Error("PasswordEnabled should be true")
 	}
 }
+
+func TestAuthHandlerLoginLockout(t *testing.T) {
+	h := setupAuthHandler(t)
+	e := echo.New()
+
+	login := func(password string) *httptest.ResponseRecorder {
+		body := `{"username":"user","password":"` + password + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		if err := h.Login(e.NewContext(req, rec)); err != nil {
+			t.Fatalf("Login handler failed: %v", err)
+		}
+		return rec
+	}
+
+	// 同一用户名连续失败 5 次后锁定，正确的密码也无法登录
+	for i := 0; i < 5; i++ {
+		if rec := login("wrong"); rec.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400, got %d", rec.Code)
+		}
+	}
+	rec := login("secret")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 after lockout, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Response should contain Retry-After")
+	}
+
+	// 失败的登录记录到审计日志
+	success := false
+	page, err := h.auditService.Query(context.Background(), repo.AuditLogFilter{Actor: "user", Success: &success}, "", 0)
+	if err != nil {
+		t.Fatalf("Query audit logs failed: %v", err)
+	}
+	if len(page.Items) != 6 {
+		t.Errorf("Expected 6 failed login audit logs, got %d", len(page.Items))
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Starktomy/smshub/internal/migration"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// BackupHandler 数据库备份API处理器
+type BackupHandler struct {
+	logger  *zap.Logger
+	service *service.BackupService
+}
+
+// NewBackupHandler 创建备份Handler实例
+func NewBackupHandler(logger *zap.Logger, service *service.BackupService) *BackupHandler {
+	return &BackupHandler{
+		logger:  logger,
+		service: service,
+	}
+}
+
+// List 列出所有备份
+// GET /api/backups
+func (h *BackupHandler) List(c echo.Context) error {
+	files, err := h.service.List(c.Request().Context())
+	if err != nil {
+		h.logger.Error("获取备份列表失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取备份列表失败",
+		})
+	}
+	if files == nil {
+		files = []service.BackupFile{}
+	}
+	return c.JSON(http.StatusOK, files)
+}
+
+// Create 立即备份数据库
+// POST /api/backups
+func (h *BackupHandler) Create(c echo.Context) error {
+	file, err := h.service.Create(c.Request().Context())
+	if err != nil {
+		if errors.Is(err, service.ErrBackupUnsupported) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("备份数据库失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "备份失败",
+		})
+	}
+	return c.JSON(http.StatusOK, file)
+}
+
+// Download 下载备份文件
+// GET /api/backups/:name
+func (h *BackupHandler) Download(c echo.Context) error {
+	name := c.Param("name")
+	r, err := h.service.Open(c.Request().Context(), name)
+	if err != nil {
+		return h.fileError(c, err, "下载备份失败")
+	}
+	defer r.Close()
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	resp.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, name))
+	resp.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(resp, r); err != nil {
+		h.logger.Error("下载备份失败", zap.Error(err), zap.String("name", name))
+	}
+	return nil
+}
+
+// Verify 校验备份能否正常恢复（解密、解压并检查数据库完整性）
+// POST /api/backups/:name/verify
+func (h *BackupHandler) Verify(c echo.Context) error {
+	info, err := h.service.Verify(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBackup) || errors.Is(err, service.ErrBackupPassphrase) ||
+			errors.Is(err, service.ErrBackupNotEncrypted) || errors.Is(err, service.ErrBackupPassphraseRequired) ||
+			errors.Is(err, migration.ErrSchemaTooNew) {
+			return c.JSON(http.StatusUnprocessableEntity, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		return h.fileError(c, err, "校验备份失败")
+	}
+	return c.JSON(http.StatusOK, info)
+}
+
+// Delete 删除备份
+// DELETE /api/backups/:name
+func (h *BackupHandler) Delete(c echo.Context) error {
+	if err := h.service.Delete(c.Request().Context(), c.Param("name")); err != nil {
+		return h.fileError(c, err, "删除备份失败")
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "删除成功",
+	})
+}
+
+// fileError 处理备份文件相关的通用错误
+func (h *BackupHandler) fileError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidBackupName):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrBackupNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	h.logger.Error(message, zap.Error(err), zap.String("name", c.Param("name")))
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": message,
+	})
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+func TestBackupHandlerInvalidName(t *testing.T) {
+	e := echo.New()
+	h := NewBackupHandler(zap.NewNop(), service.NewBackupService(zap.NewNop(), nil, config.BackupConfig{Dir: t.TempDir()}))
+
+	for _, tt := range []struct {
+		name    string
+		handler echo.HandlerFunc
+		param   string
+		want    int
+	}{
+		{"下载非法文件名", h.Download, "../config.yaml", http.StatusBadRequest},
+		{"删除非法文件名", h.Delete, "app.db", http.StatusBadRequest},
+		{"下载不存在的备份", h.Download, "smshub-20200101-000000.db", http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/backups/x", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("name")
+			c.SetParamValues(tt.param)
+
+			if err := tt.handler(c); err != nil {
+				t.Fatalf("不应返回 Echo 错误: %v", err)
+			}
+			if rec.Code != tt.want {
+				t.Errorf("期望 %d，实际 %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestBackupHandlerCreateUnsupported(t *testing.T) {
+	e := echo.New()
+	h := NewBackupHandler(zap.NewNop(), service.NewBackupService(zap.NewNop(), nil, config.BackupConfig{Dir: t.TempDir()}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/backups", nil)
+	rec := httptest.NewRecorder()
+	if err := h.Create(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("Create 不应返回 Echo 错误: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("未连接 SQLite 时应返回 400，实际 %d", rec.Code)
+	}
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CampaignHandler 定时群发API处理器
+type CampaignHandler struct {
+	logger          *zap.Logger
+	campaignService *service.CampaignService
+}
+
+// NewCampaignHandler 创建群发Handler实例
+func NewCampaignHandler(logger *zap.Logger, campaignService *service.CampaignService) *CampaignHandler {
+	return &CampaignHandler{
+		logger:          logger,
+		campaignService: campaignService,
+	}
+}
+
+// createCampaignRequest 创建群发活动请求，收件人可以直接列出或以 CSV 文本提供
+type createCampaignRequest struct {
+	models.Campaign
+	Recipients []service.CampaignRecipientInput `json:"recipients"`
+	CSV        string                           `json:"csv"`
+}
+
+// List 获取群发活动列表
+// GET /api/campaigns
+func (h *CampaignHandler) List(c echo.Context) error {
+	campaigns, err := h.campaignService.List(c.Request().Context())
+	if err != nil {
+		h.logger.Error("获取群发活动列表失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取群发活动列表失败",
+		})
+	}
+	if campaigns == nil {
+		campaigns = []models.Campaign{}
+	}
+	return c.JSON(http.StatusOK, campaigns)
+}
+
+// Get 获取群发活动详情和进度
+// GET /api/campaigns/:id
+func (h *CampaignHandler) Get(c echo.Context) error {
+	id := c.Param("id")
+	campaign, err := h.campaignService.GetById(c.Request().Context(), id)
+	if err != nil {
+		return h.fail(c, id, "获取群发活动失败", err)
+	}
+	return c.JSON(http.StatusOK, campaign)
+}
+
+// Create 创建群发活动
+// POST /api/campaigns
+// 支持 JSON 请求体，或 multipart 表单：campaign 字段为活动 JSON，file 为收件人 CSV
+func (h *CampaignHandler) Create(c echo.Context) error {
+	var req createCampaignRequest
+	if file, err := c.FormFile("file"); err == nil {
+		if err := json.Unmarshal([]byte(c.FormValue("campaign")), &req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "campaign 字段格式错误",
+			})
+		}
+		src, err := file.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "读取上传文件失败",
+			})
+		}
+		defer src.Close()
+		inputs, err := service.ParseCampaignCSV(src)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		req.Recipients = append(req.Recipients, inputs...)
+	} else if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	if req.CSV != "" {
+		inputs, err := service.ParseCampaignCSV(strings.NewReader(req.CSV))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		req.Recipients = append(req.Recipients, inputs...)
+	}
+
+	campaign := req.Campaign
+	if err := h.campaignService.Create(c.Request().Context(), &campaign, req.Recipients); err != nil {
+		return h.fail(c, "", "创建群发活动失败", err)
+	}
+	return c.JSON(http.StatusCreated, campaign)
+}
+
+// Recipients 按发送顺序分页获取活动收件人
+// GET /api/campaigns/:id/recipients?status=&cursor=&limit=
+func (h *CampaignHandler) Recipients(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	limit, err := parseInt64Query(c, "limit")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "limit 参数格式错误",
+		})
+	}
+	if _, err := h.campaignService.GetById(ctx, id); err != nil {
+		return h.fail(c, id, "获取群发活动失败", err)
+	}
+
+	status := models.CampaignRecipientStatus(c.QueryParam("status"))
+	page, err := h.campaignService.GetRecipients(ctx, id, status, c.QueryParam("cursor"), int(limit))
+	if err != nil {
+		return h.fail(c, id, "获取收件人失败", err)
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// Pause 暂停群发活动
+// POST /api/campaigns/:id/pause
+func (h *CampaignHandler) Pause(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.campaignService.Pause(c.Request().Context(), id); err != nil {
+		return h.fail(c, id, "暂停群发活动失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "群发活动已暂停",
+	})
+}
+
+// Resume 恢复群发活动
+// POST /api/campaigns/:id/resume
+func (h *CampaignHandler) Resume(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.campaignService.Resume(c.Request().Context(), id); err != nil {
+		return h.fail(c, id, "恢复群发活动失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "群发活动已恢复",
+	})
+}
+
+// Cancel 取消群发活动
+// POST /api/campaigns/:id/cancel
+func (h *CampaignHandler) Cancel(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.campaignService.Cancel(c.Request().Context(), id); err != nil {
+		return h.fail(c, id, "取消群发活动失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "群发活动已取消",
+	})
+}
+
+// Delete 删除群发活动
+// DELETE /api/campaigns/:id
+func (h *CampaignHandler) Delete(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.campaignService.Delete(c.Request().Context(), id); err != nil {
+		return h.fail(c, id, "删除群发活动失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "群发活动已删除",
+	})
+}
+
+// fail 将服务错误转换为响应，未知错误记录日志并返回 500
+func (h *CampaignHandler) fail(c echo.Context, id, msg string, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "群发活动不存在",
+		})
+	case errors.Is(err, service.ErrInvalidCampaign), errors.Is(err, service.ErrInvalidCursor):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrDeviceForbidden):
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrCampaignState):
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	h.logger.Error(msg, zap.String("id", id), zap.Error(err))
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": msg,
+	})
+}
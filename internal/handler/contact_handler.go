@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// ContactHandler 联系人API处理器
+type ContactHandler struct {
+	logger         *zap.Logger
+	contactService *service.ContactService
+}
+
+// NewContactHandler 创建联系人Handler实例
+func NewContactHandler(logger *zap.Logger, contactService *service.ContactService) *ContactHandler {
+	return &ContactHandler{
+		logger:         logger,
+		contactService: contactService,
+	}
+}
+
+// List 获取联系人列表
+// GET /api/contacts?group=
+func (h *ContactHandler) List(c echo.Context) error {
+	contacts, err := h.contactService.List(c.Request().Context(), c.QueryParam("group"))
+	if err != nil {
+		h.logger.Error("获取联系人列表失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取联系人列表失败",
+		})
+	}
+	if contacts == nil {
+		contacts = []models.Contact{}
+	}
+	return c.JSON(http.StatusOK, contacts)
+}
+
+// Groups 获取联系人分组
+// GET /api/contacts/groups
+func (h *ContactHandler) Groups(c echo.Context) error {
+	groups, err := h.contactService.Groups(c.Request().Context())
+	if err != nil {
+		h.logger.Error("获取联系人分组失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取联系人分组失败",
+		})
+	}
+	if groups == nil {
+		groups = []string{}
+	}
+	return c.JSON(http.StatusOK, groups)
+}
+
+// Create 创建联系人
+// POST /api/contacts
+func (h *ContactHandler) Create(c echo.Context) error {
+	var contact models.Contact
+	if err := c.Bind(&contact); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+	if msg := validateContact(&contact); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": msg,
+		})
+	}
+
+	if err := h.contactService.Create(c.Request().Context(), &contact); err != nil {
+		h.logger.Error("创建联系人失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "创建联系人失败",
+		})
+	}
+	return c.JSON(http.StatusCreated, contact)
+}
+
+// Update 更新联系人
+// PUT /api/contacts/:id
+func (h *ContactHandler) Update(c echo.Context) error {
+	var contact models.Contact
+	if err := c.Bind(&contact); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+	if msg := validateContact(&contact); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": msg,
+		})
+	}
+	contact.ID = c.Param("id")
+
+	if err := h.contactService.Update(c.Request().Context(), &contact); err != nil {
+		h.logger.Error("更新联系人失败", zap.String("id", contact.ID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "更新联系人失败",
+		})
+	}
+	return c.JSON(http.StatusOK, contact)
+}
+
+// Delete 删除联系人
+// DELETE /api/contacts/:id
+func (h *ContactHandler) Delete(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.contactService.Delete(c.Request().Context(), id); err != nil {
+		h.logger.Error("删除联系人失败", zap.String("id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "删除联系人失败",
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "联系人已删除",
+	})
+}
+
+// validateContact 验证联系人字段，返回错误信息
+func validateContact(contact *models.Contact) string {
+	contact.Name = strings.TrimSpace(contact.Name)
+	contact.PhoneNumber = strings.TrimSpace(contact.PhoneNumber)
+	contact.GroupName = strings.TrimSpace(contact.GroupName)
+	if contact.PhoneNumber == "" {
+		return "手机号不能为空"
+	}
+	return ""
+}
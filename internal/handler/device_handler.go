@@ -1,25 +1,30 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/service"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // DeviceHandler 设备管理API处理器
 type DeviceHandler struct {
-	logger        *zap.Logger
-	deviceManager *service.DeviceManager
+	logger          *zap.Logger
+	deviceManager   *service.DeviceManager
+	batchJobService *service.BatchJobService
 }
 
 // NewDeviceHandler 创建设备Handler实例
-func NewDeviceHandler(logger *zap.Logger, deviceManager *service.DeviceManager) *DeviceHandler {
+func NewDeviceHandler(logger *zap.Logger, deviceManager *service.DeviceManager, batchJobService *service.BatchJobService) *DeviceHandler {
 	return &DeviceHandler{
-		logger:        logger,
-		deviceManager: deviceManager,
+		logger:          logger,
+		deviceManager:   deviceManager,
+		batchJobService: batchJobService,
 	}
 }
 
@@ -152,10 +157,7 @@ func (h *DeviceHandler) Delete(c echo.Context) error {
 func (h *DeviceHandler) Enable(c echo.Context) error {
 	id := c.Param("id")
 	if err := h.deviceManager.EnableDevice(c.Request().Context(), id); err != nil {
-		h.logger.Error("启用设备失败", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "启用设备失败",
-		})
+		return h.fail(c, "启用设备失败", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -168,10 +170,7 @@ func (h *DeviceHandler) Enable(c echo.Context) error {
 func (h *DeviceHandler) Disable(c echo.Context) error {
 	id := c.Param("id")
 	if err := h.deviceManager.DisableDevice(c.Request().Context(), id); err != nil {
-		h.logger.Error("禁用设备失败", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "禁用设备失败",
-		})
+		return h.fail(c, "禁用设备失败", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -196,10 +195,7 @@ func (h *DeviceHandler) SetFlymode(c echo.Context) error {
 	}
 
 	if err := h.deviceManager.SetDeviceFlymode(c.Request().Context(), id, req.Enabled); err != nil {
-		h.logger.Error("设置飞行模式失败", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "设置飞行模式失败",
-		})
+		return h.fail(c, "设置飞行模式失败", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -212,10 +208,7 @@ func (h *DeviceHandler) SetFlymode(c echo.Context) error {
 func (h *DeviceHandler) Reboot(c echo.Context) error {
 	id := c.Param("id")
 	if err := h.deviceManager.RebootDevice(c.Request().Context(), id); err != nil {
-		h.logger.Error("重启设备失败", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "重启设备失败",
-		})
+		return h.fail(c, "重启设备失败", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -229,9 +222,7 @@ func (h *DeviceHandler) GetStatus(c echo.Context) error {
 	id := c.Param("id")
 	status, err := h.deviceManager.GetDeviceStatus(c.Request().Context(), id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "获取设备状态失败",
-		})
+		return h.fail(c, "获取设备状态失败", err)
 	}
 
 	return c.JSON(http.StatusOK, status)
@@ -292,12 +283,9 @@ func (h *DeviceHandler) SendSMS(c echo.Context) error {
 		})
 	}
 
-	msgID, err := h.deviceManager.SendSMSByDevice(id, req.To, req.Content)
+	msgID, err := h.deviceManager.SendSMSByDevice(c.Request().Context(), id, req.To, req.Content)
 	if err != nil {
-		h.logger.Error("发送短信失败", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "发送短信失败",
-		})
+		return h.fail(c, "发送短信失败", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -333,12 +321,9 @@ func (h *DeviceHandler) AutoSendSMS(c echo.Context) error {
 		req.Strategy = service.StrategyAuto
 	}
 
-	msgID, deviceID, err := h.deviceManager.SendSMS(req.To, req.Content, req.Strategy)
+	msgID, deviceID, err := h.deviceManager.SendSMS(c.Request().Context(), req.To, req.Content, req.Strategy)
 	if err != nil {
-		h.logger.Error("发送短信失败", zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "发送短信失败",
-		})
+		return h.fail(c, "发送短信失败", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{
@@ -348,10 +333,16 @@ func (h *DeviceHandler) AutoSendSMS(c echo.Context) error {
 	})
 }
 
-// BatchSendSMS 批量发送短信
+// batchSendRequest 批量发送请求，可指定完成后的回调地址
+type batchSendRequest struct {
+	service.BatchSendRequest
+	CallbackURL string `json:"callbackUrl"`
+}
+
+// BatchSendSMS 提交批量发送任务，立即返回任务ID，在后台发送
 // POST /api/sms/batch
 func (h *DeviceHandler) BatchSendSMS(c echo.Context) error {
-	var req service.BatchSendRequest
+	var req batchSendRequest
 	if err := c.Bind(&req); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "请求参数错误",
@@ -363,16 +354,45 @@ func (h *DeviceHandler) BatchSendSMS(c echo.Context) error {
 			"error": "收件人列表和内容不能为空",
 		})
 	}
+	if req.CallbackURL != "" {
+		u, err := url.Parse(req.CallbackURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "回调地址无效",
+			})
+		}
+	}
 
 	if req.Strategy == "" {
 		req.Strategy = service.StrategyAuto
 	}
 
-	results := h.deviceManager.BatchSendSMS(&req)
+	job, err := h.batchJobService.Submit(c.Request().Context(), &req.BatchSendRequest, req.CallbackURL)
+	if err != nil {
+		return h.fail(c, "创建批量发送任务失败", err)
+	}
 
-	return c.JSON(http.StatusOK, map[string]any{
-		"results": results,
-	})
+	return c.JSON(http.StatusAccepted, job)
+}
+
+// GetBatchJob 获取批量发送任务状态和各收件人结果
+// GET /api/sms/batch/:id
+func (h *DeviceHandler) GetBatchJob(c echo.Context) error {
+	id := c.Param("id")
+	job, err := h.batchJobService.Get(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "批量发送任务不存在",
+			})
+		}
+		h.logger.Error("获取批量发送任务失败", zap.String("id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取批量发送任务失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, job)
 }
 
 // GetStats 获取设备统计信息
@@ -388,3 +408,16 @@ func (h *DeviceHandler) GetStats(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, stats)
 }
+
+// fail 无权访问设备时返回 403，其他错误记录日志并返回 500
+func (h *DeviceHandler) fail(c echo.Context, msg string, err error) error {
+	if errors.Is(err, service.ErrDeviceForbidden) {
+		return c.JSON(http.StatusForbidden, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	h.logger.Error(msg, zap.Error(err))
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": msg,
+	})
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// HealthHandler 存活和就绪检查处理器
+type HealthHandler struct {
+	logger  *zap.Logger
+	service *service.HealthService
+}
+
+// NewHealthHandler 创建健康检查Handler实例
+func NewHealthHandler(logger *zap.Logger, service *service.HealthService) *HealthHandler {
+	return &HealthHandler{
+		logger:  logger,
+		service: service,
+	}
+}
+
+// Live 存活检查，进程能处理请求即返回 200
+// GET /health/live
+func (h *HealthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{
+		"status": string(service.HealthStatusUp),
+	})
+}
+
+// Ready 就绪检查，返回每个检查项的结果，未就绪时返回 503
+// GET /health/ready
+func (h *HealthHandler) Ready(c echo.Context) error {
+	report := h.service.Ready(c.Request().Context())
+	if report.Status != service.HealthStatusUp {
+		h.logger.Warn("就绪检查未通过", zap.Any("components", report.Components))
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
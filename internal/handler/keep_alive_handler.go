@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// KeepAliveHandler SIM 卡保号API处理器
+type KeepAliveHandler struct {
+	logger           *zap.Logger
+	keepAliveService *service.KeepAliveService
+}
+
+// NewKeepAliveHandler 创建保号Handler实例
+func NewKeepAliveHandler(logger *zap.Logger, keepAliveService *service.KeepAliveService) *KeepAliveHandler {
+	return &KeepAliveHandler{
+		logger:           logger,
+		keepAliveService: keepAliveService,
+	}
+}
+
+// Dashboard 获取所有 SIM 卡的保号状态
+// GET /api/keep-alive
+func (h *KeepAliveHandler) Dashboard(c echo.Context) error {
+	items, err := h.keepAliveService.Dashboard(c.Request().Context())
+	if err != nil {
+		h.logger.Error("获取保号状态失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取保号状态失败",
+		})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+// Get 获取设备的保号策略
+// GET /api/devices/:id/keep-alive
+func (h *KeepAliveHandler) Get(c echo.Context) error {
+	id := c.Param("id")
+	policy, err := h.keepAliveService.Get(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "未配置保号策略",
+			})
+		}
+		h.logger.Error("获取保号策略失败", zap.String("deviceId", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取保号策略失败",
+		})
+	}
+	return c.JSON(http.StatusOK, policy)
+}
+
+// Save 保存设备的保号策略，自动生成或更新对应的定时任务
+// PUT /api/devices/:id/keep-alive
+func (h *KeepAliveHandler) Save(c echo.Context) error {
+	var policy models.KeepAlivePolicy
+	if err := c.Bind(&policy); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+	policy.DeviceID = c.Param("id")
+	if msg := validateKeepAlive(&policy); msg != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": msg,
+		})
+	}
+
+	if err := h.keepAliveService.Save(c.Request().Context(), &policy); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "设备不存在",
+			})
+		case errors.Is(err, service.ErrInvalidSchedule):
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("保存保号策略失败", zap.String("deviceId", policy.DeviceID), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "保存保号策略失败",
+		})
+	}
+	return c.JSON(http.StatusOK, policy)
+}
+
+// Delete 删除设备的保号策略和对应的定时任务
+// DELETE /api/devices/:id/keep-alive
+func (h *KeepAliveHandler) Delete(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.keepAliveService.Delete(c.Request().Context(), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "未配置保号策略",
+			})
+		}
+		h.logger.Error("删除保号策略失败", zap.String("deviceId", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "删除保号策略失败",
+		})
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "保号策略已删除",
+	})
+}
+
+// validateKeepAlive 验证保号策略字段，返回错误信息
+func validateKeepAlive(policy *models.KeepAlivePolicy) string {
+	policy.PhoneNumber = strings.TrimSpace(policy.PhoneNumber)
+	policy.UssdCode = strings.TrimSpace(policy.UssdCode)
+	if policy.Activity == "" {
+		policy.Activity = models.TaskActionSMS
+	}
+	if policy.IntervalDays <= 0 {
+		return "保号间隔天数必须大于0"
+	}
+	if policy.LeadDays < 0 || policy.LeadDays >= policy.IntervalDays {
+		return "提前天数必须大于等于0且小于保号间隔天数"
+	}
+	switch policy.Activity {
+	case models.TaskActionSMS:
+		if policy.PhoneNumber == "" {
+			return "目标手机号不能为空"
+		}
+		if strings.TrimSpace(policy.Content) == "" {
+			return "短信内容不能为空"
+		}
+	case models.TaskActionUSSD:
+		if policy.UssdCode == "" {
+			return "USSD 代码不能为空"
+		}
+	default:
+		return "保号动作只能是 sms 或 ussd"
+	}
+	return ""
+}
@@ -28,6 +28,11 @@ func NewPropertyHandler(logger *zap.Logger, service *service.PropertyService, no
 // GetProperty 获取属性（返回 JSON 值）
 func (h *PropertyHandler) GetProperty(c echo.Context) error {
 	id := c.Param("id")
+	if service.IsInternalProperty(id) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "属性不存在",
+		})
+	}
 
 	property, err := h.service.Get(c.Request().Context(), id)
 	if err != nil {
@@ -58,6 +63,11 @@ func (h *PropertyHandler) GetProperty(c echo.Context) error {
 // SetProperty 设置属性
 func (h *PropertyHandler) SetProperty(c echo.Context) error {
 	id := c.Param("id")
+	if service.IsInternalProperty(id) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "属性不存在",
+		})
+	}
 
 	var req struct {
 		Name  string      `json:"name"`
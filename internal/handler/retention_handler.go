@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// RetentionHandler 短信清理API处理器
+type RetentionHandler struct {
+	logger  *zap.Logger
+	service *service.RetentionService
+}
+
+// NewRetentionHandler 创建短信清理Handler实例
+func NewRetentionHandler(logger *zap.Logger, service *service.RetentionService) *RetentionHandler {
+	return &RetentionHandler{
+		logger:  logger,
+		service: service,
+	}
+}
+
+// Preview 按当前保留策略预览清理结果（dry-run，不删除数据）
+// GET /api/messages/retention/preview
+func (h *RetentionHandler) Preview(c echo.Context) error {
+	report, err := h.service.Preview(c.Request().Context())
+	if err != nil {
+		h.logger.Error("预览短信清理失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "预览清理失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// Run 立即按当前保留策略执行清理
+// POST /api/messages/retention/run
+func (h *RetentionHandler) Run(c echo.Context) error {
+	report, err := h.service.Run(c.Request().Context())
+	if err != nil {
+		h.logger.Error("执行短信清理失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "执行清理失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/service"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type ScheduledTaskHandler struct {
@@ -78,6 +81,11 @@ func (h *ScheduledTaskHandler) Create(c echo.Context) error {
 
 	// 创建任务
 	if err := h.schedulerService.Create(ctx, &task); err != nil {
+		if errors.Is(err, service.ErrDeviceForbidden) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		h.logger.Error("创建定时任务失败", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "创建任务失败",
@@ -114,6 +122,16 @@ func (h *ScheduledTaskHandler) Update(c echo.Context) error {
 
 	// 更新任务
 	if err := h.schedulerService.Update(ctx, &task); err != nil {
+		if errors.Is(err, service.ErrDeviceForbidden) {
+			return c.JSON(http.StatusForbidden, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "任务不存在",
+			})
+		}
 		h.logger.Error("更新定时任务失败", zap.String("id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "更新任务失败",
@@ -131,6 +149,11 @@ func (h *ScheduledTaskHandler) Delete(c echo.Context) error {
 	id := c.Param("id")
 
 	if err := h.schedulerService.Delete(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "任务不存在",
+			})
+		}
 		h.logger.Error("删除定时任务失败", zap.String("id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "删除任务失败",
@@ -150,6 +173,11 @@ func (h *ScheduledTaskHandler) Trigger(c echo.Context) error {
 	id := c.Param("id")
 
 	if err := h.schedulerService.TriggerTask(ctx, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "任务不存在",
+			})
+		}
 		h.logger.Error("触发定时任务失败", zap.String("id", id), zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "触发任务失败",
@@ -163,19 +191,78 @@ func (h *ScheduledTaskHandler) Trigger(c echo.Context) error {
 	})
 }
 
+// Runs 获取定时任务的执行记录
+// GET /api/scheduled-tasks/:id/runs?cursor=&limit=
+func (h *ScheduledTaskHandler) Runs(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.Param("id")
+
+	limit, err := parseInt64Query(c, "limit")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "limit 参数格式错误",
+		})
+	}
+
+	if _, err := h.schedulerService.GetById(ctx, id); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "任务不存在",
+		})
+	}
+
+	page, err := h.schedulerService.GetRuns(ctx, id, c.QueryParam("cursor"), int(limit))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("获取定时任务执行记录失败", zap.String("id", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取执行记录失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
 // validateTask 验证任务字段
 func (h *ScheduledTaskHandler) validateTask(task *models.ScheduledTask) error {
 	if task.Name == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, "任务名称不能为空")
 	}
-	if task.IntervalDays <= 0 {
-		return echo.NewHTTPError(http.StatusBadRequest, "执行间隔天数必须大于0")
+	if err := service.NormalizeSchedule(task); err != nil {
+		return err
+	}
+	if task.DeviceID == service.TaskDeviceAuto {
+		task.DeviceID = ""
+	}
+	if task.FailureAlertThreshold < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "告警阈值不能小于0")
 	}
-	if task.PhoneNumber == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "目标手机号不能为空")
+	if task.ActionType == "" {
+		task.ActionType = models.TaskActionSMS
 	}
-	if task.Content == "" {
-		return echo.NewHTTPError(http.StatusBadRequest, "短信内容不能为空")
+	switch task.ActionType {
+	case models.TaskActionSMS:
+		if task.PhoneNumber == "" && len(task.Recipients) == 0 && task.ContactGroup == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "目标手机号不能为空")
+		}
+		if task.Content == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "短信内容不能为空")
+		}
+	case models.TaskActionFlymode, models.TaskActionReboot:
+	case models.TaskActionUSSD:
+		if task.UssdCode == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "USSD 代码不能为空")
+		}
+	case models.TaskActionWebhook:
+		u, err := url.Parse(task.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "Webhook 地址无效")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "不支持的动作类型")
 	}
 	return nil
 }
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// TelemetryHandler 设备遥测API处理器
+type TelemetryHandler struct {
+	logger           *zap.Logger
+	telemetryService *service.TelemetryService
+}
+
+// NewTelemetryHandler 创建设备遥测Handler实例
+func NewTelemetryHandler(logger *zap.Logger, telemetryService *service.TelemetryService) *TelemetryHandler {
+	return &TelemetryHandler{
+		logger:           logger,
+		telemetryService: telemetryService,
+	}
+}
+
+// Get 查询设备遥测数据
+// GET /api/devices/:id/telemetry?metric=rssi,rsrp&from=&to=&resolution=
+func (h *TelemetryHandler) Get(c echo.Context) error {
+	id := c.Param("id")
+	from, err := parseInt64Query(c, "from")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "from 参数错误",
+		})
+	}
+	to, err := parseInt64Query(c, "to")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "to 参数错误",
+		})
+	}
+
+	series, err := h.telemetryService.Query(c.Request().Context(), id, c.QueryParam("metric"), c.QueryParam("resolution"), from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "设备不存在",
+			})
+		case errors.Is(err, service.ErrInvalidTelemetryQuery):
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("查询设备遥测数据失败", zap.String("deviceId", id), zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "查询设备遥测数据失败",
+		})
+	}
+	return c.JSON(http.StatusOK, series)
+}
@@ -1,14 +1,22 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/repo"
 	"github.com/Starktomy/smshub/internal/service"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // TextMessageHandler 短信API处理器
@@ -49,10 +57,25 @@ func (h *TextMessageHandler) Delete(c echo.Context) error {
 	})
 }
 
-// Clear 清空所有短信
-// DELETE /api/messages
+// IssueClearToken 获取清空短信的确认令牌
+// POST /api/messages/clear-token
+func (h *TextMessageHandler) IssueClearToken(c echo.Context) error {
+	token, expiresAt := h.service.IssueClearToken()
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":     token,
+		"expiresAt": expiresAt,
+	})
+}
+
+// Clear 清空所有短信（移入回收站），需要携带确认令牌
+// DELETE /api/messages?confirm=
 func (h *TextMessageHandler) Clear(c echo.Context) error {
-	if err := h.service.Clear(c.Request().Context()); err != nil {
+	if err := h.service.Clear(c.Request().Context(), c.QueryParam("confirm")); err != nil {
+		if errors.Is(err, service.ErrInvalidConfirmToken) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		h.logger.Error("清空短信失败", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "清空失败",
@@ -79,10 +102,43 @@ func (h *TextMessageHandler) GetStats(c echo.Context) error {
 }
 
 // GetConversations 获取会话列表
-// GET /api/messages/conversations
+// GET /api/messages/conversations?archived=&pinned=&cursor=&limit=
 func (h *TextMessageHandler) GetConversations(c echo.Context) error {
-	conversations, err := h.service.GetConversations(c.Request().Context())
+	limit, err := parseInt64Query(c, "limit")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "limit 参数格式错误",
+		})
+	}
+	archived, err := parseBoolQuery(c, "archived")
 	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "archived 参数格式错误",
+		})
+	}
+	pinned, err := parseBoolQuery(c, "pinned")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "pinned 参数格式错误",
+		})
+	}
+
+	query := service.ConversationQuery{
+		Pinned: pinned,
+		Cursor: c.QueryParam("cursor"),
+		Limit:  int(limit),
+	}
+	if archived != nil {
+		query.Archived = *archived
+	}
+
+	conversations, err := h.service.GetConversations(c.Request().Context(), query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		h.logger.Error("获取会话列表失败", zap.Error(err))
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "获取会话列表失败",
@@ -92,8 +148,8 @@ func (h *TextMessageHandler) GetConversations(c echo.Context) error {
 	return c.JSON(http.StatusOK, conversations)
 }
 
-// GetConversationMessages 获取指定会话的所有消息
-// GET /api/messages/conversations/:peer/messages
+// GetConversationMessages 获取指定会话的消息（游标向更早的消息翻页）
+// GET /api/messages/conversations/:peer/messages?cursor=&limit=
 func (h *TextMessageHandler) GetConversationMessages(c echo.Context) error {
 	peer := c.Param("peer")
 	if peer == "" {
@@ -102,20 +158,25 @@ func (h *TextMessageHandler) GetConversationMessages(c echo.Context) error {
 		})
 	}
 
-	// 手动 URL 解码以处理特殊字符（如 + 号）
-	decodedPeer, err := url.QueryUnescape(peer)
+	limit, err := parseInt64Query(c, "limit")
 	if err != nil {
-		h.logger.Error("URL 解码失败", zap.Error(err), zap.String("peer", peer))
-		// 如果解码失败，使用原始值
-		decodedPeer = peer
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "limit 参数格式错误",
+		})
 	}
 
+	decodedPeer := decodePeerParam(c)
 	h.logger.Debug("获取会话消息",
 		zap.String("peer_raw", peer),
 		zap.String("peer_decoded", decodedPeer))
 
-	messages, err := h.service.GetConversationMessages(c.Request().Context(), decodedPeer)
+	messages, err := h.service.GetConversationMessages(c.Request().Context(), decodedPeer, c.QueryParam("cursor"), int(limit))
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
 		h.logger.Error("获取会话消息失败", zap.Error(err), zap.String("peer", decodedPeer))
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "获取会话消息失败",
@@ -135,14 +196,7 @@ func (h *TextMessageHandler) DeleteConversation(c echo.Context) error {
 		})
 	}
 
-	// 手动 URL 解码以处理特殊字符（如 + 号）
-	decodedPeer, err := url.QueryUnescape(peer)
-	if err != nil {
-		h.logger.Error("URL 解码失败", zap.Error(err), zap.String("peer", peer))
-		// 如果解码失败，使用原始值
-		decodedPeer = peer
-	}
-
+	decodedPeer := decodePeerParam(c)
 	h.logger.Debug("删除会话",
 		zap.String("peer_raw", peer),
 		zap.String("peer_decoded", decodedPeer))
@@ -158,3 +212,367 @@ func (h *TextMessageHandler) DeleteConversation(c echo.Context) error {
 		"message": "删除成功",
 	})
 }
+
+// Search 全文搜索短信
+// GET /api/messages/search?q=&deviceId=&peer=&type=&status=&start=&end=&hasOtp=&cursor=&limit=
+func (h *TextMessageHandler) Search(c echo.Context) error {
+	req := &service.SearchRequest{
+		Keyword:  c.QueryParam("q"),
+		DeviceID: c.QueryParam("deviceId"),
+		Peer:     c.QueryParam("peer"),
+		Type:     models.MessageType(c.QueryParam("type")),
+		Status:   models.MessageStatus(c.QueryParam("status")),
+		Cursor:   c.QueryParam("cursor"),
+	}
+
+	var err error
+	if req.StartAt, err = parseInt64Query(c, "start"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "start 参数格式错误",
+		})
+	}
+	if req.EndAt, err = parseInt64Query(c, "end"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "end 参数格式错误",
+		})
+	}
+	limit, err := parseInt64Query(c, "limit")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "limit 参数格式错误",
+		})
+	}
+	req.Limit = int(limit)
+	if req.HasOTP, err = parseBoolQuery(c, "hasOtp"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "hasOtp 参数格式错误",
+		})
+	}
+
+	result, err := h.service.Search(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("搜索短信失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "搜索短信失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// MarkConversationRead 将会话标记为已读
+// POST /api/messages/conversations/:peer/read
+func (h *TextMessageHandler) MarkConversationRead(c echo.Context) error {
+	peer := decodePeerParam(c)
+	if peer == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "peer 参数不能为空",
+		})
+	}
+
+	updated, err := h.service.MarkConversationRead(c.Request().Context(), peer)
+	if err != nil {
+		h.logger.Error("标记会话已读失败", zap.Error(err), zap.String("peer", peer))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "标记已读失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{
+		"updated": updated,
+	})
+}
+
+// MarkAllRead 将所有短信标记为已读，可按设备过滤
+// POST /api/messages/read-all?deviceId=
+func (h *TextMessageHandler) MarkAllRead(c echo.Context) error {
+	updated, err := h.service.MarkAllRead(c.Request().Context(), c.QueryParam("deviceId"))
+	if err != nil {
+		h.logger.Error("全部标记已读失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "标记已读失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{
+		"updated": updated,
+	})
+}
+
+// GetUnreadCounts 获取未读总数及各设备未读数
+// GET /api/messages/unread
+func (h *TextMessageHandler) GetUnreadCounts(c echo.Context) error {
+	counts, err := h.service.GetUnreadCounts(c.Request().Context())
+	if err != nil {
+		h.logger.Error("获取未读统计失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取未读统计失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, counts)
+}
+
+// UpdateConversationState 更新会话置顶、归档、免打扰状态
+// PUT /api/messages/conversations/:peer/state
+func (h *TextMessageHandler) UpdateConversationState(c echo.Context) error {
+	peer := decodePeerParam(c)
+	if peer == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "peer 参数不能为空",
+		})
+	}
+
+	var patch service.ConversationStatePatch
+	if err := c.Bind(&patch); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	state, err := h.service.UpdateConversationState(c.Request().Context(), peer, &patch)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "会话不存在",
+			})
+		}
+		h.logger.Error("更新会话状态失败", zap.Error(err), zap.String("peer", peer))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "更新会话状态失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, state)
+}
+
+// Export 导出短信（流式输出）
+// GET /api/messages/export?format=csv|ndjson|xml&deviceId=&peer=&start=&end=
+func (h *TextMessageHandler) Export(c echo.Context) error {
+	format, err := service.ParseExportFormat(c.QueryParam("format"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	filter := repo.MessageExportFilter{
+		DeviceID: c.QueryParam("deviceId"),
+		Peer:     c.QueryParam("peer"),
+	}
+	if filter.StartAt, err = parseInt64Query(c, "start"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "start 参数格式错误",
+		})
+	}
+	if filter.EndAt, err = parseInt64Query(c, "end"); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "end 参数格式错误",
+		})
+	}
+
+	filename := fmt.Sprintf("smshub-messages-%s.%s", time.Now().Format("20060102150405"), format)
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, format.ContentType())
+	resp.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	resp.WriteHeader(http.StatusOK)
+
+	// 响应头已发送，出错时只能记录日志并中断输出
+	if _, err := h.service.Export(c.Request().Context(), format, filter, resp); err != nil {
+		h.logger.Error("导出短信失败", zap.Error(err), zap.String("format", string(format)))
+	}
+	return nil
+}
+
+// Import 导入短信，支持 multipart 文件（字段 file）或直接上传请求体
+// POST /api/messages/import?format=csv|ndjson|xml&deviceId=&deviceName=
+func (h *TextMessageHandler) Import(c echo.Context) error {
+	body := c.Request().Body
+	formatParam := c.QueryParam("format")
+
+	if file, err := c.FormFile("file"); err == nil {
+		src, err := file.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "读取上传文件失败",
+			})
+		}
+		defer src.Close()
+		body = src
+		if formatParam == "" {
+			formatParam = strings.TrimPrefix(path.Ext(file.Filename), ".")
+		}
+	}
+
+	format, err := service.ParseExportFormat(formatParam)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	result, err := h.service.Import(c.Request().Context(), format, body, service.ImportOptions{
+		DeviceID:   c.QueryParam("deviceId"),
+		DeviceName: c.QueryParam("deviceName"),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidImportFile) {
+			// 文件中途解析失败时一并返回已处理的结果
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"error":  err.Error(),
+				"result": result,
+			})
+		}
+		h.logger.Error("导入短信失败", zap.Error(err), zap.String("format", string(format)))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "导入失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetTrash 获取回收站中的短信
+// GET /api/messages/trash?cursor=&limit=
+func (h *TextMessageHandler) GetTrash(c echo.Context) error {
+	limit, err := parseInt64Query(c, "limit")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "limit 参数格式错误",
+		})
+	}
+
+	page, err := h.service.GetTrash(c.Request().Context(), c.QueryParam("cursor"), int(limit))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("获取回收站失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "获取回收站失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// Restore 从回收站恢复单条短信
+// POST /api/messages/:id/restore
+func (h *TextMessageHandler) Restore(c echo.Context) error {
+	id := c.Param("id")
+	if id == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "id 参数不能为空",
+		})
+	}
+
+	restored, err := h.service.Restore(c.Request().Context(), id)
+	if err != nil {
+		h.logger.Error("恢复短信失败", zap.Error(err), zap.String("id", id))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "恢复失败",
+		})
+	}
+	if restored == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "回收站中不存在该短信",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{
+		"restored": restored,
+	})
+}
+
+// RestoreConversation 从回收站恢复会话的所有短信
+// POST /api/messages/conversations/:peer/restore
+func (h *TextMessageHandler) RestoreConversation(c echo.Context) error {
+	peer := decodePeerParam(c)
+	if peer == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "peer 参数不能为空",
+		})
+	}
+
+	restored, err := h.service.RestoreConversation(c.Request().Context(), peer)
+	if err != nil {
+		h.logger.Error("恢复会话失败", zap.Error(err), zap.String("peer", peer))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "恢复失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{
+		"restored": restored,
+	})
+}
+
+// RestoreAll 恢复回收站中的所有短信
+// POST /api/messages/trash/restore
+func (h *TextMessageHandler) RestoreAll(c echo.Context) error {
+	restored, err := h.service.RestoreAll(c.Request().Context())
+	if err != nil {
+		h.logger.Error("恢复回收站失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "恢复失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{
+		"restored": restored,
+	})
+}
+
+// EmptyTrash 清空回收站（永久删除）
+// DELETE /api/messages/trash
+func (h *TextMessageHandler) EmptyTrash(c echo.Context) error {
+	purged, err := h.service.EmptyTrash(c.Request().Context())
+	if err != nil {
+		h.logger.Error("清空回收站失败", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "清空回收站失败",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]int64{
+		"purged": purged,
+	})
+}
+
+// decodePeerParam 读取并 URL 解码 peer 路径参数（处理 + 号等特殊字符），解码失败时使用原始值
+func decodePeerParam(c echo.Context) string {
+	peer := c.Param("peer")
+	if decoded, err := url.QueryUnescape(peer); err == nil {
+		return decoded
+	}
+	return peer
+}
+
+// parseBoolQuery 解析布尔查询参数，未提供时返回 nil
+func parseBoolQuery(c echo.Context, name string) (*bool, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// parseInt64Query 解析整数查询参数，未提供时返回 0
+func parseInt64Query(c echo.Context, name string) (int64, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
@@ -5,7 +5,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/Starktomy/smshub/internal/service"
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 )
 
 func TestTextMessageHandlerDeleteEmptyID(t *testing.T) {
@@ -67,3 +69,117 @@ func TestTextMessageHandlerDeleteConversationEmptyPeer(t *testing.T) {
 		t.Errorf("空 peer 应返回 400，实际为 %d", rec.Code)
 	}
 }
+
+func TestTextMessageHandlerSearchInvalidParams(t *testing.T) {
+	e := echo.New()
+
+	for _, query := range []string{"start=abc", "end=1.5", "limit=x", "hasOtp=maybe"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/messages/search?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := &TextMessageHandler{}
+		if err := h.Search(c); err != nil {
+			t.Fatalf("Search 不应返回 Echo 错误: %v", err)
+		}
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("参数 %s 应返回 400，实际为 %d", query, rec.Code)
+		}
+	}
+}
+
+func TestTextMessageHandlerConversationsInvalidLimit(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/messages/conversations?limit=abc", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	h := &TextMessageHandler{}
+	if err := h.GetConversations(c); err != nil {
+		t.Fatalf("GetConversations 不应返回 Echo 错误: %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("非法 limit 应返回 400，实际为 %d", rec.Code)
+	}
+}
+
+func TestTextMessageHandlerConversationsInvalidFilter(t *testing.T) {
+	e := echo.New()
+
+	for _, query := range []string{"archived=maybe", "pinned=2x"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/messages/conversations?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		h := &TextMessageHandler{}
+		if err := h.GetConversations(c); err != nil {
+			t.Fatalf("GetConversations 不应返回 Echo 错误: %v", err)
+		}
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("参数 %s 应返回 400，实际为 %d", query, rec.Code)
+		}
+	}
+}
+
+func TestTextMessageHandlerConversationStateEmptyPeer(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/messages/conversations//state", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("peer")
+	c.SetParamValues("")
+
+	h := &TextMessageHandler{}
+	if err := h.UpdateConversationState(c); err != nil {
+		t.Fatalf("UpdateConversationState 不应返回 Echo 错误: %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("空 peer 应返回 400，实际为 %d", rec.Code)
+	}
+}
+
+func TestTextMessageHandlerExportImportInvalidFormat(t *testing.T) {
+	e := echo.New()
+	h := &TextMessageHandler{}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/messages/export?format=pdf", nil)
+	rec := httptest.NewRecorder()
+	if err := h.Export(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("Export 不应返回 Echo 错误: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("不支持的导出格式应返回 400，实际为 %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/messages/import", nil)
+	rec = httptest.NewRecorder()
+	if err := h.Import(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("Import 不应返回 Echo 错误: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("缺少导入格式应返回 400，实际为 %d", rec.Code)
+	}
+}
+
+func TestTextMessageHandlerClearRequiresToken(t *testing.T) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/messages?confirm=unknown", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	h := &TextMessageHandler{logger: zap.NewNop(), service: service.NewTextMessageService(zap.NewNop(), nil, nil)}
+	if err := h.Clear(c); err != nil {
+		t.Fatalf("Clear 不应返回 Echo 错误: %v", err)
+	}
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("无效确认令牌应返回 400，实际为 %d", rec.Code)
+	}
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Starktomy/smshub/internal/middleware"
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/service"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// UserHandler 用户管理API处理器
+type UserHandler struct {
+	logger         *zap.Logger
+	userService    *service.UserService
+	accessService  *service.AccessService
+	sessionService *service.SessionService
+	totpService    *service.TOTPService
+}
+
+// NewUserHandler 创建用户Handler实例
+func NewUserHandler(logger *zap.Logger, userService *service.UserService, accessService *service.AccessService, sessionService *service.SessionService,
+	totpService *service.TOTPService) *UserHandler {
+	return &UserHandler{
+		logger:         logger,
+		userService:    userService,
+		accessService:  accessService,
+		sessionService: sessionService,
+		totpService:    totpService,
+	}
+}
+
+// List 获取用户列表
+// GET /api/users
+func (h *UserHandler) List(c echo.Context) error {
+	users, err := h.userService.List(c.Request().Context())
+	if err != nil {
+		return h.fail(c, "", "获取用户列表失败", err)
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+	return c.JSON(http.StatusOK, users)
+}
+
+// Get 获取用户
+// GET /api/users/:id
+func (h *UserHandler) Get(c echo.Context) error {
+	id := c.Param("id")
+	user, err := h.userService.GetById(c.Request().Context(), id)
+	if err != nil {
+		return h.fail(c, id, "获取用户失败", err)
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// Create 创建本地用户
+// POST /api/users
+func (h *UserHandler) Create(c echo.Context) error {
+	var req service.CreateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	user, err := h.userService.Create(c.Request().Context(), &req)
+	if err != nil {
+		return h.fail(c, "", "创建用户失败", err)
+	}
+	h.logger.Info("创建用户", zap.String("username", user.Username), zap.String("role", string(user.Role)),
+		zap.String("operator", middleware.GetUsername(c)))
+	return c.JSON(http.StatusCreated, user)
+}
+
+// Update 更新用户昵称和角色
+// PUT /api/users/:id
+func (h *UserHandler) Update(c echo.Context) error {
+	var req service.UpdateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	id := c.Param("id")
+	user, err := h.userService.Update(c.Request().Context(), id, &req)
+	if err != nil {
+		return h.fail(c, id, "更新用户失败", err)
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// Delete 删除用户
+// DELETE /api/users/:id
+func (h *UserHandler) Delete(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.userService.Delete(c.Request().Context(), id, middleware.GetUsername(c)); err != nil {
+		return h.fail(c, id, "删除用户失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "用户已删除",
+	})
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Password string `json:"password"` // 新密码，为空时生成随机密码
+}
+
+// ResetPassword 管理员重置用户密码，返回新密码
+// POST /api/users/:id/password
+func (h *UserHandler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	id := c.Param("id")
+	password, err := h.userService.ResetPassword(c.Request().Context(), id, req.Password)
+	if err != nil {
+		return h.fail(c, id, "重置密码失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"password": password,
+	})
+}
+
+// ListGrants 获取用户的设备授权
+// GET /api/users/:id/grants
+func (h *UserHandler) ListGrants(c echo.Context) error {
+	id := c.Param("id")
+	grants, err := h.accessService.ListGrants(c.Request().Context(), id)
+	if err != nil {
+		return h.fail(c, id, "获取授权列表失败", err)
+	}
+	if grants == nil {
+		grants = []models.DeviceGrant{}
+	}
+	return c.JSON(http.StatusOK, grants)
+}
+
+// Grant 授权用户访问设备或设备分组
+// POST /api/users/:id/grants
+func (h *UserHandler) Grant(c echo.Context) error {
+	var req service.GrantRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "请求参数错误",
+		})
+	}
+
+	id := c.Param("id")
+	grant, err := h.accessService.Grant(c.Request().Context(), id, &req)
+	if err != nil {
+		return h.fail(c, id, "添加授权失败", err)
+	}
+	h.logger.Info("添加设备授权", zap.String("userId", id), zap.String("deviceId", grant.DeviceID),
+		zap.String("groupName", grant.GroupName), zap.String("operator", middleware.GetUsername(c)))
+	return c.JSON(http.StatusCreated, grant)
+}
+
+// Revoke 撤销用户的设备授权
+// DELETE /api/users/:id/grants/:grantId
+func (h *UserHandler) Revoke(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.accessService.Revoke(c.Request().Context(), id, c.Param("grantId")); err != nil {
+		return h.fail(c, id, "撤销授权失败", err)
+	}
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "授权已撤销",
+	})
+}
+
+// ListSessions 获取用户的登录会话
+// GET /api/users/:id/sessions
+func (h *UserHandler) ListSessions(c echo.Context) error {
+	id := c.Param("id")
+	sessions, err := h.sessionService.List(c.Request().Context(), id)
+	if err != nil {
+		return h.fail(c, id, "获取会话列表失败", err)
+	}
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSessions 撤销用户的所有登录会话，强制其重新登录
+// DELETE /api/users/:id/sessions
+func (h *UserHandler) RevokeSessions(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.sessionService.RevokeAll(c.Request().Context(), id); err != nil {
+		return h.fail(c, id, "撤销会话失败", err)
+	}
+	h.logger.Info("撤销用户所有会话", zap.String("userId", id), zap.String("operator", middleware.GetUsername(c)))
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "会话已撤销",
+	})
+}
+
+// ResetTOTP 为丢失验证器的用户关闭两步验证
+// DELETE /api/users/:id/totp
+func (h *UserHandler) ResetTOTP(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.totpService.Reset(c.Request().Context(), id); err != nil {
+		return h.fail(c, id, "重置两步验证失败", err)
+	}
+	h.logger.Info("重置用户两步验证", zap.String("userId", id), zap.String("operator", middleware.GetUsername(c)))
+	return c.JSON(http.StatusOK, map[string]string{
+		"message": "两步验证已关闭",
+	})
+}
+
+// fail 根据错误类型返回对应的状态码
+func (h *UserHandler) fail(c echo.Context, id, msg string, err error) error {
+	return userError(c, h.logger, id, msg, err)
+}
+
+// userError 用户相关错误转换为 HTTP 响应，AuthHandler 和 UserHandler 共用
+func userError(c echo.Context, logger *zap.Logger, id, msg string, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "用户不存在",
+		})
+	case errors.Is(err, service.ErrInvalidUser), errors.Is(err, service.ErrWeakPassword),
+		errors.Is(err, service.ErrWrongPassword), errors.Is(err, service.ErrNotLocalUser),
+		errors.Is(err, service.ErrInvalidGrant):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, service.ErrUserExists), errors.Is(err, service.ErrLastAdmin), errors.Is(err, service.ErrDeleteSelf),
+		errors.Is(err, service.ErrGrantExists):
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	logger.Error(msg, zap.String("id", id), zap.Error(err))
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": msg,
+	})
+}
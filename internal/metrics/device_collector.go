@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DeviceState 采集时的设备状态
+type DeviceState struct {
+	ID          string
+	Name        string
+	Online      bool
+	SignalLevel int
+	Rssi        int
+	Rsrp        int
+	Rsrq        float64
+	LastSeenAt  time.Time // 最近一次心跳或状态上报时间，零值表示从未上报
+	HasSignal   bool      // 是否有缓存的信号数据，没有时不导出信号指标
+}
+
+var deviceLabels = []string{"device", "name"}
+
+// DeviceCollector 每次采集时读取设备状态，导出在线状态、信号和心跳间隔
+type DeviceCollector struct {
+	states func() []DeviceState
+	now    func() time.Time
+
+	online       *prometheus.Desc
+	signalLevel  *prometheus.Desc
+	rssi         *prometheus.Desc
+	rsrp         *prometheus.Desc
+	rsrq         *prometheus.Desc
+	heartbeatAge *prometheus.Desc
+}
+
+// NewDeviceCollector 创建设备状态采集器，states 在每次采集时调用
+func NewDeviceCollector(states func() []DeviceState) *DeviceCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "device", name), help, deviceLabels, nil)
+	}
+	return &DeviceCollector{
+		states:       states,
+		now:          time.Now,
+		online:       desc("online", "设备是否在线（1 在线，0 离线）"),
+		signalLevel:  desc("signal_level", "信号等级"),
+		rssi:         desc("rssi_dbm", "接收信号强度 RSSI"),
+		rsrp:         desc("rsrp_dbm", "参考信号接收功率 RSRP"),
+		rsrq:         desc("rsrq_db", "参考信号接收质量 RSRQ"),
+		heartbeatAge: desc("last_heartbeat_age_seconds", "距离最近一次心跳的秒数"),
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *DeviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.online
+	ch <- c.signalLevel
+	ch <- c.rssi
+	ch <- c.rsrp
+	ch <- c.rsrq
+	ch <- c.heartbeatAge
+}
+
+// Collect 实现 prometheus.Collector
+func (c *DeviceCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	for _, d := range c.states() {
+		online := 0.0
+		if d.Online {
+			online = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.online, prometheus.GaugeValue, online, d.ID, d.Name)
+		if !d.LastSeenAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.heartbeatAge, prometheus.GaugeValue, now.Sub(d.LastSeenAt).Seconds(), d.ID, d.Name)
+		}
+		if !d.HasSignal {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.signalLevel, prometheus.GaugeValue, float64(d.SignalLevel), d.ID, d.Name)
+		ch <- prometheus.MustNewConstMetric(c.rssi, prometheus.GaugeValue, float64(d.Rssi), d.ID, d.Name)
+		ch <- prometheus.MustNewConstMetric(c.rsrp, prometheus.GaugeValue, float64(d.Rsrp), d.ID, d.Name)
+		ch <- prometheus.MustNewConstMetric(c.rsrq, prometheus.GaugeValue, d.Rsrq, d.ID, d.Name)
+	}
+}
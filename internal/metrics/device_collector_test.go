@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeviceCollector(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	collector := NewDeviceCollector(func() []DeviceState {
+		return []DeviceState{
+			{ID: "d1", Name: "主卡", Online: true, SignalLevel: 4, Rssi: -67, Rsrp: -95, Rsrq: -10.5, LastSeenAt: now.Add(-15 * time.Second), HasSignal: true},
+			{ID: "d2", Name: "备卡"},
+		}
+	})
+	collector.now = func() time.Time { return now }
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+
+	expected := `
+# HELP smshub_device_last_heartbeat_age_seconds 距离最近一次心跳的秒数
+# TYPE smshub_device_last_heartbeat_age_seconds gauge
+smshub_device_last_heartbeat_age_seconds{device="d1",name="主卡"} 15
+# HELP smshub_device_online 设备是否在线（1 在线，0 离线）
+# TYPE smshub_device_online gauge
+smshub_device_online{device="d1",name="主卡"} 1
+smshub_device_online{device="d2",name="备卡"} 0
+# HELP smshub_device_rsrq_db 参考信号接收质量 RSRQ
+# TYPE smshub_device_rsrq_db gauge
+smshub_device_rsrq_db{device="d1",name="主卡"} -10.5
+# HELP smshub_device_signal_level 信号等级
+# TYPE smshub_device_signal_level gauge
+smshub_device_signal_level{device="d1",name="主卡"} 4
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"smshub_device_online", "smshub_device_last_heartbeat_age_seconds",
+		"smshub_device_signal_level", "smshub_device_rsrq_db"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有信号数据的设备不导出信号指标
+	if count := testutil.CollectAndCount(collector, "smshub_device_rssi_dbm"); count != 1 {
+		t.Fatalf("RSSI 指标数量应为 1，实际 %d", count)
+	}
+}
@@ -0,0 +1,102 @@
+// Package metrics 定义 Prometheus 指标，通过 /metrics 接口导出
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "smshub"
+
+// Registry 应用使用的指标注册表，包含 Go 运行时和进程指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	// SerialReconnects 串口断开后重新连接的次数
+	SerialReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "serial_reconnects_total",
+		Help:      "串口断开或连接失败后重试的次数",
+	}, []string{"device"})
+
+	// SerialFrames 成功解析的串口消息，按消息类型统计
+	SerialFrames = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "serial_frames_total",
+		Help:      "成功解析的串口消息数量",
+	}, []string{"device", "type"})
+
+	// SerialParseErrors 解析失败的串口消息，按错误类型统计
+	SerialParseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "serial_parse_errors_total",
+		Help:      "解析失败的串口消息数量",
+	}, []string{"device", "reason"})
+
+	// SMSSent 设备回执发送成功的短信
+	SMSSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sms_sent_total",
+		Help:      "发送成功的短信数量",
+	}, []string{"device"})
+
+	// SMSFailed 发送失败的短信，包括写入串口失败和设备回执失败
+	SMSFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sms_failed_total",
+		Help:      "发送失败的短信数量",
+	}, []string{"device"})
+
+	// SMSReceived 收到的短信
+	SMSReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sms_received_total",
+		Help:      "收到的短信数量",
+	}, []string{"device"})
+
+	// SMSSendLatency 从写入发送命令到收到 sms_send_result 的耗时
+	SMSSendLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sms_send_latency_seconds",
+		Help:      "从写入发送命令到收到发送结果的耗时",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"device", "result"})
+
+	// NotificationAttempts 通知渠道的发送次数
+	NotificationAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_attempts_total",
+		Help:      "通知渠道的发送次数",
+	}, []string{"channel"})
+
+	// NotificationFailures 通知渠道发送失败的次数
+	NotificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_failures_total",
+		Help:      "通知渠道发送失败的次数",
+	}, []string{"channel"})
+
+	// SchedulerRuns 定时任务执行结果
+	SchedulerRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduler_runs_total",
+		Help:      "定时任务的执行次数，按动作和结果统计",
+	}, []string{"action", "trigger", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SerialReconnects,
+		SerialFrames,
+		SerialParseErrors,
+		SMSSent,
+		SMSFailed,
+		SMSReceived,
+		SMSSendLatency,
+		NotificationAttempts,
+		NotificationFailures,
+		SchedulerRuns,
+	)
+}
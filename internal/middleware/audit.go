@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxAuditBody 记录请求体的最大长度，超过时不记录请求体
+	maxAuditBody = 64 * 1024
+	// maxAuditParams 保存参数的最大长度
+	maxAuditParams = 4096
+	// maxAuditError 从响应中读取错误信息的最大长度
+	maxAuditError = 1024
+)
+
+// sensitiveParams 参数名包含这些字符串时脱敏
+var sensitiveParams = []string{"password", "secret", "token", "passphrase", "accesskey", "code"}
+
+// AuditRecorder 保存审计日志
+type AuditRecorder func(ctx context.Context, entry *models.AuditLog)
+
+// Audit 审计中间件，记录所有修改类请求（POST/PUT/PATCH/DELETE）的操作人、路由、参数和结果
+// 需在 JWTMiddleware 之后使用，请求IP 由 server.ip_extractor 配置的 IPExtractor 提取
+func Audit(record AuditRecorder) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			switch req.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+
+			entry := &models.AuditLog{
+				Action: req.Method + " " + c.Path(),
+				Target: strings.Join(c.ParamValues(), "/"),
+				IP:     c.RealIP(),
+				Method: req.Method,
+				Path:   req.URL.Path,
+				Params: auditParams(c),
+			}
+
+			capture := &errorCapture{ResponseWriter: c.Response().Writer, resp: c.Response()}
+			c.Response().Writer = capture
+			err := next(c)
+			if err != nil {
+				// 交给 echo 生成响应，以便记录最终的状态码
+				c.Error(err)
+			}
+			c.Response().Writer = capture.ResponseWriter
+
+			entry.Actor = GetUsername(c)
+			entry.Status = c.Response().Status
+			entry.Success = entry.Status < http.StatusBadRequest
+			if !entry.Success {
+				entry.Error = capture.message()
+				if entry.Error == "" && err != nil {
+					entry.Error = err.Error()
+				}
+			}
+			record(context.WithoutCancel(req.Context()), entry)
+			return nil
+		}
+	}
+}
+
+// auditParams 读取查询参数和 JSON 请求体并脱敏，读取后恢复请求体供处理器使用
+func auditParams(c echo.Context) string {
+	params := map[string]any{}
+	if query := c.QueryParams(); len(query) > 0 {
+		values := make(map[string]any, len(query))
+		for name, v := range query {
+			values[name] = strings.Join(v, ",")
+		}
+		params["query"] = redactParams(values)
+	}
+
+	req := c.Request()
+	if req.Body != nil && strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		body, err := io.ReadAll(io.LimitReader(req.Body, maxAuditBody+1))
+		// 未读完的部分接在已读内容之后，处理器读到的仍是完整请求体
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		if err == nil && len(body) <= maxAuditBody {
+			var value any
+			if json.Unmarshal(body, &value) == nil {
+				params["body"] = redactParams(value)
+			}
+		}
+	}
+
+	if len(params) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	if len(data) > maxAuditParams {
+		return string(data[:maxAuditParams])
+	}
+	return string(data)
+}
+
+// redactParams 递归替换敏感字段的值
+func redactParams(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, field := range v {
+			if isSensitiveParam(name) {
+				v[name] = "***"
+			} else {
+				v[name] = redactParams(field)
+			}
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactParams(v[i])
+		}
+	}
+	return value
+}
+
+func isSensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveParams {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCapture 在响应失败时保存响应体开头，用于记录失败原因
+type errorCapture struct {
+	http.ResponseWriter
+	resp *echo.Response
+	buf  bytes.Buffer
+}
+
+func (w *errorCapture) Write(b []byte) (int, error) {
+	if w.resp.Status >= http.StatusBadRequest && w.buf.Len() < maxAuditError {
+		w.buf.Write(b[:min(len(b), maxAuditError-w.buf.Len())])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorCapture) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *errorCapture) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// message 返回响应中的 error 字段，不是 JSON 时返回原始内容
+func (w *errorCapture) message() string {
+	var body struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(w.buf.Bytes(), &body) == nil {
+		if body.Error != "" {
+			return body.Error
+		}
+		return body.Message
+	}
+	return strings.TrimSpace(w.buf.String())
+}
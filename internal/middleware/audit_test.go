@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAudit(t *testing.T) {
+	var entries []*models.AuditLog
+	e := echo.New()
+	e.IPExtractor = echo.ExtractIPFromRealIPHeader()
+	g := e.Group("/api", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(ContextKeyUsername, "alice")
+			return next(c)
+		}
+	}, Audit(func(_ context.Context, entry *models.AuditLog) {
+		entries = append(entries, entry)
+	}))
+	g.GET("/devices", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	g.POST("/users/:id/password", func(c echo.Context) error {
+		// 处理器仍能读到完整的请求体
+		body, _ := io.ReadAll(c.Request().Body)
+		var req map[string]string
+		if err := json.Unmarshal(body, &req); err != nil || req["password"] != "new-password" {
+			t.Errorf("请求体应保持不变: %s", body)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "ok"})
+	})
+	g.DELETE("/devices/:id", func(c echo.Context) error {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "无权访问该设备"})
+	})
+
+	serve := func(method, target, body string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.RemoteAddr = "127.0.0.1:40000" // 默认只信任内网代理传来的 X-Real-IP
+		req.Header.Set(echo.HeaderXRealIP, "203.0.113.7")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve(http.MethodGet, "/api/devices", "")
+	serve(http.MethodPost, "/api/users/u1/password?force=true", `{"password":"new-password","note":"reset"}`)
+	serve(http.MethodDelete, "/api/devices/dev-1", "")
+
+	// GET 请求不记录
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %d", len(entries))
+	}
+
+	reset := entries[0]
+	if reset.Actor != "alice" || reset.Action != "POST /api/users/:id/password" || reset.Target != "u1" ||
+		reset.IP != "203.0.113.7" || !reset.Success || reset.Status != http.StatusOK {
+		t.Errorf("Unexpected entry: %+v", reset)
+	}
+	if strings.Contains(reset.Params, "new-password") || !strings.Contains(reset.Params, `"note":"reset"`) ||
+		!strings.Contains(reset.Params, `"force":"true"`) {
+		t.Errorf("参数应脱敏并保留其他字段: %s", reset.Params)
+	}
+
+	deleted := entries[1]
+	if deleted.Success || deleted.Status != http.StatusForbidden || deleted.Error != "无权访问该设备" || deleted.Target != "dev-1" {
+		t.Errorf("Unexpected failed entry: %+v", deleted)
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// DeviceScoper 根据用户的设备授权返回限制了设备范围的 context
+type DeviceScoper func(ctx context.Context, user *models.User) (context.Context, error)
+
+// DeviceScope 设备范围中间件，需在 JWTMiddleware 之后使用
+// 之后的处理器通过 c.Request().Context() 查询设备和短信时只能看到已授权的设备
+func DeviceScope(scope DeviceScoper, logger *zap.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx, err := scope(c.Request().Context(), GetUser(c))
+			if err != nil {
+				logger.Error("加载设备授权失败", zap.String("username", GetUsername(c)), zap.Error(err))
+				return c.JSON(http.StatusInternalServerError, map[string]string{
+					"error": "加载设备授权失败",
+				})
+			}
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// RequireAllDevices 只允许不受设备范围限制的用户访问，用于无法按设备区分的单设备模式接口
+func RequireAllDevices() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, restricted := repo.DeviceScopeFrom(c.Request().Context()); restricted {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "权限不足",
+				})
+			}
+			return next(c)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
-	"github.com/Starktomy/smshub/internal/util"
+	"github.com/Starktomy/smshub/internal/models"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
@@ -12,10 +13,19 @@ import (
 const (
 	// ContextKeyUsername Context 中用户名的 key
 	ContextKeyUsername = "username"
+	// ContextKeyRole Context 中用户角色的 key
+	ContextKeyRole = "role"
+	// ContextKeyUser Context 中当前用户的 key
+	ContextKeyUser = "user"
+	// ContextKeySession Context 中当前会话ID的 key
+	ContextKeySession = "session"
 )
 
+// TokenAuthenticator 验证访问令牌，返回当前用户和会话ID；会话被撤销或用户被删除时返回错误
+type TokenAuthenticator func(ctx context.Context, token string) (*models.User, string, error)
+
 // JWTMiddleware JWT 认证中间件
-func JWTMiddleware(secret string, logger *zap.Logger) echo.MiddlewareFunc {
+func JWTMiddleware(authenticate TokenAuthenticator, logger *zap.Logger) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// 获取 Authorization header
@@ -36,10 +46,8 @@ func JWTMiddleware(secret string, logger *zap.Logger) echo.MiddlewareFunc {
 				})
 			}
 
-			tokenString := parts[1]
-
-			// 验证 token
-			claims, err := util.VerifyToken(tokenString, secret)
+			// 验证 token 和会话，读取用户的当前角色
+			user, sessionID, err := authenticate(c.Request().Context(), parts[1])
 			if err != nil {
 				logger.Warn("token 验证失败", zap.Error(err))
 				return c.JSON(http.StatusUnauthorized, map[string]string{
@@ -47,8 +55,11 @@ func JWTMiddleware(secret string, logger *zap.Logger) echo.MiddlewareFunc {
 				})
 			}
 
-			// 将用户名存入 context
-			c.Set(ContextKeyUsername, claims.Username)
+			// 将用户、会话、用户名和角色存入 context
+			c.Set(ContextKeyUser, user)
+			c.Set(ContextKeySession, sessionID)
+			c.Set(ContextKeyUsername, user.Username)
+			c.Set(ContextKeyRole, user.Role)
 
 			// 继续处理请求
 			return next(c)
@@ -63,3 +74,41 @@ func GetUsername(c echo.Context) string {
 	}
 	return ""
 }
+
+// GetUser 从 context 中获取当前用户
+func GetUser(c echo.Context) *models.User {
+	if user, ok := c.Get(ContextKeyUser).(*models.User); ok {
+		return user
+	}
+	return nil
+}
+
+// GetSessionID 从 context 中获取当前会话ID
+func GetSessionID(c echo.Context) string {
+	if sessionID, ok := c.Get(ContextKeySession).(string); ok {
+		return sessionID
+	}
+	return ""
+}
+
+// GetRole 从 context 中获取用户角色
+func GetRole(c echo.Context) models.UserRole {
+	if role, ok := c.Get(ContextKeyRole).(models.UserRole); ok {
+		return role
+	}
+	return ""
+}
+
+// RequireRole 角色检查中间件，需在 JWTMiddleware 之后使用
+func RequireRole(min models.UserRole) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !GetRole(c).Allows(min) {
+				return c.JSON(http.StatusForbidden, map[string]string{
+					"error": "权限不足",
+				})
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsAuth /metrics 接口认证，支持 Bearer Token 和 Basic 认证，都未配置时不检查
+func MetricsAuth(metricsConfig config.MetricsConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if metricsConfig.Token == "" && metricsConfig.Username == "" {
+				return next(c)
+			}
+
+			authHeader := c.Request().Header.Get("Authorization")
+			if metricsConfig.Token != "" {
+				if token, ok := strings.CutPrefix(authHeader, "Bearer "); ok && secureEqual(token, metricsConfig.Token) {
+					return next(c)
+				}
+			}
+			if metricsConfig.Username != "" {
+				if username, password, ok := c.Request().BasicAuth(); ok &&
+					secureEqual(username, metricsConfig.Username) && secureEqual(password, metricsConfig.Password) {
+					return next(c)
+				}
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="metrics"`)
+			}
+			return c.JSON(http.StatusUnauthorized, map[string]string{
+				"error": "认证失败",
+			})
+		}
+	}
+}
+
+// secureEqual 固定时间比较，避免通过响应时间猜测密钥
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
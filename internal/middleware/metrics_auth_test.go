@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/labstack/echo/v4"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	ok := func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}
+	request := func(metricsConfig config.MetricsConfig, setup func(r *http.Request)) int {
+		e := echo.New()
+		e.GET("/metrics", ok, MetricsAuth(metricsConfig))
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if setup != nil {
+			setup(req)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 未配置认证
+	if code := request(config.MetricsConfig{}, nil); code != http.StatusOK {
+		t.Fatalf("未配置认证时应允许访问，实际 %d", code)
+	}
+
+	both := config.MetricsConfig{Token: "secret-token", Username: "prometheus", Password: "secret"}
+	cases := []struct {
+		name  string
+		setup func(r *http.Request)
+		code  int
+	}{
+		{"无认证信息", nil, http.StatusUnauthorized},
+		{"正确的 Token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret-token") }, http.StatusOK},
+		{"错误的 Token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"正确的用户名密码", func(r *http.Request) { r.SetBasicAuth("prometheus", "secret") }, http.StatusOK},
+		{"错误的密码", func(r *http.Request) { r.SetBasicAuth("prometheus", "wrong") }, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if code := request(both, tc.setup); code != tc.code {
+			t.Errorf("%s: 期望 %d，实际 %d", tc.name, tc.code, code)
+		}
+	}
+}
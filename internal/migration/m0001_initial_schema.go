@@ -0,0 +1,130 @@
+package migration
+
+import "gorm.io/gorm"
+
+// initialSchema 初始表结构
+// 使用独立的结构体快照而不是 models 中的模型，保证模型后续变化时迁移结果不变
+// 升级前已存在的表会补齐缺少的列和索引
+var initialSchema = Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		// 升级前已存在的短信没有已读状态，新增 read_at 列（旧数据为 NULL）后统一视为已读
+		migrator := tx.Migrator()
+		backfillReadAt := migrator.HasTable("text_messages") && !migrator.HasColumn("text_messages", "read_at")
+
+		if err := tx.AutoMigrate(
+			&propertyV1{},
+			&textMessageV1{},
+			&scheduledTaskV1{},
+			&deviceV1{},
+			&conversationStateV1{},
+		); err != nil {
+			return err
+		}
+
+		if backfillReadAt {
+			return tx.Exec("UPDATE text_messages SET read_at = created_at WHERE type = 'incoming' AND (read_at IS NULL OR read_at = 0)").Error
+		}
+		return nil
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(
+			&conversationStateV1{},
+			&deviceV1{},
+			&scheduledTaskV1{},
+			&textMessageV1{},
+			&propertyV1{},
+		)
+	},
+}
+
+type propertyV1 struct {
+	ID        string `gorm:"primaryKey"`
+	Name      string
+	Value     string `gorm:"type:text"`
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+func (propertyV1) TableName() string {
+	return "properties"
+}
+
+type textMessageV1 struct {
+	ID         string `gorm:"primaryKey;index:idx_text_messages_peer_time,priority:3;index:idx_text_messages_time,priority:2"`
+	From       string `gorm:"column:from_number;index"`
+	To         string `gorm:"column:to_number;index"`
+	Peer       string `gorm:"column:peer;index:idx_text_messages_peer_time,priority:1"`
+	Content    string `gorm:"type:text"`
+	Type       string `gorm:"index"`
+	Status     string `gorm:"index"`
+	DeviceID   string `gorm:"index"`
+	DeviceName string
+	OTPCode    string `gorm:"column:otp_code;index"`
+	ReadAt     int64  `gorm:"column:read_at;index"`
+	CreatedAt  int64  `gorm:"index:idx_text_messages_peer_time,priority:2;index:idx_text_messages_time,priority:1"`
+	UpdatedAt  int64
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+func (textMessageV1) TableName() string {
+	return "text_messages"
+}
+
+type scheduledTaskV1 struct {
+	ID            string `gorm:"primaryKey"`
+	Name          string
+	Enabled       bool
+	IntervalDays  int
+	PhoneNumber   string
+	Content       string `gorm:"type:text"`
+	DeviceID      string
+	CreatedAt     int64
+	UpdatedAt     int64
+	LastMsgId     string
+	LastRunAt     int64
+	LastRunStatus string
+}
+
+func (scheduledTaskV1) TableName() string {
+	return "scheduled_tasks"
+}
+
+type deviceV1 struct {
+	ID          string `gorm:"primaryKey;column:id"`
+	Name        string `gorm:"column:name"`
+	SerialPort  string `gorm:"unique;column:serial_port"`
+	Status      string `gorm:"column:status"`
+	PhoneNumber string `gorm:"column:phone_number"`
+	IMSI        string `gorm:"column:imsi"`
+	ICCID       string `gorm:"column:iccid"`
+	Operator    string `gorm:"column:operator"`
+	SimOperator string `gorm:"column:sim_operator"`
+	LAC         int    `gorm:"column:lac"`
+	CID         int    `gorm:"column:cid"`
+	SignalLevel int    `gorm:"column:signal_level"`
+	Flymode     bool   `gorm:"column:flymode"`
+	Enabled     bool   `gorm:"column:enabled"`
+	GroupName   string `gorm:"column:group_name"`
+	LastSeenAt  int64  `gorm:"column:last_seen_at"`
+	CreatedAt   int64  `gorm:"column:created_at"`
+	UpdatedAt   int64  `gorm:"column:updated_at"`
+}
+
+func (deviceV1) TableName() string {
+	return "devices"
+}
+
+type conversationStateV1 struct {
+	Peer      string `gorm:"primaryKey"`
+	Pinned    bool   `gorm:"index"`
+	Archived  bool   `gorm:"index"`
+	Muted     bool
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+func (conversationStateV1) TableName() string {
+	return "conversation_states"
+}
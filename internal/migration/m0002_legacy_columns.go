@@ -0,0 +1,43 @@
+package migration
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// legacyColumns 将旧版本字段 from/to/icc_id 的数据迁移到 from_number/to_number/iccid，并填充会话对方号码 peer
+// 旧字段保留不删除，回滚时无需处理
+var legacyColumns = Migration{
+	Version: 2,
+	Name:    "legacy_columns",
+	Up: func(tx *gorm.DB) error {
+		copies := []struct{ table, from, to string }{
+			{"text_messages", "from", "from_number"},
+			{"text_messages", "to", "to_number"},
+			{"devices", "icc_id", "iccid"},
+		}
+		migrator := tx.Migrator()
+		for _, c := range copies {
+			// 全新安装没有旧字段
+			if !migrator.HasColumn(c.table, c.from) {
+				continue
+			}
+			// from/to 为保留字，由 gorm 按方言加引号
+			from := clause.Column{Name: c.from}
+			to := clause.Column{Name: c.to}
+			err := tx.Exec("UPDATE ? SET ? = ? WHERE (? IS NULL OR ? = '') AND ? IS NOT NULL AND ? != ''",
+				clause.Table{Name: c.table}, to, from, to, to, from, from).Error
+			if err != nil {
+				return fmt.Errorf("迁移 %s.%s 字段失败: %w", c.table, c.from, err)
+			}
+		}
+
+		// 会话列表按 peer 聚合 MAX(created_at) 取最新短信，分页索引同样依赖 peer
+		return tx.Exec("UPDATE text_messages SET peer = CASE WHEN type = 'incoming' THEN from_number WHEN type = 'outgoing' THEN to_number ELSE '' END WHERE peer IS NULL OR peer = ''").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
@@ -0,0 +1,52 @@
+package migration
+
+import "gorm.io/gorm"
+
+// taskSchedules 定时任务支持多种调度方式，已有任务转换为按天间隔
+var taskSchedules = Migration{
+	Version: 3,
+	Name:    "task_schedules",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&scheduledTaskV3{}); err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE scheduled_tasks SET schedule_type = 'interval', interval_value = interval_days, interval_unit = 'days' WHERE schedule_type IS NULL OR schedule_type = ''").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, column := range []string{"schedule_type", "cron_expr", "timezone", "interval_value", "interval_unit", "run_at", "month_week", "weekday", "time_of_day"} {
+			if err := migrator.DropColumn(&scheduledTaskV3{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+type scheduledTaskV3 struct {
+	ID            string `gorm:"primaryKey"`
+	Name          string
+	Enabled       bool
+	IntervalDays  int
+	PhoneNumber   string
+	Content       string `gorm:"type:text"`
+	DeviceID      string
+	CreatedAt     int64
+	UpdatedAt     int64
+	ScheduleType  string
+	CronExpr      string
+	Timezone      string
+	IntervalValue int
+	IntervalUnit  string
+	RunAt         int64
+	MonthWeek     int
+	Weekday       int
+	TimeOfDay     string
+	LastMsgId     string
+	LastRunAt     int64
+	LastRunStatus string
+}
+
+func (scheduledTaskV3) TableName() string {
+	return "scheduled_tasks"
+}
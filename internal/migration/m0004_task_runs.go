@@ -0,0 +1,45 @@
+package migration
+
+import "gorm.io/gorm"
+
+// taskRuns 定时任务执行记录和连续失败告警阈值
+var taskRuns = Migration{
+	Version: 4,
+	Name:    "task_runs",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&scheduledTaskRunV4{}, &scheduledTaskV4{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropColumn(&scheduledTaskV4{}, "failure_alert_threshold"); err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable(&scheduledTaskRunV4{})
+	},
+}
+
+type scheduledTaskRunV4 struct {
+	ID         string `gorm:"primaryKey;index:idx_scheduled_task_runs_task_time,priority:3"`
+	TaskID     string `gorm:"index:idx_scheduled_task_runs_task_time,priority:1"`
+	Trigger    string
+	DeviceID   string
+	MsgID      string `gorm:"index"`
+	Status     string
+	Error      string `gorm:"type:text"`
+	DurationMs int64
+	CreatedAt  int64 `gorm:"index:idx_scheduled_task_runs_task_time,priority:2"`
+	UpdatedAt  int64
+}
+
+func (scheduledTaskRunV4) TableName() string {
+	return "scheduled_task_runs"
+}
+
+// scheduledTaskV4 只包含新增字段，AutoMigrate 不会删除其他列
+type scheduledTaskV4 struct {
+	ID                    string `gorm:"primaryKey"`
+	FailureAlertThreshold int
+}
+
+func (scheduledTaskV4) TableName() string {
+	return "scheduled_tasks"
+}
@@ -0,0 +1,72 @@
+package migration
+
+import "gorm.io/gorm"
+
+// taskActions 联系人分组、定时任务多收件人和多种执行动作
+var taskActions = Migration{
+	Version: 5,
+	Name:    "task_actions",
+	Up: func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&contactV5{}, &scheduledTaskV5{}, &scheduledTaskRunV5{}); err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE scheduled_tasks SET action_type = 'sms' WHERE action_type IS NULL OR action_type = ''").Error
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, column := range []string{"action_type", "recipients", "contact_group", "flymode", "ussd_code", "webhook_url", "webhook_method", "webhook_body", "run_count"} {
+			if err := migrator.DropColumn(&scheduledTaskV5{}, column); err != nil {
+				return err
+			}
+		}
+		for _, column := range []string{"action_type", "recipient", "output"} {
+			if err := migrator.DropColumn(&scheduledTaskRunV5{}, column); err != nil {
+				return err
+			}
+		}
+		return migrator.DropTable(&contactV5{})
+	},
+}
+
+type contactV5 struct {
+	ID          string `gorm:"primaryKey"`
+	Name        string
+	PhoneNumber string
+	GroupName   string `gorm:"index"`
+	Remark      string
+	CreatedAt   int64
+	UpdatedAt   int64
+}
+
+func (contactV5) TableName() string {
+	return "contacts"
+}
+
+// scheduledTaskV5 只包含新增字段，AutoMigrate 不会删除其他列
+type scheduledTaskV5 struct {
+	ID            string `gorm:"primaryKey"`
+	ActionType    string
+	Recipients    string `gorm:"type:text"`
+	ContactGroup  string
+	Flymode       bool
+	UssdCode      string
+	WebhookURL    string `gorm:"column:webhook_url"`
+	WebhookMethod string
+	WebhookBody   string `gorm:"type:text"`
+	RunCount      int64
+}
+
+func (scheduledTaskV5) TableName() string {
+	return "scheduled_tasks"
+}
+
+type scheduledTaskRunV5 struct {
+	ID         string `gorm:"primaryKey"`
+	ActionType string
+	Recipient  string
+	Output     string `gorm:"type:text"`
+}
+
+func (scheduledTaskRunV5) TableName() string {
+	return "scheduled_task_runs"
+}
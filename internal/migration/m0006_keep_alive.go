@@ -0,0 +1,34 @@
+package migration
+
+import "gorm.io/gorm"
+
+// keepAlive SIM 卡保号策略
+var keepAlive = Migration{
+	Version: 6,
+	Name:    "keep_alive",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&keepAlivePolicyV6{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&keepAlivePolicyV6{})
+	},
+}
+
+type keepAlivePolicyV6 struct {
+	DeviceID     string `gorm:"primaryKey"`
+	Enabled      bool
+	Activity     string
+	IntervalDays int
+	LeadDays     int
+	PhoneNumber  string
+	Content      string `gorm:"type:text"`
+	UssdCode     string
+	CheckTime    string
+	TaskID       string `gorm:"index"`
+	CreatedAt    int64
+	UpdatedAt    int64
+}
+
+func (keepAlivePolicyV6) TableName() string {
+	return "keep_alive_policies"
+}
@@ -0,0 +1,56 @@
+package migration
+
+import "gorm.io/gorm"
+
+// campaigns 定时群发活动
+var campaigns = Migration{
+	Version: 7,
+	Name:    "campaigns",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&campaignV7{}, &campaignRecipientV7{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&campaignRecipientV7{}, &campaignV7{})
+	},
+}
+
+type campaignV7 struct {
+	ID            string `gorm:"primaryKey"`
+	Name          string
+	Content       string `gorm:"type:text"`
+	DeviceID      string
+	Strategy      string
+	StartAt       int64
+	QuietStart    string
+	QuietEnd      string
+	Timezone      string
+	RatePerMinute int
+	Status        string `gorm:"index"`
+	StartedAt     int64
+	FinishedAt    int64
+	CreatedAt     int64
+	UpdatedAt     int64
+}
+
+func (campaignV7) TableName() string {
+	return "campaigns"
+}
+
+type campaignRecipientV7 struct {
+	ID          string `gorm:"primaryKey"`
+	CampaignID  string `gorm:"index:idx_campaign_recipients_campaign_status,priority:1"`
+	Seq         int
+	PhoneNumber string
+	Vars        string `gorm:"type:text"`
+	Status      string `gorm:"index:idx_campaign_recipients_campaign_status,priority:2"`
+	MsgID       string `gorm:"index"`
+	DeviceID    string
+	Error       string `gorm:"type:text"`
+	SentAt      int64  `gorm:"index:idx_campaign_recipients_campaign_status,priority:3"`
+	CreatedAt   int64
+	UpdatedAt   int64
+}
+
+func (campaignRecipientV7) TableName() string {
+	return "campaign_recipients"
+}
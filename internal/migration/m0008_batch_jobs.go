@@ -0,0 +1,50 @@
+package migration
+
+import "gorm.io/gorm"
+
+// batchJobs 后台批量发送任务
+var batchJobs = Migration{
+	Version: 8,
+	Name:    "batch_jobs",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&batchJobV8{}, &batchJobResultV8{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&batchJobResultV8{}, &batchJobV8{})
+	},
+}
+
+type batchJobV8 struct {
+	ID             string `gorm:"primaryKey"`
+	Content        string `gorm:"type:text"`
+	DeviceID       string
+	Strategy       string
+	CallbackURL    string `gorm:"column:callback_url"`
+	CallbackStatus string
+	Status         string `gorm:"index"`
+	Total          int
+	FinishedAt     int64
+	CreatedAt      int64
+	UpdatedAt      int64
+}
+
+func (batchJobV8) TableName() string {
+	return "batch_jobs"
+}
+
+type batchJobResultV8 struct {
+	ID        string `gorm:"primaryKey"`
+	JobID     string `gorm:"index:idx_batch_job_results_job_seq,priority:1"`
+	Seq       int    `gorm:"index:idx_batch_job_results_job_seq,priority:2"`
+	Recipient string
+	Status    string
+	MessageID string
+	DeviceID  string
+	Error     string `gorm:"type:text"`
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+func (batchJobResultV8) TableName() string {
+	return "batch_job_results"
+}
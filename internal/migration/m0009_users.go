@@ -0,0 +1,31 @@
+package migration
+
+import "gorm.io/gorm"
+
+// users 数据库用户和角色，取代配置文件中的静态用户
+var users = Migration{
+	Version: 9,
+	Name:    "users",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&userV9{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&userV9{})
+	},
+}
+
+type userV9 struct {
+	ID        string `gorm:"primaryKey"`
+	Username  string `gorm:"uniqueIndex"`
+	Nickname  string
+	Password  string
+	Role      string
+	Source    string
+	LastLogin int64
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+func (userV9) TableName() string {
+	return "users"
+}
@@ -0,0 +1,27 @@
+package migration
+
+import "gorm.io/gorm"
+
+// deviceGrants 用户的设备和设备分组访问授权
+var deviceGrants = Migration{
+	Version: 10,
+	Name:    "device_grants",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&deviceGrantV10{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&deviceGrantV10{})
+	},
+}
+
+type deviceGrantV10 struct {
+	ID        string `gorm:"primaryKey"`
+	UserID    string `gorm:"index"`
+	DeviceID  string
+	GroupName string
+	CreatedAt int64
+}
+
+func (deviceGrantV10) TableName() string {
+	return "device_grants"
+}
@@ -0,0 +1,34 @@
+package migration
+
+import "gorm.io/gorm"
+
+// auditLogs 审计日志
+var auditLogs = Migration{
+	Version: 11,
+	Name:    "audit_logs",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&auditLogV11{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&auditLogV11{})
+	},
+}
+
+type auditLogV11 struct {
+	ID        string `gorm:"primaryKey"`
+	Actor     string `gorm:"index"`
+	Action    string `gorm:"index"`
+	Target    string `gorm:"index"`
+	IP        string
+	Method    string
+	Path      string
+	Params    string
+	Status    int
+	Success   bool
+	Error     string
+	CreatedAt int64 `gorm:"index"`
+}
+
+func (auditLogV11) TableName() string {
+	return "audit_logs"
+}
@@ -0,0 +1,31 @@
+package migration
+
+import "gorm.io/gorm"
+
+// sessions 登录会话和刷新令牌
+var sessions = Migration{
+	Version: 12,
+	Name:    "sessions",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&sessionV12{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&sessionV12{})
+	},
+}
+
+type sessionV12 struct {
+	ID                string `gorm:"primaryKey"`
+	UserID            string `gorm:"index"`
+	RefreshTokenHash  string `gorm:"uniqueIndex"`
+	PreviousTokenHash string `gorm:"index"`
+	IP                string
+	UserAgent         string
+	CreatedAt         int64
+	LastUsedAt        int64
+	ExpiresAt         int64
+}
+
+func (sessionV12) TableName() string {
+	return "sessions"
+}
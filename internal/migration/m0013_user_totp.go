@@ -0,0 +1,43 @@
+package migration
+
+import "gorm.io/gorm"
+
+// userTOTP 用户两步验证（TOTP）密钥和恢复码
+var userTOTP = Migration{
+	Version: 13,
+	Name:    "user_totp",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&userV13{})
+	},
+	Down: func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+		for _, column := range []string{"totp_enabled", "totp_secret", "totp_last_step", "recovery_codes"} {
+			if migrator.HasColumn(&userV13{}, column) {
+				if err := migrator.DropColumn(&userV13{}, column); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
+
+type userV13 struct {
+	ID            string `gorm:"primaryKey"`
+	Username      string `gorm:"uniqueIndex"`
+	Nickname      string
+	Password      string
+	Role          string
+	Source        string
+	LastLogin     int64
+	TOTPEnabled   bool
+	TOTPSecret    string
+	TOTPLastStep  int64
+	RecoveryCodes string `gorm:"type:text"`
+	CreatedAt     int64
+	UpdatedAt     int64
+}
+
+func (userV13) TableName() string {
+	return "users"
+}
@@ -0,0 +1,35 @@
+package migration
+
+import "gorm.io/gorm"
+
+// sessionIDToken 会话保存 OIDC ID Token，用于退出 IdP 会话
+var sessionIDToken = Migration{
+	Version: 14,
+	Name:    "session_id_token",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&sessionV14{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&sessionV14{}, "id_token") {
+			return tx.Migrator().DropColumn(&sessionV14{}, "id_token")
+		}
+		return nil
+	},
+}
+
+type sessionV14 struct {
+	ID                string `gorm:"primaryKey"`
+	UserID            string `gorm:"index"`
+	RefreshTokenHash  string `gorm:"uniqueIndex"`
+	PreviousTokenHash string `gorm:"index"`
+	IP                string
+	UserAgent         string
+	CreatedAt         int64
+	LastUsedAt        int64
+	ExpiresAt         int64
+	IDToken           string `gorm:"type:text"`
+}
+
+func (sessionV14) TableName() string {
+	return "sessions"
+}
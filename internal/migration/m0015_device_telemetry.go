@@ -0,0 +1,36 @@
+package migration
+
+import "gorm.io/gorm"
+
+// deviceTelemetry 设备遥测时间序列
+var deviceTelemetry = Migration{
+	Version: 15,
+	Name:    "device_telemetry",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&deviceTelemetryV15{})
+	},
+	Down: func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(&deviceTelemetryV15{})
+	},
+}
+
+type deviceTelemetryV15 struct {
+	ID          string `gorm:"primaryKey"`
+	DeviceID    string `gorm:"index:idx_device_telemetry_lookup,priority:1"`
+	Resolution  string `gorm:"index:idx_device_telemetry_lookup,priority:2"`
+	SampledAt   int64  `gorm:"index:idx_device_telemetry_lookup,priority:3"`
+	Samples     int
+	SignalLevel float64
+	Rssi        float64
+	Rsrp        float64
+	Rsrq        float64
+	Csq         float64
+	Lac         int
+	Cid         int
+	MemKb       float64
+	Uptime      int64
+}
+
+func (deviceTelemetryV15) TableName() string {
+	return "device_telemetry"
+}
@@ -0,0 +1,29 @@
+package migration
+
+import "gorm.io/gorm"
+
+// taskRunExecution 执行记录保存执行序号，多个收件人的记录按执行分组统计连续失败
+// 旧记录无法还原所属的执行，保留为 0，不参与统计
+var taskRunExecution = Migration{
+	Version: 16,
+	Name:    "task_run_execution",
+	Up: func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&scheduledTaskRunV16{})
+	},
+	Down: func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(&scheduledTaskRunV16{}, "execution") {
+			return tx.Migrator().DropColumn(&scheduledTaskRunV16{}, "execution")
+		}
+		return nil
+	},
+}
+
+// scheduledTaskRunV16 只包含新增字段，AutoMigrate 不会删除其他列
+type scheduledTaskRunV16 struct {
+	ID        string `gorm:"primaryKey"`
+	Execution int64
+}
+
+func (scheduledTaskRunV16) TableName() string {
+	return "scheduled_task_runs"
+}
@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"fmt"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"gorm.io/gorm"
+)
+
+// normalizePeerBatchSize 规范化 peer 时每批读取的短信数量
+const normalizePeerBatchSize = 500
+
+// normalizePeer 将已有短信的 peer 按 models.NormalizePeer 规范化，
+// 使旧数据中带空格、横线的号码与新写入的短信归为同一会话，并能匹配按规范化号码查询的接口
+// 规范化规则在 Go 中实现，无法用 SQL 表达，按主键分批读取后逐条更新
+var normalizePeer = Migration{
+	Version: 17,
+	Name:    "normalize_peer",
+	Up: func(tx *gorm.DB) error {
+		lastID := ""
+		for {
+			var rows []struct {
+				ID   string
+				Peer string
+			}
+			err := tx.Table("text_messages").Select("id, peer").
+				Where("id > ?", lastID).
+				Order("id").Limit(normalizePeerBatchSize).
+				Scan(&rows).Error
+			if err != nil {
+				return fmt.Errorf("读取短信失败: %w", err)
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			for _, row := range rows {
+				peer := models.NormalizePeer(row.Peer)
+				if peer == row.Peer {
+					continue
+				}
+				if err := tx.Table("text_messages").Where("id = ?", row.ID).Update("peer", peer).Error; err != nil {
+					return fmt.Errorf("规范化短信 %s 的 peer 失败: %w", row.ID, err)
+				}
+			}
+			lastID = rows[len(rows)-1].ID
+		}
+	},
+	// 规范化前的号码仍保存在 from_number/to_number 中，回滚时无需处理
+	Down: func(tx *gorm.DB) error {
+		return nil
+	},
+}
@@ -0,0 +1,22 @@
+package migration
+
+// migrations 内置迁移列表，新增迁移追加到末尾，已发布的迁移不可修改
+var migrations = []Migration{
+	initialSchema,
+	legacyColumns,
+	taskSchedules,
+	taskRuns,
+	taskActions,
+	keepAlive,
+	campaigns,
+	batchJobs,
+	users,
+	deviceGrants,
+	auditLogs,
+	sessions,
+	userTOTP,
+	sessionIDToken,
+	deviceTelemetry,
+	taskRunExecution,
+	normalizePeer,
+}
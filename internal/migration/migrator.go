@@ -0,0 +1,205 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrIrreversible 迁移不支持回滚
+	ErrIrreversible = errors.New("迁移不支持回滚")
+	// ErrSchemaTooNew 数据库中存在程序不认识的迁移版本（数据库由更新版本的程序升级过）
+	ErrSchemaTooNew = errors.New("数据库版本高于程序版本，请使用新版本程序执行 migrate down 后再降级")
+)
+
+// Migration 版本化迁移，版本号递增且发布后不可修改
+type Migration struct {
+	Version int64                   // 版本号
+	Name    string                  // 迁移名称
+	Up      func(tx *gorm.DB) error // 升级
+	Down    func(tx *gorm.DB) error // 回滚，为 nil 表示不可回滚
+}
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int64  `gorm:"primaryKey;autoIncrement:false" json:"version"` // 版本号
+	Name      string `json:"name"`                                          // 迁移名称
+	AppliedAt int64  `json:"appliedAt"`                                     // 执行时间（时间戳毫秒）
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   int64  `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt int64  `json:"appliedAt"`
+	Unknown   bool   `json:"unknown"` // 数据库中存在但程序中没有的迁移
+}
+
+// Migrator 执行版本化迁移
+// 每个迁移在独立事务中执行（MySQL 的 DDL 会隐式提交，失败时可能需要手动修复）
+type Migrator struct {
+	logger     *zap.Logger
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New 使用内置迁移列表创建 Migrator
+func New(logger *zap.Logger, db *gorm.DB) *Migrator {
+	return NewWithMigrations(logger, db, migrations)
+}
+
+// NewWithMigrations 使用指定迁移列表创建 Migrator
+func NewWithMigrations(logger *zap.Logger, db *gorm.DB, list []Migration) *Migrator {
+	sorted := make([]Migration, len(list))
+	copy(sorted, list)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{
+		logger:     logger,
+		db:         db,
+		migrations: sorted,
+	}
+}
+
+// applied 查询已执行的迁移（按版本号正序）
+func (m *Migrator) applied(ctx context.Context) ([]SchemaMigration, error) {
+	db := m.db.WithContext(ctx)
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("创建迁移记录表失败: %w", err)
+	}
+	var records []SchemaMigration
+	err := db.Order("version ASC").Find(&records).Error
+	return records, err
+}
+
+// Up 按版本顺序执行所有未执行的迁移，返回执行的迁移数量
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	records, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	done := make(map[int64]bool, len(records))
+	for _, record := range records {
+		done[record.Version] = true
+	}
+	if len(records) > 0 && len(m.migrations) > 0 &&
+		records[len(records)-1].Version > m.migrations[len(m.migrations)-1].Version {
+		return 0, ErrSchemaTooNew
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if done[migration.Version] {
+			continue
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UnixMilli(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("执行迁移 %d_%s 失败: %w", migration.Version, migration.Name, err)
+		}
+		m.logger.Info("执行数据库迁移", zap.Int64("version", migration.Version), zap.String("name", migration.Name))
+		count++
+	}
+	return count, nil
+}
+
+// Down 按版本倒序回滚最近执行的 steps 个迁移，返回回滚的迁移数量
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	records, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	count := 0
+	for i := len(records) - 1; i >= 0 && count < steps; i-- {
+		record := records[i]
+		migration, ok := known[record.Version]
+		if !ok {
+			return count, fmt.Errorf("回滚迁移 %d_%s 失败: %w", record.Version, record.Name, ErrSchemaTooNew)
+		}
+		if migration.Down == nil {
+			return count, fmt.Errorf("回滚迁移 %d_%s 失败: %w", record.Version, record.Name, ErrIrreversible)
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Where("version = ?", record.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("回滚迁移 %d_%s 失败: %w", record.Version, record.Name, err)
+		}
+		m.logger.Info("回滚数据库迁移", zap.Int64("version", record.Version), zap.String("name", record.Name))
+		count++
+	}
+	return count, nil
+}
+
+// Status 返回所有迁移的执行状态（按版本号正序）
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	records, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		record, ok := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: record.AppliedAt,
+		})
+	}
+	for _, record := range records {
+		if !known[record.Version] {
+			statuses = append(statuses, Status{
+				Version:   record.Version,
+				Name:      record.Name,
+				Applied:   true,
+				AppliedAt: record.AppliedAt,
+				Unknown:   true,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Latest 返回内置迁移的最新版本号
+func Latest() int64 {
+	var latest int64
+	for _, migration := range migrations {
+		latest = max(latest, migration.Version)
+	}
+	return latest
+}
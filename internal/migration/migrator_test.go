@@ -0,0 +1,217 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 按 SMSHUB_TEST_DB/SMSHUB_TEST_DSN 选择测试数据库，默认使用 SQLite 内存数据库
+func setupTestDB(t *testing.T) *gorm.DB {
+	var dialector gorm.Dialector
+	switch backend := os.Getenv("SMSHUB_TEST_DB"); backend {
+	case "", "sqlite":
+		dialector = sqlite.Open(fmt.Sprintf("file:migration_%d?mode=memory&cache=shared", time.Now().UnixNano()))
+	case "mysql":
+		dialector = mysql.Open(os.Getenv("SMSHUB_TEST_DSN"))
+	case "postgres":
+		dialector = postgres.Open(os.Getenv("SMSHUB_TEST_DSN"))
+	default:
+		t.Fatalf("不支持的测试数据库: %s", backend)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+	db.Migrator().DropTable("schema_migrations", "properties", "text_messages", "scheduled_tasks", "devices", "conversation_states")
+	return db
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	db := setupTestDB(t)
+	migrator := New(zap.NewNop(), db)
+	ctx := context.Background()
+
+	count, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	if count != len(migrations) {
+		t.Errorf("执行迁移数期望 %d，实际 %d", len(migrations), count)
+	}
+
+	// 重复执行不会再次迁移
+	if count, err := migrator.Up(ctx); err != nil || count != 0 {
+		t.Errorf("重复执行迁移期望 0，实际 %d, err=%v", count, err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("查询迁移状态失败: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt == 0 {
+			t.Errorf("迁移 %d 应已执行", status.Version)
+		}
+	}
+
+	if count, err := migrator.Down(ctx, 1); err != nil || count != 1 {
+		t.Fatalf("回滚 1 个迁移失败: count=%d err=%v", count, err)
+	}
+	statuses, _ = migrator.Status(ctx)
+	if last := statuses[len(statuses)-1]; last.Applied {
+		t.Errorf("迁移 %d 应已回滚", last.Version)
+	}
+
+	// 回滚全部迁移后表被删除
+	if _, err := migrator.Down(ctx, len(migrations)); err != nil {
+		t.Fatalf("回滚全部迁移失败: %v", err)
+	}
+	if db.Migrator().HasTable("text_messages") {
+		t.Error("回滚初始迁移后 text_messages 表应被删除")
+	}
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	db := setupTestDB(t)
+	if _, err := New(zap.NewNop(), db).Up(context.Background()); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+
+	// 模型新增的字段和索引必须有对应的迁移
+	for _, model := range []any{
+		&models.Property{},
+		&models.TextMessage{},
+		&models.ScheduledTask{},
+		&models.ScheduledTaskRun{},
+		&models.Contact{},
+		&models.KeepAlivePolicy{},
+		&models.Campaign{},
+		&models.CampaignRecipient{},
+		&models.BatchJob{},
+		&models.BatchJobResult{},
+		&models.User{},
+		&models.DeviceGrant{},
+		&models.AuditLog{},
+		&models.Session{},
+		&models.Device{},
+		&models.ConversationState{},
+		&models.DeviceTelemetry{},
+	} {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("解析模型失败: %v", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("表 %s 缺少字段 %s，请添加迁移", stmt.Schema.Table, field.DBName)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !db.Migrator().HasIndex(model, index.Name) {
+				t.Errorf("表 %s 缺少索引 %s，请添加迁移", stmt.Schema.Table, index.Name)
+			}
+		}
+	}
+}
+
+// legacyTextMessage 旧版本短信表结构（from/to 为保留字，没有 read_at）
+type legacyTextMessage struct {
+	ID        string `gorm:"primaryKey"`
+	From      string `gorm:"column:from"`
+	To        string `gorm:"column:to"`
+	Type      string
+	CreatedAt int64
+}
+
+func (legacyTextMessage) TableName() string {
+	return "text_messages"
+}
+
+type legacyDevice struct {
+	ID    string `gorm:"primaryKey"`
+	IccID string `gorm:"column:icc_id"`
+}
+
+func (legacyDevice) TableName() string {
+	return "devices"
+}
+
+func TestMigrateLegacySchema(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	if err := db.AutoMigrate(&legacyTextMessage{}, &legacyDevice{}); err != nil {
+		t.Fatalf("创建旧表结构失败: %v", err)
+	}
+	db.Create(&legacyTextMessage{ID: "in", From: "10086", To: "13800138000", Type: "incoming", CreatedAt: 100})
+	db.Create(&legacyTextMessage{ID: "out", From: "13800138000", To: "10010", Type: "outgoing", CreatedAt: 200})
+	db.Create(&legacyTextMessage{ID: "pretty", From: "+86 138-0000-0000", To: "10010", Type: "incoming", CreatedAt: 300})
+	db.Create(&legacyDevice{ID: "dev", IccID: "8986"})
+
+	if _, err := New(zap.NewNop(), db).Up(ctx); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+
+	var msgs []models.TextMessage
+	if err := db.Order("id").Find(&msgs).Error; err != nil {
+		t.Fatalf("查询短信失败: %v", err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("短信数量期望 3，实际 %d", len(msgs))
+	}
+	in, out, pretty := msgs[0], msgs[1], msgs[2]
+	if in.From != "10086" || in.Peer != "10086" || in.ReadAt != 100 {
+		t.Errorf("接收短信迁移错误: from=%q peer=%q readAt=%d", in.From, in.Peer, in.ReadAt)
+	}
+	if out.To != "10010" || out.Peer != "10010" || out.ReadAt != 0 {
+		t.Errorf("发送短信迁移错误: to=%q peer=%q readAt=%d", out.To, out.Peer, out.ReadAt)
+	}
+	// 带格式的旧号码保留原值，peer 按规范化后的号码保存
+	if pretty.From != "+86 138-0000-0000" || pretty.Peer != "+8613800000000" {
+		t.Errorf("带格式号码迁移错误: from=%q peer=%q", pretty.From, pretty.Peer)
+	}
+
+	var device models.Device
+	if err := db.First(&device, "id = ?", "dev").Error; err != nil {
+		t.Fatalf("查询设备失败: %v", err)
+	}
+	if device.ICCID != "8986" {
+		t.Errorf("iccid 期望 8986，实际 %q", device.ICCID)
+	}
+}
+
+func TestMigratorRejectsNewerSchema(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	newer := append([]Migration{}, migrations...)
+	newer = append(newer, Migration{Version: 9999, Name: "future", Up: func(tx *gorm.DB) error { return nil }})
+	if _, err := NewWithMigrations(zap.NewNop(), db, newer).Up(ctx); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+
+	migrator := New(zap.NewNop(), db)
+	if _, err := migrator.Up(ctx); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("期望 ErrSchemaTooNew，实际 %v", err)
+	}
+	statuses, _ := migrator.Status(ctx)
+	if last := statuses[len(statuses)-1]; !last.Unknown {
+		t.Error("未知迁移应标记为 unknown")
+	}
+	if _, err := migrator.Down(ctx, 1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("回滚未知迁移期望 ErrSchemaTooNew，实际 %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+// AuditLog 审计日志，记录每次修改类请求的操作人、动作、对象和结果
+type AuditLog struct {
+	ID        string `gorm:"primaryKey" json:"id"`                        // UUID
+	Actor     string `gorm:"index" json:"actor"`                          // 操作人用户名，未登录时为空
+	Action    string `gorm:"index" json:"action"`                         // 动作：请求方法和路由，如 POST /api/devices/:id/reboot
+	Target    string `gorm:"index" json:"target"`                         // 操作对象：路由参数，如设备ID、会话号码
+	IP        string `json:"ip"`                                          // 请求IP（按 server.ip_extractor 提取）
+	Method    string `json:"method"`                                      // HTTP 方法
+	Path      string `json:"path"`                                        // 请求路径
+	Params    string `json:"params"`                                      // 请求参数（JSON，敏感字段已脱敏）
+	Status    int    `json:"status"`                                      // 响应状态码
+	Success   bool   `json:"success"`                                     // 是否成功
+	Error     string `json:"error"`                                       // 失败原因
+	CreatedAt int64  `json:"createdAt" gorm:"autoCreateTime:milli;index"` // 创建时间（时间戳毫秒）
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
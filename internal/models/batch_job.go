@@ -0,0 +1,61 @@
+package models
+
+// BatchJobStatus 批量发送任务状态
+type BatchJobStatus string
+
+const (
+	BatchJobStatusPending   BatchJobStatus = "pending"   // 等待处理
+	BatchJobStatusRunning   BatchJobStatus = "running"   // 发送中
+	BatchJobStatusCompleted BatchJobStatus = "completed" // 已完成
+)
+
+// BatchJobResultStatus 批量发送中单个收件人的结果
+type BatchJobResultStatus string
+
+const (
+	BatchJobResultPending BatchJobResultStatus = "pending" // 等待发送
+	BatchJobResultSuccess BatchJobResultStatus = "success" // 已提交给设备
+	BatchJobResultFailed  BatchJobResultStatus = "failed"  // 发送失败
+)
+
+// BatchJob 后台执行的批量发送任务，服务重启后继续处理未完成的收件人
+type BatchJob struct {
+	ID             string         `gorm:"primaryKey" json:"id"`                   // UUID
+	Content        string         `gorm:"type:text" json:"content"`               // 短信内容
+	DeviceID       string         `json:"deviceId"`                               // 指定发送设备
+	Strategy       string         `json:"strategy"`                               // 设备选择策略
+	CallbackURL    string         `gorm:"column:callback_url" json:"callbackUrl"` // 完成后回调地址
+	CallbackStatus string         `json:"callbackStatus"`                         // 回调结果：响应状态或错误信息
+	Status         BatchJobStatus `gorm:"index" json:"status"`                    // 任务状态
+	Total          int            `json:"total"`                                  // 收件人数量
+	FinishedAt     int64          `json:"finishedAt"`                             // 完成时间（时间戳毫秒）
+	CreatedAt      int64          `json:"createdAt" gorm:"autoCreateTime:milli"`  // 创建时间（时间戳毫秒）
+	UpdatedAt      int64          `json:"updatedAt" gorm:"autoUpdateTime:milli"`  // 更新时间（时间戳毫秒）
+
+	Pending   int64            `gorm:"-" json:"pending"`           // 等待发送数量
+	Succeeded int64            `gorm:"-" json:"succeeded"`         // 成功数量
+	Failed    int64            `gorm:"-" json:"failed"`            // 失败数量
+	Results   []BatchJobResult `gorm:"-" json:"results,omitempty"` // 各收件人结果
+}
+
+func (BatchJob) TableName() string {
+	return "batch_jobs"
+}
+
+// BatchJobResult 批量发送任务中单个收件人的结果
+type BatchJobResult struct {
+	ID        string               `gorm:"primaryKey" json:"-"`                                       // UUID
+	JobID     string               `gorm:"index:idx_batch_job_results_job_seq,priority:1" json:"-"`   // 任务ID
+	Seq       int                  `gorm:"index:idx_batch_job_results_job_seq,priority:2" json:"seq"` // 收件人顺序
+	Recipient string               `json:"recipient"`                                                 // 收件人
+	Status    BatchJobResultStatus `json:"status"`                                                    // 发送结果
+	MessageID string               `json:"messageId"`                                                 // 短信ID
+	DeviceID  string               `json:"deviceId"`                                                  // 发送设备
+	Error     string               `gorm:"type:text" json:"error,omitempty"`                          // 失败原因
+	CreatedAt int64                `json:"-" gorm:"autoCreateTime:milli"`                             // 创建时间（时间戳毫秒）
+	UpdatedAt int64                `json:"updatedAt" gorm:"autoUpdateTime:milli"`                     // 更新时间（时间戳毫秒）
+}
+
+func (BatchJobResult) TableName() string {
+	return "batch_job_results"
+}
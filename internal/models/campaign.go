@@ -0,0 +1,78 @@
+package models
+
+// CampaignStatus 群发活动状态
+type CampaignStatus string
+
+const (
+	CampaignStatusScheduled CampaignStatus = "scheduled" // 等待开始
+	CampaignStatusRunning   CampaignStatus = "running"   // 发送中
+	CampaignStatusPaused    CampaignStatus = "paused"    // 已暂停
+	CampaignStatusCompleted CampaignStatus = "completed" // 已完成
+	CampaignStatusCancelled CampaignStatus = "cancelled" // 已取消
+)
+
+// CampaignRecipientStatus 群发收件人发送状态
+type CampaignRecipientStatus string
+
+const (
+	CampaignRecipientQueued    CampaignRecipientStatus = "queued"    // 排队中
+	CampaignRecipientSent      CampaignRecipientStatus = "sent"      // 已提交给设备，等待发送结果
+	CampaignRecipientDelivered CampaignRecipientStatus = "delivered" // 设备回报发送成功
+	CampaignRecipientFailed    CampaignRecipientStatus = "failed"    // 发送失败
+	CampaignRecipientCancelled CampaignRecipientStatus = "cancelled" // 活动取消，未发送
+)
+
+// Campaign 群发活动：在指定时间开始，按速率限制向收件人列表逐条发送模板短信
+type Campaign struct {
+	ID            string         `gorm:"primaryKey" json:"id"`                  // UUID
+	Name          string         `json:"name"`                                  // 活动名称
+	Content       string         `gorm:"type:text" json:"content"`              // 短信内容模板，支持 {{phone}} 和 CSV 列名变量
+	DeviceID      string         `json:"deviceId"`                              // 指定发送设备，为空时按策略从设备池选择
+	Strategy      string         `json:"strategy"`                              // 设备选择策略，同批量发送
+	StartAt       int64          `json:"startAt"`                               // 开始时间（时间戳毫秒），0 表示立即开始
+	QuietStart    string         `json:"quietStart"`                            // 免打扰开始时刻 HH:MM
+	QuietEnd      string         `json:"quietEnd"`                              // 免打扰结束时刻 HH:MM，可跨午夜
+	Timezone      string         `json:"timezone"`                              // 免打扰时段所在时区，为空使用服务器时区
+	RatePerMinute int            `json:"ratePerMinute"`                         // 每台设备每分钟最多发送条数（同时进行的活动共用设备配额）
+	Status        CampaignStatus `gorm:"index" json:"status"`                   // 活动状态
+	StartedAt     int64          `json:"startedAt"`                             // 实际开始时间
+	FinishedAt    int64          `json:"finishedAt"`                            // 完成或取消时间
+	CreatedAt     int64          `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间（时间戳毫秒）
+	UpdatedAt     int64          `json:"updatedAt" gorm:"autoUpdateTime:milli"` // 更新时间（时间戳毫秒）
+
+	Progress CampaignProgress `gorm:"-" json:"progress"` // 发送进度，不持久化
+}
+
+func (Campaign) TableName() string {
+	return "campaigns"
+}
+
+// CampaignProgress 群发活动各状态的收件人数量
+type CampaignProgress struct {
+	Total     int64 `json:"total"`
+	Queued    int64 `json:"queued"`
+	Sent      int64 `json:"sent"`
+	Delivered int64 `json:"delivered"`
+	Failed    int64 `json:"failed"`
+	Cancelled int64 `json:"cancelled"`
+}
+
+// CampaignRecipient 群发活动的收件人
+type CampaignRecipient struct {
+	ID          string                  `gorm:"primaryKey" json:"id"`                                                       // UUID
+	CampaignID  string                  `gorm:"index:idx_campaign_recipients_campaign_status,priority:1" json:"campaignId"` // 活动ID
+	Seq         int                     `json:"seq"`                                                                        // 发送顺序
+	PhoneNumber string                  `json:"phoneNumber"`                                                                // 收件人号码
+	Vars        map[string]string       `gorm:"serializer:json;type:text" json:"vars"`                                      // 模板变量（CSV 各列）
+	Status      CampaignRecipientStatus `gorm:"index:idx_campaign_recipients_campaign_status,priority:2" json:"status"`     // 发送状态
+	MsgID       string                  `gorm:"index" json:"msgId"`                                                         // 短信ID
+	DeviceID    string                  `json:"deviceId"`                                                                   // 发送设备（单设备模式为空）
+	Error       string                  `gorm:"type:text" json:"error"`                                                     // 失败原因
+	SentAt      int64                   `gorm:"index:idx_campaign_recipients_campaign_status,priority:3" json:"sentAt"`     // 提交发送时间（时间戳毫秒）
+	CreatedAt   int64                   `json:"createdAt" gorm:"autoCreateTime:milli"`                                      // 创建时间（时间戳毫秒）
+	UpdatedAt   int64                   `json:"updatedAt" gorm:"autoUpdateTime:milli"`                                      // 更新时间（时间戳毫秒）
+}
+
+func (CampaignRecipient) TableName() string {
+	return "campaign_recipients"
+}
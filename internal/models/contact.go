@@ -0,0 +1,16 @@
+package models
+
+// Contact 联系人，按分组作为定时任务等的收件人
+type Contact struct {
+	ID          string `gorm:"primaryKey" json:"id"`                  // UUID
+	Name        string `json:"name"`                                  // 姓名
+	PhoneNumber string `json:"phoneNumber"`                           // 手机号
+	GroupName   string `gorm:"index" json:"groupName"`                // 分组
+	Remark      string `json:"remark"`                                // 备注
+	CreatedAt   int64  `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间（时间戳毫秒）
+	UpdatedAt   int64  `json:"updatedAt" gorm:"autoUpdateTime:milli"` // 更新时间（时间戳毫秒）
+}
+
+func (Contact) TableName() string {
+	return "contacts"
+}
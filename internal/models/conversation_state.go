@@ -0,0 +1,34 @@
+package models
+
+import "strings"
+
+// ConversationState 会话状态（置顶、归档、免打扰），按规范化后的对方号码存储
+type ConversationState struct {
+	Peer      string `gorm:"primaryKey" json:"peer"`                // 规范化后的对方号码
+	Pinned    bool   `gorm:"index" json:"pinned"`                   // 是否置顶
+	Archived  bool   `gorm:"index" json:"archived"`                 // 是否归档
+	Muted     bool   `json:"muted"`                                 // 是否免打扰（不发送通知）
+	CreatedAt int64  `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间（时间戳毫秒）
+	UpdatedAt int64  `json:"updatedAt" gorm:"autoUpdateTime:milli"` // 更新时间（时间戳毫秒）
+}
+
+func (ConversationState) TableName() string {
+	return "conversation_states"
+}
+
+// NormalizePeer 规范化号码：去除首尾空白，纯号码时去掉空格、横线、括号等分隔符
+// 字母类发送方（如运营商名称）只去除首尾空白
+func NormalizePeer(peer string) string {
+	peer = strings.TrimSpace(peer)
+	for _, r := range peer {
+		if (r < '0' || r > '9') && !strings.ContainsRune("+ -().", r) {
+			return peer
+		}
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -().", r) {
+			return -1
+		}
+		return r
+	}, peer)
+}
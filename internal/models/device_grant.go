@@ -0,0 +1,15 @@
+package models
+
+// DeviceGrant 用户的设备访问授权，授权单个设备或整个设备分组
+// 没有任何授权的非管理员用户看不到任何设备和短信，管理员不受限制
+type DeviceGrant struct {
+	ID        string `gorm:"primaryKey" json:"id"`                  // UUID
+	UserID    string `gorm:"index" json:"userId"`                   // 用户ID
+	DeviceID  string `json:"deviceId"`                              // 授权的设备ID，与 GroupName 二选一
+	GroupName string `json:"groupName"`                             // 授权的设备分组，分组内之后新增的设备同样可以访问
+	CreatedAt int64  `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间（时间戳毫秒）
+}
+
+func (DeviceGrant) TableName() string {
+	return "device_grants"
+}
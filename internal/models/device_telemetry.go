@@ -0,0 +1,33 @@
+package models
+
+// TelemetryResolution 遥测数据的精度
+type TelemetryResolution string
+
+const (
+	TelemetryResolutionRaw    TelemetryResolution = "raw" // 原始采样
+	TelemetryResolution5Min   TelemetryResolution = "5m"  // 5 分钟平均
+	TelemetryResolutionHourly TelemetryResolution = "1h"  // 1 小时平均
+)
+
+// DeviceTelemetry 设备遥测数据，原始采样来自设备状态上报，按 5 分钟和 1 小时汇总
+// 汇总数据的数值指标为区间内的平均值，LAC/CID 和开机时长取区间内最后一个值
+type DeviceTelemetry struct {
+	ID          string              `gorm:"primaryKey" json:"-"`                                                             // UUID
+	DeviceID    string              `gorm:"index:idx_device_telemetry_lookup,priority:1" json:"deviceId"`                    // 设备ID
+	Resolution  TelemetryResolution `gorm:"index:idx_device_telemetry_lookup,priority:2" json:"resolution"`                  // 精度：raw/5m/1h
+	Timestamp   int64               `gorm:"column:sampled_at;index:idx_device_telemetry_lookup,priority:3" json:"timestamp"` // 采样时间或汇总区间开始时间（时间戳毫秒）
+	Samples     int                 `json:"samples"`                                                                         // 汇总的原始采样数
+	SignalLevel float64             `json:"signalLevel"`                                                                     // 信号等级
+	Rssi        float64             `json:"rssi"`                                                                            // RSSI
+	Rsrp        float64             `json:"rsrp"`                                                                            // RSRP
+	Rsrq        float64             `json:"rsrq"`                                                                            // RSRQ
+	Csq         float64             `json:"csq"`                                                                             // CSQ
+	Lac         int                 `json:"lac"`                                                                             // LAC/TAC
+	Cid         int                 `json:"cid"`                                                                             // CellID
+	MemKb       float64             `json:"memKb"`                                                                           // 模块内存占用（KB）
+	Uptime      int64               `json:"uptime"`                                                                          // 模块开机时长（秒）
+}
+
+func (DeviceTelemetry) TableName() string {
+	return "device_telemetry"
+}
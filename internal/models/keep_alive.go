@@ -0,0 +1,21 @@
+package models
+
+// KeepAlivePolicy SIM 卡保号策略，每个设备一条，自动生成绑定该设备的定时任务
+type KeepAlivePolicy struct {
+	DeviceID     string         `gorm:"primaryKey" json:"deviceId"`            // 设备ID
+	Enabled      bool           `json:"enabled"`                               // 是否启用
+	Activity     TaskActionType `json:"activity"`                              // 保号动作：sms 或 ussd
+	IntervalDays int            `json:"intervalDays"`                          // 运营商要求的活动间隔天数，例如 180
+	LeadDays     int            `json:"leadDays"`                              // 提前多少天执行保号动作
+	PhoneNumber  string         `json:"phoneNumber"`                           // 保号短信目标号码（sms）
+	Content      string         `gorm:"type:text" json:"content"`              // 保号短信内容，支持定时任务模板变量（sms）
+	UssdCode     string         `json:"ussdCode"`                              // USSD 代码（ussd）
+	CheckTime    string         `json:"checkTime"`                             // 每天检查的时刻 HH:MM
+	TaskID       string         `gorm:"index" json:"taskId"`                   // 自动生成的定时任务ID
+	CreatedAt    int64          `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间（时间戳毫秒）
+	UpdatedAt    int64          `json:"updatedAt" gorm:"autoUpdateTime:milli"` // 更新时间（时间戳毫秒）
+}
+
+func (KeepAlivePolicy) TableName() string {
+	return "keep_alive_policies"
+}
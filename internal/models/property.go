@@ -40,3 +40,23 @@ type WebhookConfig struct {
 	BodyTemplate string            `json:"bodyTemplate,omitempty"` // 请求体模板：json, form, custom
 	CustomBody   string            `json:"customBody,omitempty"`   // 自定义请求体模板（支持变量）
 }
+
+// MessageRetentionConfig 短信保留策略（存储在 Property 中）
+// 保留天数为 0 表示永久保留；设备规则优先于类型规则，类型规则优先于全局规则
+type MessageRetentionConfig struct {
+	Enabled            bool                `json:"enabled"`            // 是否启用自动清理
+	Days               int                 `json:"days"`               // 全局保留天数
+	DeviceDays         map[string]int      `json:"deviceDays"`         // 按设备保留天数（设备ID -> 天数）
+	TypeDays           map[MessageType]int `json:"typeDays"`           // 按消息类型保留天数（incoming/outgoing -> 天数）
+	OTPDays            int                 `json:"otpDays"`            // 验证码短信保留天数（通常短于其他短信）
+	ExemptPinned       bool                `json:"exemptPinned"`       // 置顶会话不清理
+	TrashDays          int                 `json:"trashDays"`          // 回收站保留天数，到期后永久删除
+	VacuumIntervalDays int                 `json:"vacuumIntervalDays"` // 定期 VACUUM 间隔天数，0 表示不执行
+}
+
+// MessageRetentionStatus 短信清理运行状态（存储在 Property 中）
+type MessageRetentionStatus struct {
+	LastRunAt    int64 `json:"lastRunAt"`    // 上次清理时间（时间戳毫秒）
+	LastDeleted  int64 `json:"lastDeleted"`  // 上次清理条数
+	LastVacuumAt int64 `json:"lastVacuumAt"` // 上次 VACUUM 时间（时间戳毫秒）
+}
@@ -8,18 +8,71 @@ const (
 	LastRunStatusFailed  LastRunStatus = "failed"
 )
 
+// ScheduleType 定时任务调度方式
+type ScheduleType string
+
+const (
+	ScheduleTypeCron         ScheduleType = "cron"          // cron 表达式
+	ScheduleTypeInterval     ScheduleType = "interval"      // 固定间隔
+	ScheduleTypeOnce         ScheduleType = "once"          // 指定时间执行一次
+	ScheduleTypeMonthWeekday ScheduleType = "month_weekday" // 每月第 N 个星期几
+)
+
+// IntervalUnit 固定间隔的时间单位
+type IntervalUnit string
+
+const (
+	IntervalUnitMinutes IntervalUnit = "minutes"
+	IntervalUnitHours   IntervalUnit = "hours"
+	IntervalUnitDays    IntervalUnit = "days"
+)
+
+// TaskActionType 定时任务执行的动作
+type TaskActionType string
+
+const (
+	TaskActionSMS     TaskActionType = "sms"     // 发送短信
+	TaskActionFlymode TaskActionType = "flymode" // 切换设备飞行模式
+	TaskActionReboot  TaskActionType = "reboot"  // 重启设备
+	TaskActionUSSD    TaskActionType = "ussd"    // 发送 USSD（如查询余额）
+	TaskActionWebhook TaskActionType = "webhook" // 调用 Webhook
+)
+
 // ScheduledTask 定时任务
 type ScheduledTask struct {
 	ID           string `gorm:"primaryKey" json:"id"`                  // UUID
 	Name         string `json:"name"`                                  // 任务名称
 	Enabled      bool   `json:"enabled"`                               // 是否启用
-	IntervalDays int    `json:"intervalDays"`                          // 执行间隔天数，例如 90 表示每90天执行一次
+	IntervalDays int    `json:"intervalDays"`                          // 执行间隔天数（旧版本字段，未指定 scheduleType 时等同于按天间隔）
 	PhoneNumber  string `json:"phoneNumber"`                           // 目标手机号
-	Content      string `gorm:"type:text" json:"content"`              // 短信内容
+	Content      string `gorm:"type:text" json:"content"`              // 短信内容模板，支持 {{date}} {{time}} {{device}} {{sim}} {{counter}} 等变量
 	DeviceID     string `json:"deviceId"`                              // 指定设备发送（空则自动分配）
 	CreatedAt    int64  `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间（时间戳毫秒）
 	UpdatedAt    int64  `json:"updatedAt" gorm:"autoUpdateTime:milli"` // 更新时间（时间戳毫秒）
 
+	ScheduleType  ScheduleType `json:"scheduleType"`       // 调度方式
+	CronExpr      string       `json:"cronExpr"`           // cron 表达式（5 位，支持 @daily 等描述符）
+	Timezone      string       `json:"timezone"`           // 时区，例如 Asia/Shanghai，空则使用服务器时区（cron / month_weekday）
+	IntervalValue int          `json:"intervalValue"`      // 间隔数值（interval）
+	IntervalUnit  IntervalUnit `json:"intervalUnit"`       // 间隔单位（interval）
+	RunAt         int64        `json:"runAt"`              // 执行时间（时间戳毫秒，once）
+	MonthWeek     int          `json:"monthWeek"`          // 第几周 1-5，-1 表示最后一周（month_weekday）
+	Weekday       int          `json:"weekday"`            // 星期几 0-6，0 为星期日（month_weekday）
+	TimeOfDay     string       `json:"timeOfDay"`          // 执行时刻 HH:MM（month_weekday）
+	NextRunAt     int64        `gorm:"-" json:"nextRunAt"` // 下次执行时间（时间戳毫秒，0 表示不会再执行），由调度器计算
+
+	FailureAlertThreshold int `json:"failureAlertThreshold"` // 连续失败多少次后发送告警，0 表示不告警
+
+	ActionType    TaskActionType `json:"actionType"`                                  // 执行动作，空则为发送短信
+	Recipients    []string       `gorm:"serializer:json;type:text" json:"recipients"` // 其他收件人（sms）
+	ContactGroup  string         `json:"contactGroup"`                                // 联系人分组，分组内所有联系人均为收件人（sms）
+	Flymode       bool           `json:"flymode"`                                     // 目标飞行模式状态（flymode）
+	UssdCode      string         `json:"ussdCode"`                                    // USSD 代码，例如 *100#（ussd）
+	WebhookURL    string         `gorm:"column:webhook_url" json:"webhookUrl"`        // Webhook 地址（webhook）
+	WebhookMethod string         `json:"webhookMethod"`                               // 请求方法，默认 POST（webhook）
+	WebhookBody   string         `gorm:"type:text" json:"webhookBody"`                // 请求体模板（webhook）
+	RunCount      int64          `json:"runCount"`                                    // 累计执行次数，对应模板变量 {{counter}}
+
 	LastMsgId     string        `json:"lastMsgId"`     // 上次发送的短信ID
 	LastRunAt     int64         `json:"lastRunAt"`     // 上次执行时间（时间戳毫秒）
 	LastRunStatus LastRunStatus `json:"lastRunStatus"` // 上次执行状态
@@ -28,3 +81,33 @@ type ScheduledTask struct {
 func (ScheduledTask) TableName() string {
 	return "scheduled_tasks"
 }
+
+// TaskRunTrigger 定时任务触发来源
+type TaskRunTrigger string
+
+const (
+	TaskRunTriggerScheduled TaskRunTrigger = "scheduled" // 按计划触发
+	TaskRunTriggerManual    TaskRunTrigger = "manual"    // 手动触发
+)
+
+// ScheduledTaskRun 定时任务执行记录
+type ScheduledTaskRun struct {
+	ID         string         `gorm:"primaryKey;index:idx_scheduled_task_runs_task_time,priority:3" json:"id"` // UUID
+	TaskID     string         `gorm:"index:idx_scheduled_task_runs_task_time,priority:1" json:"taskId"`        // 任务ID
+	Execution  int64          `json:"execution"`                                                               // 执行序号（任务的第几次执行），多个收件人的记录属于同一次执行，旧记录为 0
+	Trigger    TaskRunTrigger `json:"trigger"`                                                                 // 触发来源
+	ActionType TaskActionType `json:"actionType"`                                                              // 执行动作
+	DeviceID   string         `json:"deviceId"`                                                                // 发送设备（单设备模式为空）
+	Recipient  string         `json:"recipient"`                                                               // 收件人，多个收件人时每人一条记录（sms）
+	Output     string         `gorm:"type:text" json:"output"`                                                 // 执行输出，例如 USSD 回复、Webhook 响应状态
+	MsgID      string         `gorm:"index" json:"msgId"`                                                      // 短信ID
+	Status     LastRunStatus  `json:"status"`                                                                  // 执行状态，发送后等待回执时为 unknown
+	Error      string         `gorm:"type:text" json:"error"`                                                  // 失败原因
+	DurationMs int64          `json:"durationMs"`                                                              // 执行耗时（毫秒）
+	CreatedAt  int64          `gorm:"index:idx_scheduled_task_runs_task_time,priority:2" json:"createdAt"`     // 执行时间（时间戳毫秒）
+	UpdatedAt  int64          `json:"updatedAt" gorm:"autoUpdateTime:milli"`                                   // 更新时间（时间戳毫秒）
+}
+
+func (ScheduledTaskRun) TableName() string {
+	return "scheduled_task_runs"
+}
@@ -0,0 +1,20 @@
+package models
+
+// Session 登录会话，保存刷新令牌的哈希
+// 访问令牌携带会话ID，会话被撤销或过期后访问令牌和刷新令牌都立即失效
+type Session struct {
+	ID                string `gorm:"primaryKey" json:"id"`                  // UUID
+	UserID            string `gorm:"index" json:"userId"`                   // 用户ID
+	RefreshTokenHash  string `gorm:"uniqueIndex" json:"-"`                  // 当前刷新令牌的 SHA-256
+	PreviousTokenHash string `gorm:"index" json:"-"`                        // 上一个刷新令牌的 SHA-256，再次使用说明令牌泄露
+	IP                string `json:"ip"`                                    // 登录或最近刷新时的IP
+	UserAgent         string `json:"userAgent"`                             // 登录或最近刷新时的 User-Agent
+	CreatedAt         int64  `json:"createdAt" gorm:"autoCreateTime:milli"` // 登录时间（时间戳毫秒）
+	LastUsedAt        int64  `json:"lastUsedAt"`                            // 最近刷新时间（时间戳毫秒）
+	ExpiresAt         int64  `json:"expiresAt"`                             // 刷新令牌过期时间（时间戳毫秒）
+	IDToken           string `json:"-" gorm:"type:text"`                    // OIDC 登录的 ID Token，退出 IdP 会话时作为 id_token_hint
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
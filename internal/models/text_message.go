@@ -1,5 +1,7 @@
 package models
 
+import "gorm.io/gorm"
+
 type MessageType string
 
 const (
@@ -17,20 +19,48 @@ const (
 )
 
 // TextMessage 短信记录
+//
+// 索引策略：
+//   - idx_text_messages_peer_time (peer, created_at, id)：按号码聚合 MAX(created_at) 取会话最新短信，以及会话消息游标分页
+//   - idx_text_messages_time (created_at, id)：全局按时间倒序的游标分页与时间范围过滤
+//
+// 删除为软删除：基于模型的查询会自动排除回收站中的短信，直接使用表名的查询需手动加 deleted_at IS NULL
 type TextMessage struct {
-	ID         string        `gorm:"primaryKey" json:"id"`                  // UUID
-	From       string        `gorm:"column:from_number;index" json:"from"`  // 发送方号码
-	To         string        `gorm:"column:to_number;index" json:"to"`      // 接收方号码
-	Content    string        `gorm:"type:text" json:"content"`              // 短信内容
-	Type       MessageType   `gorm:"index" json:"type"`                     // 消息类型：incoming（收到）、outgoing（发送）
-	Status     MessageStatus `gorm:"index" json:"status"`                   // 状态：received、sent、failed
-	DeviceID   string        `gorm:"index" json:"deviceId"`                 // 关联设备ID
-	DeviceName string        `json:"deviceName"`                            // 设备名称（冗余）
-	CreatedAt  int64         `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间
-	UpdatedAt  int64         `json:"updatedAt" gorm:"autoUpdateTime:milli"` // 更新时间
+	ID         string         `gorm:"primaryKey;index:idx_text_messages_peer_time,priority:3;index:idx_text_messages_time,priority:2" json:"id"`                  // UUID
+	From       string         `gorm:"column:from_number;index" json:"from"`                                                                                       // 发送方号码
+	To         string         `gorm:"column:to_number;index" json:"to"`                                                                                           // 接收方号码
+	Peer       string         `gorm:"column:peer;index:idx_text_messages_peer_time,priority:1" json:"peer"`                                                       // 会话对方号码（接收取 from，发送取 to）
+	Content    string         `gorm:"type:text" json:"content"`                                                                                                   // 短信内容
+	Type       MessageType    `gorm:"index" json:"type"`                                                                                                          // 消息类型：incoming（收到）、outgoing（发送）
+	Status     MessageStatus  `gorm:"index" json:"status"`                                                                                                        // 状态：received、sent、failed
+	DeviceID   string         `gorm:"index" json:"deviceId"`                                                                                                      // 关联设备ID
+	DeviceName string         `json:"deviceName"`                                                                                                                 // 设备名称（冗余）
+	OTPCode    string         `gorm:"column:otp_code;index" json:"otpCode"`                                                                                       // 识别出的验证码（无则为空）
+	ReadAt     int64          `gorm:"column:read_at;index" json:"readAt"`                                                                                         // 已读时间（时间戳毫秒，0 表示未读，仅接收短信有效）
+	CreatedAt  int64          `json:"createdAt" gorm:"autoCreateTime:milli;index:idx_text_messages_peer_time,priority:2;index:idx_text_messages_time,priority:1"` // 创建时间
+	UpdatedAt  int64          `json:"updatedAt" gorm:"autoUpdateTime:milli"`                                                                                      // 更新时间
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`                                                                                                             // 删除时间（软删除，非空表示在回收站中）
 }
 
 // TableName 指定表名
 func (TextMessage) TableName() string {
 	return "text_messages"
 }
+
+// PeerOf 返回短信所属会话的对方号码
+func (m *TextMessage) PeerOf() string {
+	switch m.Type {
+	case MessageTypeIncoming:
+		return m.From
+	case MessageTypeOutgoing:
+		return m.To
+	default:
+		return ""
+	}
+}
+
+// BeforeSave 写入前同步会话对方号码
+func (m *TextMessage) BeforeSave(tx *gorm.DB) error {
+	m.Peer = NormalizePeer(m.PeerOf())
+	return nil
+}
@@ -0,0 +1,59 @@
+package models
+
+// UserRole 用户角色，权限从高到低为 admin、operator、viewer
+type UserRole string
+
+const (
+	RoleAdmin    UserRole = "admin"    // 管理员：全部权限，包括用户、通知渠道、系统设置和备份
+	RoleOperator UserRole = "operator" // 操作员：发送短信、管理设备状态、定时任务和群发
+	RoleViewer   UserRole = "viewer"   // 只读：查看短信、设备和任务
+)
+
+// roleLevels 角色的权限等级
+var roleLevels = map[UserRole]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Valid 是否为已知角色
+func (r UserRole) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Allows 当前角色是否具有 min 角色的全部权限
+func (r UserRole) Allows(min UserRole) bool {
+	return r.Valid() && roleLevels[r] >= roleLevels[min]
+}
+
+// UserSource 用户来源
+type UserSource string
+
+const (
+	UserSourceLocal UserSource = "local" // 本地账号，使用密码登录
+	UserSourceOIDC  UserSource = "oidc"  // OIDC 登录时自动创建，角色由分组映射
+)
+
+// User 登录用户
+type User struct {
+	ID        string     `gorm:"primaryKey" json:"id"`        // UUID
+	Username  string     `gorm:"uniqueIndex" json:"username"` // 用户名，OIDC 用户为邮箱或 preferred_username
+	Nickname  string     `json:"nickname"`                    // 显示名称
+	Password  string     `json:"-"`                           // bcrypt 密码哈希，OIDC 用户为空
+	Role      UserRole   `json:"role"`                        // 角色
+	Source    UserSource `json:"source"`                      // 来源
+	LastLogin int64      `json:"lastLogin"`                   // 最近登录时间（时间戳毫秒）
+
+	TOTPEnabled   bool   `json:"totpEnabled"`        // 是否已启用两步验证
+	TOTPSecret    string `json:"-"`                  // 两步验证密钥（base32），启用前为待确认的密钥
+	TOTPLastStep  int64  `json:"-"`                  // 最近使用的验证码时间步，防止验证码重放
+	RecoveryCodes string `json:"-" gorm:"type:text"` // 恢复码的 SHA-256 哈希，逗号分隔，使用后删除
+
+	CreatedAt int64 `json:"createdAt" gorm:"autoCreateTime:milli"` // 创建时间（时间戳毫秒）
+	UpdatedAt int64 `json:"updatedAt" gorm:"autoUpdateTime:milli"` // 更新时间（时间戳毫秒）
+}
+
+func (User) TableName() string {
+	return "users"
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+// AuditLogFilter 审计日志查询条件
+type AuditLogFilter struct {
+	Actor   string // 操作人
+	Action  string // 动作，包含匹配，如 reboot、DELETE /api/messages
+	Target  string // 操作对象
+	IP      string // 请求IP
+	Success *bool  // 是否成功，为空不过滤
+	StartAt int64  // 开始时间（毫秒，含）
+	EndAt   int64  // 结束时间（毫秒，不含）
+}
+
+func (f AuditLogFilter) where(db *gorm.DB) *gorm.DB {
+	if f.Actor != "" {
+		db = db.Where("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		db = db.Where("action LIKE ?", "%"+f.Action+"%")
+	}
+	if f.Target != "" {
+		db = db.Where("target = ?", f.Target)
+	}
+	if f.IP != "" {
+		db = db.Where("ip = ?", f.IP)
+	}
+	if f.Success != nil {
+		db = db.Where("success = ?", *f.Success)
+	}
+	if f.StartAt > 0 {
+		db = db.Where("created_at >= ?", f.StartAt)
+	}
+	if f.EndAt > 0 {
+		db = db.Where("created_at < ?", f.EndAt)
+	}
+	return db
+}
+
+// AuditLogRepo 审计日志数据访问层
+type AuditLogRepo struct {
+	orz.Repository[models.AuditLog, string]
+	db *gorm.DB
+}
+
+// NewAuditLogRepo 创建审计日志仓储实例
+func NewAuditLogRepo(db *gorm.DB) *AuditLogRepo {
+	return &AuditLogRepo{
+		Repository: orz.NewRepository[models.AuditLog, string](db),
+		db:         db,
+	}
+}
+
+// Find 按时间倒序游标分页查询审计日志
+func (r *AuditLogRepo) Find(ctx context.Context, filter AuditLogFilter, cursor *Cursor, limit int) ([]models.AuditLog, error) {
+	query := filter.where(r.db.WithContext(ctx))
+	if cursor != nil {
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	var logs []models.AuditLog
+	err := query.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+type BatchJobRepo struct {
+	orz.Repository[models.BatchJob, string]
+	db *gorm.DB
+}
+
+func NewBatchJobRepo(db *gorm.DB) *BatchJobRepo {
+	return &BatchJobRepo{
+		Repository: orz.NewRepository[models.BatchJob, string](db),
+		db:         db,
+	}
+}
+
+// FindById 按 ID 查询 ctx 设备范围内的任务
+func (r *BatchJobRepo) FindById(ctx context.Context, id string) (models.BatchJob, error) {
+	var job models.BatchJob
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("id = ?", id).First(&job).Error
+	return job, err
+}
+
+// FindUnfinished 查询未完成的任务，按创建时间先后
+func (r *BatchJobRepo) FindUnfinished(ctx context.Context) ([]models.BatchJob, error) {
+	var jobs []models.BatchJob
+	err := r.db.WithContext(ctx).
+		Where("status <> ?", models.BatchJobStatusCompleted).
+		Order("created_at").Order("id").
+		Find(&jobs).Error
+	return jobs, err
+}
+
+type BatchJobResultRepo struct {
+	orz.Repository[models.BatchJobResult, string]
+	db *gorm.DB
+}
+
+func NewBatchJobResultRepo(db *gorm.DB) *BatchJobResultRepo {
+	return &BatchJobResultRepo{
+		Repository: orz.NewRepository[models.BatchJobResult, string](db),
+		db:         db,
+	}
+}
+
+// CreateInBatches 批量写入收件人
+func (r *BatchJobResultRepo) CreateInBatches(ctx context.Context, results []models.BatchJobResult) error {
+	return r.db.WithContext(ctx).CreateInBatches(results, 500).Error
+}
+
+// FindByJob 按收件人顺序查询任务结果，status 为空时查询全部
+func (r *BatchJobResultRepo) FindByJob(ctx context.Context, jobID string, status models.BatchJobResultStatus) ([]models.BatchJobResult, error) {
+	query := r.db.WithContext(ctx).Where("job_id = ?", jobID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var results []models.BatchJobResult
+	err := query.Order("seq").Find(&results).Error
+	return results, err
+}
+
+// UpdateResult 更新单个收件人的发送结果
+// 发送协程会并发调用，直接使用 db 而不是 orz 的 UpdateColumnsById（其表名缓存不是并发安全的）
+func (r *BatchJobResultRepo) UpdateResult(ctx context.Context, id string, columns map[string]any) error {
+	return r.db.WithContext(ctx).Model(&models.BatchJobResult{}).Where("id = ?", id).Updates(columns).Error
+}
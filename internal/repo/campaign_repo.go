@@ -0,0 +1,161 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+type CampaignRepo struct {
+	orz.Repository[models.Campaign, string]
+	db *gorm.DB
+}
+
+func NewCampaignRepo(db *gorm.DB) *CampaignRepo {
+	return &CampaignRepo{
+		Repository: orz.NewRepository[models.Campaign, string](db),
+		db:         db,
+	}
+}
+
+// FindById 按 ID 查询 ctx 设备范围内的活动
+func (r *CampaignRepo) FindById(ctx context.Context, id string) (models.Campaign, error) {
+	var campaign models.Campaign
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("id = ?", id).First(&campaign).Error
+	return campaign, err
+}
+
+// FindAllOrdered 按创建时间倒序查询 ctx 设备范围内的所有活动
+func (r *CampaignRepo) FindAllOrdered(ctx context.Context) ([]models.Campaign, error) {
+	var campaigns []models.Campaign
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Order("created_at DESC").Order("id DESC").Find(&campaigns).Error
+	return campaigns, err
+}
+
+// FindByStatus 查询指定状态的活动，按创建时间先后
+func (r *CampaignRepo) FindByStatus(ctx context.Context, statuses ...models.CampaignStatus) ([]models.Campaign, error) {
+	var campaigns []models.Campaign
+	err := r.db.WithContext(ctx).Where("status IN ?", statuses).Order("created_at").Order("id").Find(&campaigns).Error
+	return campaigns, err
+}
+
+// UpdateStatus 仅在当前状态为 from 之一时更新状态，返回是否更新
+func (r *CampaignRepo) UpdateStatus(ctx context.Context, id string, to models.CampaignStatus, columns map[string]any, from ...models.CampaignStatus) (bool, error) {
+	updates := map[string]any{"status": to}
+	for k, v := range columns {
+		updates[k] = v
+	}
+	result := r.db.WithContext(ctx).Model(&models.Campaign{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+type CampaignRecipientRepo struct {
+	orz.Repository[models.CampaignRecipient, string]
+	db *gorm.DB
+}
+
+func NewCampaignRecipientRepo(db *gorm.DB) *CampaignRecipientRepo {
+	return &CampaignRecipientRepo{
+		Repository: orz.NewRepository[models.CampaignRecipient, string](db),
+		db:         db,
+	}
+}
+
+// CreateInBatches 批量写入收件人
+func (r *CampaignRecipientRepo) CreateInBatches(ctx context.Context, recipients []models.CampaignRecipient) error {
+	return r.db.WithContext(ctx).CreateInBatches(recipients, 500).Error
+}
+
+// CountByStatus 统计活动各状态的收件人数量
+func (r *CampaignRecipientRepo) CountByStatus(ctx context.Context, campaignID string) (map[models.CampaignRecipientStatus]int64, error) {
+	var rows []struct {
+		Status models.CampaignRecipientStatus
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&models.CampaignRecipient{}).
+		Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[models.CampaignRecipientStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// CountSentSinceByDevice 按设备统计指定活动在 since 之后提交发送的收件人数量，用于各活动共用的设备速率限制
+func (r *CampaignRecipientRepo) CountSentSinceByDevice(ctx context.Context, campaignIDs []string, since int64) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	if len(campaignIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		DeviceID string
+		Count    int64
+	}
+	err := r.db.WithContext(ctx).Model(&models.CampaignRecipient{}).
+		Select("device_id, COUNT(*) AS count").
+		Where("campaign_id IN ? AND status <> ? AND sent_at >= ?", campaignIDs, models.CampaignRecipientQueued, since).
+		Group("device_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.DeviceID] = row.Count
+	}
+	return counts, nil
+}
+
+// FindQueued 按发送顺序查询活动中排队的收件人
+func (r *CampaignRecipientRepo) FindQueued(ctx context.Context, campaignID string, limit int) ([]models.CampaignRecipient, error) {
+	var recipients []models.CampaignRecipient
+	err := r.db.WithContext(ctx).
+		Where("campaign_id = ? AND status = ?", campaignID, models.CampaignRecipientQueued).
+		Order("seq").
+		Limit(limit).
+		Find(&recipients).Error
+	return recipients, err
+}
+
+// FindByCampaign 按发送顺序游标分页查询活动收件人，status 为空时查询全部
+// 游标的 CreatedAt 存放发送顺序 seq
+func (r *CampaignRecipientRepo) FindByCampaign(ctx context.Context, campaignID string, status models.CampaignRecipientStatus, cursor *Cursor, limit int) ([]models.CampaignRecipient, error) {
+	query := r.db.WithContext(ctx).Where("campaign_id = ?", campaignID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if cursor != nil {
+		query = query.Where("seq > ?", cursor.CreatedAt)
+	}
+	var recipients []models.CampaignRecipient
+	err := query.Order("seq").Limit(limit).Find(&recipients).Error
+	return recipients, err
+}
+
+// UpdateStatusByMsgId 根据短信发送结果更新收件人状态
+func (r *CampaignRecipientRepo) UpdateStatusByMsgId(ctx context.Context, msgId string, status models.CampaignRecipientStatus) error {
+	return r.db.WithContext(ctx).Model(&models.CampaignRecipient{}).
+		Where("msg_id = ?", msgId).
+		Update("status", status).Error
+}
+
+// CancelQueued 将活动中排队的收件人标记为已取消
+func (r *CampaignRecipientRepo) CancelQueued(ctx context.Context, campaignID string) error {
+	return r.db.WithContext(ctx).Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND status = ?", campaignID, models.CampaignRecipientQueued).
+		Update("status", models.CampaignRecipientCancelled).Error
+}
+
+// DeleteByCampaign 删除活动的所有收件人
+func (r *CampaignRecipientRepo) DeleteByCampaign(ctx context.Context, campaignID string) error {
+	return r.db.WithContext(ctx).Where("campaign_id = ?", campaignID).Delete(&models.CampaignRecipient{}).Error
+}
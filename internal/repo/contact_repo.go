@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+type ContactRepo struct {
+	orz.Repository[models.Contact, string]
+	db *gorm.DB
+}
+
+func NewContactRepo(db *gorm.DB) *ContactRepo {
+	return &ContactRepo{
+		Repository: orz.NewRepository[models.Contact, string](db),
+		db:         db,
+	}
+}
+
+// FindByGroup 查询联系人，group 为空时查询全部
+func (r *ContactRepo) FindByGroup(ctx context.Context, group string) ([]models.Contact, error) {
+	query := r.db.WithContext(ctx)
+	if group != "" {
+		query = query.Where("group_name = ?", group)
+	}
+	var contacts []models.Contact
+	err := query.Order("group_name").Order("name").Find(&contacts).Error
+	return contacts, err
+}
+
+// FindGroups 查询所有联系人分组
+func (r *ContactRepo) FindGroups(ctx context.Context) ([]string, error) {
+	var groups []string
+	err := r.db.WithContext(ctx).Model(&models.Contact{}).
+		Where("group_name != ''").
+		Distinct("group_name").
+		Order("group_name").
+		Pluck("group_name", &groups).Error
+	return groups, err
+}
@@ -0,0 +1,41 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+type ConversationStateRepo struct {
+	orz.Repository[models.ConversationState, string]
+	db *gorm.DB
+}
+
+func NewConversationStateRepo(db *gorm.DB) *ConversationStateRepo {
+	return &ConversationStateRepo{
+		Repository: orz.NewRepository[models.ConversationState, string](db),
+		db:         db,
+	}
+}
+
+// FindByPeer 查询会话状态，不存在时返回默认状态
+func (r *ConversationStateRepo) FindByPeer(ctx context.Context, peer string) (models.ConversationState, error) {
+	var state models.ConversationState
+	err := r.db.WithContext(ctx).Where("peer = ?", peer).First(&state).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ConversationState{Peer: peer}, nil
+	}
+	return state, err
+}
+
+// IsMuted 查询会话是否免打扰
+func (r *ConversationStateRepo) IsMuted(ctx context.Context, peer string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ConversationState{}).
+		Where("peer = ? AND muted = ?", peer, true).
+		Count(&count).Error
+	return count > 0, err
+}
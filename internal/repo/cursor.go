@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor 游标格式错误
+var ErrInvalidCursor = errors.New("无效的分页游标")
+
+// Cursor 基于 (created_at, id) 的分页游标
+type Cursor struct {
+	CreatedAt int64
+	ID        string
+}
+
+// Encode 编码为 URL 安全的字符串
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt, 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor 解析游标字符串，空字符串返回 nil
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	ts, err := strconv.ParseInt(createdAt, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: ts, ID: id}, nil
+}
@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+// DeviceGrantRepo 设备访问授权数据访问层
+type DeviceGrantRepo struct {
+	orz.Repository[models.DeviceGrant, string]
+	db *gorm.DB
+}
+
+// NewDeviceGrantRepo 创建设备访问授权仓储实例
+func NewDeviceGrantRepo(db *gorm.DB) *DeviceGrantRepo {
+	return &DeviceGrantRepo{
+		Repository: orz.NewRepository[models.DeviceGrant, string](db),
+		db:         db,
+	}
+}
+
+// FindByUser 查找用户的所有授权
+func (r *DeviceGrantRepo) FindByUser(ctx context.Context, userID string) ([]models.DeviceGrant, error) {
+	var grants []models.DeviceGrant
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&grants).Error
+	return grants, err
+}
+
+// ExistsGrant 判断用户是否已有相同的授权
+func (r *DeviceGrantRepo) ExistsGrant(ctx context.Context, grant *models.DeviceGrant) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.DeviceGrant{}).
+		Where("user_id = ? AND device_id = ? AND group_name = ?", grant.UserID, grant.DeviceID, grant.GroupName).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindGrantedDeviceIDs 查找用户通过设备授权和分组授权可以访问的所有设备ID
+func (r *DeviceGrantRepo) FindGrantedDeviceIDs(ctx context.Context, userID string) ([]string, error) {
+	db := r.db.WithContext(ctx)
+	grantedDevices := db.Model(&models.DeviceGrant{}).Select("device_id").
+		Where("user_id = ? AND device_id != ''", userID)
+	grantedGroups := db.Model(&models.DeviceGrant{}).Select("group_name").
+		Where("user_id = ? AND group_name != ''", userID)
+
+	var deviceIDs []string
+	err := db.Model(&models.Device{}).
+		Where("id IN (?) OR group_name IN (?)", grantedDevices, grantedGroups).
+		Order("id").
+		Pluck("id", &deviceIDs).Error
+	return deviceIDs, err
+}
+
+// DeleteByUser 删除用户的所有授权
+func (r *DeviceGrantRepo) DeleteByUser(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.DeviceGrant{}).Error
+}
+
+// DeleteByUserAndId 删除用户的单个授权，返回删除条数
+func (r *DeviceGrantRepo) DeleteByUserAndId(ctx context.Context, userID, id string) (int64, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).Delete(&models.DeviceGrant{})
+	return result.RowsAffected, result.Error
+}
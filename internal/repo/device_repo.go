@@ -30,7 +30,7 @@ func (r *DeviceRepo) Save(ctx context.Context, device *models.Device) error {
 // FindById 根据ID查找设备
 func (r *DeviceRepo) FindById(ctx context.Context, id string) (*models.Device, error) {
 	var device models.Device
-	err := r.db.WithContext(ctx).Where("id = ?", id).First(&device).Error
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "id").Where("id = ?", id).First(&device).Error
 	if err != nil {
 		return nil, err
 	}
@@ -47,10 +47,10 @@ func (r *DeviceRepo) FindBySerialPort(ctx context.Context, serialPort string) (*
 	return &device, nil
 }
 
-// FindAll 查找所有设备
+// FindAll 查找所有设备（受 ctx 的设备范围限制，下同）
 func (r *DeviceRepo) FindAll(ctx context.Context) ([]models.Device, error) {
 	var devices []models.Device
-	err := r.db.WithContext(ctx).Order("created_at DESC").Find(&devices).Error
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "id").Order("created_at DESC").Find(&devices).Error
 	return devices, err
 }
 
@@ -64,7 +64,8 @@ func (r *DeviceRepo) FindAllEnabled(ctx context.Context) ([]models.Device, error
 // FindAllOnline 查找所有在线的设备
 func (r *DeviceRepo) FindAllOnline(ctx context.Context) ([]models.Device, error) {
 	var devices []models.Device
-	err := r.db.WithContext(ctx).Where("enabled = ? AND status = ?", true, models.DeviceStatusOnline).Order("signal_level DESC").Find(&devices).Error
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "id").
+		Where("enabled = ? AND status = ?", true, models.DeviceStatusOnline).Order("signal_level DESC").Find(&devices).Error
 	return devices, err
 }
 
@@ -75,13 +76,13 @@ func (r *DeviceRepo) DeleteById(ctx context.Context, id string) error {
 
 // UpdateColumnsById 根据ID更新指定字段
 func (r *DeviceRepo) UpdateColumnsById(ctx context.Context, id string, columns map[string]any) error {
-	return r.db.WithContext(ctx).Model(&models.Device{}).Where("id = ?", id).Updates(columns).Error
+	return ScopeDevices(ctx, r.db.WithContext(ctx), "id").Model(&models.Device{}).Where("id = ?", id).Updates(columns).Error
 }
 
 // GetGroups 获取所有设备分组
 func (r *DeviceRepo) GetGroups(ctx context.Context) ([]string, error) {
 	var groups []string
-	err := r.db.WithContext(ctx).Model(&models.Device{}).
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "id").Model(&models.Device{}).
 		Distinct("group_name").
 		Where("group_name != ''").
 		Pluck("group_name", &groups).Error
@@ -95,7 +96,7 @@ func (r *DeviceRepo) CountByStatus(ctx context.Context) (map[string]int64, error
 		Count  int64
 	}
 	var results []result
-	err := r.db.WithContext(ctx).Model(&models.Device{}).
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "id").Model(&models.Device{}).
 		Select("status, count(*) as count").
 		Group("status").
 		Find(&results).Error
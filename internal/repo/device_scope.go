@@ -0,0 +1,43 @@
+package repo
+
+import (
+	"context"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+type deviceScopeKey struct{}
+
+// WithDeviceScope 限制 ctx 只能访问指定的设备，之后经过 repo 的设备和短信查询都只返回这些设备的数据
+// deviceIDs 为空表示不能访问任何设备；没有调用过的 ctx（管理员、后台任务）不受限制
+func WithDeviceScope(ctx context.Context, deviceIDs []string) context.Context {
+	if deviceIDs == nil {
+		deviceIDs = []string{}
+	}
+	return context.WithValue(ctx, deviceScopeKey{}, deviceIDs)
+}
+
+// DeviceScopeFrom 返回 ctx 可访问的设备ID，restricted 为 false 时不受限制
+func DeviceScopeFrom(ctx context.Context) (deviceIDs []string, restricted bool) {
+	deviceIDs, restricted = ctx.Value(deviceScopeKey{}).([]string)
+	return deviceIDs, restricted
+}
+
+// InDeviceScope 判断 ctx 是否可以访问指定设备
+func InDeviceScope(ctx context.Context, deviceID string) bool {
+	deviceIDs, restricted := DeviceScopeFrom(ctx)
+	return !restricted || slices.Contains(deviceIDs, deviceID)
+}
+
+// ScopeDevices 按 ctx 的设备范围过滤查询，column 为设备ID所在的列
+func ScopeDevices(ctx context.Context, query *gorm.DB, column string) *gorm.DB {
+	deviceIDs, restricted := DeviceScopeFrom(ctx)
+	if !restricted {
+		return query
+	}
+	if len(deviceIDs) == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where(column+" IN ?", deviceIDs)
+}
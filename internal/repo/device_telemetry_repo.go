@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+// DeviceTelemetryRepo 设备遥测数据访问层
+type DeviceTelemetryRepo struct {
+	orz.Repository[models.DeviceTelemetry, string]
+	db *gorm.DB
+}
+
+// NewDeviceTelemetryRepo 创建设备遥测仓储实例
+func NewDeviceTelemetryRepo(db *gorm.DB) *DeviceTelemetryRepo {
+	return &DeviceTelemetryRepo{
+		Repository: orz.NewRepository[models.DeviceTelemetry, string](db),
+		db:         db,
+	}
+}
+
+// CreateBatch 批量写入遥测数据
+func (r *DeviceTelemetryRepo) CreateBatch(ctx context.Context, rows []models.DeviceTelemetry) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(rows, 200).Error
+}
+
+// FindRange 按时间顺序查询设备在 [from, to) 内指定精度的遥测数据
+func (r *DeviceTelemetryRepo) FindRange(ctx context.Context, deviceID string, resolution models.TelemetryResolution, from, to int64) ([]models.DeviceTelemetry, error) {
+	var rows []models.DeviceTelemetry
+	err := r.db.WithContext(ctx).
+		Where("device_id = ? AND resolution = ? AND sampled_at >= ? AND sampled_at < ?", deviceID, resolution, from, to).
+		Order("sampled_at ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
+// LatestTimestamp 返回设备指定精度的最新数据时间，没有数据时返回 0
+func (r *DeviceTelemetryRepo) LatestTimestamp(ctx context.Context, deviceID string, resolution models.TelemetryResolution) (int64, error) {
+	var latest sql.NullInt64
+	err := r.db.WithContext(ctx).Model(&models.DeviceTelemetry{}).
+		Where("device_id = ? AND resolution = ?", deviceID, resolution).
+		Select("MAX(sampled_at)").
+		Scan(&latest).Error
+	return latest.Int64, err
+}
+
+// FindDeviceIDs 返回有指定精度数据的设备ID
+func (r *DeviceTelemetryRepo) FindDeviceIDs(ctx context.Context, resolution models.TelemetryResolution) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).Model(&models.DeviceTelemetry{}).
+		Where("resolution = ?", resolution).
+		Distinct().
+		Pluck("device_id", &ids).Error
+	return ids, err
+}
+
+// DeleteBefore 删除指定精度中早于 before 的数据，返回删除条数
+func (r *DeviceTelemetryRepo) DeleteBefore(ctx context.Context, resolution models.TelemetryResolution, before int64) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("resolution = ? AND sampled_at < ?", resolution, before).
+		Delete(&models.DeviceTelemetry{})
+	return result.RowsAffected, result.Error
+}
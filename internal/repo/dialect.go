@@ -0,0 +1,47 @@
+package repo
+
+import "gorm.io/gorm"
+
+// 支持的数据库方言（与 gorm Dialector.Name() 一致）
+const (
+	DialectSqlite   = "sqlite"
+	DialectMysql    = "mysql"
+	DialectPostgres = "postgres"
+)
+
+// likeOperator 返回不区分大小写的模糊匹配运算符
+// SQLite 的 LIKE 对 ASCII 不区分大小写，MySQL 默认排序规则不区分大小写，PostgreSQL 需使用 ILIKE
+func likeOperator(db *gorm.DB) string {
+	if db.Dialector.Name() == DialectPostgres {
+		return "ILIKE"
+	}
+	return "LIKE"
+}
+
+// likeCondition 生成带转义的模糊匹配条件，配合 EscapeLike 使用
+func likeCondition(db *gorm.DB, column string) string {
+	return column + " " + likeOperator(db) + " ? ESCAPE '!'"
+}
+
+// deleteByIDs 按主键分批永久删除：先查出一批 ID 再删除
+// MySQL 不支持在 DELETE 的子查询中使用 LIMIT 或引用被删除的表，因此不使用 id IN (子查询)
+func deleteByIDs(db *gorm.DB, model any, ids *gorm.DB, batchSize int) (int64, error) {
+	var total int64
+	for {
+		var batch []string
+		if err := ids.Session(&gorm.Session{}).Limit(batchSize).Pluck("id", &batch).Error; err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+		result := db.Unscoped().Where("id IN ?", batch).Delete(model)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if len(batch) < batchSize {
+			return total, nil
+		}
+	}
+}
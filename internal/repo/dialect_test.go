@@ -0,0 +1,36 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Starktomy/smshub/internal/models"
+)
+
+func TestDeleteByIDsBatches(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTextMessageRepo(db)
+	ctx := context.Background()
+
+	for i, id := range []string{"a", "b", "c", "d", "e"} {
+		msg := &models.TextMessage{ID: id, From: "10086", Type: models.MessageTypeIncoming, CreatedAt: int64(100 + i)}
+		if err := repo.Create(ctx, msg); err != nil {
+			t.Fatalf("创建短信失败: %v", err)
+		}
+	}
+
+	rules := []RetentionRule{{Before: 104}}
+	deleted, err := repo.DeleteExpired(ctx, rules, false, 2)
+	if err != nil {
+		t.Fatalf("删除过期短信失败: %v", err)
+	}
+	if deleted != 4 {
+		t.Errorf("删除条数期望 4，实际 %d", deleted)
+	}
+
+	var remaining int64
+	db.Unscoped().Model(&models.TextMessage{}).Count(&remaining)
+	if remaining != 1 {
+		t.Errorf("剩余短信期望 1，实际 %d", remaining)
+	}
+}
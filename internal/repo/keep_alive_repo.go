@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"gorm.io/gorm"
+)
+
+// KeepAlivePolicyRepo 保号策略数据访问层
+type KeepAlivePolicyRepo struct {
+	db *gorm.DB
+}
+
+// NewKeepAlivePolicyRepo 创建保号策略仓储实例
+func NewKeepAlivePolicyRepo(db *gorm.DB) *KeepAlivePolicyRepo {
+	return &KeepAlivePolicyRepo{db: db}
+}
+
+// Save 保存保号策略（更新或创建）
+func (r *KeepAlivePolicyRepo) Save(ctx context.Context, policy *models.KeepAlivePolicy) error {
+	return r.db.WithContext(ctx).Save(policy).Error
+}
+
+// FindByDevice 根据设备ID查找 ctx 设备范围内的保号策略
+func (r *KeepAlivePolicyRepo) FindByDevice(ctx context.Context, deviceID string) (*models.KeepAlivePolicy, error) {
+	var policy models.KeepAlivePolicy
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("device_id = ?", deviceID).First(&policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// FindByTask 根据生成的定时任务ID查找保号策略，没有时返回 nil
+func (r *KeepAlivePolicyRepo) FindByTask(ctx context.Context, taskID string) (*models.KeepAlivePolicy, error) {
+	var policies []models.KeepAlivePolicy
+	err := r.db.WithContext(ctx).Where("task_id = ?", taskID).Limit(1).Find(&policies).Error
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+	return &policies[0], nil
+}
+
+// FindAll 查找 ctx 设备范围内的所有保号策略
+func (r *KeepAlivePolicyRepo) FindAll(ctx context.Context) ([]models.KeepAlivePolicy, error) {
+	var policies []models.KeepAlivePolicy
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Order("device_id").Find(&policies).Error
+	return policies, err
+}
+
+// DeleteByDevice 删除 ctx 设备范围内设备的保号策略
+func (r *KeepAlivePolicyRepo) DeleteByDevice(ctx context.Context, deviceID string) error {
+	return ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("device_id = ?", deviceID).Delete(&models.KeepAlivePolicy{}).Error
+}
@@ -27,10 +27,17 @@ func (r *ScheduledTaskRepo) FindAllEnabled(ctx context.Context) ([]models.Schedu
 	return tasks, err
 }
 
-// FindAll 查询所有任务
+// FindById 按 ID 查询 ctx 设备范围内的任务
+func (r *ScheduledTaskRepo) FindById(ctx context.Context, id string) (models.ScheduledTask, error) {
+	var task models.ScheduledTask
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("id = ?", id).First(&task).Error
+	return task, err
+}
+
+// FindAll 查询 ctx 设备范围内的所有任务
 func (r *ScheduledTaskRepo) FindAll(ctx context.Context) ([]models.ScheduledTask, error) {
 	var tasks []models.ScheduledTask
-	err := r.db.WithContext(ctx).Find(&tasks).Error
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Find(&tasks).Error
 	return tasks, err
 }
 
@@ -39,3 +46,18 @@ func (r *ScheduledTaskRepo) UpdateLastRunStatusByMsgId(ctx context.Context, msgI
 		Where("last_msg_id = ?", msgId).
 		Update("last_run_status", status).Error
 }
+
+// IncrementRunCount 累加任务执行次数，返回累加后的值
+func (r *ScheduledTaskRepo) IncrementRunCount(ctx context.Context, id string) (int64, error) {
+	err := r.db.WithContext(ctx).Model(&models.ScheduledTask{}).
+		Where("id = ?", id).
+		UpdateColumn("run_count", gorm.Expr("run_count + 1")).Error
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = r.db.WithContext(ctx).Model(&models.ScheduledTask{}).
+		Where("id = ?", id).
+		Pluck("run_count", &count).Error
+	return count, err
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+type ScheduledTaskRunRepo struct {
+	orz.Repository[models.ScheduledTaskRun, string]
+	db *gorm.DB
+}
+
+func NewScheduledTaskRunRepo(db *gorm.DB) *ScheduledTaskRunRepo {
+	return &ScheduledTaskRunRepo{
+		Repository: orz.NewRepository[models.ScheduledTaskRun, string](db),
+		db:         db,
+	}
+}
+
+// FindByTask 按时间倒序游标分页查询任务的执行记录
+func (r *ScheduledTaskRunRepo) FindByTask(ctx context.Context, taskID string, cursor *Cursor, limit int) ([]models.ScheduledTaskRun, error) {
+	query := r.db.WithContext(ctx).Where("task_id = ?", taskID)
+	if cursor != nil {
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	var runs []models.ScheduledTaskRun
+	err := query.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// FindRecentExecutionFailures 查询任务最近 limit 次执行是否失败，按执行序号倒序
+// 一次执行有多条记录（多个收件人）时，任一记录失败即视为该次执行失败；没有执行序号的旧记录不参与统计
+func (r *ScheduledTaskRunRepo) FindRecentExecutionFailures(ctx context.Context, taskID string, limit int) ([]bool, error) {
+	var rows []struct {
+		Failed int
+	}
+	err := r.db.WithContext(ctx).Model(&models.ScheduledTaskRun{}).
+		Select("MAX(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failed", models.LastRunStatusFailed).
+		Where("task_id = ? AND execution > 0", taskID).
+		Group("execution").
+		Order("execution DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]bool, len(rows))
+	for i, row := range rows {
+		failures[i] = row.Failed > 0
+	}
+	return failures, nil
+}
+
+// CountFailedInExecution 统计任务某次执行中失败的记录数
+func (r *ScheduledTaskRunRepo) CountFailedInExecution(ctx context.Context, taskID string, execution int64) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ScheduledTaskRun{}).
+		Where("task_id = ? AND execution = ? AND status = ?", taskID, execution, models.LastRunStatusFailed).
+		Count(&count).Error
+	return count, err
+}
+
+// UpdateStatusByMsgId 根据短信回执更新执行状态，返回更新前的执行记录（没有记录时 ID 为空）
+func (r *ScheduledTaskRunRepo) UpdateStatusByMsgId(ctx context.Context, msgId string, status models.LastRunStatus) (models.ScheduledTaskRun, error) {
+	var run models.ScheduledTaskRun
+	err := r.db.WithContext(ctx).Where("msg_id = ?", msgId).Limit(1).Find(&run).Error
+	if err != nil || run.ID == "" {
+		return run, err
+	}
+	err = r.db.WithContext(ctx).Model(&models.ScheduledTaskRun{}).Where("id = ?", run.ID).Update("status", status).Error
+	return run, err
+}
+
+// DeleteByTask 删除任务的所有执行记录
+func (r *ScheduledTaskRunRepo) DeleteByTask(ctx context.Context, taskID string) error {
+	return r.db.WithContext(ctx).Where("task_id = ?", taskID).Delete(&models.ScheduledTaskRun{}).Error
+}
+
+// LastSuccessAt 查询任务最近一次执行成功的时间，没有时为 0
+func (r *ScheduledTaskRunRepo) LastSuccessAt(ctx context.Context, taskID string) (int64, error) {
+	var at *int64
+	err := r.db.WithContext(ctx).Model(&models.ScheduledTaskRun{}).
+		Where("task_id = ? AND status = ?", taskID, models.LastRunStatusSuccess).
+		Select("MAX(created_at)").
+		Scan(&at).Error
+	if err != nil || at == nil {
+		return 0, err
+	}
+	return *at, nil
+}
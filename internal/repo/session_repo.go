@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+// SessionRepo 登录会话数据访问层
+type SessionRepo struct {
+	orz.Repository[models.Session, string]
+	db *gorm.DB
+}
+
+// NewSessionRepo 创建登录会话仓储实例
+func NewSessionRepo(db *gorm.DB) *SessionRepo {
+	return &SessionRepo{
+		Repository: orz.NewRepository[models.Session, string](db),
+		db:         db,
+	}
+}
+
+// FindActiveByUser 查找用户未过期的会话，最近使用的在前
+func (r *SessionRepo) FindActiveByUser(ctx context.Context, userID string, now int64) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// FindByRefreshTokenHash 根据当前刷新令牌查找会话
+func (r *SessionRepo) FindByRefreshTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.WithContext(ctx).Where("refresh_token_hash = ?", hash).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindByPreviousTokenHash 根据已轮换的刷新令牌查找会话
+func (r *SessionRepo) FindByPreviousTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.WithContext(ctx).Where("previous_token_hash = ?", hash).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate 轮换刷新令牌，只有当前令牌仍为 oldHash 时才更新，返回是否更新成功
+func (r *SessionRepo) Rotate(ctx context.Context, id, oldHash, newHash string, columns map[string]any) (bool, error) {
+	columns["refresh_token_hash"] = newHash
+	columns["previous_token_hash"] = oldHash
+	result := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ?", id, oldHash).
+		Updates(columns)
+	return result.RowsAffected > 0, result.Error
+}
+
+// DeleteByUserAndId 删除用户的单个会话，返回删除条数
+func (r *SessionRepo) DeleteByUserAndId(ctx context.Context, userID, id string) (int64, error) {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND id = ?", userID, id).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteByUser 删除用户的所有会话
+func (r *SessionRepo) DeleteByUser(ctx context.Context, userID string) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.Session{}).Error
+}
+
+// DeleteByUserExcept 删除用户除 keepID 以外的所有会话
+func (r *SessionRepo) DeleteByUserExcept(ctx context.Context, userID, keepID string) error {
+	return r.db.WithContext(ctx).Where("user_id = ? AND id != ?", userID, keepID).Delete(&models.Session{}).Error
+}
+
+// DeleteExpired 删除已过期的会话
+func (r *SessionRepo) DeleteExpired(ctx context.Context, now int64) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.Session{})
+	return result.RowsAffected, result.Error
+}
@@ -9,19 +9,40 @@ import (
 	"time"
 
 	"github.com/Starktomy/smshub/internal/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// testDialector 按环境变量选择测试数据库：
+//   - SMSHUB_TEST_DB：sqlite（默认，内存数据库）、mysql、postgres
+//   - SMSHUB_TEST_DSN：mysql/postgres 的连接串
+//
+// mysql/postgres 下各测试共用同一个库，需配合 go test -p 1 串行执行各包
+func testDialector(t *testing.T, name string) gorm.Dialector {
+	dsn := os.Getenv("SMSHUB_TEST_DSN")
+	switch backend := os.Getenv("SMSHUB_TEST_DB"); backend {
+	case "", DialectSqlite:
+		return sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+	case DialectMysql:
+		return mysql.Open(dsn)
+	case DialectPostgres:
+		return postgres.Open(dsn)
+	default:
+		t.Fatalf("不支持的测试数据库: %s", backend)
+		return nil
+	}
+}
+
 // setupTestDB 创建内存数据库用于测试
 func setupTestDB(t *testing.T) *gorm.DB {
 	// 使用随机名称确保隔离
 	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 	dbName := fmt.Sprintf("memdb_%d", rng.Int())
 
-	// 使用内存数据库
-	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", dbName)), &gorm.Config{
+	db, err := gorm.Open(testDialector(t, dbName), &gorm.Config{
 		Logger: logger.New(
 			log.New(os.Stdout, "\r\n", log.LstdFlags),
 			logger.Config{
@@ -33,8 +54,8 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("连接数据库失败: %v", err)
 	}
 
-	// 清理旧数据 (因为 cache=shared)
-	db.Migrator().DropTable(&models.Device{}, &models.TextMessage{}, &models.Property{}, &models.ScheduledTask{})
+	// 清理旧数据 (因为 cache=shared，mysql/postgres 共用同一个库)
+	db.Migrator().DropTable(&models.Device{}, &models.TextMessage{}, &models.Property{}, &models.ScheduledTask{}, &models.ScheduledTaskRun{}, &models.Contact{}, &models.KeepAlivePolicy{}, &models.Campaign{}, &models.CampaignRecipient{}, &models.BatchJob{}, &models.BatchJobResult{}, &models.User{}, &models.DeviceGrant{}, &models.AuditLog{}, &models.Session{}, &models.DeviceTelemetry{}, &models.ConversationState{})
 
 	// 自动迁移
 	err = db.AutoMigrate(
@@ -42,6 +63,19 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&models.TextMessage{},
 		&models.Property{},
 		&models.ScheduledTask{},
+		&models.ScheduledTaskRun{},
+		&models.Contact{},
+		&models.KeepAlivePolicy{},
+		&models.Campaign{},
+		&models.CampaignRecipient{},
+		&models.BatchJob{},
+		&models.BatchJobResult{},
+		&models.User{},
+		&models.DeviceGrant{},
+		&models.AuditLog{},
+		&models.Session{},
+		&models.ConversationState{},
+		&models.DeviceTelemetry{},
 	)
 	if err != nil {
 		t.Fatalf("数据库迁移失败: %v", err)
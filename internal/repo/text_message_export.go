@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"gorm.io/gorm"
+)
+
+// MessageExportFilter 短信导出条件
+type MessageExportFilter struct {
+	DeviceID string // 设备ID
+	Peer     string // 对方号码（按规范化后的号码匹配）
+	StartAt  int64  // 开始时间（毫秒，含）
+	EndAt    int64  // 结束时间（毫秒，不含）
+}
+
+func (f MessageExportFilter) where(db *gorm.DB) *gorm.DB {
+	if f.DeviceID != "" {
+		db = db.Where("device_id = ?", f.DeviceID)
+	}
+	if f.Peer != "" {
+		db = db.Where("peer = ?", models.NormalizePeer(f.Peer))
+	}
+	if f.StartAt > 0 {
+		db = db.Where("created_at >= ?", f.StartAt)
+	}
+	if f.EndAt > 0 {
+		db = db.Where("created_at < ?", f.EndAt)
+	}
+	return db
+}
+
+// CountForExport 统计待导出短信数量
+func (r *TextMessageRepo) CountForExport(ctx context.Context, filter MessageExportFilter) (int64, error) {
+	var count int64
+	query := ScopeDevices(ctx, r.db.WithContext(ctx).Model(&models.TextMessage{}), "device_id")
+	err := filter.where(query).Count(&count).Error
+	return count, err
+}
+
+// FindForExport 按 created_at、id 正序分批查询待导出短信
+func (r *TextMessageRepo) FindForExport(ctx context.Context, filter MessageExportFilter, after *Cursor, limit int) ([]models.TextMessage, error) {
+	query := filter.where(ScopeDevices(ctx, r.db.WithContext(ctx), "device_id"))
+	if after != nil {
+		query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", after.CreatedAt, after.CreatedAt, after.ID)
+	}
+
+	var msgs []models.TextMessage
+	err := query.Order("created_at ASC").Order("id ASC").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// ExistsDuplicate 判断是否已存在相同 ID 或相同（会话、类型、时间、内容）的短信，包括回收站中的短信
+func (r *TextMessageRepo) ExistsDuplicate(ctx context.Context, msg *models.TextMessage) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.TextMessage{}).
+		Where("id = ? OR (peer = ? AND type = ? AND created_at = ? AND content = ?)",
+			msg.ID, models.NormalizePeer(msg.PeerOf()), msg.Type, msg.CreatedAt, msg.Content).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/Starktomy/smshub/internal/models"
@@ -19,6 +20,8 @@ func NewTextMessageRepo(db *gorm.DB) *TextMessageRepo {
 type TextMessageRepo struct {
 	orz.Repository[models.TextMessage, string]
 	db *gorm.DB
+	// 是否已启用 FTS5 全文索引
+	searchIndex bool
 }
 
 func (r *TextMessageRepo) CountToday(ctx context.Context) (int64, error) {
@@ -47,3 +50,200 @@ func (r *TextMessageRepo) FindByPeer(ctx context.Context, peer string) ([]*model
 		Find(&msgs).Error
 	return msgs, err
 }
+
+// HasPeer 判断 ctx 可访问的设备中是否有与该号码的短信
+func (r *TextMessageRepo) HasPeer(ctx context.Context, peer string) (bool, error) {
+	var count int64
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Model(&models.TextMessage{}).
+		Where("peer = ?", peer).
+		Limit(1).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// FindOTPCandidates 分批查询内容包含任一关键词且尚未识别验证码的接收短信（按 created_at、id 正序）
+func (r *TextMessageRepo) FindOTPCandidates(ctx context.Context, keywords []string, after *Cursor, limit int) ([]models.TextMessage, error) {
+	query := r.db.WithContext(ctx).
+		Where("type = ? AND (otp_code = '' OR otp_code IS NULL)", models.MessageTypeIncoming)
+
+	if len(keywords) > 0 {
+		conditions := make([]string, len(keywords))
+		args := make([]any, len(keywords))
+		for i, keyword := range keywords {
+			conditions[i] = "LOWER(content) LIKE ? ESCAPE '!'"
+			args[i] = "%" + EscapeLike(keyword) + "%"
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	if after != nil {
+		query = query.Where("(created_at > ? OR (created_at = ? AND id > ?))", after.CreatedAt, after.CreatedAt, after.ID)
+	}
+
+	var msgs []models.TextMessage
+	err := query.Order("created_at ASC").Order("id ASC").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// ConversationRow 会话查询结果（会话最后一条消息、消息总数、未读数及会话状态）
+type ConversationRow struct {
+	models.TextMessage
+	MessageCount int64 `gorm:"column:message_count"`
+	UnreadCount  int64 `gorm:"column:unread_count"`
+	Pinned       bool  `gorm:"column:pinned"`
+	Archived     bool  `gorm:"column:archived"`
+	Muted        bool  `gorm:"column:muted"`
+}
+
+// ConversationFilter 会话列表查询条件
+type ConversationFilter struct {
+	Archived bool    // 是否查询已归档会话（默认只查未归档）
+	Pinned   *bool   // 是否置顶
+	Cursor   *Cursor // 分页游标
+	Limit    int     // 返回条数
+}
+
+// FindConversations 按会话最后消息时间倒序游标分页查询会话
+// 先按 peer 分组取最后消息时间（走 peer, created_at 索引），游标在分组时过滤，再关联出每个会话的最后一条消息；
+// 消息总数和未读数只对当前页的会话统计
+func (r *TextMessageRepo) FindConversations(ctx context.Context, filter ConversationFilter) ([]ConversationRow, error) {
+	db := r.db.WithContext(ctx)
+
+	latest := db.Table("text_messages").
+		Select("peer, MAX(created_at) AS last_at").
+		Where("peer != '' AND deleted_at IS NULL").
+		Group("peer")
+	latest = ScopeDevices(ctx, latest, "device_id")
+	if filter.Cursor != nil {
+		latest = latest.Having("MAX(created_at) <= ?", filter.Cursor.CreatedAt)
+	}
+
+	// 同一时间有多条消息时取 id 最大的一条
+	lastID := db.Table("text_messages AS t").
+		Select("MAX(t.id)").
+		Where("t.peer = latest.peer AND t.created_at = latest.last_at AND t.deleted_at IS NULL")
+	lastID = ScopeDevices(ctx, lastID, "t.device_id")
+
+	query := db.Table("(?) AS latest", latest).
+		Select(`m.*,
+			COALESCE(s.pinned, ?) AS pinned,
+			COALESCE(s.archived, ?) AS archived,
+			COALESCE(s.muted, ?) AS muted`, false, false, false).
+		Joins("JOIN text_messages m ON m.id = (?)", lastID).
+		Joins("LEFT JOIN conversation_states s ON s.peer = latest.peer").
+		Where("COALESCE(s.archived, ?) = ?", false, filter.Archived)
+	if filter.Pinned != nil {
+		query = query.Where("COALESCE(s.pinned, ?) = ?", false, *filter.Pinned)
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(m.created_at < ? OR (m.created_at = ? AND m.id < ?))",
+			filter.Cursor.CreatedAt, filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+
+	var rows []ConversationRow
+	if err := query.Order("m.created_at DESC").Order("m.id DESC").Limit(filter.Limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return rows, nil
+	}
+
+	peers := make([]string, len(rows))
+	for i := range rows {
+		peers[i] = rows[i].Peer
+	}
+	var counts []struct {
+		Peer         string
+		MessageCount int64
+		UnreadCount  int64
+	}
+	countQuery := db.Table("text_messages").
+		Select("peer, COUNT(*) AS message_count, SUM(CASE WHEN type = ? AND read_at = 0 THEN 1 ELSE 0 END) AS unread_count",
+			models.MessageTypeIncoming).
+		Where("peer IN ? AND deleted_at IS NULL", peers).
+		Group("peer")
+	if err := ScopeDevices(ctx, countQuery, "device_id").Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	byPeer := make(map[string]int, len(rows))
+	for i := range rows {
+		byPeer[rows[i].Peer] = i
+	}
+	for _, c := range counts {
+		if i, ok := byPeer[c.Peer]; ok {
+			rows[i].MessageCount = c.MessageCount
+			rows[i].UnreadCount = c.UnreadCount
+		}
+	}
+	return rows, nil
+}
+
+// FindConversationMessages 按时间倒序游标分页查询会话消息
+func (r *TextMessageRepo) FindConversationMessages(ctx context.Context, peer string, cursor *Cursor, limit int) ([]models.TextMessage, error) {
+	query := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("peer = ?", peer)
+	if cursor != nil {
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	var msgs []models.TextMessage
+	err := query.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// DeleteByPeer 删除会话的所有消息，返回删除条数
+func (r *TextMessageRepo) DeleteByPeer(ctx context.Context, peer string) (int64, error) {
+	result := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("peer = ?", peer).Delete(&models.TextMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// MarkReadByPeer 将会话中所有未读的接收短信标记为已读，返回更新条数
+func (r *TextMessageRepo) MarkReadByPeer(ctx context.Context, peer string, readAt int64) (int64, error) {
+	return r.markRead(ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("peer = ?", peer), readAt)
+}
+
+// MarkAllRead 将所有未读的接收短信标记为已读，deviceID 不为空时只处理该设备，返回更新条数
+func (r *TextMessageRepo) MarkAllRead(ctx context.Context, deviceID string, readAt int64) (int64, error) {
+	query := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id")
+	if deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	return r.markRead(query, readAt)
+}
+
+func (r *TextMessageRepo) markRead(query *gorm.DB, readAt int64) (int64, error) {
+	result := query.Model(&models.TextMessage{}).
+		Where("type = ? AND read_at = 0", models.MessageTypeIncoming).
+		UpdateColumn("read_at", readAt)
+	return result.RowsAffected, result.Error
+}
+
+// DeviceUnreadCount 设备未读短信数
+type DeviceUnreadCount struct {
+	DeviceID   string `json:"deviceId"`
+	DeviceName string `json:"deviceName"`
+	Count      int64  `json:"count"`
+}
+
+// CountUnreadByDevice 按设备统计未读的接收短信数
+func (r *TextMessageRepo) CountUnreadByDevice(ctx context.Context) ([]DeviceUnreadCount, error) {
+	var counts []DeviceUnreadCount
+	err := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Model(&models.TextMessage{}).
+		Select("device_id, MAX(device_name) AS device_name, COUNT(*) AS count").
+		Where("type = ? AND read_at = 0", models.MessageTypeIncoming).
+		Group("device_id").
+		Order("device_id").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// LastSentAt 查询设备最近一条发送成功的短信时间（包含回收站中的短信），没有时为 0
+func (r *TextMessageRepo) LastSentAt(ctx context.Context, deviceID string) (int64, error) {
+	var at *int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.TextMessage{}).
+		Where("device_id = ? AND type = ? AND status = ?", deviceID, models.MessageTypeOutgoing, models.MessageStatusSent).
+		Select("MAX(created_at)").
+		Scan(&at).Error
+	if err != nil || at == nil {
+		return 0, err
+	}
+	return *at, nil
+}
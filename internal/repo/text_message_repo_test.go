@@ -124,3 +124,185 @@ func TestTextMessageRepoConversations(t *testing.T) {
 		t.Errorf("会话 A 消息数量期望 2，实际 %d", len(msgs))
 	}
 }
+
+func TestTextMessageRepoConversationIndexes(t *testing.T) {
+	db := setupTestDB(t)
+
+	for _, name := range []string{"idx_text_messages_peer_time", "idx_text_messages_time"} {
+		if !db.Migrator().HasIndex(&models.TextMessage{}, name) {
+			t.Errorf("缺少索引 %s", name)
+		}
+	}
+}
+
+func TestTextMessageRepoFindConversations(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTextMessageRepo(db)
+	ctx := context.Background()
+
+	msgs := []models.TextMessage{
+		{ID: "1", From: "10086", Type: models.MessageTypeIncoming, CreatedAt: 1000},
+		{ID: "2", To: "10086", Type: models.MessageTypeOutgoing, CreatedAt: 3000},
+		{ID: "3", From: "95588", Type: models.MessageTypeIncoming, CreatedAt: 2000},
+		// 无类型的消息不属于任何会话
+		{ID: "4", From: "10010", CreatedAt: 4000},
+	}
+	for i := range msgs {
+		if err := repo.Create(ctx, &msgs[i]); err != nil {
+			t.Fatalf("创建短信失败: %v", err)
+		}
+	}
+
+	rows, err := repo.FindConversations(ctx, ConversationFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("查询会话失败: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("会话数量期望 2，实际 %d", len(rows))
+	}
+	if rows[0].Peer != "10086" || rows[0].ID != "2" || rows[0].MessageCount != 2 {
+		t.Errorf("第一个会话不正确: peer=%s id=%s count=%d", rows[0].Peer, rows[0].ID, rows[0].MessageCount)
+	}
+
+	rows, err = repo.FindConversations(ctx, ConversationFilter{Cursor: &Cursor{CreatedAt: 3000, ID: "2"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("游标查询会话失败: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Peer != "95588" {
+		t.Errorf("游标后应只剩会话 95588，实际 %+v", rows)
+	}
+
+	deleted, err := repo.DeleteByPeer(ctx, "10086")
+	if err != nil {
+		t.Fatalf("删除会话失败: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("删除条数期望 2，实际 %d", deleted)
+	}
+}
+
+func TestTextMessageRepoFindConversationsTieAndScope(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTextMessageRepo(db)
+	ctx := context.Background()
+
+	msgs := []models.TextMessage{
+		// 10086 的两条消息时间相同，取 id 较大的一条
+		{ID: "a1", From: "10086", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: 2000},
+		{ID: "a2", From: "10086", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: 2000},
+		// 95588 最后消息时间与 10086 相同，按 id 排在后面
+		{ID: "a0", From: "95588", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: 2000},
+		// dev-b 的消息更新，但不在授权范围内时不应影响 95588 的最后一条消息
+		{ID: "b1", To: "95588", Type: models.MessageTypeOutgoing, DeviceID: "dev-b", CreatedAt: 3000},
+	}
+	for i := range msgs {
+		if err := repo.Create(ctx, &msgs[i]); err != nil {
+			t.Fatalf("创建短信失败: %v", err)
+		}
+	}
+
+	scoped := WithDeviceScope(ctx, []string{"dev-a"})
+	rows, err := repo.FindConversations(scoped, ConversationFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("查询会话失败: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Peer != "10086" || rows[0].ID != "a2" || rows[0].MessageCount != 2 || rows[0].UnreadCount != 2 {
+		t.Fatalf("第一页会话不正确: %+v", rows)
+	}
+
+	rows, err = repo.FindConversations(scoped, ConversationFilter{Cursor: &Cursor{CreatedAt: 2000, ID: "a2"}, Limit: 1})
+	if err != nil {
+		t.Fatalf("游标查询会话失败: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Peer != "95588" || rows[0].ID != "a0" || rows[0].MessageCount != 1 {
+		t.Fatalf("第二页会话不正确: %+v", rows)
+	}
+
+	// 不限制设备时 95588 的最后一条消息来自 dev-b
+	rows, err = repo.FindConversations(ctx, ConversationFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("查询会话失败: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Peer != "95588" || rows[0].ID != "b1" || rows[0].MessageCount != 2 {
+		t.Errorf("会话不正确: %+v", rows)
+	}
+}
+
+func TestTextMessageRepoReadState(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTextMessageRepo(db)
+	stateRepo := NewConversationStateRepo(db)
+	ctx := context.Background()
+
+	msgs := []models.TextMessage{
+		{ID: "1", From: "10086", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: 1000},
+		{ID: "2", From: "10086", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: 2000},
+		{ID: "3", To: "10086", Type: models.MessageTypeOutgoing, DeviceID: "dev-a", CreatedAt: 3000},
+		{ID: "4", From: "95588", Type: models.MessageTypeIncoming, DeviceID: "dev-b", CreatedAt: 4000},
+	}
+	for i := range msgs {
+		if err := repo.Create(ctx, &msgs[i]); err != nil {
+			t.Fatalf("创建短信失败: %v", err)
+		}
+	}
+
+	rows, err := repo.FindConversations(ctx, ConversationFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("查询会话失败: %v", err)
+	}
+	unread := map[string]int64{}
+	for _, row := range rows {
+		unread[row.Peer] = row.UnreadCount
+	}
+	if unread["10086"] != 2 || unread["95588"] != 1 {
+		t.Errorf("会话未读数不正确: %v", unread)
+	}
+
+	counts, err := repo.CountUnreadByDevice(ctx)
+	if err != nil {
+		t.Fatalf("统计设备未读失败: %v", err)
+	}
+	if len(counts) != 2 || counts[0].DeviceID != "dev-a" || counts[0].Count != 2 || counts[1].Count != 1 {
+		t.Errorf("设备未读数不正确: %+v", counts)
+	}
+
+	updated, err := repo.MarkReadByPeer(ctx, "10086", 5000)
+	if err != nil {
+		t.Fatalf("标记会话已读失败: %v", err)
+	}
+	if updated != 2 {
+		t.Errorf("标记条数期望 2，实际 %d", updated)
+	}
+
+	updated, err = repo.MarkAllRead(ctx, "dev-a", 6000)
+	if err != nil {
+		t.Fatalf("标记全部已读失败: %v", err)
+	}
+	if updated != 0 {
+		t.Errorf("dev-a 已无未读，标记条数期望 0，实际 %d", updated)
+	}
+
+	// 归档后默认列表不再返回该会话
+	if err := stateRepo.Save(ctx, &models.ConversationState{Peer: "95588", Archived: true, Muted: true}); err != nil {
+		t.Fatalf("保存会话状态失败: %v", err)
+	}
+	rows, err = repo.FindConversations(ctx, ConversationFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("查询会话失败: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Peer != "10086" || rows[0].UnreadCount != 0 {
+		t.Errorf("未归档会话不正确: %+v", rows)
+	}
+	rows, err = repo.FindConversations(ctx, ConversationFilter{Archived: true, Limit: 10})
+	if err != nil {
+		t.Fatalf("查询归档会话失败: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Peer != "95588" || !rows[0].Archived || !rows[0].Muted {
+		t.Errorf("归档会话不正确: %+v", rows)
+	}
+
+	muted, err := stateRepo.IsMuted(ctx, "95588")
+	if err != nil || !muted {
+		t.Errorf("95588 应为免打扰: muted=%v err=%v", muted, err)
+	}
+}
@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"gorm.io/gorm"
+)
+
+// RetentionRule 保留规则：满足条件且创建时间早于 Before 的短信视为过期
+type RetentionRule struct {
+	DeviceID         string               // 仅匹配该设备
+	ExcludeDeviceIDs []string             // 排除的设备（已有单独规则）
+	Type             models.MessageType   // 仅匹配该类型
+	ExcludeTypes     []models.MessageType // 排除的类型（已有单独规则）
+	OTPOnly          bool                 // 仅匹配识别出验证码的短信
+	Before           int64                // 过期时间点（时间戳毫秒）
+}
+
+// where 生成规则对应的查询条件
+func (rule RetentionRule) where(db *gorm.DB) *gorm.DB {
+	query := db.Where("created_at < ?", rule.Before)
+	if rule.DeviceID != "" {
+		query = query.Where("device_id = ?", rule.DeviceID)
+	}
+	if len(rule.ExcludeDeviceIDs) > 0 {
+		query = query.Where("device_id NOT IN ?", rule.ExcludeDeviceIDs)
+	}
+	if rule.Type != "" {
+		query = query.Where("type = ?", rule.Type)
+	}
+	if len(rule.ExcludeTypes) > 0 {
+		query = query.Where("type NOT IN ?", rule.ExcludeTypes)
+	}
+	if rule.OTPOnly {
+		query = query.Where("otp_code != ''")
+	}
+	return query
+}
+
+// expiredQuery 组合多条规则（任一规则命中即过期），exemptPinned 时排除置顶会话
+func (r *TextMessageRepo) expiredQuery(ctx context.Context, rules []RetentionRule, exemptPinned bool) *gorm.DB {
+	newDB := r.db.Session(&gorm.Session{NewDB: true})
+
+	var matched *gorm.DB
+	for _, rule := range rules {
+		if matched == nil {
+			matched = newDB.Where(rule.where(newDB))
+		} else {
+			matched = matched.Or(rule.where(newDB))
+		}
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.TextMessage{}).Where(matched)
+	if exemptPinned {
+		query = query.Where("peer NOT IN (?)",
+			newDB.Model(&models.ConversationState{}).Select("peer").Where("pinned = ?", true))
+	}
+	return query
+}
+
+// CountExpired 统计过期短信数量
+func (r *TextMessageRepo) CountExpired(ctx context.Context, rules []RetentionRule, exemptPinned bool) (int64, error) {
+	if len(rules) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := r.expiredQuery(ctx, rules, exemptPinned).Count(&count).Error
+	return count, err
+}
+
+// DeleteExpired 分批删除过期短信，避免长时间锁表，返回删除条数
+func (r *TextMessageRepo) DeleteExpired(ctx context.Context, rules []RetentionRule, exemptPinned bool, batchSize int) (int64, error) {
+	if len(rules) == 0 {
+		return 0, nil
+	}
+	// 过期短信直接永久删除，不进入回收站
+	return deleteByIDs(r.db.WithContext(ctx), &models.TextMessage{}, r.expiredQuery(ctx, rules, exemptPinned), batchSize)
+}
+
+// Vacuum 整理 SQLite 数据库文件，回收已删除数据占用的空间，其他数据库不执行
+func (r *TextMessageRepo) Vacuum(ctx context.Context) (bool, error) {
+	db := r.db.WithContext(ctx)
+	if db.Dialector.Name() != DialectSqlite {
+		return false, nil
+	}
+	if err := db.Exec("VACUUM").Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,181 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Starktomy/smshub/internal/models"
+)
+
+const (
+	// textMessageFTSTable FTS5 全文索引表（外部内容表，内容来自 text_messages）
+	textMessageFTSTable = "text_messages_fts"
+	// trigramMinRunes trigram 分词器要求的最短查询长度
+	trigramMinRunes = 3
+)
+
+// 高亮占位符：先用控制字符标记命中位置，由上层转义后再替换为最终标签
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// MessageSearchFilter 短信搜索条件
+type MessageSearchFilter struct {
+	Keyword  string               // 关键词（空格分隔多个词，AND 关系）
+	DeviceID string               // 设备ID
+	Peer     string               // 对方号码（按规范化后的号码匹配）
+	Type     models.MessageType   // 消息类型
+	Status   models.MessageStatus // 消息状态
+	StartAt  int64                // 开始时间（毫秒，含）
+	EndAt    int64                // 结束时间（毫秒，不含）
+	HasOTP   *bool                // 是否包含验证码
+	Cursor   *Cursor              // 分页游标
+	Limit    int                  // 返回条数
+}
+
+// MessageSearchHit 搜索命中记录
+type MessageSearchHit struct {
+	models.TextMessage
+	Snippet string `gorm:"column:snippet"` // FTS 生成的摘要（LIKE 回退时为空）
+}
+
+// EnsureSearchIndex 创建 FTS5 全文索引及同步触发器
+// 仅 SQLite 且驱动支持 FTS5 时启用，否则搜索回退到 LIKE 查询
+func (r *TextMessageRepo) EnsureSearchIndex(ctx context.Context) (bool, error) {
+	db := r.db.WithContext(ctx)
+	if db.Dialector.Name() != DialectSqlite {
+		return false, nil
+	}
+
+	var exists int64
+	if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", textMessageFTSTable).
+		Scan(&exists).Error; err != nil {
+		return false, err
+	}
+
+	if exists == 0 {
+		err := db.Exec(`CREATE VIRTUAL TABLE ` + textMessageFTSTable + ` USING fts5(
+			content,
+			content = 'text_messages',
+			content_rowid = 'rowid',
+			tokenize = 'trigram'
+		)`).Error
+		if err != nil {
+			// 驱动未编译 FTS5 或 SQLite 版本过低（trigram 需要 3.34+）
+			if strings.Contains(err.Error(), "no such module") || strings.Contains(err.Error(), "no such tokenizer") {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+
+	triggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS text_messages_fts_ai AFTER INSERT ON text_messages BEGIN
+			INSERT INTO text_messages_fts(rowid, content) VALUES (new.rowid, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS text_messages_fts_ad AFTER DELETE ON text_messages BEGIN
+			INSERT INTO text_messages_fts(text_messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS text_messages_fts_au AFTER UPDATE OF content ON text_messages BEGIN
+			INSERT INTO text_messages_fts(text_messages_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+			INSERT INTO text_messages_fts(rowid, content) VALUES (new.rowid, new.content);
+		END`,
+	}
+	for _, trigger := range triggers {
+		if err := db.Exec(trigger).Error; err != nil {
+			return false, fmt.Errorf("创建全文索引触发器失败: %w", err)
+		}
+	}
+
+	// 新建索引时为已有数据建立索引
+	if exists == 0 {
+		if err := db.Exec("INSERT INTO " + textMessageFTSTable + "(" + textMessageFTSTable + ") VALUES ('rebuild')").Error; err != nil {
+			return false, fmt.Errorf("重建全文索引失败: %w", err)
+		}
+	}
+
+	r.searchIndex = true
+	return true, nil
+}
+
+// Search 按关键词和过滤条件搜索短信，按 created_at、id 倒序返回
+func (r *TextMessageRepo) Search(ctx context.Context, filter MessageSearchFilter) ([]MessageSearchHit, error) {
+	terms := strings.Fields(filter.Keyword)
+	db := r.db.WithContext(ctx)
+
+	query := db.Table("text_messages AS m").Select("m.*")
+	if len(terms) > 0 {
+		if r.searchIndex && termsIndexable(terms) {
+			query = db.Table(textMessageFTSTable).
+				Select("m.*, snippet("+textMessageFTSTable+", 0, ?, ?, '…', 32) AS snippet", HighlightStart, HighlightEnd).
+				Joins("JOIN text_messages m ON m.rowid = "+textMessageFTSTable+".rowid").
+				Where(textMessageFTSTable+" MATCH ?", ftsMatchExpression(terms))
+		} else {
+			for _, term := range terms {
+				query = query.Where(likeCondition(db, "m.content"), "%"+EscapeLike(term)+"%")
+			}
+		}
+	}
+
+	query = ScopeDevices(ctx, query.Where("m.deleted_at IS NULL"), "m.device_id")
+	if filter.DeviceID != "" {
+		query = query.Where("m.device_id = ?", filter.DeviceID)
+	}
+	if filter.Peer != "" {
+		query = query.Where("m.peer = ?", models.NormalizePeer(filter.Peer))
+	}
+	if filter.Type != "" {
+		query = query.Where("m.type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("m.status = ?", filter.Status)
+	}
+	if filter.StartAt > 0 {
+		query = query.Where("m.created_at >= ?", filter.StartAt)
+	}
+	if filter.EndAt > 0 {
+		query = query.Where("m.created_at < ?", filter.EndAt)
+	}
+	if filter.HasOTP != nil {
+		if *filter.HasOTP {
+			query = query.Where("m.otp_code != ''")
+		} else {
+			query = query.Where("(m.otp_code = '' OR m.otp_code IS NULL)")
+		}
+	}
+	if filter.Cursor != nil {
+		query = query.Where("(m.created_at < ? OR (m.created_at = ? AND m.id < ?))",
+			filter.Cursor.CreatedAt, filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+
+	var hits []MessageSearchHit
+	err := query.Order("m.created_at DESC").Order("m.id DESC").Limit(filter.Limit).Scan(&hits).Error
+	return hits, err
+}
+
+// termsIndexable 判断所有关键词是否满足 trigram 最短长度
+func termsIndexable(terms []string) bool {
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < trigramMinRunes {
+			return false
+		}
+	}
+	return true
+}
+
+// ftsMatchExpression 将关键词转为 FTS5 短语查询，避免用户输入被解析为查询语法
+func ftsMatchExpression(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// EscapeLike 转义 LIKE 通配符，配合 ESCAPE '!' 使用
+func EscapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
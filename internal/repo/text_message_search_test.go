@@ -0,0 +1,166 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	glebarez "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func seedSearchMessages(t *testing.T, repo *TextMessageRepo) {
+	ctx := context.Background()
+	msgs := []models.TextMessage{
+		{ID: "s1", From: "10086", Type: models.MessageTypeIncoming, Status: models.MessageStatusReceived,
+			Content: "您的验证码为 482913，请勿泄露", OTPCode: "482913", DeviceID: "dev-1", CreatedAt: 1000},
+		{ID: "s2", From: "95588", Type: models.MessageTypeIncoming, Status: models.MessageStatusReceived,
+			Content: "工商银行账户余额变动提醒", DeviceID: "dev-2", CreatedAt: 2000},
+		{ID: "s3", To: "10086", Type: models.MessageTypeOutgoing, Status: models.MessageStatusSent,
+			Content: "查询余额 CXYE", DeviceID: "dev-1", CreatedAt: 3000},
+		{ID: "s4", From: "10086", Type: models.MessageTypeIncoming, Status: models.MessageStatusReceived,
+			Content: "您的账户余额为 12.50 元", DeviceID: "dev-1", CreatedAt: 4000},
+	}
+	for i := range msgs {
+		if err := repo.Create(ctx, &msgs[i]); err != nil {
+			t.Fatalf("创建短信失败: %v", err)
+		}
+	}
+}
+
+func searchIDs(hits []MessageSearchHit) string {
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestTextMessageRepoSearchLikeFallback(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewTextMessageRepo(db)
+	ctx := context.Background()
+	seedSearchMessages(t, repo)
+
+	hasOTP := true
+	tests := []struct {
+		name   string
+		filter MessageSearchFilter
+		want   string
+	}{
+		{"关键词", MessageSearchFilter{Keyword: "余额"}, "s4,s3,s2"},
+		{"多关键词 AND", MessageSearchFilter{Keyword: "余额 账户"}, "s4,s2"},
+		{"设备过滤", MessageSearchFilter{Keyword: "余额", DeviceID: "dev-1"}, "s4,s3"},
+		{"会话过滤", MessageSearchFilter{Peer: "10086"}, "s4,s3,s1"},
+		{"类型过滤", MessageSearchFilter{Keyword: "余额", Type: models.MessageTypeOutgoing}, "s3"},
+		{"时间范围", MessageSearchFilter{StartAt: 2000, EndAt: 4000}, "s3,s2"},
+		{"验证码过滤", MessageSearchFilter{HasOTP: &hasOTP}, "s1"},
+		{"通配符转义", MessageSearchFilter{Keyword: "%"}, ""},
+		{"游标分页", MessageSearchFilter{Cursor: &Cursor{CreatedAt: 3000, ID: "s3"}}, "s2,s1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Limit = 10
+			hits, err := repo.Search(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("搜索失败: %v", err)
+			}
+			if got := searchIDs(hits); got != tt.want {
+				t.Errorf("搜索结果期望 %q，实际 %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTextMessageRepoSearchFTS(t *testing.T) {
+	// 生产环境使用的纯 Go SQLite 驱动内置 FTS5
+	db, err := gorm.Open(glebarez.Open(fmt.Sprintf("file:fts_%d?mode=memory&cache=shared", time.Now().UnixNano())), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(&models.TextMessage{}); err != nil {
+		t.Fatalf("数据库迁移失败: %v", err)
+	}
+
+	repo := NewTextMessageRepo(db)
+	ctx := context.Background()
+
+	// 先写入部分数据，验证建索引时会重建已有数据
+	if err := repo.Create(ctx, &models.TextMessage{ID: "old", Content: "历史短信中的工商银行通知", CreatedAt: 500}); err != nil {
+		t.Fatalf("创建短信失败: %v", err)
+	}
+
+	enabled, err := repo.EnsureSearchIndex(ctx)
+	if err != nil {
+		t.Fatalf("创建全文索引失败: %v", err)
+	}
+	if !enabled {
+		t.Fatal("期望启用 FTS5 全文索引")
+	}
+	// 重复调用应幂等
+	if _, err := repo.EnsureSearchIndex(ctx); err != nil {
+		t.Fatalf("重复创建全文索引失败: %v", err)
+	}
+
+	seedSearchMessages(t, repo)
+
+	hits, err := repo.Search(ctx, MessageSearchFilter{Keyword: "工商银行", Limit: 10})
+	if err != nil {
+		t.Fatalf("搜索失败: %v", err)
+	}
+	if got := searchIDs(hits); got != "s2,old" {
+		t.Fatalf("搜索结果期望 %q，实际 %q", "s2,old", got)
+	}
+	if !strings.Contains(hits[0].Snippet, HighlightStart+"工商银行"+HighlightEnd) {
+		t.Errorf("摘要未高亮命中词: %q", hits[0].Snippet)
+	}
+
+	// 更新和删除应同步到索引
+	if err := repo.UpdateColumnsById(ctx, "s2", map[string]interface{}{"content": "招商银行通知"}); err != nil {
+		t.Fatalf("更新短信失败: %v", err)
+	}
+	if err := repo.DeleteById(ctx, "old"); err != nil {
+		t.Fatalf("删除短信失败: %v", err)
+	}
+	hits, err = repo.Search(ctx, MessageSearchFilter{Keyword: "工商银行", Limit: 10})
+	if err != nil {
+		t.Fatalf("搜索失败: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("更新和删除后不应再命中，实际 %q", searchIDs(hits))
+	}
+
+	// 短于 3 个字的关键词回退到 LIKE
+	hits, err = repo.Search(ctx, MessageSearchFilter{Keyword: "余额", DeviceID: "dev-1", Limit: 10})
+	if err != nil {
+		t.Fatalf("短关键词搜索失败: %v", err)
+	}
+	if got := searchIDs(hits); got != "s4,s3" {
+		t.Errorf("短关键词搜索结果期望 %q，实际 %q", "s4,s3", got)
+	}
+}
+
+func TestCursorEncodeDecode(t *testing.T) {
+	c := Cursor{CreatedAt: 1700000000000, ID: "a:b"}
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("解析游标失败: %v", err)
+	}
+	if *decoded != c {
+		t.Errorf("游标不一致，期望 %+v，实际 %+v", c, *decoded)
+	}
+
+	if _, err := DecodeCursor("not-a-cursor!"); err == nil {
+		t.Error("无效游标应返回错误")
+	}
+	if empty, err := DecodeCursor(""); err != nil || empty != nil {
+		t.Error("空游标应返回 nil")
+	}
+}
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"gorm.io/gorm"
+)
+
+// SoftDeleteById 将短信移入回收站，返回删除条数
+func (r *TextMessageRepo) SoftDeleteById(ctx context.Context, id string) (int64, error) {
+	result := ScopeDevices(ctx, r.db.WithContext(ctx), "device_id").Where("id = ?", id).Delete(&models.TextMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// SoftDeleteAll 将所有短信移入回收站，返回删除条数
+func (r *TextMessageRepo) SoftDeleteAll(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Where("1 = 1").Delete(&models.TextMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// trashed 回收站查询（受 ctx 的设备范围限制）
+func (r *TextMessageRepo) trashed(ctx context.Context) *gorm.DB {
+	query := r.db.WithContext(ctx).Unscoped().Model(&models.TextMessage{}).Where("deleted_at IS NOT NULL")
+	return ScopeDevices(ctx, query, "device_id")
+}
+
+// FindTrash 按时间倒序游标分页查询回收站中的短信
+func (r *TextMessageRepo) FindTrash(ctx context.Context, cursor *Cursor, limit int) ([]models.TextMessage, error) {
+	query := r.trashed(ctx)
+	if cursor != nil {
+		query = query.Where("(created_at < ? OR (created_at = ? AND id < ?))", cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	var msgs []models.TextMessage
+	err := query.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// RestoreById 从回收站恢复单条短信，返回恢复条数
+func (r *TextMessageRepo) RestoreById(ctx context.Context, id string) (int64, error) {
+	return r.restore(r.trashed(ctx).Where("id = ?", id))
+}
+
+// RestoreByPeer 从回收站恢复会话的所有短信，返回恢复条数
+func (r *TextMessageRepo) RestoreByPeer(ctx context.Context, peer string) (int64, error) {
+	return r.restore(r.trashed(ctx).Where("peer = ?", peer))
+}
+
+// RestoreAll 恢复回收站中的所有短信，返回恢复条数
+func (r *TextMessageRepo) RestoreAll(ctx context.Context) (int64, error) {
+	return r.restore(r.trashed(ctx))
+}
+
+func (r *TextMessageRepo) restore(query *gorm.DB) (int64, error) {
+	result := query.UpdateColumn("deleted_at", nil)
+	return result.RowsAffected, result.Error
+}
+
+// CountTrash 统计回收站中删除时间早于 before 的短信，before 为零值时统计全部
+func (r *TextMessageRepo) CountTrash(ctx context.Context, before time.Time) (int64, error) {
+	query := r.trashed(ctx)
+	if !before.IsZero() {
+		query = query.Where("deleted_at < ?", before)
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+// PurgeTrash 分批永久删除回收站中删除时间早于 before 的短信，before 为零值时清空回收站
+func (r *TextMessageRepo) PurgeTrash(ctx context.Context, before time.Time, batchSize int) (int64, error) {
+	ids := r.trashed(ctx)
+	if !before.IsZero() {
+		ids = ids.Where("deleted_at < ?", before)
+	}
+	return deleteByIDs(r.db.WithContext(ctx), &models.TextMessage{}, ids, batchSize)
+}
@@ -0,0 +1,76 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/go-orz/orz"
+	"gorm.io/gorm"
+)
+
+// UserRepo 用户数据访问层
+type UserRepo struct {
+	orz.Repository[models.User, string]
+	db *gorm.DB
+}
+
+// NewUserRepo 创建用户仓储实例
+func NewUserRepo(db *gorm.DB) *UserRepo {
+	return &UserRepo{
+		Repository: orz.NewRepository[models.User, string](db),
+		db:         db,
+	}
+}
+
+// FindByUsername 根据用户名查找用户
+func (r *UserRepo) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindAll 查找所有用户，按用户名排序
+func (r *UserRepo) FindAll(ctx context.Context) ([]models.User, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).Order("username").Find(&users).Error
+	return users, err
+}
+
+// CountByRole 统计指定角色的用户数量
+func (r *UserRepo) CountByRole(ctx context.Context, role models.UserRole) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", role).Count(&count).Error
+	return count, err
+}
+
+// CountBySource 统计指定来源的用户数量
+func (r *UserRepo) CountBySource(ctx context.Context, source models.UserSource) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("source = ?", source).Count(&count).Error
+	return count, err
+}
+
+// UpdateLastLogin 更新最近登录时间
+func (r *UserRepo) UpdateLastLogin(ctx context.Context, id string, lastLogin int64) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).
+		UpdateColumn("last_login", lastLogin).Error
+}
+
+// UpdateTOTPStep 记录已使用的验证码时间步，时间步不大于已记录的值时返回 false（验证码被重放）
+func (r *UserRepo) UpdateTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		UpdateColumn("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateRecoveryCodes 在恢复码未被并发修改时替换为新的恢复码，用于消耗恢复码
+func (r *UserRepo) UpdateRecoveryCodes(ctx context.Context, id, old, codes string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND recovery_codes = ?", id, old).
+		UpdateColumn("recovery_codes", codes)
+	return result.RowsAffected > 0, result.Error
+}
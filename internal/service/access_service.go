@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDeviceForbidden 当前用户没有该设备的访问授权
+	ErrDeviceForbidden = errors.New("无权访问该设备")
+	// ErrInvalidGrant 授权无效
+	ErrInvalidGrant = errors.New("必须且只能指定 deviceId 或 groupName 其中之一")
+	// ErrGrantExists 授权已存在
+	ErrGrantExists = errors.New("授权已存在")
+)
+
+// GrantRequest 添加授权请求
+type GrantRequest struct {
+	DeviceID  string `json:"deviceId"`
+	GroupName string `json:"groupName"`
+}
+
+// AccessService 用户设备访问授权服务
+type AccessService struct {
+	logger     *zap.Logger
+	repo       *repo.DeviceGrantRepo
+	userRepo   *repo.UserRepo
+	deviceRepo *repo.DeviceRepo
+}
+
+// NewAccessService 创建设备访问授权服务实例
+func NewAccessService(logger *zap.Logger, db *gorm.DB) *AccessService {
+	return &AccessService{
+		logger:     logger,
+		repo:       repo.NewDeviceGrantRepo(db),
+		userRepo:   repo.NewUserRepo(db),
+		deviceRepo: repo.NewDeviceRepo(db),
+	}
+}
+
+// ListGrants 获取用户的所有授权
+func (s *AccessService) ListGrants(ctx context.Context, userID string) ([]models.DeviceGrant, error) {
+	if _, err := s.userRepo.FindById(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByUser(ctx, userID)
+}
+
+// Grant 授权用户访问单个设备或整个设备分组
+func (s *AccessService) Grant(ctx context.Context, userID string, req *GrantRequest) (*models.DeviceGrant, error) {
+	deviceID := strings.TrimSpace(req.DeviceID)
+	groupName := strings.TrimSpace(req.GroupName)
+	if (deviceID == "") == (groupName == "") {
+		return nil, ErrInvalidGrant
+	}
+	if _, err := s.userRepo.FindById(ctx, userID); err != nil {
+		return nil, err
+	}
+	if deviceID != "" {
+		if _, err := s.deviceRepo.FindById(ctx, deviceID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidGrant
+			}
+			return nil, err
+		}
+	}
+
+	grant := &models.DeviceGrant{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		DeviceID:  deviceID,
+		GroupName: groupName,
+	}
+	exists, err := s.repo.ExistsGrant(ctx, grant)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrGrantExists
+	}
+	if err := s.repo.Create(ctx, grant); err != nil {
+		return nil, err
+	}
+	return grant, nil
+}
+
+// Revoke 撤销用户的单个授权
+func (s *AccessService) Revoke(ctx context.Context, userID, grantID string) error {
+	affected, err := s.repo.DeleteByUserAndId(ctx, userID, grantID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ScopeContext 按用户的授权限制 ctx 可访问的设备，管理员不受限制
+func (s *AccessService) ScopeContext(ctx context.Context, user *models.User) (context.Context, error) {
+	if user == nil || user.Role == models.RoleAdmin {
+		return ctx, nil
+	}
+	deviceIDs, err := s.repo.FindGrantedDeviceIDs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return repo.WithDeviceScope(ctx, deviceIDs), nil
+}
+
+// checkSendDevice 后台发送（批量任务、活动、定时任务）执行时不带用户信息，
+// 受限用户提交时必须指定一个已授权的设备，不能交给自动选择
+func checkSendDevice(ctx context.Context, deviceID string) error {
+	if _, restricted := repo.DeviceScopeFrom(ctx); !restricted {
+		return nil
+	}
+	if deviceID == "" || deviceID == TaskDeviceAuto || !repo.InDeviceScope(ctx, deviceID) {
+		return ErrDeviceForbidden
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestAccessServiceScope(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserService(zap.NewNop(), db)
+	access := NewAccessService(zap.NewNop(), db)
+	deviceRepo := repo.NewDeviceRepo(db)
+	messages := NewTextMessageService(zap.NewNop(), repo.NewTextMessageRepo(db), repo.NewConversationStateRepo(db))
+
+	for _, device := range []models.Device{
+		{ID: "dev-a", SerialPort: "/dev/ttyUSB0", GroupName: "香港"},
+		{ID: "dev-b", SerialPort: "/dev/ttyUSB1", GroupName: "内地"},
+		{ID: "dev-c", SerialPort: "/dev/ttyUSB2", GroupName: "内地"},
+		{ID: "dev-d", SerialPort: "/dev/ttyUSB3"},
+	} {
+		if err := deviceRepo.Create(ctx, &device); err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+		msg := &models.TextMessage{ID: "msg-" + device.ID, From: "1000-" + device.ID, Content: "hi", Type: models.MessageTypeIncoming, DeviceID: device.ID}
+		if err := messages.Save(ctx, msg); err != nil {
+			t.Fatalf("Failed to save message: %v", err)
+		}
+	}
+
+	viewer, err := users.Create(ctx, &CreateUserRequest{Username: "viewer", Password: "viewer-password", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// 授权校验
+	if _, err := access.Grant(ctx, viewer.ID, &GrantRequest{}); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("Expected ErrInvalidGrant, got %v", err)
+	}
+	if _, err := access.Grant(ctx, viewer.ID, &GrantRequest{DeviceID: "missing"}); !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("Expected ErrInvalidGrant for missing device, got %v", err)
+	}
+
+	// 没有授权时看不到任何设备
+	scoped, err := access.ScopeContext(ctx, viewer)
+	if err != nil {
+		t.Fatalf("ScopeContext failed: %v", err)
+	}
+	if devices, _ := deviceRepo.FindAll(scoped); len(devices) != 0 {
+		t.Errorf("Expected no devices without grants, got %d", len(devices))
+	}
+
+	if _, err := access.Grant(ctx, viewer.ID, &GrantRequest{DeviceID: "dev-a"}); err != nil {
+		t.Fatalf("Grant device failed: %v", err)
+	}
+	if _, err := access.Grant(ctx, viewer.ID, &GrantRequest{GroupName: "内地"}); err != nil {
+		t.Fatalf("Grant group failed: %v", err)
+	}
+	if _, err := access.Grant(ctx, viewer.ID, &GrantRequest{DeviceID: "dev-a"}); !errors.Is(err, ErrGrantExists) {
+		t.Errorf("Expected ErrGrantExists, got %v", err)
+	}
+
+	scoped, err = access.ScopeContext(ctx, viewer)
+	if err != nil {
+		t.Fatalf("ScopeContext failed: %v", err)
+	}
+	if devices, _ := deviceRepo.FindAll(scoped); len(devices) != 3 {
+		t.Errorf("Expected 3 granted devices, got %d", len(devices))
+	}
+	if _, err := deviceRepo.FindById(scoped, "dev-d"); err == nil {
+		t.Error("未授权的设备不应能查到")
+	}
+
+	page, err := messages.GetConversations(scoped, ConversationQuery{})
+	if err != nil {
+		t.Fatalf("GetConversations failed: %v", err)
+	}
+	if len(page.Items) != 3 {
+		t.Errorf("Expected 3 conversations, got %d", len(page.Items))
+	}
+	stats, err := messages.GetStats(scoped)
+	if err != nil || stats.TotalCount != 3 {
+		t.Errorf("Expected 3 messages in stats, got %v %+v", err, stats)
+	}
+
+	// 受限用户的后台发送必须指定已授权的设备
+	if err := checkSendDevice(scoped, ""); !errors.Is(err, ErrDeviceForbidden) {
+		t.Errorf("Expected ErrDeviceForbidden for auto device, got %v", err)
+	}
+	if err := checkSendDevice(scoped, "dev-d"); !errors.Is(err, ErrDeviceForbidden) {
+		t.Errorf("Expected ErrDeviceForbidden for dev-d, got %v", err)
+	}
+	if err := checkSendDevice(scoped, "dev-b"); err != nil {
+		t.Errorf("dev-b 已通过分组授权: %v", err)
+	}
+
+	// 管理员不受限制
+	adminCtx, _ := access.ScopeContext(ctx, &models.User{Role: models.RoleAdmin})
+	if devices, _ := deviceRepo.FindAll(adminCtx); len(devices) != 4 {
+		t.Errorf("Expected admin to see 4 devices, got %d", len(devices))
+	}
+
+	// 撤销授权
+	grants, _ := access.ListGrants(ctx, viewer.ID)
+	if len(grants) != 2 {
+		t.Fatalf("Expected 2 grants, got %d", len(grants))
+	}
+	groupGrant := grants[0]
+	if groupGrant.GroupName == "" {
+		groupGrant = grants[1]
+	}
+	if err := access.Revoke(ctx, viewer.ID, groupGrant.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	scoped, _ = access.ScopeContext(ctx, viewer)
+	if ids, _ := repo.DeviceScopeFrom(scoped); len(ids) != 1 || ids[0] != "dev-a" {
+		t.Errorf("Expected only dev-a after revoke, got %v", ids)
+	}
+}
+
+func TestDeviceScope_TasksCampaignsJobs(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	scheduler := NewSchedulerService(zap.NewNop(), db, nil, nil)
+	campaigns := NewCampaignService(zap.NewNop(), db, nil, nil)
+	batchJobs := NewBatchJobService(zap.NewNop(), db, nil)
+	keepAlive := NewKeepAliveService(zap.NewNop(), db, scheduler)
+
+	for _, id := range []string{"dev-a", "dev-b"} {
+		if err := repo.NewDeviceRepo(db).Create(ctx, &models.Device{ID: id, Name: id, SerialPort: "/dev/" + id}); err != nil {
+			t.Fatalf("Failed to create device: %v", err)
+		}
+	}
+
+	// 管理员在 dev-b 上创建的任务、群发、批量发送和保号策略
+	task := &models.ScheduledTask{Name: "b", Enabled: true, DeviceID: "dev-b", ActionType: models.TaskActionReboot, ScheduleType: models.ScheduleTypeCron, CronExpr: "0 0 1 1 *"}
+	if err := scheduler.Create(ctx, task); err != nil {
+		t.Fatalf("Create task failed: %v", err)
+	}
+	campaign := &models.Campaign{Name: "b", Content: "hi", DeviceID: "dev-b"}
+	if err := campaigns.Create(ctx, campaign, []CampaignRecipientInput{{PhoneNumber: "10086"}}); err != nil {
+		t.Fatalf("Create campaign failed: %v", err)
+	}
+	job := &models.BatchJob{ID: "job-b", Content: "hi", DeviceID: "dev-b", Status: models.BatchJobStatusCompleted}
+	if err := repo.NewBatchJobRepo(db).Create(ctx, job); err != nil {
+		t.Fatalf("Create batch job failed: %v", err)
+	}
+	policy := &models.KeepAlivePolicy{DeviceID: "dev-b", Enabled: true, Activity: models.TaskActionUSSD, IntervalDays: 90, UssdCode: "*100#"}
+	if err := keepAlive.Save(ctx, policy); err != nil {
+		t.Fatalf("Save keep-alive failed: %v", err)
+	}
+
+	// 只能访问 dev-a 的用户
+	scoped := repo.WithDeviceScope(ctx, []string{"dev-a"})
+	notFound := func(name string, err error) {
+		t.Helper()
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("%s: expected ErrRecordNotFound, got %v", name, err)
+		}
+	}
+
+	if tasks, err := scheduler.GetAll(scoped); err != nil || len(tasks) != 0 {
+		t.Errorf("Expected no visible tasks, got %d, %v", len(tasks), err)
+	}
+	_, err := scheduler.GetById(scoped, task.ID)
+	notFound("GetById", err)
+	notFound("TriggerTask", scheduler.TriggerTask(scoped, task.ID))
+	notFound("Delete", scheduler.Delete(scoped, task.ID))
+	_, err = scheduler.GetRuns(scoped, task.ID, "", 10)
+	notFound("GetRuns", err)
+	// 不能把其他设备的任务改到自己的设备上
+	notFound("Update", scheduler.Update(scoped, &models.ScheduledTask{ID: task.ID, Name: "a", Enabled: true, DeviceID: "dev-a", ActionType: models.TaskActionReboot, ScheduleType: models.ScheduleTypeCron, CronExpr: "0 0 1 1 *"}))
+	if err := scheduler.Create(scoped, &models.ScheduledTask{Name: "hook", ActionType: models.TaskActionWebhook, WebhookURL: "http://example.com"}); !errors.Is(err, ErrDeviceForbidden) {
+		t.Errorf("Expected ErrDeviceForbidden for unbound webhook task, got %v", err)
+	}
+
+	if list, err := campaigns.List(scoped); err != nil || len(list) != 0 {
+		t.Errorf("Expected no visible campaigns, got %d, %v", len(list), err)
+	}
+	_, err = campaigns.GetById(scoped, campaign.ID)
+	notFound("Campaign GetById", err)
+	_, err = campaigns.GetRecipients(scoped, campaign.ID, "", "", 10)
+	notFound("GetRecipients", err)
+	notFound("Pause", campaigns.Pause(scoped, campaign.ID))
+	notFound("Cancel", campaigns.Cancel(scoped, campaign.ID))
+	notFound("Campaign Delete", campaigns.Delete(scoped, campaign.ID))
+
+	_, err = batchJobs.Get(scoped, job.ID)
+	notFound("BatchJob Get", err)
+
+	if items, err := keepAlive.Dashboard(scoped); err != nil || len(items) != 0 {
+		t.Errorf("Expected no visible keep-alive policies, got %d, %v", len(items), err)
+	}
+	_, err = keepAlive.Get(scoped, "dev-b")
+	notFound("KeepAlive Get", err)
+
+	// 管理员仍可访问
+	if _, err := scheduler.GetById(ctx, task.ID); err != nil {
+		t.Errorf("Admin GetById failed: %v", err)
+	}
+	if fetched, err := campaigns.GetById(ctx, campaign.ID); err != nil || fetched.Status != models.CampaignStatusScheduled {
+		t.Errorf("Expected campaign untouched, got %+v, %v", fetched, err)
+	}
+	if _, err := batchJobs.Get(ctx, job.ID); err != nil {
+		t.Errorf("Admin batch job Get failed: %v", err)
+	}
+}
@@ -2,188 +2,168 @@ package service
 
 import (
 	"context"
-	"time"
 
-	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
 	"github.com/go-errors/errors"
-	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func NewAccountService(logger *zap.Logger, oidcService *OIDCService, appConfig *config.AppConfig) *AccountService {
-	jwtSecret := appConfig.JWT.Secret
-	tokenExpireHours := appConfig.JWT.ExpiresHours
-
-	if jwtSecret == "" {
-		logger.Fatal("JWT secret cannot be empty")
-	}
-	if len(jwtSecret) < 32 {
-		logger.Warn("JWT secret is too short, should be at least 32 characters for security")
-	}
-	if tokenExpireHours <= 0 {
-		tokenExpireHours = 168 // 默认7天
+func NewAccountService(logger *zap.Logger, oidcService *OIDCService, userService *UserService, sessionService *SessionService,
+	totpService *TOTPService, loginGuard *LoginGuard) *AccountService {
+	return &AccountService{
+		logger:         logger,
+		oidcService:    oidcService,
+		userService:    userService,
+		sessionService: sessionService,
+		totpService:    totpService,
+		loginGuard:     loginGuard,
 	}
-
-	service := &AccountService{
-		logger:           logger,
-		oidcService:      oidcService,
-		jwtSecret:        jwtSecret,
-		tokenExpireHours: tokenExpireHours,
-		users:            appConfig.Users,
-	}
-	return service
 }
 
 type AccountService struct {
-	logger           *zap.Logger
-	oidcService      *OIDCService
-	jwtSecret        string
-	tokenExpireHours int
-
-	users map[string]string
-}
-
-// JWTClaims JWT 声明
-type JWTClaims struct {
-	UserID   string `json:"userId"`
-	Username string `json:"username"`
-	jwt.RegisteredClaims
+	logger         *zap.Logger
+	oidcService    *OIDCService
+	userService    *UserService
+	sessionService *SessionService
+	totpService    *TOTPService
+	loginGuard     *LoginGuard
 }
 
 // UserInfo 用户信息（简化版）
 type UserInfo struct {
-	Username string `json:"username"`
+	ID       string          `json:"id"`
+	Username string          `json:"username"`
+	Nickname string          `json:"nickname"`
+	Role     models.UserRole `json:"role"`
+}
+
+// newUserInfo 从用户生成用户信息
+func newUserInfo(user *models.User) *UserInfo {
+	return &UserInfo{
+		ID:       user.ID,
+		Username: user.Username,
+		Nickname: user.Nickname,
+		Role:     user.Role,
+	}
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt int64     `json:"expiresAt"`
-	User      *UserInfo `json:"user"`
+	*TokenPair
+	User *UserInfo `json:"user"`
 }
 
-// Login 用户登录（Basic Auth）
-func (s *AccountService) Login(ctx context.Context, username, password string) (*LoginResponse, error) {
-	// 使用 Basic Auth 验证
-	if err := s.ValidateCredentials(ctx, username, password); err != nil {
+// Login 用户登录（Basic Auth），启用两步验证的用户还需要提供验证码或恢复码
+// 同一 IP 或用户名失败次数过多时返回 *LoginLockedError
+func (s *AccountService) Login(ctx context.Context, username, password, code string, client ClientInfo) (*LoginResponse, error) {
+	if err := s.loginGuard.Check(client.IP, username); err != nil {
 		return nil, err
 	}
 
-	// 生成 JWT token
-	token, expiresAt, err := s.generateToken(username, username)
+	user, err := s.userService.Authenticate(ctx, username, password)
 	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			s.loginGuard.Fail(client.IP, username)
+		}
 		return nil, err
 	}
+	if user.TOTPEnabled {
+		// 未提交验证码时提示客户端输入，不计入失败次数
+		if code == "" {
+			return nil, ErrTOTPRequired
+		}
+		if err := s.totpService.Verify(ctx, user, code); err != nil {
+			if errors.Is(err, ErrInvalidTOTP) {
+				s.loginGuard.Fail(client.IP, username)
+			}
+			return nil, err
+		}
+		s.userService.RecordLogin(ctx, user)
+	}
+	s.loginGuard.Succeed(username)
 
-	s.logger.Info("用户登录成功", zap.String("username", username))
+	// 创建会话并签发令牌
+	pair, err := s.sessionService.Issue(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("用户登录成功", zap.String("username", username), zap.String("role", string(user.Role)))
 
 	return &LoginResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		User: &UserInfo{
-			Username: username,
-		},
+		TokenPair: pair,
+		User:      newUserInfo(user),
 	}, nil
 }
 
 // LoginWithOIDC OIDC 登录
-func (s *AccountService) LoginWithOIDC(ctx context.Context, code, state string) (*LoginResponse, error) {
+func (s *AccountService) LoginWithOIDC(ctx context.Context, code, state string, client ClientInfo) (*LoginResponse, error) {
 	// 使用 OIDC 验证
-	username, nickname, err := s.oidcService.ExchangeCode(ctx, code, state)
+	identity, err := s.oidcService.ExchangeCode(ctx, code, state)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按分组映射角色，同步到用户表
+	user, err := s.userService.SyncOIDCUser(ctx, identity.Username, identity.Nickname, s.oidcService.MapRole(identity.Groups))
 	if err != nil {
 		return nil, err
 	}
 
-	// 生成 JWT token
-	token, expiresAt, err := s.generateToken(username, nickname)
+	// 创建会话并签发令牌，保存 ID Token 用于退出 IdP 会话
+	pair, err := s.sessionService.IssueOIDC(ctx, user, client, identity.IDToken)
 	if err != nil {
 		return nil, err
 	}
 
-	s.logger.Info("OIDC 登录成功", zap.String("username", username))
+	s.logger.Info("OIDC 登录成功", zap.String("username", user.Username), zap.String("role", string(user.Role)))
 
 	return &LoginResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		User: &UserInfo{
-			Username: username,
-		},
+		TokenPair: pair,
+		User:      newUserInfo(user),
 	}, nil
 }
 
-// generateToken 生成 JWT token
-func (s *AccountService) generateToken(username, nickname string) (string, int64, error) {
-	expiresAt := time.Now().Add(time.Duration(s.tokenExpireHours) * time.Hour)
-	claims := &JWTClaims{
-		UserID:   username, // 使用 username 作为 userID
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expiresAt),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "pika",
-			Subject:   username,
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+// Refresh 使用刷新令牌换取新令牌
+func (s *AccountService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*LoginResponse, error) {
+	pair, user, err := s.sessionService.Refresh(ctx, refreshToken, client)
 	if err != nil {
-		s.logger.Error("生成token失败", zap.Error(err))
-		return "", 0, errors.New("生成token失败")
+		return nil, err
 	}
-
-	return tokenString, expiresAt.UnixMilli(), nil
+	return &LoginResponse{
+		TokenPair: pair,
+		User:      newUserInfo(user),
+	}, nil
 }
 
-// Logout 用户登出
-func (s *AccountService) Logout(ctx context.Context, userID string) error {
+// Logout 用户登出，撤销当前会话
+// OIDC 登录且启用了 RP-initiated logout 时返回退出 IdP 会话的地址，由前端跳转
+func (s *AccountService) Logout(ctx context.Context, userID, sessionID string) (string, error) {
+	session, err := s.sessionService.Get(ctx, userID, sessionID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.sessionService.Revoke(ctx, userID, sessionID); err != nil {
+		return "", err
+	}
+	s.logger.Info("用户登出成功", zap.String("userID", userID), zap.String("session", sessionID))
 
-	s.logger.Info("用户登出成功", zap.String("userID", userID))
-	return nil
+	if session.IDToken == "" {
+		return "", nil
+	}
+	return s.oidcService.LogoutURL(session.IDToken), nil
 }
 
 // ValidateCredentials 验证用户名和密码
 func (s *AccountService) ValidateCredentials(ctx context.Context, username, password string) error {
-	// 从配置中获取用户的bcrypt密码哈希
-	hashedPassword, exists := s.users[username]
-	if !exists {
-		s.logger.Debug("用户不存在", zap.String("username", username))
-		return errors.New("用户名或密码错误")
-	}
-
-	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)); err != nil {
-		s.logger.Debug("密码验证失败", zap.String("username", username), zap.Error(err))
-		return errors.New("用户名或密码错误")
+	if _, err := s.userService.Authenticate(ctx, username, password); err != nil {
+		return err
 	}
 
 	s.logger.Info("User 认证成功", zap.String("username", username))
 	return nil
 }
 
-// ValidateToken 验证 JWT token
-func (s *AccountService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名方法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("无效的签名方法")
-		}
-		return []byte(s.jwtSecret), nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-		return claims, nil
-	}
-
-	return nil, errors.New("无效的token")
-}
-
 // AuthConfig 认证配置
 type AuthConfig struct {
 	OIDCEnabled     bool `json:"oidcEnabled"`
@@ -192,10 +172,14 @@ type AuthConfig struct {
 }
 
 // GetAuthConfig 获取认证配置
-func (s *AccountService) GetAuthConfig() *AuthConfig {
+func (s *AccountService) GetAuthConfig(ctx context.Context) *AuthConfig {
+	passwordEnabled, err := s.userService.HasLocalUsers(ctx)
+	if err != nil {
+		s.logger.Error("查询本地用户失败", zap.Error(err))
+	}
 	return &AuthConfig{
 		OIDCEnabled:     s.oidcService.IsEnabled(),
-		PasswordEnabled: len(s.users) > 0,
+		PasswordEnabled: passwordEnabled,
 	}
 }
 
@@ -206,12 +190,12 @@ type OIDCAuthURL struct {
 }
 
 // GetOIDCAuthURL 获取 OIDC 认证 URL
-func (s *AccountService) GetOIDCAuthURL() (*OIDCAuthURL, error) {
+func (s *AccountService) GetOIDCAuthURL(ctx context.Context) (*OIDCAuthURL, error) {
 	if !s.oidcService.IsEnabled() {
 		return nil, errors.New("OIDC 未启用")
 	}
 
-	authURL, state, err := s.oidcService.GenerateAuthURL()
+	authURL, state, err := s.oidcService.GenerateAuthURL(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -28,9 +29,18 @@ func TestAccountService(t *testing.T) {
 		},
 	}
 
-	// Mock OIDCService (nil is fine for basic auth tests)
-	svc := NewAccountService(logger, &OIDCService{}, appConfig)
+	// 配置文件中的用户首次启动时导入为管理员
 	ctx := context.Background()
+	db := setupTestDB(t)
+	userService := NewUserService(logger, db)
+	if err := userService.Bootstrap(ctx, appConfig.Users); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+
+	// Mock OIDCService (nil is fine for basic auth tests)
+	sessionService := NewSessionService(logger, db, appConfig.JWT)
+	svc := NewAccountService(logger, &OIDCService{}, userService, sessionService,
+		NewTOTPService(logger, db, ""), NewLoginGuard(logger, config.LoginConfig{}))
 
 	// 1. Test ValidateCredentials
 	err := svc.ValidateCredentials(ctx, "admin", "secret123")
@@ -49,61 +59,59 @@ func TestAccountService(t *testing.T) {
 	}
 
 	// 2. Test Login (Basic Auth)
-	resp, err := svc.Login(ctx, "admin", "secret123")
+	resp, err := svc.Login(ctx, "admin", "secret123", "", ClientInfo{IP: "127.0.0.1"})
 	if err != nil {
 		t.Fatalf("Login failed: %v", err)
 	}
-	if resp.Token == "" {
+	if resp.Token == "" || resp.RefreshToken == "" {
 		t.Error("Login response token is empty")
 	}
 	if resp.User.Username != "admin" {
 		t.Errorf("Expected username 'admin', got '%s'", resp.User.Username)
 	}
+	if resp.User.Role != models.RoleAdmin {
+		t.Errorf("Expected role 'admin', got '%s'", resp.User.Role)
+	}
 
 	// 3. Test Token Validation
-	claims, err := svc.ValidateToken(resp.Token)
+	user, sessionID, err := sessionService.Authenticate(ctx, resp.Token)
 	if err != nil {
-		t.Fatalf("ValidateToken failed: %v", err)
+		t.Fatalf("Authenticate failed: %v", err)
 	}
-	if claims.Username != "admin" {
-		t.Errorf("Token claim username wrong: %s", claims.Username)
+	if user.Username != "admin" || sessionID != resp.SessionID {
+		t.Errorf("Token user or session wrong: %s %s", user.Username, sessionID)
 	}
 
 	// 4. Test Invalid Token
-	_, err = svc.ValidateToken("invalid-token")
-	if err == nil {
-		t.Error("ValidateToken should fail for invalid token")
+	if _, _, err = sessionService.Authenticate(ctx, "invalid-token"); err == nil {
+		t.Error("Authenticate should fail for invalid token")
+	}
+
+	// 5. Test Logout
+	if _, err := svc.Logout(ctx, user.ID, sessionID); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	if _, _, err = sessionService.Authenticate(ctx, resp.Token); err == nil {
+		t.Error("Authenticate should fail after logout")
 	}
 }
 
 func TestAccountServiceTokenExpiry(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
-	appConfig := &config.AppConfig{
-		JWT: config.JWTConfig{
-			Secret:       "very-long-secret-key-at-least-32-bytes",
-			ExpiresHours: -1, // Expired immediately (effectively, logical check)
-		},
-		Users: map[string]string{},
+	jwtConfig := config.JWTConfig{
+		Secret:             "very-long-secret-key-at-least-32-bytes",
+		ExpiresHours:       1,
+		AccessTokenMinutes: 5,
 	}
+	sessionService := NewSessionService(logger, setupTestDB(t), jwtConfig)
 
-	// Note: AccountService constructor sets default to 168 if <= 0
-	// So we can't easily test "expired immediately" without modifying the service code
-	// or using a very short duration and sleeping.
-	// Let's verify the default behavior instead.
-
-	svc := NewAccountService(logger, &OIDCService{}, appConfig)
-
-	// Override duration for test via reflection or just trust the logic?
-	// Since struct fields are private/unexported, we can't easily change them.
-	// But generateToken uses s.tokenExpireHours.
-
-	// Let's just test that a generated token has correct expiration claim
-	token, _, err := svc.generateToken("user", "User")
+	// 访问令牌使用较短的有效期，刷新令牌使用会话有效期
+	pair, err := sessionService.Issue(context.Background(), &models.User{ID: "1", Username: "user", Role: models.RoleViewer}, ClientInfo{})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	claims, err := svc.ValidateToken(token)
+	claims, err := sessionService.ParseAccessToken(pair.Token)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,4 +119,10 @@ func TestAccountServiceTokenExpiry(t *testing.T) {
 	if claims.ExpiresAt.Time.Before(time.Now()) {
 		t.Error("Token shouldn't be expired yet")
 	}
+	if claims.ExpiresAt.Time.After(time.Now().Add(6 * time.Minute)) {
+		t.Errorf("Access token should expire within 5 minutes, got %v", claims.ExpiresAt.Time)
+	}
+	if pair.RefreshExpiresAt < time.Now().Add(59*time.Minute).UnixMilli() {
+		t.Errorf("Refresh token should expire after 1 hour, got %d", pair.RefreshExpiresAt)
+	}
 }
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// auditCSVColumns 审计日志 CSV 导出列
+var auditCSVColumns = []string{"id", "createdAt", "actor", "action", "target", "ip", "method", "path", "params", "status", "success", "error"}
+
+// AuditService 审计日志服务
+type AuditService struct {
+	logger *zap.Logger
+	repo   *repo.AuditLogRepo
+}
+
+// NewAuditService 创建审计日志服务实例
+func NewAuditService(logger *zap.Logger, db *gorm.DB) *AuditService {
+	return &AuditService{
+		logger: logger,
+		repo:   repo.NewAuditLogRepo(db),
+	}
+}
+
+// Record 保存一条审计日志，失败只记录日志，不影响业务请求
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditLog) {
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = time.Now().UnixMilli()
+	}
+	if err := s.repo.Create(ctx, entry); err != nil {
+		s.logger.Error("保存审计日志失败", zap.String("actor", entry.Actor), zap.String("action", entry.Action), zap.Error(err))
+	}
+}
+
+// Query 按时间倒序游标分页查询审计日志
+func (s *AuditService) Query(ctx context.Context, filter repo.AuditLogFilter, cursor string, limit int) (*Page[models.AuditLog], error) {
+	after, err := repo.DecodeCursor(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	limit = normalizePageLimit(limit)
+
+	logs, err := s.repo.Find(ctx, filter, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+
+	page := &Page[models.AuditLog]{Items: logs}
+	if len(logs) > limit {
+		page.Items = logs[:limit]
+		last := logs[limit-1]
+		page.NextCursor = repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	if page.Items == nil {
+		page.Items = []models.AuditLog{}
+	}
+	return page, nil
+}
+
+// ExportCSV 按时间倒序将符合条件的审计日志写为 CSV，返回导出条数
+func (s *AuditService) ExportCSV(ctx context.Context, filter repo.AuditLogFilter, w io.Writer) (int64, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return 0, err
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(auditCSVColumns); err != nil {
+		return 0, err
+	}
+
+	var exported int64
+	var after *repo.Cursor
+	for {
+		logs, err := s.repo.Find(ctx, filter, after, exportBatchSize)
+		if err != nil {
+			return exported, fmt.Errorf("查询审计日志失败: %w", err)
+		}
+		for _, log := range logs {
+			if err := writer.Write([]string{
+				log.ID,
+				strconv.FormatInt(log.CreatedAt, 10),
+				log.Actor,
+				log.Action,
+				log.Target,
+				log.IP,
+				log.Method,
+				log.Path,
+				log.Params,
+				strconv.Itoa(log.Status),
+				strconv.FormatBool(log.Success),
+				log.Error,
+			}); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return exported, err
+		}
+		if flusher, ok := w.(interface{ Flush() }); ok {
+			flusher.Flush()
+		}
+		if len(logs) < exportBatchSize {
+			break
+		}
+		last := logs[len(logs)-1]
+		after = &repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return exported, nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"testing"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"go.uber.org/zap"
+)
+
+func TestAuditService(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAuditService(zap.NewNop(), setupTestDB(t))
+
+	for i, entry := range []models.AuditLog{
+		{Actor: "alice", Action: "POST /api/devices/:id/reboot", Target: "dev-1", Status: 200, Success: true},
+		{Actor: "bob", Action: "DELETE /api/messages", Status: 200, Success: true},
+		{Actor: "alice", Action: "POST /api/sms/send", Status: 500, Error: "发送短信失败"},
+	} {
+		entry.CreatedAt = int64(1000 + i)
+		svc.Record(ctx, &entry)
+	}
+
+	page, err := svc.Query(ctx, repo.AuditLogFilter{Actor: "alice"}, "", 1)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Action != "POST /api/sms/send" || page.NextCursor == "" {
+		t.Fatalf("Unexpected first page: %+v", page)
+	}
+	page, err = svc.Query(ctx, repo.AuditLogFilter{Actor: "alice"}, page.NextCursor, 1)
+	if err != nil || len(page.Items) != 1 || page.Items[0].Target != "dev-1" || page.NextCursor != "" {
+		t.Fatalf("Unexpected second page: %v %+v", err, page)
+	}
+
+	failed := false
+	page, _ = svc.Query(ctx, repo.AuditLogFilter{Success: &failed}, "", 0)
+	if len(page.Items) != 1 || page.Items[0].Error != "发送短信失败" {
+		t.Errorf("Expected only the failed entry, got %+v", page.Items)
+	}
+	page, _ = svc.Query(ctx, repo.AuditLogFilter{Action: "reboot"}, "", 0)
+	if len(page.Items) != 1 {
+		t.Errorf("Expected action filter to match reboot, got %+v", page.Items)
+	}
+
+	var buf bytes.Buffer
+	exported, err := svc.ExportCSV(ctx, repo.AuditLogFilter{StartAt: 1001}, &buf)
+	if err != nil || exported != 2 {
+		t.Fatalf("ExportCSV failed: %v %d", err, exported)
+	}
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(buf.Bytes(), []byte(utf8BOM)))).ReadAll()
+	if err != nil || len(records) != 3 || records[0][2] != "actor" || records[1][2] != "alice" || records[2][2] != "bob" {
+		t.Errorf("Unexpected CSV: %v %v", err, records)
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 加密备份格式：
+//
+//	magic(8) | salt(16) | noncePrefix(7) | chunk...
+//
+// 明文按 64KB 分块，每块使用 AES-256-GCM 独立加密，nonce = noncePrefix | 块序号(4) | 是否最后一块(1)，
+// 块序号和结束标记保证分块不能被重排或截断
+const (
+	backupMagic       = "SMSHUBE1"
+	backupSaltSize    = 16
+	backupPrefixSize  = 7
+	backupChunkSize   = 64 * 1024
+	backupKeySize     = 32
+	backupScryptN     = 1 << 15
+	backupScryptR     = 8
+	backupScryptP     = 1
+	backupGCMOverhead = 16
+)
+
+var (
+	// ErrBackupPassphrase 口令错误或备份文件已损坏
+	ErrBackupPassphrase = errors.New("备份解密失败：口令错误或文件已损坏")
+	// ErrBackupNotEncrypted 不是加密备份文件
+	ErrBackupNotEncrypted = errors.New("不是有效的加密备份文件")
+	// ErrBackupPassphraseRequired 备份已加密但未配置口令
+	ErrBackupPassphraseRequired = errors.New("备份已加密，请配置 Backup.Passphrase")
+)
+
+// deriveBackupKey 使用 scrypt 从口令派生加密密钥
+func deriveBackupKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, backupScryptN, backupScryptR, backupScryptP, backupKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// backupNonce 生成分块 nonce
+func backupNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, backupPrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptWriter 分块加密写入器，Close 时写入最后一块
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+// newEncryptWriter 写入加密头并返回加密写入器
+func newEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	header := make([]byte, len(backupMagic)+backupSaltSize+backupPrefixSize)
+	copy(header, backupMagic)
+	if _, err := rand.Read(header[len(backupMagic):]); err != nil {
+		return nil, err
+	}
+	salt := header[len(backupMagic) : len(backupMagic)+backupSaltSize]
+	prefix := header[len(backupMagic)+backupSaltSize:]
+
+	aead, err := deriveBackupKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, backupChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// 缓冲区满且还有数据时才写出，保证最后一块总在 Close 时写出
+		if len(e.buf) == backupChunkSize {
+			if err := e.seal(false); err != nil {
+				return 0, err
+			}
+		}
+		take := min(backupChunkSize-len(e.buf), len(p))
+		e.buf = append(e.buf, p[:take]...)
+		p = p[take:]
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.aead.Seal(nil, backupNonce(e.prefix, e.counter, last), e.buf, nil)
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+// decryptReader 分块解密读取器
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+// newDecryptReader 读取加密头并返回解密读取器
+func newDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, len(backupMagic)+backupSaltSize+backupPrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(backupMagic)], []byte(backupMagic)) {
+		return nil, ErrBackupNotEncrypted
+	}
+	aead, err := deriveBackupKey(passphrase, header[len(backupMagic):len(backupMagic)+backupSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      bufio.NewReaderSize(r, backupChunkSize+backupGCMOverhead+1),
+		aead:   aead,
+		prefix: header[len(backupMagic)+backupSaltSize:],
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	chunk := make([]byte, backupChunkSize+backupGCMOverhead)
+	n, err := io.ReadFull(d.r, chunk)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			// 没有读到结束块，文件被截断
+			return ErrBackupPassphrase
+		}
+		return err
+	}
+	_, peekErr := d.r.Peek(1)
+	last := errors.Is(peekErr, io.EOF)
+
+	plain, err := d.aead.Open(chunk[:0], backupNonce(d.prefix, d.counter, last), chunk[:n], nil)
+	if err != nil {
+		return ErrBackupPassphrase
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
@@ -0,0 +1,406 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/migration"
+	"github.com/glebarez/sqlite"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	// backupTimeLayout 备份文件名中的时间格式
+	backupTimeLayout = "20060102-150405"
+	// defaultBackupKeep 默认保留的备份数量
+	defaultBackupKeep = 7
+)
+
+// backupNamePattern 备份文件名：smshub-<时间>.db[.gz][.enc]
+var backupNamePattern = regexp.MustCompile(`^smshub-(\d{8}-\d{6})\.db(\.gz)?(\.enc)?$`)
+
+var (
+	// ErrBackupUnsupported 当前数据库不支持备份
+	ErrBackupUnsupported = errors.New("仅支持备份 SQLite 数据库，MySQL/PostgreSQL 请使用 mysqldump/pg_dump")
+	// ErrInvalidBackupName 备份文件名不合法
+	ErrInvalidBackupName = errors.New("备份文件名不合法")
+	// ErrInvalidBackup 备份文件校验失败
+	ErrInvalidBackup = errors.New("备份文件校验失败")
+)
+
+// IsBackupName 判断是否为合法的备份文件名
+func IsBackupName(name string) bool {
+	return backupNamePattern.MatchString(name)
+}
+
+// backupCreatedAt 从文件名解析备份时间，解析失败时使用 fallback
+func backupCreatedAt(name string, fallback time.Time) int64 {
+	if m := backupNamePattern.FindStringSubmatch(name); m != nil {
+		if t, err := time.ParseInLocation(backupTimeLayout, m[1], time.Local); err == nil {
+			return t.UnixMilli()
+		}
+	}
+	return fallback.UnixMilli()
+}
+
+// BackupInfo 备份校验结果
+type BackupInfo struct {
+	Name          string `json:"name"`          // 备份名称或路径
+	SchemaVersion int64  `json:"schemaVersion"` // 数据库迁移版本
+	Messages      int64  `json:"messages"`      // 短信条数
+	Previous      string `json:"previous"`      // 恢复前的数据库文件被移动到的位置（仅恢复时）
+}
+
+// BackupService 数据库备份与恢复
+type BackupService struct {
+	logger  *zap.Logger
+	db      *gorm.DB
+	cfg     config.BackupConfig
+	storage BackupStorage
+	cron    *cron.Cron
+	// 防止定时备份与手动备份并发执行
+	mu sync.Mutex
+}
+
+// NewBackupService 创建备份服务实例，db 为 nil 时只能执行恢复
+func NewBackupService(logger *zap.Logger, db *gorm.DB, cfg config.BackupConfig) *BackupService {
+	if cfg.Dir == "" {
+		cfg.Dir = "./data/backups"
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = defaultBackupKeep
+	}
+	return &BackupService{
+		logger:  logger,
+		db:      db,
+		cfg:     cfg,
+		storage: NewBackupStorage(cfg),
+	}
+}
+
+// Start 启动定时备份（未配置 Schedule 时不启用）
+func (s *BackupService) Start(ctx context.Context) error {
+	if s.cfg.Schedule == "" {
+		return nil
+	}
+	s.cron = cron.New()
+	_, err := s.cron.AddFunc(s.cfg.Schedule, func() {
+		if _, err := s.Create(context.Background()); err != nil {
+			s.logger.Error("定时备份失败", zap.Error(err))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("添加备份任务失败: %w", err)
+	}
+	s.cron.Start()
+	s.logger.Info("定时备份已启用", zap.String("schedule", s.cfg.Schedule), zap.String("storage", s.storage.String()))
+	return nil
+}
+
+// Stop 停止定时备份
+func (s *BackupService) Stop() {
+	if s.cron != nil {
+		<-s.cron.Stop().Done()
+	}
+}
+
+// Create 在线备份数据库（VACUUM INTO），按配置压缩、加密后保存，并清理超出保留数量的旧备份
+func (s *BackupService) Create(ctx context.Context) (*BackupFile, error) {
+	if s.db == nil || s.db.Dialector.Name() != "sqlite" {
+		return nil, ErrBackupUnsupported
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+
+	now := time.Now()
+	name := "smshub-" + now.Format(backupTimeLayout) + ".db"
+	if s.cfg.Gzip {
+		name += ".gz"
+	}
+	if s.cfg.Passphrase != "" {
+		name += ".enc"
+	}
+
+	// VACUUM INTO 生成一致的数据库快照，不阻塞写入
+	snapshot := filepath.Join(s.cfg.Dir, ".snapshot-"+now.Format(backupTimeLayout)+".db")
+	_ = os.Remove(snapshot)
+	defer os.Remove(snapshot)
+	if err := s.db.WithContext(ctx).Exec("VACUUM INTO ?", snapshot).Error; err != nil {
+		return nil, fmt.Errorf("生成数据库快照失败: %w", err)
+	}
+
+	archive, err := os.CreateTemp(s.cfg.Dir, ".archive-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	if err := s.encode(snapshot, archive); err != nil {
+		return nil, fmt.Errorf("打包备份失败: %w", err)
+	}
+	if err := s.storage.Put(ctx, name, archive); err != nil {
+		return nil, fmt.Errorf("保存备份失败: %w", err)
+	}
+
+	info, err := archive.Stat()
+	if err != nil {
+		return nil, err
+	}
+	file := &BackupFile{Name: name, Size: info.Size(), CreatedAt: now.UnixMilli()}
+	s.logger.Info("数据库备份完成", zap.String("name", name), zap.Int64("size", file.Size), zap.String("storage", s.storage.String()))
+
+	if err := s.prune(ctx); err != nil {
+		s.logger.Warn("清理旧备份失败", zap.Error(err))
+	}
+	return file, nil
+}
+
+// encode 将快照按配置压缩、加密后写入 archive
+func (s *BackupService) encode(snapshot string, archive *os.File) error {
+	src, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var w io.Writer = archive
+	var closers []io.Closer
+	if s.cfg.Passphrase != "" {
+		enc, err := newEncryptWriter(w, s.cfg.Passphrase)
+		if err != nil {
+			return err
+		}
+		w = enc
+		closers = append(closers, enc)
+	}
+	if s.cfg.Gzip {
+		gz := gzip.NewWriter(w)
+		w = gz
+		closers = append(closers, gz)
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	// 先关闭外层（gzip）再关闭内层（加密）
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return err
+		}
+	}
+	return archive.Sync()
+}
+
+// prune 只保留最近 Keep 个备份
+func (s *BackupService) prune(ctx context.Context) error {
+	files, err := s.storage.List(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(files)-s.cfg.Keep; i++ {
+		if err := s.storage.Delete(ctx, files[i].Name); err != nil {
+			return err
+		}
+		s.logger.Info("删除旧备份", zap.String("name", files[i].Name))
+	}
+	return nil
+}
+
+// List 列出所有备份（按时间倒序）
+func (s *BackupService) List(ctx context.Context) ([]BackupFile, error) {
+	files, err := s.storage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+		files[i], files[j] = files[j], files[i]
+	}
+	return files, nil
+}
+
+// Open 读取备份文件内容（原始归档，用于下载）
+func (s *BackupService) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !IsBackupName(name) {
+		return nil, ErrInvalidBackupName
+	}
+	return s.storage.Get(ctx, name)
+}
+
+// Delete 删除备份
+func (s *BackupService) Delete(ctx context.Context, name string) error {
+	if !IsBackupName(name) {
+		return ErrInvalidBackupName
+	}
+	return s.storage.Delete(ctx, name)
+}
+
+// Verify 解密、解压备份并校验数据库完整性，不修改当前数据库
+func (s *BackupService) Verify(ctx context.Context, name string) (*BackupInfo, error) {
+	if err := os.MkdirAll(s.cfg.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	tmp, info, err := s.extract(ctx, name, s.cfg.Dir)
+	if tmp != "" {
+		defer os.Remove(tmp)
+	}
+	return info, err
+}
+
+// Restore 校验备份后替换 dbPath 处的数据库文件，原文件重命名保留
+// source 可以是本地文件路径或备份名称；必须在服务停止时执行
+func (s *BackupService) Restore(ctx context.Context, source, dbPath string) (*BackupInfo, error) {
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	tmp, info, err := s.extract(ctx, source, dir)
+	if err != nil {
+		if tmp != "" {
+			os.Remove(tmp)
+		}
+		return nil, err
+	}
+
+	// 原数据库及 WAL 文件整体改名保留，便于恢复失败时手动回退
+	if _, err := os.Stat(dbPath); err == nil {
+		suffix := ".before-restore-" + time.Now().Format(backupTimeLayout)
+		for _, ext := range []string{"", "-wal", "-shm"} {
+			if _, err := os.Stat(dbPath + ext); err != nil {
+				continue
+			}
+			if err := os.Rename(dbPath+ext, dbPath+ext+suffix); err != nil {
+				os.Remove(tmp)
+				return nil, fmt.Errorf("移动原数据库失败: %w", err)
+			}
+		}
+		info.Previous = dbPath + suffix
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return nil, fmt.Errorf("替换数据库失败: %w", err)
+	}
+	s.logger.Info("数据库恢复完成", zap.String("source", source), zap.String("path", dbPath), zap.String("previous", info.Previous))
+	return info, nil
+}
+
+// extract 将备份还原为 dir 中的临时数据库文件并校验，返回临时文件路径
+func (s *BackupService) extract(ctx context.Context, source, dir string) (string, *BackupInfo, error) {
+	src, name, err := s.openSource(ctx, source)
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(dir, ".restore-*.db")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.decode(src, name, tmp); err != nil {
+		tmp.Close()
+		return tmp.Name(), nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return tmp.Name(), nil, err
+	}
+
+	info, err := validateBackupDatabase(tmp.Name())
+	if err != nil {
+		return tmp.Name(), nil, err
+	}
+	info.Name = source
+	return tmp.Name(), info, nil
+}
+
+// openSource 打开本地文件或存储中的备份
+func (s *BackupService) openSource(ctx context.Context, source string) (io.ReadCloser, string, error) {
+	if stat, err := os.Stat(source); err == nil && !stat.IsDir() {
+		f, err := os.Open(source)
+		return f, filepath.Base(source), err
+	}
+	if !IsBackupName(source) {
+		return nil, "", ErrInvalidBackupName
+	}
+	r, err := s.storage.Get(ctx, source)
+	return r, source, err
+}
+
+// decode 按文件扩展名解密、解压
+func (s *BackupService) decode(src io.Reader, name string, dst io.Writer) error {
+	r := src
+	if strings.HasSuffix(name, ".enc") {
+		if s.cfg.Passphrase == "" {
+			return ErrBackupPassphraseRequired
+		}
+		dec, err := newDecryptReader(r, s.cfg.Passphrase)
+		if err != nil {
+			return err
+		}
+		r = dec
+		name = strings.TrimSuffix(name, ".enc")
+	}
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	_, err := io.Copy(dst, r)
+	return err
+}
+
+// validateBackupDatabase 校验数据库完整性、表结构和迁移版本
+func validateBackupDatabase(path string) (*BackupInfo, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	defer sqlDB.Close()
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if result != "ok" {
+		return nil, fmt.Errorf("%w: 完整性检查未通过: %s", ErrInvalidBackup, result)
+	}
+	if !db.Migrator().HasTable("text_messages") {
+		return nil, fmt.Errorf("%w: 不是 SMSHub 数据库", ErrInvalidBackup)
+	}
+
+	info := &BackupInfo{}
+	if db.Migrator().HasTable(&migration.SchemaMigration{}) {
+		if err := db.Model(&migration.SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&info.SchemaVersion).Error; err != nil {
+			return nil, err
+		}
+		if info.SchemaVersion > migration.Latest() {
+			return nil, migration.ErrSchemaTooNew
+		}
+	}
+	if err := db.Table("text_messages").Count(&info.Messages).Error; err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+)
+
+// ErrBackupNotFound 备份不存在
+var ErrBackupNotFound = errors.New("备份不存在")
+
+// BackupFile 备份文件信息
+type BackupFile struct {
+	Name      string `json:"name"`      // 文件名
+	Size      int64  `json:"size"`      // 大小（字节）
+	CreatedAt int64  `json:"createdAt"` // 创建时间（时间戳毫秒）
+}
+
+// BackupStorage 备份存储
+type BackupStorage interface {
+	// Put 上传本地文件
+	Put(ctx context.Context, name string, file *os.File) error
+	// Get 读取备份
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List 列出所有备份（按名称正序，即按时间正序）
+	List(ctx context.Context) ([]BackupFile, error)
+	// Delete 删除备份
+	Delete(ctx context.Context, name string) error
+	// String 存储位置描述（用于日志）
+	String() string
+}
+
+// NewBackupStorage 根据配置创建备份存储：启用 S3 时上传到 S3，否则保存在本地目录
+func NewBackupStorage(cfg config.BackupConfig) BackupStorage {
+	if cfg.S3.Enabled {
+		return &s3BackupStorage{cfg: cfg.S3, client: &http.Client{Timeout: 30 * time.Minute}}
+	}
+	return &localBackupStorage{dir: cfg.Dir}
+}
+
+// localBackupStorage 本地目录存储
+type localBackupStorage struct {
+	dir string
+}
+
+func (s *localBackupStorage) Put(ctx context.Context, name string, file *os.File) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	target := filepath.Join(s.dir, name)
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(tmp, file); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (s *localBackupStorage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBackupNotFound
+	}
+	return f, err
+}
+
+func (s *localBackupStorage) List(ctx context.Context) ([]BackupFile, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []BackupFile
+	for _, entry := range entries {
+		if entry.IsDir() || !IsBackupName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, BackupFile{Name: entry.Name(), Size: info.Size(), CreatedAt: backupCreatedAt(entry.Name(), info.ModTime())})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func (s *localBackupStorage) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrBackupNotFound
+	}
+	return err
+}
+
+func (s *localBackupStorage) String() string {
+	return s.dir
+}
+
+// s3BackupStorage S3 兼容对象存储，使用 AWS Signature V4 签名
+type s3BackupStorage struct {
+	cfg    config.S3Config
+	client *http.Client
+	// now 签名时间（测试时可替换）
+	now func() time.Time
+}
+
+// s3EmptyPayloadHash 空请求体的 SHA256
+const s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// objectURL 返回对象地址，key 为空时返回存储桶地址
+func (s *s3BackupStorage) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimRight(s.cfg.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	escapedKey := ""
+	if key != "" {
+		escapedKey = "/" + s3EscapePath(key)
+	}
+	if s.cfg.PathStyle {
+		u.Path = "/" + s.cfg.Bucket + escapedKey
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = escapedKey
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+	u.RawPath = u.Path
+	return u, nil
+}
+
+func (s *s3BackupStorage) key(name string) string {
+	return s.cfg.Prefix + name
+}
+
+// do 签名并发送请求
+func (s *s3BackupStorage) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.sign(req, payloadHash)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBackupNotFound
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("S3 请求失败: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// sign 按 AWS Signature V4 为请求签名
+func (s *s3BackupStorage) sign(req *http.Request, payloadHash string) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	region := s.cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func (s *s3BackupStorage) Put(ctx context.Context, name string, file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	// 先计算文件哈希用于签名，再从头上传
+	hash := sha256.New()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	u, err := s.objectURL(s.key(name))
+	if err != nil {
+		return err
+	}
+	// 包装为非 Closer，避免 http.Client 发送完成后关闭文件
+	body := io.LimitReader(file, info.Size())
+	resp, err := s.do(ctx, http.MethodPut, u, body, info.Size(), hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *s3BackupStorage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	u, err := s.objectURL(s.key(name))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, u, nil, 0, s3EmptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// s3ListResult ListObjectsV2 响应
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3BackupStorage) List(ctx context.Context) ([]BackupFile, error) {
+	var files []BackupFile
+	token := ""
+	for {
+		u, err := s.objectURL("")
+		if err != nil {
+			return nil, err
+		}
+		query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = s3CanonicalQuery(query)
+
+		resp, err := s.do(ctx, http.MethodGet, u, nil, 0, s3EmptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析 S3 列表失败: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.cfg.Prefix)
+			if !IsBackupName(name) {
+				continue
+			}
+			files = append(files, BackupFile{Name: name, Size: object.Size, CreatedAt: backupCreatedAt(name, object.LastModified)})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func (s *s3BackupStorage) Delete(ctx context.Context, name string) error {
+	u, err := s.objectURL(s.key(name))
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, u, nil, 0, s3EmptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *s3BackupStorage) String() string {
+	return "s3://" + s.cfg.Bucket + "/" + s.cfg.Prefix
+}
+
+// s3EscapePath 按 S3 规则转义对象路径（保留 /）
+func s3EscapePath(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = s3Escape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// s3Escape 按 RFC 3986 转义（空格为 %20）
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// s3CanonicalQuery 按键排序生成规范化查询字符串
+func s3CanonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range values[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// BatchJobService 后台批量发送服务
+// 提交后立即返回任务ID，在后台按并发限制发送，任务和每个收件人的结果都持久化，重启后继续处理未完成的任务
+type BatchJobService struct {
+	logger     *zap.Logger
+	repo       *repo.BatchJobRepo
+	resultRepo *repo.BatchJobResultRepo
+	httpClient *http.Client
+
+	// send 向单个收件人发送
+	send func(req *BatchSendRequest, recipient string) BatchSendResult
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewBatchJobService 创建批量发送服务实例
+func NewBatchJobService(logger *zap.Logger, db *gorm.DB, deviceManager *DeviceManager) *BatchJobService {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &BatchJobService{
+		logger:     logger,
+		repo:       repo.NewBatchJobRepo(db),
+		resultRepo: repo.NewBatchJobResultRepo(db),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	if deviceManager != nil {
+		s.send = deviceManager.sendBatchRecipient
+	}
+	return s
+}
+
+// Start 继续处理上次未完成的任务
+func (s *BatchJobService) Start(ctx context.Context) error {
+	jobs, err := s.repo.FindUnfinished(ctx)
+	if err != nil {
+		return fmt.Errorf("获取未完成的批量发送任务失败: %w", err)
+	}
+	for _, job := range jobs {
+		s.logger.Info("继续处理批量发送任务", zap.String("id", job.ID))
+		s.wg.Add(1)
+		go s.run(job.ID)
+	}
+	return nil
+}
+
+// Stop 停止批量发送，正在发送的短信完成后退出，未发送的收件人下次启动时继续
+func (s *BatchJobService) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	s.logger.Info("批量发送服务已停止")
+}
+
+// Submit 创建批量发送任务并在后台执行
+func (s *BatchJobService) Submit(ctx context.Context, req *BatchSendRequest, callbackURL string) (*models.BatchJob, error) {
+	if err := checkSendDevice(ctx, req.DeviceID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	job := &models.BatchJob{
+		ID:          uuid.New().String(),
+		Content:     req.Content,
+		DeviceID:    req.DeviceID,
+		Strategy:    string(req.Strategy),
+		CallbackURL: callbackURL,
+		Status:      models.BatchJobStatusPending,
+		Total:       len(req.Recipients),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	results := make([]models.BatchJobResult, len(req.Recipients))
+	for i, recipient := range req.Recipients {
+		results[i] = models.BatchJobResult{
+			ID:        uuid.New().String(),
+			JobID:     job.ID,
+			Seq:       i + 1,
+			Recipient: recipient,
+			Status:    models.BatchJobResultPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+
+	if err := s.repo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	if err := s.resultRepo.CreateInBatches(ctx, results); err != nil {
+		return nil, err
+	}
+	job.Pending = int64(job.Total)
+
+	s.wg.Add(1)
+	go s.run(job.ID)
+	return job, nil
+}
+
+// Get 获取任务状态和各收件人结果
+func (s *BatchJobService) Get(ctx context.Context, id string) (*models.BatchJob, error) {
+	job, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.resultRepo.FindByJob(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+	job.Results = results
+	for _, result := range results {
+		switch result.Status {
+		case models.BatchJobResultPending:
+			job.Pending++
+		case models.BatchJobResultSuccess:
+			job.Succeeded++
+		case models.BatchJobResultFailed:
+			job.Failed++
+		}
+	}
+	return &job, nil
+}
+
+// run 发送任务中所有等待发送的收件人，完成后调用回调地址
+func (s *BatchJobService) run(id string) {
+	defer s.wg.Done()
+	ctx := s.ctx
+
+	job, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		s.logger.Error("获取批量发送任务失败", zap.String("id", id), zap.Error(err))
+		return
+	}
+	if err := s.repo.UpdateColumnsById(ctx, id, map[string]any{"status": models.BatchJobStatusRunning}); err != nil {
+		s.logger.Error("更新批量发送任务状态失败", zap.String("id", id), zap.Error(err))
+		return
+	}
+
+	pending, err := s.resultRepo.FindByJob(ctx, id, models.BatchJobResultPending)
+	if err != nil {
+		s.logger.Error("获取批量发送收件人失败", zap.String("id", id), zap.Error(err))
+		return
+	}
+
+	req := &BatchSendRequest{
+		Content:  job.Content,
+		DeviceID: job.DeviceID,
+		Strategy: SendStrategy(job.Strategy),
+	}
+
+	// 使用带缓冲的 channel 实现并发限制
+	semaphore := make(chan struct{}, MaxConcurrentSends)
+	var wg sync.WaitGroup
+	for _, item := range pending {
+		semaphore <- struct{}{}
+		if ctx.Err() != nil {
+			<-semaphore
+			break
+		}
+		wg.Add(1)
+		go func(item models.BatchJobResult) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			result := s.sendOne(req, item.Recipient)
+			columns := map[string]any{
+				"status":     models.BatchJobResultSuccess,
+				"message_id": result.MessageID,
+				"device_id":  result.DeviceID,
+				"error":      result.Error,
+			}
+			if !result.Success {
+				columns["status"] = models.BatchJobResultFailed
+			}
+			// 服务停止时也要记录已发送的结果，避免重启后重复发送
+			if err := s.resultRepo.UpdateResult(context.Background(), item.ID, columns); err != nil {
+				s.logger.Error("保存批量发送结果失败", zap.String("id", id), zap.Error(err))
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+	if err := s.repo.UpdateColumnsById(ctx, id, map[string]any{
+		"status":      models.BatchJobStatusCompleted,
+		"finished_at": time.Now().UnixMilli(),
+	}); err != nil {
+		s.logger.Error("更新批量发送任务状态失败", zap.String("id", id), zap.Error(err))
+		return
+	}
+	s.logger.Info("批量发送任务完成", zap.String("id", id), zap.Int("total", job.Total))
+
+	if job.CallbackURL != "" {
+		s.callback(ctx, id)
+	}
+}
+
+// sendOne 向单个收件人发送，没有设备管理器时直接失败
+func (s *BatchJobService) sendOne(req *BatchSendRequest, recipient string) BatchSendResult {
+	if s.send == nil {
+		return BatchSendResult{Recipient: recipient, Error: "没有可用的设备"}
+	}
+	return s.send(req, recipient)
+}
+
+// callback 将任务结果 POST 到回调地址，并记录回调结果
+func (s *BatchJobService) callback(ctx context.Context, id string) {
+	job, err := s.Get(ctx, id)
+	if err != nil {
+		s.logger.Error("获取批量发送任务失败", zap.String("id", id), zap.Error(err))
+		return
+	}
+
+	status, err := s.postCallback(ctx, job)
+	if err != nil {
+		s.logger.Warn("批量发送回调失败", zap.String("id", id), zap.String("url", job.CallbackURL), zap.Error(err))
+		status = err.Error()
+	}
+	if err := s.repo.UpdateColumnsById(ctx, id, map[string]any{"callback_status": status}); err != nil {
+		s.logger.Error("保存批量发送回调结果失败", zap.String("id", id), zap.Error(err))
+	}
+}
+
+func (s *BatchJobService) postCallback(ctx context.Context, job *models.BatchJob) (string, error) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建回调请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("调用回调地址失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("回调地址返回错误状态: %s", resp.Status)
+	}
+	return resp.Status, nil
+}
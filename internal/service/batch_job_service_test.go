@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// waitBatchJob 等待批量发送任务完成
+// 轮询直接查询数据库，避免与后台任务并发使用同一个 repo
+func waitBatchJob(t *testing.T, db *gorm.DB, svc *BatchJobService, id string) *models.BatchJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var job models.BatchJob
+		if err := db.First(&job, "id = ?", id).Error; err != nil {
+			t.Fatalf("query job failed: %v", err)
+		}
+		if job.Status == models.BatchJobStatusCompleted && (job.CallbackURL == "" || job.CallbackStatus != "") {
+			done, err := svc.Get(context.Background(), id)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			return done
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("批量发送任务 %s 未在规定时间内完成", id)
+	return nil
+}
+
+func TestBatchJobService(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	callbacks := make(chan models.BatchJob, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job models.BatchJob
+		json.NewDecoder(r.Body).Decode(&job)
+		callbacks <- job
+	}))
+	defer server.Close()
+
+	svc := NewBatchJobService(zap.NewNop(), db, nil)
+	defer svc.Stop()
+	svc.send = func(req *BatchSendRequest, recipient string) BatchSendResult {
+		if recipient == "10000" {
+			return BatchSendResult{Recipient: recipient, Error: "设备不在线"}
+		}
+		return BatchSendResult{Recipient: recipient, MessageID: "msg-" + recipient, DeviceID: "dev-1", Success: true}
+	}
+
+	job, err := svc.Submit(ctx, &BatchSendRequest{
+		Recipients: []string{"10086", "10000", "10010"},
+		Content:    "hello",
+		Strategy:   StrategyRoundRobin,
+	}, server.URL)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if job.ID == "" || job.Total != 3 || job.Pending != 3 {
+		t.Errorf("Unexpected submitted job: %+v", job)
+	}
+
+	done := waitBatchJob(t, db, svc, job.ID)
+	if done.Succeeded != 2 || done.Failed != 1 || done.Pending != 0 || done.FinishedAt == 0 {
+		t.Errorf("Unexpected job counts: %+v", done)
+	}
+	if len(done.Results) != 3 || done.Results[1].Recipient != "10000" || done.Results[1].Error == "" {
+		t.Errorf("Expected results in recipient order, got %+v", done.Results)
+	}
+	if done.Results[0].MessageID != "msg-10086" || done.Results[0].Status != models.BatchJobResultSuccess {
+		t.Errorf("Unexpected first result: %+v", done.Results[0])
+	}
+	if done.CallbackStatus != "200 OK" {
+		t.Errorf("Expected callback status 200 OK, got %q", done.CallbackStatus)
+	}
+
+	select {
+	case payload := <-callbacks:
+		if payload.ID != job.ID || payload.Succeeded != 2 || len(payload.Results) != 3 {
+			t.Errorf("Unexpected callback payload: %+v", payload)
+		}
+	default:
+		t.Error("Expected callback to be called")
+	}
+}
+
+func TestBatchJobService_Resume(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	// 模拟上次运行中断：一个收件人已发送，一个还在等待
+	job := models.BatchJob{ID: "job-1", Content: "hi", Status: models.BatchJobStatusRunning, Total: 2}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatalf("创建任务失败: %v", err)
+	}
+	results := []models.BatchJobResult{
+		{ID: "r1", JobID: job.ID, Seq: 1, Recipient: "10086", Status: models.BatchJobResultSuccess, MessageID: "old"},
+		{ID: "r2", JobID: job.ID, Seq: 2, Recipient: "10010", Status: models.BatchJobResultPending},
+	}
+	if err := db.Create(&results).Error; err != nil {
+		t.Fatalf("创建收件人失败: %v", err)
+	}
+
+	var mu sync.Mutex
+	var sent []string
+	svc := NewBatchJobService(zap.NewNop(), db, nil)
+	defer svc.Stop()
+	svc.send = func(req *BatchSendRequest, recipient string) BatchSendResult {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, recipient)
+		return BatchSendResult{Recipient: recipient, MessageID: "new", Success: true}
+	}
+	if err := svc.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	done := waitBatchJob(t, db, svc, job.ID)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 1 || sent[0] != "10010" {
+		t.Errorf("Expected only pending recipient to be sent, got %v", sent)
+	}
+	if done.Succeeded != 2 || done.Results[0].MessageID != "old" {
+		t.Errorf("Unexpected resumed job: %+v", done)
+	}
+
+	if _, err := svc.Get(ctx, "missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected ErrRecordNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,563 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// campaignTickInterval 群发调度检查间隔
+	campaignTickInterval = 5 * time.Second
+	// defaultCampaignRate 未指定速率时每分钟发送条数
+	defaultCampaignRate = 10
+)
+
+var (
+	// ErrInvalidCampaign 群发活动配置无效
+	ErrInvalidCampaign = errors.New("群发活动配置无效")
+	// ErrCampaignState 当前状态不允许该操作
+	ErrCampaignState = errors.New("当前状态不允许该操作")
+)
+
+// campaignPhoneColumns CSV 中识别为手机号的列名（不区分大小写），都没有时使用第一列
+var campaignPhoneColumns = []string{"phone", "phonenumber", "phone_number", "mobile", "手机号", "号码"}
+
+// CampaignRecipientInput 创建群发活动时的收件人
+type CampaignRecipientInput struct {
+	PhoneNumber string            `json:"phoneNumber"`
+	Vars        map[string]string `json:"vars"`
+}
+
+// CampaignService 定时群发服务
+// 活动到达开始时间后，每隔 campaignTickInterval 按速率限制从设备池发送一批，免打扰时段内暂停发送
+// 速率按设备限制，同时进行的活动共用每台设备的配额，先创建的活动优先使用
+type CampaignService struct {
+	logger        *zap.Logger
+	repo          *repo.CampaignRepo
+	recipientRepo *repo.CampaignRecipientRepo
+	serialService *SerialService
+	deviceManager *DeviceManager
+	cron          *cron.Cron
+
+	// 防止调度发送与暂停、取消、删除并发执行
+	mu sync.Mutex
+	// send 通过 allow 允许的设备发送一条短信，返回短信ID和实际使用的设备，没有允许的设备时返回 errDeviceRateLimited
+	send func(campaign models.Campaign, to, content string, allow func(deviceID string) bool) (string, string, error)
+}
+
+// NewCampaignService 创建群发服务实例
+func NewCampaignService(
+	logger *zap.Logger,
+	db *gorm.DB,
+	serialService *SerialService,
+	deviceManager *DeviceManager,
+) *CampaignService {
+	s := &CampaignService{
+		logger:        logger,
+		repo:          repo.NewCampaignRepo(db),
+		recipientRepo: repo.NewCampaignRecipientRepo(db),
+		serialService: serialService,
+		deviceManager: deviceManager,
+	}
+	s.send = s.sendSMS
+	return s
+}
+
+// Start 启动群发调度
+func (s *CampaignService) Start(ctx context.Context) error {
+	s.cron = cron.New()
+	_, err := s.cron.AddFunc(fmt.Sprintf("@every %s", campaignTickInterval), func() {
+		s.tick(context.Background(), time.Now())
+	})
+	if err != nil {
+		return fmt.Errorf("添加群发调度失败: %w", err)
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop 停止群发调度
+func (s *CampaignService) Stop() {
+	if s.cron != nil {
+		s.cron.Stop()
+		s.logger.Info("群发服务已停止")
+	}
+}
+
+// List 获取 ctx 设备范围内的所有群发活动及进度
+func (s *CampaignService) List(ctx context.Context) ([]models.Campaign, error) {
+	campaigns, err := s.repo.FindAllOrdered(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range campaigns {
+		if err := s.fillProgress(ctx, &campaigns[i]); err != nil {
+			return nil, err
+		}
+	}
+	return campaigns, nil
+}
+
+// GetById 获取群发活动及进度，不在 ctx 设备范围内时返回 gorm.ErrRecordNotFound
+func (s *CampaignService) GetById(ctx context.Context, id string) (*models.Campaign, error) {
+	campaign, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillProgress(ctx, &campaign); err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// Create 创建群发活动，重复的号码只保留第一个
+func (s *CampaignService) Create(ctx context.Context, campaign *models.Campaign, inputs []CampaignRecipientInput) error {
+	if err := normalizeCampaign(campaign); err != nil {
+		return err
+	}
+	if err := checkSendDevice(ctx, campaign.DeviceID); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	campaign.ID = uuid.New().String()
+	campaign.Status = models.CampaignStatusScheduled
+	campaign.StartedAt = 0
+	campaign.FinishedAt = 0
+	campaign.CreatedAt = now
+	campaign.UpdatedAt = now
+
+	seen := make(map[string]bool, len(inputs))
+	recipients := make([]models.CampaignRecipient, 0, len(inputs))
+	for _, input := range inputs {
+		phone := strings.TrimSpace(input.PhoneNumber)
+		if phone == "" || seen[phone] {
+			continue
+		}
+		seen[phone] = true
+		recipients = append(recipients, models.CampaignRecipient{
+			ID:          uuid.New().String(),
+			CampaignID:  campaign.ID,
+			Seq:         len(recipients) + 1,
+			PhoneNumber: phone,
+			Vars:        input.Vars,
+			Status:      models.CampaignRecipientQueued,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("%w: 收件人不能为空", ErrInvalidCampaign)
+	}
+
+	if err := s.repo.Create(ctx, campaign); err != nil {
+		return err
+	}
+	if err := s.recipientRepo.CreateInBatches(ctx, recipients); err != nil {
+		return err
+	}
+	campaign.Progress = models.CampaignProgress{Total: int64(len(recipients)), Queued: int64(len(recipients))}
+	return nil
+}
+
+// GetRecipients 按发送顺序分页获取活动收件人
+func (s *CampaignService) GetRecipients(ctx context.Context, id string, status models.CampaignRecipientStatus, cursor string, limit int) (*Page[models.CampaignRecipient], error) {
+	if _, err := s.repo.FindById(ctx, id); err != nil {
+		return nil, err
+	}
+	after, err := repo.DecodeCursor(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	limit = normalizePageLimit(limit)
+
+	recipients, err := s.recipientRepo.FindByCampaign(ctx, id, status, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page[models.CampaignRecipient]{}
+	if len(recipients) > limit {
+		recipients = recipients[:limit]
+		last := recipients[limit-1]
+		page.NextCursor = repo.Cursor{CreatedAt: int64(last.Seq), ID: last.ID}.Encode()
+	}
+	page.Items = recipients
+	if page.Items == nil {
+		page.Items = []models.CampaignRecipient{}
+	}
+	return page, nil
+}
+
+// Pause 暂停群发活动，已提交给设备的短信不受影响
+func (s *CampaignService) Pause(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transition(ctx, id, models.CampaignStatusPaused, nil,
+		models.CampaignStatusScheduled, models.CampaignStatusRunning)
+}
+
+// Resume 恢复已暂停的群发活动，未到开始时间时继续等待
+func (s *CampaignService) Resume(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transition(ctx, id, models.CampaignStatusScheduled, nil, models.CampaignStatusPaused)
+}
+
+// Cancel 取消群发活动，排队中的收件人标记为已取消
+func (s *CampaignService) Cancel(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.transition(ctx, id, models.CampaignStatusCancelled, map[string]any{"finished_at": time.Now().UnixMilli()},
+		models.CampaignStatusScheduled, models.CampaignStatusRunning, models.CampaignStatusPaused)
+	if err != nil {
+		return err
+	}
+	return s.recipientRepo.CancelQueued(ctx, id)
+}
+
+// Delete 删除群发活动和收件人
+func (s *CampaignService) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.repo.FindById(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteById(ctx, id); err != nil {
+		return err
+	}
+	return s.recipientRepo.DeleteByCampaign(ctx, id)
+}
+
+// UpdateStatusByMsgId 根据设备回报的发送结果更新收件人状态
+func (s *CampaignService) UpdateStatusByMsgId(ctx context.Context, msgId string, status models.LastRunStatus) error {
+	var recipientStatus models.CampaignRecipientStatus
+	switch status {
+	case models.LastRunStatusSuccess:
+		recipientStatus = models.CampaignRecipientDelivered
+	case models.LastRunStatusFailed:
+		recipientStatus = models.CampaignRecipientFailed
+	default:
+		return nil
+	}
+	return s.recipientRepo.UpdateStatusByMsgId(ctx, msgId, recipientStatus)
+}
+
+// transition 在当前状态为 from 之一时切换活动状态
+func (s *CampaignService) transition(ctx context.Context, id string, to models.CampaignStatus, columns map[string]any, from ...models.CampaignStatus) error {
+	if _, err := s.repo.FindById(ctx, id); err != nil {
+		return err
+	}
+	ok, err := s.repo.UpdateStatus(ctx, id, to, columns, from...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCampaignState
+	}
+	return nil
+}
+
+// fillProgress 统计活动各状态的收件人数量
+func (s *CampaignService) fillProgress(ctx context.Context, campaign *models.Campaign) error {
+	counts, err := s.recipientRepo.CountByStatus(ctx, campaign.ID)
+	if err != nil {
+		return err
+	}
+	progress := models.CampaignProgress{
+		Queued:    counts[models.CampaignRecipientQueued],
+		Sent:      counts[models.CampaignRecipientSent],
+		Delivered: counts[models.CampaignRecipientDelivered],
+		Failed:    counts[models.CampaignRecipientFailed],
+		Cancelled: counts[models.CampaignRecipientCancelled],
+	}
+	for _, count := range counts {
+		progress.Total += count
+	}
+	campaign.Progress = progress
+	return nil
+}
+
+// tick 启动到达开始时间的活动，并为发送中的活动发送一批短信
+func (s *CampaignService) tick(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	campaigns, err := s.repo.FindByStatus(ctx, models.CampaignStatusScheduled, models.CampaignStatusRunning)
+	if err != nil {
+		s.logger.Error("获取群发活动失败", zap.Error(err))
+		return
+	}
+
+	// 最近一分钟内各活动通过每台设备已发送的条数计入该设备的速率
+	ids := make([]string, len(campaigns))
+	for i := range campaigns {
+		ids[i] = campaigns[i].ID
+	}
+	lastMinute, err := s.recipientRepo.CountSentSinceByDevice(ctx, ids, now.Add(-time.Minute).UnixMilli())
+	if err != nil {
+		s.logger.Error("统计群发设备速率失败", zap.Error(err))
+		return
+	}
+	budget := &campaignDeviceBudget{lastMinute: lastMinute, thisTick: make(map[string]int)}
+
+	for _, campaign := range campaigns {
+		if campaign.Status == models.CampaignStatusScheduled {
+			if campaign.StartAt > now.UnixMilli() {
+				continue
+			}
+			columns := map[string]any{}
+			if campaign.StartedAt == 0 {
+				columns["started_at"] = now.UnixMilli()
+			}
+			ok, err := s.repo.UpdateStatus(ctx, campaign.ID, models.CampaignStatusRunning, columns, models.CampaignStatusScheduled)
+			if err != nil || !ok {
+				continue
+			}
+			s.logger.Info("群发活动开始", zap.String("id", campaign.ID), zap.String("name", campaign.Name))
+		}
+
+		if err := s.sendBatch(ctx, campaign, budget, now); err != nil {
+			s.logger.Error("群发活动发送失败", zap.String("id", campaign.ID), zap.Error(err))
+		}
+	}
+}
+
+// campaignDeviceBudget 一次调度检查中各设备的发送配额，所有活动共用
+// 设备ID为空表示单设备模式的串口
+type campaignDeviceBudget struct {
+	lastMinute map[string]int64 // 最近一分钟内已发送条数
+	thisTick   map[string]int   // 本次检查已发送条数
+}
+
+// allow 判断设备是否还能按 rate 发送：一分钟内不超过 rate 条，每次检查最多发送一分钟配额的相应比例，避免集中发送
+func (b *campaignDeviceBudget) allow(deviceID string, rate int) bool {
+	return b.lastMinute[deviceID] < int64(rate) && b.thisTick[deviceID] < campaignPerTick(rate)
+}
+
+// record 记录设备发送了一条
+func (b *campaignDeviceBudget) record(deviceID string) {
+	b.lastMinute[deviceID]++
+	b.thisTick[deviceID]++
+}
+
+// campaignPerTick 每次检查每台设备最多发送的条数
+func campaignPerTick(rate int) int {
+	return max(1, (rate*int(campaignTickInterval/time.Second)+59)/60)
+}
+
+// sendBatch 按设备速率限制发送一批短信，没有排队的收件人时完成活动
+func (s *CampaignService) sendBatch(ctx context.Context, campaign models.Campaign, budget *campaignDeviceBudget, now time.Time) error {
+	quiet, err := inQuietHours(campaign, now)
+	if err != nil || quiet {
+		return err
+	}
+
+	// 从设备池发送时每台在线设备各有一份配额
+	limit := campaignPerTick(campaign.RatePerMinute)
+	if campaign.DeviceID == "" && s.deviceManager != nil {
+		limit *= max(1, s.deviceManager.GetOnlineDeviceCount())
+	}
+	recipients, err := s.recipientRepo.FindQueued(ctx, campaign.ID, limit)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		if _, err := s.repo.UpdateStatus(ctx, campaign.ID, models.CampaignStatusCompleted,
+			map[string]any{"finished_at": now.UnixMilli()}, models.CampaignStatusRunning); err != nil {
+			return err
+		}
+		s.logger.Info("群发活动完成", zap.String("id", campaign.ID), zap.String("name", campaign.Name))
+		return nil
+	}
+
+	allow := func(deviceID string) bool {
+		return budget.allow(deviceID, campaign.RatePerMinute)
+	}
+	for _, recipient := range recipients {
+		vars := make(map[string]string, len(recipient.Vars)+1)
+		for k, v := range recipient.Vars {
+			vars[k] = v
+		}
+		vars["phone"] = recipient.PhoneNumber
+		content := renderTaskTemplate(campaign.Content, vars, nil)
+
+		msgID, deviceID, err := s.send(campaign, recipient.PhoneNumber, content, allow)
+		if errors.Is(err, errDeviceRateLimited) {
+			// 设备配额已用完，剩余收件人留到下次检查
+			return nil
+		}
+		budget.record(deviceID)
+		columns := map[string]any{
+			"status":    models.CampaignRecipientSent,
+			"msg_id":    msgID,
+			"device_id": deviceID,
+			"sent_at":   now.UnixMilli(),
+		}
+		if err != nil {
+			columns["status"] = models.CampaignRecipientFailed
+			columns["error"] = err.Error()
+			s.logger.Warn("群发短信发送失败",
+				zap.String("id", campaign.ID),
+				zap.String("to", recipient.PhoneNumber),
+				zap.Error(err))
+		}
+		if err := s.recipientRepo.UpdateColumnsById(ctx, recipient.ID, columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendSMS 通过指定设备、按策略从设备池选择的设备或单设备模式发送短信
+// 活动在后台执行，设备权限已在创建时检查
+func (s *CampaignService) sendSMS(campaign models.Campaign, to, content string, allow func(deviceID string) bool) (string, string, error) {
+	ctx := context.Background()
+	if campaign.DeviceID != "" {
+		if !allow(campaign.DeviceID) {
+			return "", "", errDeviceRateLimited
+		}
+		if s.deviceManager == nil {
+			return "", "", fmt.Errorf("设备不在线: %s", campaign.DeviceID)
+		}
+		msgID, err := s.deviceManager.SendSMSByDevice(ctx, campaign.DeviceID, to, content)
+		return msgID, campaign.DeviceID, err
+	}
+	if s.deviceManager != nil && s.deviceManager.GetOnlineDeviceCount() > 0 {
+		return s.deviceManager.SendSMSWhere(ctx, to, content, SendStrategy(campaign.Strategy), allow)
+	}
+	if s.serialService == nil {
+		return "", "", fmt.Errorf("没有可用的设备")
+	}
+	if !allow("") {
+		return "", "", errDeviceRateLimited
+	}
+	msgID, err := s.serialService.SendSMS(ctx, to, content)
+	return msgID, "", err
+}
+
+// normalizeCampaign 校验群发活动配置并填充默认值
+func normalizeCampaign(campaign *models.Campaign) error {
+	campaign.Name = strings.TrimSpace(campaign.Name)
+	if campaign.Name == "" {
+		return fmt.Errorf("%w: 活动名称不能为空", ErrInvalidCampaign)
+	}
+	if strings.TrimSpace(campaign.Content) == "" {
+		return fmt.Errorf("%w: 短信内容不能为空", ErrInvalidCampaign)
+	}
+	if campaign.DeviceID == TaskDeviceAuto {
+		campaign.DeviceID = ""
+	}
+	if campaign.RatePerMinute < 0 {
+		return fmt.Errorf("%w: 每分钟发送条数不能小于0", ErrInvalidCampaign)
+	}
+	if campaign.RatePerMinute == 0 {
+		campaign.RatePerMinute = defaultCampaignRate
+	}
+	if (campaign.QuietStart == "") != (campaign.QuietEnd == "") {
+		return fmt.Errorf("%w: 免打扰开始和结束时刻需同时设置", ErrInvalidCampaign)
+	}
+	_, err := inQuietHours(*campaign, time.Now())
+	return err
+}
+
+// inQuietHours 判断 now 是否处于活动的免打扰时段，时段可跨午夜
+func inQuietHours(campaign models.Campaign, now time.Time) (bool, error) {
+	if campaign.QuietStart == "" || campaign.QuietStart == campaign.QuietEnd {
+		return false, nil
+	}
+	start, err := time.Parse("15:04", campaign.QuietStart)
+	if err != nil {
+		return false, fmt.Errorf("%w: 免打扰开始时刻格式应为 HH:MM", ErrInvalidCampaign)
+	}
+	end, err := time.Parse("15:04", campaign.QuietEnd)
+	if err != nil {
+		return false, fmt.Errorf("%w: 免打扰结束时刻格式应为 HH:MM", ErrInvalidCampaign)
+	}
+	loc := time.Local
+	if campaign.Timezone != "" {
+		if loc, err = time.LoadLocation(campaign.Timezone); err != nil {
+			return false, fmt.Errorf("%w: 未知时区 %s", ErrInvalidCampaign, campaign.Timezone)
+		}
+	}
+
+	now = now.In(loc)
+	minute := now.Hour()*60 + now.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	if startMinute < endMinute {
+		return minute >= startMinute && minute < endMinute, nil
+	}
+	return minute >= startMinute || minute < endMinute, nil
+}
+
+// ParseCampaignCSV 解析收件人 CSV，首行为列名，每列都作为模板变量
+func ParseCampaignCSV(r io.Reader) ([]CampaignRecipientInput, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: CSV 内容为空", ErrInvalidCampaign)
+		}
+		return nil, fmt.Errorf("%w: 解析 CSV 失败: %v", ErrInvalidCampaign, err)
+	}
+	phoneIndex := 0
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], utf8BOM))
+	}
+	for i, name := range header {
+		if matchesPhoneColumn(name) {
+			phoneIndex = i
+			break
+		}
+	}
+
+	var inputs []CampaignRecipientInput
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: 解析 CSV 失败: %v", ErrInvalidCampaign, err)
+		}
+		if phoneIndex >= len(record) || strings.TrimSpace(record[phoneIndex]) == "" {
+			continue
+		}
+		vars := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) && name != "" {
+				vars[name] = strings.TrimSpace(record[i])
+			}
+		}
+		inputs = append(inputs, CampaignRecipientInput{
+			PhoneNumber: strings.TrimSpace(record[phoneIndex]),
+			Vars:        vars,
+		})
+	}
+	return inputs, nil
+}
+
+func matchesPhoneColumn(name string) bool {
+	for _, column := range campaignPhoneColumns {
+		if strings.EqualFold(name, column) {
+			return true
+		}
+	}
+	return false
+}
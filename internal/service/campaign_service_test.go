@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestParseCampaignCSV(t *testing.T) {
+	inputs, err := ParseCampaignCSV(strings.NewReader(utf8BOM + "name,Phone,code\nAlice,10086,A1\nBob,,B2\nCarol,10010\n"))
+	if err != nil {
+		t.Fatalf("ParseCampaignCSV failed: %v", err)
+	}
+	if len(inputs) != 2 {
+		t.Fatalf("Expected 2 recipients, got %d", len(inputs))
+	}
+	if inputs[0].PhoneNumber != "10086" || inputs[0].Vars["name"] != "Alice" || inputs[0].Vars["code"] != "A1" {
+		t.Errorf("Unexpected first recipient: %+v", inputs[0])
+	}
+	if inputs[1].PhoneNumber != "10010" || inputs[1].Vars["code"] != "" {
+		t.Errorf("Unexpected second recipient: %+v", inputs[1])
+	}
+
+	// 没有手机号列时使用第一列
+	inputs, err = ParseCampaignCSV(strings.NewReader("number,name\n10086,Alice\n"))
+	if err != nil || len(inputs) != 1 || inputs[0].PhoneNumber != "10086" {
+		t.Errorf("Expected first column as phone, got %+v, %v", inputs, err)
+	}
+
+	if _, err := ParseCampaignCSV(strings.NewReader("")); !errors.Is(err, ErrInvalidCampaign) {
+		t.Errorf("Expected ErrInvalidCampaign for empty CSV, got %v", err)
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return time.Date(2024, 1, 1, parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+	}
+	for _, tt := range []struct {
+		start, end, now string
+		want            bool
+	}{
+		{"", "", "03:00", false},
+		{"22:00", "08:00", "23:30", true},
+		{"22:00", "08:00", "07:59", true},
+		{"22:00", "08:00", "08:00", false},
+		{"12:00", "14:00", "13:00", true},
+		{"12:00", "14:00", "11:59", false},
+	} {
+		campaign := models.Campaign{QuietStart: tt.start, QuietEnd: tt.end, Timezone: "UTC"}
+		got, err := inQuietHours(campaign, at(tt.now))
+		if err != nil {
+			t.Fatalf("inQuietHours failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("quiet %s-%s at %s: expected %v, got %v", tt.start, tt.end, tt.now, tt.want, got)
+		}
+	}
+}
+
+func TestCampaignService(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	svc := NewCampaignService(zap.NewNop(), db, nil, nil)
+
+	var sent []string
+	svc.send = func(campaign models.Campaign, to, content string, allow func(string) bool) (string, string, error) {
+		if !allow("dev-1") {
+			return "", "", errDeviceRateLimited
+		}
+		if to == "10000" {
+			return "", "", errors.New("设备不在线")
+		}
+		sent = append(sent, content)
+		return fmt.Sprintf("msg-%d", len(sent)), "dev-1", nil
+	}
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	campaign := &models.Campaign{
+		Name:          "新年问候",
+		Content:       "{{name}} 你好，号码 {{phone}}",
+		StartAt:       start.UnixMilli(),
+		QuietStart:    "22:00",
+		QuietEnd:      "08:00",
+		Timezone:      "UTC",
+		RatePerMinute: 12,
+	}
+	var inputs []CampaignRecipientInput
+	for i := 1; i <= 14; i++ {
+		inputs = append(inputs, CampaignRecipientInput{PhoneNumber: fmt.Sprintf("1380000%04d", i), Vars: map[string]string{"name": fmt.Sprintf("用户%d", i)}})
+	}
+	inputs = append(inputs, CampaignRecipientInput{PhoneNumber: "13800000001"}, CampaignRecipientInput{PhoneNumber: "10000"})
+	if err := svc.Create(ctx, campaign, inputs); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if campaign.Progress.Total != 15 {
+		t.Errorf("Expected duplicate number removed, got total %d", campaign.Progress.Total)
+	}
+
+	// 未到开始时间不发送
+	svc.tick(ctx, start.Add(-time.Minute))
+	if len(sent) != 0 {
+		t.Fatalf("Expected no sends before start, got %d", len(sent))
+	}
+
+	// 每次检查最多发送一分钟配额的 5 秒比例（12/分钟 -> 1 条）
+	svc.tick(ctx, start)
+	if len(sent) != 1 || sent[0] != "用户1 你好，号码 13800000001" {
+		t.Fatalf("Expected 1 rendered message, got %v", sent)
+	}
+	fetched, _ := svc.GetById(ctx, campaign.ID)
+	if fetched.Status != models.CampaignStatusRunning || fetched.StartedAt != start.UnixMilli() {
+		t.Errorf("Expected running campaign, got %+v", fetched)
+	}
+
+	// 一分钟内最多发送 12 条
+	for i := 1; i <= 20; i++ {
+		svc.tick(ctx, start.Add(time.Duration(i)*campaignTickInterval/2))
+	}
+	if len(sent) != 12 {
+		t.Fatalf("Expected 12 sends within a minute, got %d", len(sent))
+	}
+
+	// 发送结果更新进度
+	if err := svc.UpdateStatusByMsgId(ctx, "msg-1", models.LastRunStatusSuccess); err != nil {
+		t.Fatalf("UpdateStatusByMsgId failed: %v", err)
+	}
+	if err := svc.UpdateStatusByMsgId(ctx, "msg-2", models.LastRunStatusFailed); err != nil {
+		t.Fatalf("UpdateStatusByMsgId failed: %v", err)
+	}
+	fetched, _ = svc.GetById(ctx, campaign.ID)
+	want := models.CampaignProgress{Total: 15, Queued: 3, Sent: 10, Delivered: 1, Failed: 1}
+	if fetched.Progress != want {
+		t.Errorf("Expected progress %+v, got %+v", want, fetched.Progress)
+	}
+
+	// 暂停后不发送，恢复后继续
+	if err := svc.Pause(ctx, campaign.ID); err != nil {
+		t.Fatalf("Pause failed: %v", err)
+	}
+	if err := svc.Pause(ctx, campaign.ID); !errors.Is(err, ErrCampaignState) {
+		t.Errorf("Expected ErrCampaignState when pausing twice, got %v", err)
+	}
+	svc.tick(ctx, start.Add(2*time.Minute))
+	if len(sent) != 12 {
+		t.Fatalf("Expected no sends while paused, got %d", len(sent))
+	}
+	if err := svc.Resume(ctx, campaign.ID); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	// 免打扰时段内不发送
+	svc.tick(ctx, start.Add(14*time.Hour))
+	if len(sent) != 12 {
+		t.Fatalf("Expected no sends in quiet hours, got %d", len(sent))
+	}
+
+	// 发送剩余收件人，发送失败的记录原因
+	later := start.Add(3 * time.Minute)
+	for i := 0; i < 3; i++ {
+		svc.tick(ctx, later.Add(time.Duration(i)*campaignTickInterval))
+	}
+	page, err := svc.GetRecipients(ctx, campaign.ID, models.CampaignRecipientFailed, "", 10)
+	if err != nil {
+		t.Fatalf("GetRecipients failed: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[1].PhoneNumber != "10000" || page.Items[1].Error == "" {
+		t.Errorf("Expected failed recipient with error, got %+v", page.Items)
+	}
+
+	// 没有排队的收件人后完成
+	svc.tick(ctx, later.Add(time.Minute))
+	fetched, _ = svc.GetById(ctx, campaign.ID)
+	if fetched.Status != models.CampaignStatusCompleted || fetched.Progress.Queued != 0 {
+		t.Errorf("Expected completed campaign, got %+v", fetched)
+	}
+	if err := svc.Cancel(ctx, campaign.ID); !errors.Is(err, ErrCampaignState) {
+		t.Errorf("Expected ErrCampaignState when cancelling completed campaign, got %v", err)
+	}
+
+	// 分页
+	first, err := svc.GetRecipients(ctx, campaign.ID, "", "", 10)
+	if err != nil || len(first.Items) != 10 || first.NextCursor == "" {
+		t.Fatalf("Expected first page of 10, got %+v, %v", first, err)
+	}
+	second, err := svc.GetRecipients(ctx, campaign.ID, "", first.NextCursor, 10)
+	if err != nil || len(second.Items) != 5 || second.Items[0].Seq != 11 {
+		t.Errorf("Expected second page starting at seq 11, got %+v, %v", second, err)
+	}
+}
+
+func TestCampaignService_Cancel(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	svc := NewCampaignService(zap.NewNop(), db, nil, nil)
+	svc.send = func(models.Campaign, string, string, func(string) bool) (string, string, error) {
+		t.Fatal("Cancelled campaign should not send")
+		return "", "", nil
+	}
+
+	campaign := &models.Campaign{Name: "取消", Content: "hi"}
+	if err := svc.Create(ctx, campaign, []CampaignRecipientInput{{PhoneNumber: "10086"}, {PhoneNumber: "10010"}}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if campaign.RatePerMinute != defaultCampaignRate {
+		t.Errorf("Expected default rate %d, got %d", defaultCampaignRate, campaign.RatePerMinute)
+	}
+	if err := svc.Cancel(ctx, campaign.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	svc.tick(ctx, time.Now())
+
+	fetched, _ := svc.GetById(ctx, campaign.ID)
+	if fetched.Status != models.CampaignStatusCancelled || fetched.Progress.Cancelled != 2 {
+		t.Errorf("Expected cancelled campaign, got %+v", fetched)
+	}
+
+	if err := svc.Create(ctx, &models.Campaign{Name: "x", Content: "hi", QuietStart: "22:00"}, []CampaignRecipientInput{{PhoneNumber: "10086"}}); !errors.Is(err, ErrInvalidCampaign) {
+		t.Errorf("Expected ErrInvalidCampaign for half quiet hours, got %v", err)
+	}
+}
+
+func TestCampaignService_SharedDeviceRate(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	svc := NewCampaignService(zap.NewNop(), db, nil, nil)
+
+	// 两个活动都通过 dev-1 发送
+	sent := map[string]int{}
+	svc.send = func(campaign models.Campaign, to, content string, allow func(string) bool) (string, string, error) {
+		if !allow("dev-1") {
+			return "", "", errDeviceRateLimited
+		}
+		sent[campaign.ID]++
+		return fmt.Sprintf("msg-%s-%d", campaign.ID, sent[campaign.ID]), "dev-1", nil
+	}
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	var ids []string
+	for _, name := range []string{"A", "B"} {
+		campaign := &models.Campaign{Name: name, Content: "hi", StartAt: start.UnixMilli(), RatePerMinute: 12}
+		var inputs []CampaignRecipientInput
+		for i := 1; i <= 20; i++ {
+			inputs = append(inputs, CampaignRecipientInput{PhoneNumber: fmt.Sprintf("1380000%04d", i)})
+		}
+		if err := svc.Create(ctx, campaign, inputs); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		ids = append(ids, campaign.ID)
+	}
+
+	// 同时进行的活动共用设备配额，一分钟内 dev-1 合计最多发送 12 条
+	for i := 0; i < 12; i++ {
+		svc.tick(ctx, start.Add(time.Duration(i)*campaignTickInterval))
+	}
+	if total := sent[ids[0]] + sent[ids[1]]; total != 12 {
+		t.Fatalf("Expected 12 sends on dev-1 within a minute, got %d (%v)", total, sent)
+	}
+	// 配额按活动先后分配，先处理的活动用完全部配额
+	if max(sent[ids[0]], sent[ids[1]]) != 12 {
+		t.Errorf("Expected one campaign to use the whole device quota, got %v", sent)
+	}
+
+	// 配额用完的收件人仍在排队
+	for _, id := range ids {
+		fetched, err := svc.GetById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetById failed: %v", err)
+		}
+		if fetched.Progress.Failed != 0 || fetched.Progress.Queued != int64(20-sent[id]) {
+			t.Errorf("Unexpected progress for %s: %+v", id, fetched.Progress)
+		}
+	}
+}
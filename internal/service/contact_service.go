@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ContactService 联系人管理服务
+type ContactService struct {
+	repo *repo.ContactRepo
+}
+
+// NewContactService 创建联系人服务实例
+func NewContactService(db *gorm.DB) *ContactService {
+	return &ContactService{
+		repo: repo.NewContactRepo(db),
+	}
+}
+
+// List 获取联系人，group 为空时获取全部
+func (s *ContactService) List(ctx context.Context, group string) ([]models.Contact, error) {
+	return s.repo.FindByGroup(ctx, group)
+}
+
+// Groups 获取所有联系人分组
+func (s *ContactService) Groups(ctx context.Context) ([]string, error) {
+	return s.repo.FindGroups(ctx)
+}
+
+// GetById 根据ID获取联系人
+func (s *ContactService) GetById(ctx context.Context, id string) (*models.Contact, error) {
+	contact, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// Create 创建联系人
+func (s *ContactService) Create(ctx context.Context, contact *models.Contact) error {
+	now := time.Now().UnixMilli()
+	contact.ID = uuid.New().String()
+	contact.CreatedAt = now
+	contact.UpdatedAt = now
+	return s.repo.Create(ctx, contact)
+}
+
+// Update 更新联系人
+func (s *ContactService) Update(ctx context.Context, contact *models.Contact) error {
+	existing, err := s.GetById(ctx, contact.ID)
+	if err != nil {
+		return err
+	}
+	existing.Name = contact.Name
+	existing.PhoneNumber = contact.PhoneNumber
+	existing.GroupName = contact.GroupName
+	existing.Remark = contact.Remark
+	if err := s.repo.Save(ctx, existing); err != nil {
+		return err
+	}
+	*contact = *existing
+	return nil
+}
+
+// Delete 删除联系人
+func (s *ContactService) Delete(ctx context.Context, id string) error {
+	return s.repo.DeleteById(ctx, id)
+}
+
+// PhoneNumbersByGroup 获取分组内所有联系人的号码
+func (s *ContactService) PhoneNumbersByGroup(ctx context.Context, group string) ([]string, error) {
+	contacts, err := s.repo.FindByGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		numbers = append(numbers, contact.PhoneNumber)
+	}
+	return numbers, nil
+}
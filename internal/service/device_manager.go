@@ -2,16 +2,21 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/Starktomy/smshub/internal/metrics"
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/Starktomy/smshub/internal/tracing"
 	"github.com/google/uuid"
 	"go.bug.st/serial"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -34,6 +39,12 @@ const (
 	MaxConcurrentSends = 5
 )
 
+// errDeviceRateLimited 在线设备都已达到发送速率上限，稍后重试
+var errDeviceRateLimited = errors.New("在线设备均已达到发送速率上限")
+
+// TelemetryRecorder 记录设备状态上报中的遥测数据
+type TelemetryRecorder func(deviceID string, status *StatusData)
+
 // ManagedDevice 管理的设备（包含运行时状态）
 type ManagedDevice struct {
 	Device        *models.Device
@@ -58,6 +69,8 @@ type DeviceManager struct {
 
 	// 定时任务状态更新器
 	scheduledTaskStatusUpdater ScheduledTaskStatusUpdater
+	// 设备遥测记录器
+	telemetryRecorder TelemetryRecorder
 
 	// 停止信号
 	stopCh chan struct{}
@@ -88,6 +101,11 @@ func (dm *DeviceManager) SetScheduledTaskStatusUpdater(updater ScheduledTaskStat
 	dm.scheduledTaskStatusUpdater = updater
 }
 
+// SetTelemetryRecorder 设置设备遥测记录器，在每次状态上报后调用
+func (dm *DeviceManager) SetTelemetryRecorder(recorder TelemetryRecorder) {
+	dm.telemetryRecorder = recorder
+}
+
 // Start 启动设备管理器
 func (dm *DeviceManager) Start(ctx context.Context) error {
 	dm.logger.Info("启动设备管理器")
@@ -249,6 +267,10 @@ func (dm *DeviceManager) updateDeviceStatus(deviceID string, status *StatusData)
 	if err := dm.repo.UpdateColumnsById(ctx, deviceID, columns); err != nil {
 		dm.logger.Error("更新设备状态失败", zap.String("id", deviceID), zap.Error(err))
 	}
+
+	if status != nil && dm.telemetryRecorder != nil {
+		dm.telemetryRecorder(deviceID, status)
+	}
 }
 
 // healthCheckLoop 健康检查循环
@@ -409,6 +431,9 @@ func (dm *DeviceManager) EnableDevice(ctx context.Context, id string) error {
 
 // DisableDevice 禁用设备
 func (dm *DeviceManager) DisableDevice(ctx context.Context, id string) error {
+	if !repo.InDeviceScope(ctx, id) {
+		return ErrDeviceForbidden
+	}
 	if err := dm.repo.UpdateColumnsById(ctx, id, map[string]any{
 		"enabled": false,
 		"status":  models.DeviceStatusOffline,
@@ -421,12 +446,9 @@ func (dm *DeviceManager) DisableDevice(ctx context.Context, id string) error {
 
 // SetDeviceFlymode 设置设备飞行模式
 func (dm *DeviceManager) SetDeviceFlymode(ctx context.Context, id string, enabled bool) error {
-	dm.devicesMu.RLock()
-	md, exists := dm.devices[id]
-	dm.devicesMu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("设备不在线: %s", id)
+	md, err := dm.onlineDevice(ctx, id)
+	if err != nil {
+		return err
 	}
 
 	return md.SerialService.SetFlymode(enabled)
@@ -434,25 +456,29 @@ func (dm *DeviceManager) SetDeviceFlymode(ctx context.Context, id string, enable
 
 // RebootDevice 重启设备
 func (dm *DeviceManager) RebootDevice(ctx context.Context, id string) error {
-	dm.devicesMu.RLock()
-	md, exists := dm.devices[id]
-	dm.devicesMu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("设备不在线: %s", id)
+	md, err := dm.onlineDevice(ctx, id)
+	if err != nil {
+		return err
 	}
 
 	return md.SerialService.RebootMcu()
 }
 
+// SendUSSDByDevice 通过指定设备发送 USSD 请求并返回运营商回复
+func (dm *DeviceManager) SendUSSDByDevice(ctx context.Context, id, code string) (string, error) {
+	md, err := dm.onlineDevice(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	return md.SerialService.SendUSSD(ctx, code)
+}
+
 // GetDeviceStatus 获取设备状态
 func (dm *DeviceManager) GetDeviceStatus(ctx context.Context, id string) (*StatusData, error) {
-	dm.devicesMu.RLock()
-	md, exists := dm.devices[id]
-	dm.devicesMu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("设备不在线: %s", id)
+	md, err := dm.onlineDevice(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 
 	return md.SerialService.GetStatus()
@@ -485,26 +511,29 @@ func (dm *DeviceManager) GetDeviceGroups(ctx context.Context) ([]string, error)
 // ==================== 短信发送 API ====================
 
 // SendSMSByDevice 通过指定设备发送短信
-func (dm *DeviceManager) SendSMSByDevice(deviceID, to, content string) (string, error) {
-	dm.devicesMu.RLock()
-	md, exists := dm.devices[deviceID]
-	dm.devicesMu.RUnlock()
-
-	if !exists {
-		return "", fmt.Errorf("设备不在线: %s", deviceID)
+func (dm *DeviceManager) SendSMSByDevice(ctx context.Context, deviceID, to, content string) (string, error) {
+	md, err := dm.onlineDevice(ctx, deviceID)
+	if err != nil {
+		return "", err
 	}
 
-	return md.SerialService.SendSMS(to, content)
+	return md.SerialService.SendSMS(ctx, to, content)
+}
+
+// SendSMS 自动从 ctx 可访问的在线设备中选择设备发送短信
+func (dm *DeviceManager) SendSMS(ctx context.Context, to, content string, strategy SendStrategy) (string, string, error) {
+	return dm.SendSMSWhere(ctx, to, content, strategy, nil)
 }
 
-// SendSMS 自动选择设备发送短信
-func (dm *DeviceManager) SendSMS(to, content string, strategy SendStrategy) (string, string, error) {
-	device, err := dm.selectDevice(strategy)
+// SendSMSWhere 按策略从 allow 允许的在线设备中选择设备发送短信，allow 为空时不限制
+// 在线设备都不被允许时返回 errDeviceRateLimited
+func (dm *DeviceManager) SendSMSWhere(ctx context.Context, to, content string, strategy SendStrategy, allow func(deviceID string) bool) (string, string, error) {
+	device, err := dm.selectDevice(ctx, strategy, allow)
 	if err != nil {
 		return "", "", err
 	}
 
-	msgID, err := dm.SendSMSByDevice(device.ID, to, content)
+	msgID, err := dm.SendSMSByDevice(ctx, device.ID, to, content)
 	return msgID, device.ID, err
 }
 
@@ -525,65 +554,69 @@ type BatchSendResult struct {
 	Error     string `json:"error,omitempty"`
 }
 
-// BatchSendSMS 批量发送短信
-func (dm *DeviceManager) BatchSendSMS(req *BatchSendRequest) []BatchSendResult {
-	results := make([]BatchSendResult, len(req.Recipients))
-
-	// 使用带缓冲的 channel 实现并发限制
-	semaphore := make(chan struct{}, MaxConcurrentSends)
-	var wg sync.WaitGroup
-
-	for i, recipient := range req.Recipients {
-		wg.Add(1)
-		go func(index int, recipient string) {
-			defer wg.Done()
-
-			// 获取信号量，控制并发数
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			result := BatchSendResult{Recipient: recipient}
-
-			var msgID, deviceID string
-			var err error
-
-			if req.DeviceID != "" {
-				// 指定设备发送
-				msgID, err = dm.SendSMSByDevice(req.DeviceID, recipient, req.Content)
-				deviceID = req.DeviceID
-			} else {
-				// 按策略选择设备
-				msgID, deviceID, err = dm.SendSMS(recipient, req.Content, req.Strategy)
-			}
+// sendBatchRecipient 批量发送中向单个收件人发送，指定设备时使用该设备，否则按策略选择
+// 批量任务在后台执行，设备权限已在提交时检查
+func (dm *DeviceManager) sendBatchRecipient(req *BatchSendRequest, recipient string) BatchSendResult {
+	ctx := context.Background()
+	result := BatchSendResult{Recipient: recipient}
 
-			if err != nil {
-				result.Success = false
-				result.Error = err.Error()
-			} else {
-				result.Success = true
-				result.MessageID = msgID
-				result.DeviceID = deviceID
-			}
+	var msgID, deviceID string
+	var err error
 
-			results[index] = result
-		}(i, recipient)
+	if req.DeviceID != "" {
+		// 指定设备发送
+		msgID, err = dm.SendSMSByDevice(ctx, req.DeviceID, recipient, req.Content)
+		deviceID = req.DeviceID
+	} else {
+		// 按策略选择设备
+		msgID, deviceID, err = dm.SendSMS(ctx, recipient, req.Content, req.Strategy)
 	}
 
-	wg.Wait()
-	return results
-}
+	if err != nil {
+		result.Success = false
+		result.Error = err.Error()
+	} else {
+		result.Success = true
+		result.MessageID = msgID
+		result.DeviceID = deviceID
+	}
+	return result
+}
+
+// selectDevice 根据策略从 ctx 可访问的在线设备中选择设备
+func (dm *DeviceManager) selectDevice(ctx context.Context, strategy SendStrategy, allow func(deviceID string) bool) (device *models.Device, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "device.select", trace.WithAttributes(
+		attribute.String("device.strategy", string(strategy)),
+	))
+	defer func() {
+		if device != nil {
+			span.SetAttributes(attribute.String("device.id", device.ID), attribute.String("device.name", device.Name))
+		}
+		tracing.End(span, err)
+	}()
 
-// selectDevice 根据策略选择设备
-func (dm *DeviceManager) selectDevice(strategy SendStrategy) (*models.Device, error) {
-	ctx := context.Background()
 	onlineDevices, err := dm.repo.FindAllOnline(ctx)
 	if err != nil {
 		return nil, err
 	}
+	span.SetAttributes(attribute.Int("device.online", len(onlineDevices)))
 
 	if len(onlineDevices) == 0 {
 		return nil, fmt.Errorf("没有可用的在线设备")
 	}
+	if allow != nil {
+		// 保持原有顺序（按信号排序），只过滤
+		allowed := onlineDevices[:0]
+		for _, d := range onlineDevices {
+			if allow(d.ID) {
+				allowed = append(allowed, d)
+			}
+		}
+		if len(allowed) == 0 {
+			return nil, errDeviceRateLimited
+		}
+		onlineDevices = allowed
+	}
 
 	switch strategy {
 	case StrategyRoundRobin:
@@ -631,7 +664,93 @@ func (dm *DeviceManager) GetOnlineDeviceCount() int {
 	return len(dm.devices)
 }
 
+// onlineDevice 获取在线设备，ctx 无权访问该设备时返回 ErrDeviceForbidden
+func (dm *DeviceManager) onlineDevice(ctx context.Context, id string) (*ManagedDevice, error) {
+	if !repo.InDeviceScope(ctx, id) {
+		return nil, ErrDeviceForbidden
+	}
+
+	dm.devicesMu.RLock()
+	md, exists := dm.devices[id]
+	dm.devicesMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("设备不在线: %s", id)
+	}
+	return md, nil
+}
+
 // GetDeviceStats 获取设备统计信息
 func (dm *DeviceManager) GetDeviceStats(ctx context.Context) (map[string]int64, error) {
 	return dm.repo.CountByStatus(ctx)
 }
+
+// DeviceStates 返回所有设备的在线状态、信号和最近心跳时间，供指标采集使用
+func (dm *DeviceManager) DeviceStates() []metrics.DeviceState {
+	devices, err := dm.repo.FindAll(context.Background())
+	if err != nil {
+		dm.logger.Warn("读取设备状态失败", zap.Error(err))
+		return nil
+	}
+
+	states := make([]metrics.DeviceState, 0, len(devices))
+	for _, device := range devices {
+		state := metrics.DeviceState{
+			ID:          device.ID,
+			Name:        device.Name,
+			Online:      device.Status == models.DeviceStatusOnline,
+			SignalLevel: device.SignalLevel,
+		}
+		if device.LastSeenAt > 0 {
+			state.LastSeenAt = time.UnixMilli(device.LastSeenAt)
+		}
+
+		// 设备运行中且有缓存的状态时导出详细信号数据
+		dm.devicesMu.RLock()
+		md, running := dm.devices[device.ID]
+		dm.devicesMu.RUnlock()
+		if running && state.Online {
+			if status, ok := md.SerialService.deviceCache.Get(CacheKeyDeviceStatus); ok {
+				state.HasSignal = true
+				state.SignalLevel = status.Mobile.SignalLevel
+				state.Rssi = status.Mobile.Rssi
+				state.Rsrp = status.Mobile.Rsrp
+				state.Rsrq = status.Mobile.Rsrq
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// SerialConnection 运行中设备的串口连接状态
+type SerialConnection struct {
+	DeviceID   string `json:"deviceId"`
+	DeviceName string `json:"deviceName"`
+	Port       string `json:"port"`
+	Connected  bool   `json:"connected"`
+}
+
+// SerialConnections 返回运行中设备的串口连接状态
+func (dm *DeviceManager) SerialConnections() []SerialConnection {
+	dm.devicesMu.RLock()
+	defer dm.devicesMu.RUnlock()
+
+	connections := make([]SerialConnection, 0, len(dm.devices))
+	for id, md := range dm.devices {
+		port, connected := md.SerialService.getConnectionInfo()
+		connections = append(connections, SerialConnection{
+			DeviceID:   id,
+			DeviceName: md.SerialService.GetDeviceName(),
+			Port:       port,
+			Connected:  connected,
+		})
+	}
+	return connections
+}
+
+// CountOnlineDevices 统计启用且在线的设备数量
+func (dm *DeviceManager) CountOnlineDevices(ctx context.Context) (int, error) {
+	devices, err := dm.repo.FindAllOnline(ctx)
+	return len(devices), err
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultMaxNotificationBacklog 默认允许同时发送中的通知数量
+	defaultMaxNotificationBacklog = 100
+	// healthCheckTimeout 单次就绪检查的超时时间
+	healthCheckTimeout = 3 * time.Second
+)
+
+// 就绪检查项
+const (
+	HealthComponentDatabase      = "database"
+	HealthComponentDevices       = "devices"
+	HealthComponentSerial        = "serial"
+	HealthComponentScheduler     = "scheduler"
+	HealthComponentNotifications = "notifications"
+)
+
+// HealthStatus 检查结果
+type HealthStatus string
+
+const (
+	HealthStatusUp   HealthStatus = "up"
+	HealthStatusDown HealthStatus = "down"
+)
+
+// ComponentHealth 单个检查项的结果
+type ComponentHealth struct {
+	Status   HealthStatus `json:"status"`
+	Optional bool         `json:"optional,omitempty"` // 不影响就绪状态
+	Error    string       `json:"error,omitempty"`
+	Details  any          `json:"details,omitempty"`
+}
+
+// HealthReport 就绪检查结果，任一非可选检查项失败时整体为 down
+type HealthReport struct {
+	Status     HealthStatus               `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+	CheckedAt  int64                      `json:"checkedAt"`
+}
+
+// HealthService 存活和就绪检查
+type HealthService struct {
+	logger           *zap.Logger
+	db               *gorm.DB
+	deviceManager    *DeviceManager
+	serialService    *SerialService // 单设备模式的串口服务，未配置串口时为 nil
+	scheduler        *SchedulerService
+	notifier         *Notifier
+	minOnlineDevices int
+	maxBacklog       int64
+	optional         map[string]bool
+}
+
+// NewHealthService 创建健康检查服务实例，serialService 仅在单设备模式下传入
+func NewHealthService(
+	logger *zap.Logger,
+	db *gorm.DB,
+	healthConfig config.HealthConfig,
+	deviceManager *DeviceManager,
+	serialService *SerialService,
+	scheduler *SchedulerService,
+	notifier *Notifier,
+) *HealthService {
+	maxBacklog := healthConfig.MaxNotificationBacklog
+	if maxBacklog <= 0 {
+		maxBacklog = defaultMaxNotificationBacklog
+	}
+	optional := make(map[string]bool, len(healthConfig.Optional))
+	for _, name := range healthConfig.Optional {
+		switch name {
+		case HealthComponentDatabase, HealthComponentDevices, HealthComponentSerial,
+			HealthComponentScheduler, HealthComponentNotifications:
+			optional[name] = true
+		default:
+			logger.Warn("未知的就绪检查项", zap.String("name", name))
+		}
+	}
+
+	return &HealthService{
+		logger:           logger,
+		db:               db,
+		deviceManager:    deviceManager,
+		serialService:    serialService,
+		scheduler:        scheduler,
+		notifier:         notifier,
+		minOnlineDevices: healthConfig.MinOnlineDevices,
+		maxBacklog:       int64(maxBacklog),
+		optional:         optional,
+	}
+}
+
+// Ready 执行所有检查项
+func (s *HealthService) Ready(ctx context.Context) *HealthReport {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := &HealthReport{
+		Status: HealthStatusUp,
+		Components: map[string]ComponentHealth{
+			HealthComponentDatabase:      s.checkDatabase(ctx),
+			HealthComponentDevices:       s.checkDevices(ctx),
+			HealthComponentSerial:        s.checkSerial(),
+			HealthComponentScheduler:     s.checkScheduler(),
+			HealthComponentNotifications: s.checkNotifications(),
+		},
+		CheckedAt: time.Now().UnixMilli(),
+	}
+	for name, component := range report.Components {
+		if s.optional[name] {
+			component.Optional = true
+			report.Components[name] = component
+			continue
+		}
+		if component.Status != HealthStatusUp {
+			report.Status = HealthStatusDown
+		}
+	}
+	return report
+}
+
+// checkDatabase 检查数据库连接
+func (s *HealthService) checkDatabase(ctx context.Context) ComponentHealth {
+	sqlDB, err := s.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		return componentDown(err)
+	}
+	return ComponentHealth{Status: HealthStatusUp}
+}
+
+// checkDevices 检查在线设备数量是否达到要求
+func (s *HealthService) checkDevices(ctx context.Context) ComponentHealth {
+	online, err := s.deviceManager.CountOnlineDevices(ctx)
+	if err != nil {
+		return componentDown(err)
+	}
+	details := map[string]int{"online": online, "required": s.minOnlineDevices}
+	if online < s.minOnlineDevices {
+		return ComponentHealth{
+			Status:  HealthStatusDown,
+			Error:   fmt.Sprintf("在线设备数量 %d 少于 %d", online, s.minOnlineDevices),
+			Details: details,
+		}
+	}
+	return ComponentHealth{Status: HealthStatusUp, Details: details}
+}
+
+// checkSerial 检查每个运行中设备的串口是否已连接
+func (s *HealthService) checkSerial() ComponentHealth {
+	connections := s.deviceManager.SerialConnections()
+	if s.serialService != nil {
+		port, connected := s.serialService.getConnectionInfo()
+		connections = append(connections, SerialConnection{DeviceName: "default", Port: port, Connected: connected})
+	}
+
+	var disconnected []string
+	for _, conn := range connections {
+		if !conn.Connected {
+			disconnected = append(disconnected, conn.DeviceName)
+		}
+	}
+	if len(disconnected) > 0 {
+		return ComponentHealth{
+			Status:  HealthStatusDown,
+			Error:   "串口未连接: " + strings.Join(disconnected, ", "),
+			Details: connections,
+		}
+	}
+	return ComponentHealth{Status: HealthStatusUp, Details: connections}
+}
+
+// checkScheduler 检查定时任务调度是否在运行
+func (s *HealthService) checkScheduler() ComponentHealth {
+	if !s.scheduler.Running() {
+		return ComponentHealth{Status: HealthStatusDown, Error: "定时任务调度未运行"}
+	}
+	return ComponentHealth{Status: HealthStatusUp}
+}
+
+// checkNotifications 检查正在发送的通知是否积压
+func (s *HealthService) checkNotifications() ComponentHealth {
+	pending := s.notifier.Pending()
+	details := map[string]int64{"pending": pending, "max": s.maxBacklog}
+	if pending > s.maxBacklog {
+		return ComponentHealth{
+			Status:  HealthStatusDown,
+			Error:   fmt.Sprintf("待发送通知 %d 条，超过 %d", pending, s.maxBacklog),
+			Details: details,
+		}
+	}
+	return ComponentHealth{Status: HealthStatusUp, Details: details}
+}
+
+// componentDown 检查出错时的结果
+func componentDown(err error) ComponentHealth {
+	return ComponentHealth{Status: HealthStatusDown, Error: err.Error()}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"go.uber.org/zap"
+)
+
+func TestHealthServiceReady(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	deviceRepo := repo.NewDeviceRepo(db)
+	if err := deviceRepo.Create(ctx, &models.Device{ID: "d1", Name: "主卡", Enabled: true, Status: models.DeviceStatusOnline}); err != nil {
+		t.Fatal(err)
+	}
+	dm := NewDeviceManager(zap.NewNop(), deviceRepo, nil, nil, nil)
+	scheduler := NewSchedulerService(zap.NewNop(), db, nil, dm)
+	notifier := NewNotifier(zap.NewNop())
+
+	newHealth := func(healthConfig config.HealthConfig) *HealthService {
+		return NewHealthService(zap.NewNop(), db, healthConfig, dm, nil, scheduler, notifier)
+	}
+
+	// 调度未启动时未就绪
+	report := newHealth(config.HealthConfig{MinOnlineDevices: 1}).Ready(ctx)
+	if report.Status != HealthStatusDown || report.Components[HealthComponentScheduler].Status != HealthStatusDown {
+		t.Fatalf("调度未运行时应未就绪: %+v", report)
+	}
+	if report.Components[HealthComponentDatabase].Status != HealthStatusUp ||
+		report.Components[HealthComponentDevices].Status != HealthStatusUp {
+		t.Fatalf("数据库和设备检查应通过: %+v", report.Components)
+	}
+
+	if err := scheduler.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer scheduler.Stop()
+	if report := newHealth(config.HealthConfig{MinOnlineDevices: 1}).Ready(ctx); report.Status != HealthStatusUp {
+		t.Fatalf("所有检查通过时应就绪: %+v", report.Components)
+	}
+
+	// 在线设备不足
+	report = newHealth(config.HealthConfig{MinOnlineDevices: 2}).Ready(ctx)
+	if report.Status != HealthStatusDown || report.Components[HealthComponentDevices].Error == "" {
+		t.Fatalf("在线设备不足时应未就绪: %+v", report.Components)
+	}
+
+	// 可选检查项失败不影响就绪状态
+	report = newHealth(config.HealthConfig{MinOnlineDevices: 2, Optional: []string{HealthComponentDevices}}).Ready(ctx)
+	devices := report.Components[HealthComponentDevices]
+	if report.Status != HealthStatusUp || devices.Status != HealthStatusDown || !devices.Optional {
+		t.Fatalf("可选检查项失败时仍应就绪: %+v", report)
+	}
+
+	// 通知积压
+	notifier.pending.Add(5)
+	defer notifier.pending.Add(-5)
+	report = newHealth(config.HealthConfig{MaxNotificationBacklog: 3}).Ready(ctx)
+	if report.Status != HealthStatusDown || report.Components[HealthComponentNotifications].Status != HealthStatusDown {
+		t.Fatalf("通知积压时应未就绪: %+v", report.Components)
+	}
+}
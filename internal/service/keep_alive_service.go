@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultKeepAliveCheckTime 保号任务默认每天检查的时刻
+const defaultKeepAliveCheckTime = "10:00"
+
+// KeepAliveStatus 保号看板中单张 SIM 卡的状态
+type KeepAliveStatus struct {
+	DeviceID        string                `json:"deviceId"`
+	DeviceName      string                `json:"deviceName"`
+	PhoneNumber     string                `json:"phoneNumber"` // SIM 卡号码
+	Enabled         bool                  `json:"enabled"`
+	Activity        models.TaskActionType `json:"activity"`
+	IntervalDays    int                   `json:"intervalDays"`
+	LeadDays        int                   `json:"leadDays"`
+	TaskID          string                `json:"taskId"`
+	LastActivityAt  int64                 `json:"lastActivityAt"`  // 最近一次有效活动时间，0 表示没有记录
+	ExpiresAt       int64                 `json:"expiresAt"`       // 预计到期时间，没有活动记录时为 0
+	DaysUntilExpiry *int                  `json:"daysUntilExpiry"` // 距离到期的天数，已过期为负数，没有活动记录时为 null
+	NextCheckAt     int64                 `json:"nextCheckAt"`     // 保号任务下次检查时间
+}
+
+// KeepAliveService SIM 卡保号服务
+// 为每个设备维护保号策略和对应的定时任务，任务每天检查一次，最近有真实发送记录时跳过
+type KeepAliveService struct {
+	logger          *zap.Logger
+	repo            *repo.KeepAlivePolicyRepo
+	deviceRepo      *repo.DeviceRepo
+	textMessageRepo *repo.TextMessageRepo
+	runRepo         *repo.ScheduledTaskRunRepo
+	scheduler       *SchedulerService
+}
+
+// NewKeepAliveService 创建保号服务实例，并注册定时任务的跳过检查
+func NewKeepAliveService(logger *zap.Logger, db *gorm.DB, scheduler *SchedulerService) *KeepAliveService {
+	s := &KeepAliveService{
+		logger:          logger,
+		repo:            repo.NewKeepAlivePolicyRepo(db),
+		deviceRepo:      repo.NewDeviceRepo(db),
+		textMessageRepo: repo.NewTextMessageRepo(db),
+		runRepo:         repo.NewScheduledTaskRunRepo(db),
+		scheduler:       scheduler,
+	}
+	scheduler.SetTaskSkipper(s.skipReason)
+	return s
+}
+
+// Get 获取设备的保号策略
+func (s *KeepAliveService) Get(ctx context.Context, deviceID string) (*models.KeepAlivePolicy, error) {
+	return s.repo.FindByDevice(ctx, deviceID)
+}
+
+// Save 保存设备的保号策略，并创建或更新绑定该设备的定时任务
+func (s *KeepAliveService) Save(ctx context.Context, policy *models.KeepAlivePolicy) error {
+	device, err := s.deviceRepo.FindById(ctx, policy.DeviceID)
+	if err != nil {
+		return err
+	}
+	if policy.CheckTime == "" {
+		policy.CheckTime = defaultKeepAliveCheckTime
+	}
+
+	existing, err := s.repo.FindByDevice(ctx, policy.DeviceID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if existing != nil {
+		policy.TaskID = existing.TaskID
+		policy.CreatedAt = existing.CreatedAt
+	}
+
+	task, err := keepAliveTask(policy, device)
+	if err != nil {
+		return err
+	}
+	if policy.TaskID != "" {
+		if _, err := s.scheduler.GetById(ctx, policy.TaskID); err == nil {
+			task.ID = policy.TaskID
+			if err := s.scheduler.Update(ctx, task); err != nil {
+				return fmt.Errorf("更新保号任务失败: %w", err)
+			}
+		}
+	}
+	if task.ID == "" {
+		// 首次保存或任务已被手动删除时重新生成
+		if err := s.scheduler.Create(ctx, task); err != nil {
+			return fmt.Errorf("创建保号任务失败: %w", err)
+		}
+	}
+	policy.TaskID = task.ID
+
+	return s.repo.Save(ctx, policy)
+}
+
+// Delete 删除设备的保号策略和对应的定时任务
+func (s *KeepAliveService) Delete(ctx context.Context, deviceID string) error {
+	policy, err := s.repo.FindByDevice(ctx, deviceID)
+	if err != nil {
+		return err
+	}
+	if policy.TaskID != "" {
+		if err := s.scheduler.Delete(ctx, policy.TaskID); err != nil {
+			return fmt.Errorf("删除保号任务失败: %w", err)
+		}
+	}
+	return s.repo.DeleteByDevice(ctx, deviceID)
+}
+
+// Dashboard 获取所有保号策略的状态，按到期时间升序，没有活动记录的排在最前
+func (s *KeepAliveService) Dashboard(ctx context.Context) ([]KeepAliveStatus, error) {
+	policies, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	items := make([]KeepAliveStatus, 0, len(policies))
+	for _, policy := range policies {
+		item := KeepAliveStatus{
+			DeviceID:     policy.DeviceID,
+			Enabled:      policy.Enabled,
+			Activity:     policy.Activity,
+			IntervalDays: policy.IntervalDays,
+			LeadDays:     policy.LeadDays,
+			TaskID:       policy.TaskID,
+		}
+		if device, err := s.deviceRepo.FindById(ctx, policy.DeviceID); err == nil {
+			item.DeviceName = device.Name
+			item.PhoneNumber = device.PhoneNumber
+		}
+		if task, err := s.scheduler.GetById(ctx, policy.TaskID); err == nil {
+			item.NextCheckAt = task.NextRunAt
+		}
+
+		item.LastActivityAt, err = s.lastActivityAt(ctx, policy)
+		if err != nil {
+			return nil, err
+		}
+		if item.LastActivityAt > 0 {
+			expiresAt := time.UnixMilli(item.LastActivityAt).Add(time.Duration(policy.IntervalDays) * 24 * time.Hour)
+			days := int(math.Ceil(expiresAt.Sub(now).Hours() / 24))
+			item.ExpiresAt = expiresAt.UnixMilli()
+			item.DaysUntilExpiry = &days
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].ExpiresAt < items[j].ExpiresAt
+	})
+	return items, nil
+}
+
+// lastActivityAt 设备最近一次有效活动时间：真实发送成功的短信或保号任务执行成功
+func (s *KeepAliveService) lastActivityAt(ctx context.Context, policy models.KeepAlivePolicy) (int64, error) {
+	sentAt, err := s.textMessageRepo.LastSentAt(ctx, policy.DeviceID)
+	if err != nil {
+		return 0, fmt.Errorf("查询最近发送短信失败: %w", err)
+	}
+	if policy.TaskID == "" {
+		return sentAt, nil
+	}
+	runAt, err := s.runRepo.LastSuccessAt(ctx, policy.TaskID)
+	if err != nil {
+		return 0, fmt.Errorf("查询保号任务执行记录失败: %w", err)
+	}
+	return max(sentAt, runAt), nil
+}
+
+// skipReason 保号任务的跳过检查：距离上次有效活动还没到需要保号的时间时跳过
+func (s *KeepAliveService) skipReason(ctx context.Context, task models.ScheduledTask) string {
+	policy, err := s.repo.FindByTask(ctx, task.ID)
+	if err != nil {
+		s.logger.Warn("查询保号策略失败", zap.String("taskId", task.ID), zap.Error(err))
+		return ""
+	}
+	if policy == nil {
+		return ""
+	}
+
+	lastActivityAt, err := s.lastActivityAt(ctx, *policy)
+	if err != nil {
+		s.logger.Warn("查询设备最近活动失败", zap.String("deviceId", policy.DeviceID), zap.Error(err))
+		return ""
+	}
+	if lastActivityAt == 0 {
+		return ""
+	}
+	dueAt := time.UnixMilli(lastActivityAt).Add(time.Duration(policy.IntervalDays-policy.LeadDays) * 24 * time.Hour)
+	if time.Now().Before(dueAt) {
+		return fmt.Sprintf("最近活动于 %s，%s 后才需要保号",
+			time.UnixMilli(lastActivityAt).Format(time.DateTime), dueAt.Format(time.DateTime))
+	}
+	return ""
+}
+
+// keepAliveTask 根据保号策略生成绑定设备的定时任务，每天在检查时刻触发
+func keepAliveTask(policy *models.KeepAlivePolicy, device *models.Device) (*models.ScheduledTask, error) {
+	clock, err := time.Parse("15:04", policy.CheckTime)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 检查时刻格式应为 HH:MM", ErrInvalidSchedule)
+	}
+	name := device.Name
+	if name == "" {
+		name = device.ID
+	}
+	return &models.ScheduledTask{
+		Name:         "保号: " + name,
+		Enabled:      policy.Enabled,
+		DeviceID:     policy.DeviceID,
+		ScheduleType: models.ScheduleTypeCron,
+		CronExpr:     fmt.Sprintf("%d %d * * *", clock.Minute(), clock.Hour()),
+		ActionType:   policy.Activity,
+		PhoneNumber:  policy.PhoneNumber,
+		Content:      policy.Content,
+		UssdCode:     policy.UssdCode,
+	}, nil
+}
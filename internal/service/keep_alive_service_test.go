@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestKeepAliveService(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	scheduler := NewSchedulerService(zap.NewNop(), db, nil, nil)
+	svc := NewKeepAliveService(zap.NewNop(), db, scheduler)
+
+	device := &models.Device{ID: "dev-1", Name: "giffgaff", SerialPort: "/dev/ttyUSB0", PhoneNumber: "+447700900000"}
+	if err := db.Create(device).Error; err != nil {
+		t.Fatalf("创建设备失败: %v", err)
+	}
+
+	policy := &models.KeepAlivePolicy{
+		DeviceID:     device.ID,
+		Enabled:      true,
+		Activity:     models.TaskActionSMS,
+		IntervalDays: 180,
+		LeadDays:     14,
+		PhoneNumber:  "43430",
+		Content:      "keep alive {{date}}",
+	}
+	if err := svc.Save(ctx, policy); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// 自动生成绑定设备的定时任务
+	task, err := scheduler.GetById(ctx, policy.TaskID)
+	if err != nil {
+		t.Fatalf("生成的任务不存在: %v", err)
+	}
+	if task.DeviceID != device.ID || task.Name != "保号: giffgaff" || task.CronExpr != "0 10 * * *" {
+		t.Errorf("生成的任务不正确: %+v", task)
+	}
+
+	// 再次保存更新同一个任务
+	policy.CheckTime = "08:30"
+	if err := svc.Save(ctx, policy); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if policy.TaskID != task.ID {
+		t.Errorf("Expected task %s to be reused, got %s", task.ID, policy.TaskID)
+	}
+	tasks, _ := scheduler.GetAll(ctx)
+	if len(tasks) != 1 || tasks[0].CronExpr != "30 8 * * *" {
+		t.Errorf("Expected 1 updated task, got %+v", tasks)
+	}
+
+	// 没有活动记录时不跳过
+	if reason := svc.skipReason(ctx, *task); reason != "" {
+		t.Errorf("Expected no skip without activity, got %q", reason)
+	}
+
+	// 最近有真实发送的短信时跳过
+	sentAt := time.Now().AddDate(0, 0, -30)
+	msg := &models.TextMessage{ID: "msg-1", To: "10086", Type: models.MessageTypeOutgoing, Status: models.MessageStatusSent, DeviceID: device.ID, CreatedAt: sentAt.UnixMilli()}
+	if err := db.Create(msg).Error; err != nil {
+		t.Fatalf("创建短信失败: %v", err)
+	}
+	if reason := svc.skipReason(ctx, *task); reason == "" {
+		t.Error("Expected skip after recent outgoing message")
+	}
+
+	items, err := svc.Dashboard(ctx)
+	if err != nil {
+		t.Fatalf("Dashboard failed: %v", err)
+	}
+	if len(items) != 1 || items[0].DaysUntilExpiry == nil {
+		t.Fatalf("Expected 1 item with expiry, got %+v", items)
+	}
+	if days := *items[0].DaysUntilExpiry; days != 150 {
+		t.Errorf("Expected 150 days until expiry, got %d", days)
+	}
+	if items[0].DeviceName != "giffgaff" || items[0].PhoneNumber != device.PhoneNumber {
+		t.Errorf("Unexpected dashboard item: %+v", items[0])
+	}
+
+	// 超过间隔减提前天数后不再跳过
+	db.Model(msg).Update("created_at", time.Now().AddDate(0, 0, -170).UnixMilli())
+	if reason := svc.skipReason(ctx, *task); reason != "" {
+		t.Errorf("Expected no skip when keep-alive is due, got %q", reason)
+	}
+
+	// 发送失败的短信不算活动
+	db.Model(msg).Updates(map[string]any{"created_at": sentAt.UnixMilli(), "status": models.MessageStatusFailed})
+	if reason := svc.skipReason(ctx, *task); reason != "" {
+		t.Errorf("Expected failed message to be ignored, got %q", reason)
+	}
+
+	// 删除策略同时删除任务
+	if err := svc.Delete(ctx, device.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := scheduler.GetById(ctx, task.ID); err == nil {
+		t.Error("Expected generated task to be deleted")
+	}
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultIPMaxAttempts 同一 IP 默认允许的失败次数
+	defaultIPMaxAttempts = 20
+	// defaultUsernameMaxAttempts 同一用户名默认允许的失败次数
+	defaultUsernameMaxAttempts = 5
+	// defaultLoginWindowMinutes 默认失败次数统计窗口
+	defaultLoginWindowMinutes = 15
+	// defaultLockoutSeconds 默认首次锁定时长
+	defaultLockoutSeconds = 60
+	// defaultMaxLockoutMinutes 默认最长锁定时长
+	defaultMaxLockoutMinutes = 60
+)
+
+// ErrLoginLocked 登录失败次数过多，暂时禁止登录
+var ErrLoginLocked = errors.New("登录失败次数过多，请稍后再试")
+
+// LoginLockedError 登录被锁定，RetryAfter 为剩余锁定时间
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("登录失败次数过多，请 %d 秒后再试", int(e.RetryAfter.Seconds()+0.5))
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrLoginLocked
+}
+
+// loginAttempts 单个 IP 或用户名的失败记录
+type loginAttempts struct {
+	failures    int       // 当前窗口内的失败次数
+	lockouts    int       // 连续锁定次数，决定下次锁定时长
+	lastFailure time.Time // 最近一次失败时间
+	lockedUntil time.Time // 锁定截止时间
+}
+
+// LoginGuard 按 IP 和用户名统计登录失败次数，超过限制后锁定，连续锁定时锁定时长指数增长
+// 记录保存在内存中，重启后清空
+type LoginGuard struct {
+	logger     *zap.Logger
+	ipLimit    int
+	userLimit  int
+	window     time.Duration
+	lockout    time.Duration
+	maxLockout time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string]*loginAttempts
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewLoginGuard 创建登录保护实例，未配置的项使用默认值
+func NewLoginGuard(logger *zap.Logger, loginConfig config.LoginConfig) *LoginGuard {
+	ipLimit := loginConfig.IPMaxAttempts
+	if ipLimit == 0 {
+		ipLimit = defaultIPMaxAttempts
+	}
+	userLimit := loginConfig.UsernameMaxAttempts
+	if userLimit == 0 {
+		userLimit = defaultUsernameMaxAttempts
+	}
+	windowMinutes := loginConfig.WindowMinutes
+	if windowMinutes <= 0 {
+		windowMinutes = defaultLoginWindowMinutes
+	}
+	lockoutSeconds := loginConfig.LockoutSeconds
+	if lockoutSeconds <= 0 {
+		lockoutSeconds = defaultLockoutSeconds
+	}
+	maxLockoutMinutes := loginConfig.MaxLockoutMinutes
+	if maxLockoutMinutes <= 0 {
+		maxLockoutMinutes = defaultMaxLockoutMinutes
+	}
+
+	return &LoginGuard{
+		logger:     logger,
+		ipLimit:    ipLimit,
+		userLimit:  userLimit,
+		window:     time.Duration(windowMinutes) * time.Minute,
+		lockout:    time.Duration(lockoutSeconds) * time.Second,
+		maxLockout: time.Duration(maxLockoutMinutes) * time.Minute,
+		attempts:   make(map[string]*loginAttempts),
+		now:        time.Now,
+	}
+}
+
+// Check 检查 IP 和用户名是否被锁定，锁定时返回 *LoginLockedError
+func (g *LoginGuard) Check(ip, username string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.prune(now)
+
+	var retryAfter time.Duration
+	for _, key := range g.keys(ip, username) {
+		if a, ok := g.attempts[key]; ok && a.lockedUntil.After(now) {
+			retryAfter = max(retryAfter, a.lockedUntil.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Fail 记录一次登录失败，达到次数限制时锁定
+func (g *LoginGuard) Fail(ip, username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for _, key := range g.keys(ip, username) {
+		limit := g.userLimit
+		if strings.HasPrefix(key, "ip:") {
+			limit = g.ipLimit
+		}
+		if limit < 0 {
+			continue
+		}
+
+		a, ok := g.attempts[key]
+		if !ok {
+			a = &loginAttempts{}
+			g.attempts[key] = a
+		}
+		// 锁定结束后超过统计窗口没有失败，重新计算
+		if now.Sub(latest(a.lastFailure, a.lockedUntil)) > g.window {
+			a.failures = 0
+			a.lockouts = 0
+		}
+		a.failures++
+		a.lastFailure = now
+		if a.failures < limit {
+			continue
+		}
+
+		a.failures = 0
+		a.lockouts++
+		duration := g.lockout << min(a.lockouts-1, 20)
+		if duration > g.maxLockout || duration <= 0 {
+			duration = g.maxLockout
+		}
+		a.lockedUntil = now.Add(duration)
+		g.logger.Warn("登录失败次数过多，暂时锁定", zap.String("key", key),
+			zap.Int("lockouts", a.lockouts), zap.Duration("duration", duration))
+	}
+}
+
+// Succeed 登录成功后清除用户名的失败记录，IP 的记录保留，避免攻击者用自己的账号重置计数
+func (g *LoginGuard) Succeed(username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.attempts, userKey(username))
+}
+
+// keys 返回 IP 和用户名对应的记录键
+func (g *LoginGuard) keys(ip, username string) []string {
+	keys := make([]string, 0, 2)
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	if username != "" {
+		keys = append(keys, userKey(username))
+	}
+	return keys
+}
+
+// prune 定期清理已经过期的记录
+func (g *LoginGuard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < g.window {
+		return
+	}
+	g.lastPrune = now
+	for key, a := range g.attempts {
+		if now.Sub(latest(a.lastFailure, a.lockedUntil)) > g.window {
+			delete(g.attempts, key)
+		}
+	}
+}
+
+// userKey 用户名不区分大小写，避免通过改变大小写绕过限制
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
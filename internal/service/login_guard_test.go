@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"go.uber.org/zap"
+)
+
+func TestLoginGuardLockout(t *testing.T) {
+	guard := NewLoginGuard(zap.NewNop(), config.LoginConfig{
+		IPMaxAttempts:       5,
+		UsernameMaxAttempts: 3,
+		WindowMinutes:       10,
+		LockoutSeconds:      60,
+		MaxLockoutMinutes:   3,
+	})
+	now := time.Unix(1700000000, 0)
+	guard.now = func() time.Time { return now }
+
+	locked := func(ip, username string) time.Duration {
+		var lockedErr *LoginLockedError
+		if err := guard.Check(ip, username); errors.As(err, &lockedErr) {
+			if !errors.Is(err, ErrLoginLocked) {
+				t.Error("LoginLockedError should wrap ErrLoginLocked")
+			}
+			return lockedErr.RetryAfter
+		}
+		return 0
+	}
+
+	// 用户名连续失败 3 次后锁定 60 秒，用户名不区分大小写
+	guard.Fail("1.1.1.1", "alice")
+	guard.Fail("1.1.1.2", "Alice")
+	if locked("1.1.1.3", "alice") != 0 {
+		t.Fatal("2 次失败不应锁定")
+	}
+	guard.Fail("1.1.1.3", "ALICE")
+	if d := locked("1.1.1.4", "alice"); d != time.Minute {
+		t.Fatalf("Expected 60s lockout, got %v", d)
+	}
+	if locked("1.1.1.4", "bob") != 0 {
+		t.Error("其他用户不受影响")
+	}
+
+	// 锁定结束后再次达到次数限制，锁定时长翻倍，最长 3 分钟
+	for _, expected := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		now = now.Add(locked("", "alice"))
+		for i := 0; i < 3; i++ {
+			guard.Fail("", "alice")
+		}
+		if d := locked("", "alice"); d != expected {
+			t.Errorf("Expected %v lockout, got %v", expected, d)
+		}
+	}
+
+	// 超过统计窗口没有失败后重新计算
+	now = now.Add(3*time.Minute + 11*time.Minute)
+	for i := 0; i < 3; i++ {
+		guard.Fail("", "alice")
+	}
+	if d := locked("", "alice"); d != time.Minute {
+		t.Errorf("Expected lockout reset to 60s, got %v", d)
+	}
+
+	// 同一 IP 尝试不同用户名，达到 IP 限制后锁定该 IP
+	for i, username := range []string{"u1", "u2", "u3", "u4", "u5"} {
+		if locked("2.2.2.2", username) != 0 {
+			t.Fatalf("第 %d 次尝试前不应锁定", i+1)
+		}
+		guard.Fail("2.2.2.2", username)
+	}
+	if locked("2.2.2.2", "u6") == 0 {
+		t.Error("Expected IP lockout")
+	}
+
+	// 登录成功清除用户名的失败记录
+	guard.Fail("", "carol")
+	guard.Fail("", "carol")
+	guard.Succeed("carol")
+	guard.Fail("", "carol")
+	if locked("", "carol") != 0 {
+		t.Error("登录成功后应重新计算失败次数")
+	}
+}
@@ -0,0 +1,595 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ExportFormat 短信导出/导入格式
+type ExportFormat string
+
+const (
+	ExportFormatCSV    ExportFormat = "csv"
+	ExportFormatNDJSON ExportFormat = "ndjson"
+	ExportFormatXML    ExportFormat = "xml" // Android SMS Backup & Restore 格式
+)
+
+const (
+	// exportBatchSize 导出每批查询条数
+	exportBatchSize = 500
+	// importMaxErrors 导入结果中最多保留的错误信息条数
+	importMaxErrors = 20
+	// ndjsonMaxLineSize NDJSON 单行最大字节数
+	ndjsonMaxLineSize = 1 << 20
+)
+
+// ErrUnsupportedFormat 不支持的导出/导入格式
+var ErrUnsupportedFormat = errors.New("不支持的格式，可选 csv、ndjson、xml")
+
+// ErrInvalidImportFile 导入文件无法继续解析
+var ErrInvalidImportFile = errors.New("导入文件格式错误")
+
+// csvColumns CSV 导出列
+var csvColumns = []string{"id", "createdAt", "type", "status", "from", "to", "peer", "deviceId", "deviceName", "content", "otpCode", "readAt"}
+
+// utf8BOM 写在 CSV 开头，便于 Excel 正确识别中文
+const utf8BOM = "\uFEFF"
+
+// ParseExportFormat 解析格式参数
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(strings.ToLower(s)); format {
+	case ExportFormatCSV, ExportFormatNDJSON, ExportFormatXML:
+		return format, nil
+	case "json", "jsonl":
+		return ExportFormatNDJSON, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// ContentType 返回格式对应的 HTTP Content-Type
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case ExportFormatXML:
+		return "application/xml; charset=utf-8"
+	default:
+		return "application/x-ndjson; charset=utf-8"
+	}
+}
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	DeviceID   string // 记录未包含设备时使用的设备ID
+	DeviceName string // 记录未包含设备时使用的设备名称
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Total      int      `json:"total"`            // 读取的记录数
+	Imported   int      `json:"imported"`         // 成功导入数
+	Duplicates int      `json:"duplicates"`       // 已存在而跳过的数量
+	Skipped    int      `json:"skipped"`          // 不支持的记录（如草稿）
+	Failed     int      `json:"failed"`           // 格式错误的记录数
+	Errors     []string `json:"errors,omitempty"` // 部分失败原因
+}
+
+// Export 按条件导出短信，按时间正序分批写入 w，返回导出条数
+func (s *TextMessageService) Export(ctx context.Context, format ExportFormat, filter repo.MessageExportFilter, w io.Writer) (int64, error) {
+	var encoder messageEncoder
+	switch format {
+	case ExportFormatCSV:
+		encoder = &csvMessageEncoder{w: w}
+	case ExportFormatNDJSON:
+		encoder = &ndjsonMessageEncoder{enc: json.NewEncoder(w)}
+	case ExportFormatXML:
+		encoder = &xmlMessageEncoder{w: w}
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+
+	var total int64
+	if format == ExportFormatXML {
+		// XML 根节点需要记录总数
+		count, err := s.repo.CountForExport(ctx, filter)
+		if err != nil {
+			return 0, fmt.Errorf("统计导出短信失败: %w", err)
+		}
+		total = count
+	}
+	if err := encoder.Begin(total); err != nil {
+		return 0, err
+	}
+
+	var exported int64
+	var after *repo.Cursor
+	for {
+		msgs, err := s.repo.FindForExport(ctx, filter, after, exportBatchSize)
+		if err != nil {
+			return exported, fmt.Errorf("查询导出短信失败: %w", err)
+		}
+		for i := range msgs {
+			if err := encoder.Encode(&msgs[i]); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+		if flusher, ok := w.(interface{ Flush() }); ok {
+			flusher.Flush()
+		}
+		if len(msgs) < exportBatchSize {
+			break
+		}
+		last := msgs[len(msgs)-1]
+		after = &repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	if err := encoder.End(); err != nil {
+		return exported, err
+	}
+	s.logger.Info("导出短信完成", zap.String("format", string(format)), zap.Int64("count", exported))
+	return exported, nil
+}
+
+// Import 导入短信，按 ID 或（会话、类型、时间、内容）去重
+func (s *TextMessageService) Import(ctx context.Context, format ExportFormat, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	var decoder messageDecoder
+	switch format {
+	case ExportFormatCSV:
+		decoder = newCSVMessageDecoder(r)
+	case ExportFormatNDJSON:
+		decoder = newNDJSONMessageDecoder(r)
+	case ExportFormatXML:
+		decoder = &xmlMessageDecoder{dec: xml.NewDecoder(r)}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	result := &ImportResult{}
+	fail := func(reason string) {
+		result.Failed++
+		if len(result.Errors) < importMaxErrors {
+			result.Errors = append(result.Errors, fmt.Sprintf("第 %d 条: %s", result.Total, reason))
+		}
+	}
+
+	for {
+		msg, err := decoder.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		result.Total++
+
+		var recordErr *importRecordError
+		switch {
+		case errors.Is(err, errImportSkip):
+			result.Skipped++
+			continue
+		case errors.As(err, &recordErr):
+			fail(recordErr.reason)
+			continue
+		case err != nil:
+			return result, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+
+		duplicate, err := s.importOne(ctx, msg, opts)
+		if err != nil {
+			var recordErr *importRecordError
+			if errors.As(err, &recordErr) {
+				fail(recordErr.reason)
+				continue
+			}
+			return result, err
+		}
+		if duplicate {
+			result.Duplicates++
+		} else {
+			result.Imported++
+		}
+	}
+
+	s.logger.Info("导入短信完成",
+		zap.String("format", string(format)),
+		zap.Int("total", result.Total),
+		zap.Int("imported", result.Imported),
+		zap.Int("duplicates", result.Duplicates),
+		zap.Int("failed", result.Failed))
+	return result, nil
+}
+
+// importOne 校验并写入单条记录，已存在时返回 true
+func (s *TextMessageService) importOne(ctx context.Context, msg *models.TextMessage, opts ImportOptions) (bool, error) {
+	if msg.Type != models.MessageTypeIncoming && msg.Type != models.MessageTypeOutgoing {
+		return false, &importRecordError{reason: fmt.Sprintf("无效的消息类型 %q", msg.Type)}
+	}
+	if msg.PeerOf() == "" {
+		return false, &importRecordError{reason: "缺少号码"}
+	}
+	if msg.CreatedAt <= 0 {
+		return false, &importRecordError{reason: "缺少时间"}
+	}
+
+	if msg.ID == "" {
+		msg.ID = uuid.NewString()
+	}
+	exists, err := s.repo.ExistsDuplicate(ctx, msg)
+	if err != nil {
+		return false, fmt.Errorf("查询重复短信失败: %w", err)
+	}
+	if exists {
+		return true, nil
+	}
+
+	if msg.DeviceID == "" {
+		msg.DeviceID = opts.DeviceID
+		msg.DeviceName = opts.DeviceName
+	}
+	if !repo.InDeviceScope(ctx, msg.DeviceID) {
+		return false, &importRecordError{reason: "无权导入该设备的短信"}
+	}
+	if msg.Status == "" {
+		if msg.Type == models.MessageTypeIncoming {
+			msg.Status = models.MessageStatusReceived
+		} else {
+			msg.Status = models.MessageStatusSent
+		}
+	}
+	if msg.Type == models.MessageTypeIncoming && msg.OTPCode == "" {
+		msg.OTPCode = ExtractOTP(msg.Content)
+	}
+	// 保留原始时间，避免被自动时间戳覆盖
+	if msg.UpdatedAt == 0 {
+		msg.UpdatedAt = msg.CreatedAt
+	}
+
+	if err := s.repo.Create(ctx, msg); err != nil {
+		return false, fmt.Errorf("保存导入短信失败: %w", err)
+	}
+	return false, nil
+}
+
+// ==================== 编码 ====================
+
+type messageEncoder interface {
+	Begin(total int64) error
+	Encode(msg *models.TextMessage) error
+	End() error
+}
+
+type csvMessageEncoder struct {
+	w   io.Writer
+	csv *csv.Writer
+}
+
+func (e *csvMessageEncoder) Begin(int64) error {
+	if _, err := io.WriteString(e.w, utf8BOM); err != nil {
+		return err
+	}
+	e.csv = csv.NewWriter(e.w)
+	return e.csv.Write(csvColumns)
+}
+
+func (e *csvMessageEncoder) Encode(msg *models.TextMessage) error {
+	return e.csv.Write([]string{
+		msg.ID,
+		strconv.FormatInt(msg.CreatedAt, 10),
+		string(msg.Type),
+		string(msg.Status),
+		msg.From,
+		msg.To,
+		msg.Peer,
+		msg.DeviceID,
+		msg.DeviceName,
+		msg.Content,
+		msg.OTPCode,
+		strconv.FormatInt(msg.ReadAt, 10),
+	})
+}
+
+func (e *csvMessageEncoder) End() error {
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+type ndjsonMessageEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonMessageEncoder) Begin(int64) error { return nil }
+
+func (e *ndjsonMessageEncoder) Encode(msg *models.TextMessage) error { return e.enc.Encode(msg) }
+
+func (e *ndjsonMessageEncoder) End() error { return nil }
+
+// backupSMS SMS Backup & Restore 的 <sms> 节点
+type backupSMS struct {
+	XMLName       xml.Name `xml:"sms"`
+	Protocol      string   `xml:"protocol,attr"`
+	Address       string   `xml:"address,attr"`
+	Date          int64    `xml:"date,attr"`
+	Type          int      `xml:"type,attr"`
+	Subject       string   `xml:"subject,attr"`
+	Body          string   `xml:"body,attr"`
+	Toa           string   `xml:"toa,attr"`
+	ScToa         string   `xml:"sc_toa,attr"`
+	ServiceCenter string   `xml:"service_center,attr"`
+	Read          int      `xml:"read,attr"`
+	Status        int      `xml:"status,attr"`
+	Locked        int      `xml:"locked,attr"`
+	DateSent      int64    `xml:"date_sent,attr"`
+	ReadableDate  string   `xml:"readable_date,attr"`
+	ContactName   string   `xml:"contact_name,attr"`
+}
+
+// SMS Backup & Restore 中的短信类型与状态
+const (
+	backupTypeInbox  = 1
+	backupTypeSent   = 2
+	backupTypeDraft  = 3
+	backupTypeOutbox = 4
+	backupTypeFailed = 5
+	backupTypeQueued = 6
+
+	backupStatusNone     = -1
+	backupStatusComplete = 0
+	backupStatusPending  = 32
+	backupStatusFailed   = 64
+)
+
+type xmlMessageEncoder struct {
+	w io.Writer
+}
+
+func (e *xmlMessageEncoder) Begin(total int64) error {
+	_, err := fmt.Fprintf(e.w, "<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>\n<smses count=\"%d\">\n", total)
+	return err
+}
+
+func (e *xmlMessageEncoder) Encode(msg *models.TextMessage) error {
+	sms := backupSMS{
+		Protocol:      "0",
+		Address:       msg.PeerOf(),
+		Date:          msg.CreatedAt,
+		Subject:       "null",
+		Body:          msg.Content,
+		Toa:           "null",
+		ScToa:         "null",
+		ServiceCenter: "null",
+		Read:          1,
+		Status:        backupStatusNone,
+		ReadableDate:  time.UnixMilli(msg.CreatedAt).Format(time.DateTime),
+		ContactName:   "(Unknown)",
+	}
+	if msg.Type == models.MessageTypeIncoming {
+		sms.Type = backupTypeInbox
+		if msg.ReadAt == 0 {
+			sms.Read = 0
+		}
+	} else {
+		switch msg.Status {
+		case models.MessageStatusFailed:
+			sms.Type, sms.Status = backupTypeFailed, backupStatusFailed
+		case models.MessageStatusSending:
+			sms.Type, sms.Status = backupTypeOutbox, backupStatusPending
+		default:
+			sms.Type, sms.Status = backupTypeSent, backupStatusComplete
+		}
+	}
+
+	data, err := xml.Marshal(sms)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, "  %s\n", data)
+	return err
+}
+
+func (e *xmlMessageEncoder) End() error {
+	_, err := io.WriteString(e.w, "</smses>\n")
+	return err
+}
+
+// ==================== 解码 ====================
+
+// errImportSkip 记录不需要导入（如草稿）
+var errImportSkip = errors.New("跳过记录")
+
+// importRecordError 单条记录格式错误，不影响后续记录
+type importRecordError struct {
+	reason string
+}
+
+func (e *importRecordError) Error() string { return e.reason }
+
+type messageDecoder interface {
+	// Next 返回下一条记录，结束时返回 io.EOF
+	Next() (*models.TextMessage, error)
+}
+
+type csvMessageDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+	err     error
+}
+
+func newCSVMessageDecoder(r io.Reader) *csvMessageDecoder {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	return &csvMessageDecoder{r: reader}
+}
+
+func (d *csvMessageDecoder) Next() (*models.TextMessage, error) {
+	if d.columns == nil {
+		header, err := d.r.Read()
+		if err != nil {
+			return nil, err
+		}
+		d.columns = make(map[string]int, len(header))
+		for i, name := range header {
+			if i == 0 {
+				name = strings.TrimPrefix(name, utf8BOM)
+			}
+			d.columns[strings.TrimSpace(name)] = i
+		}
+		for _, required := range []string{"type", "createdAt", "content"} {
+			if _, ok := d.columns[required]; !ok {
+				return nil, fmt.Errorf("CSV 缺少 %s 列", required)
+			}
+		}
+	}
+
+	record, err := d.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	createdAt, err := strconv.ParseInt(field("createdAt"), 10, 64)
+	if err != nil {
+		return nil, &importRecordError{reason: "createdAt 格式错误"}
+	}
+	var readAt int64
+	if v := field("readAt"); v != "" {
+		if readAt, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, &importRecordError{reason: "readAt 格式错误"}
+		}
+	}
+
+	msg := &models.TextMessage{
+		ID:         field("id"),
+		From:       field("from"),
+		To:         field("to"),
+		Content:    field("content"),
+		Type:       models.MessageType(field("type")),
+		Status:     models.MessageStatus(field("status")),
+		DeviceID:   field("deviceId"),
+		DeviceName: field("deviceName"),
+		OTPCode:    field("otpCode"),
+		ReadAt:     readAt,
+		CreatedAt:  createdAt,
+	}
+	fillAddressFromPeer(msg, field("peer"))
+	return msg, nil
+}
+
+type ndjsonMessageDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func newNDJSONMessageDecoder(r io.Reader) *ndjsonMessageDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), ndjsonMaxLineSize)
+	return &ndjsonMessageDecoder{scanner: scanner}
+}
+
+func (d *ndjsonMessageDecoder) Next() (*models.TextMessage, error) {
+	for d.scanner.Scan() {
+		line := strings.TrimSpace(d.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg models.TextMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return nil, &importRecordError{reason: "JSON 格式错误"}
+		}
+		fillAddressFromPeer(&msg, msg.Peer)
+		return &msg, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+type xmlMessageDecoder struct {
+	dec *xml.Decoder
+}
+
+func (d *xmlMessageDecoder) Next() (*models.TextMessage, error) {
+	for {
+		token, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local == "smses" {
+			continue
+		}
+		if start.Name.Local != "sms" {
+			// 彩信等其他节点不导入
+			if err := d.dec.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var sms backupSMS
+		if err := d.dec.DecodeElement(&sms, &start); err != nil {
+			return nil, err
+		}
+		return backupSMSToMessage(&sms)
+	}
+}
+
+// backupSMSToMessage 将 SMS Backup & Restore 记录转换为短信记录
+func backupSMSToMessage(sms *backupSMS) (*models.TextMessage, error) {
+	msg := &models.TextMessage{
+		Content:   sms.Body,
+		CreatedAt: sms.Date,
+	}
+	switch sms.Type {
+	case backupTypeInbox:
+		msg.Type, msg.Status, msg.From = models.MessageTypeIncoming, models.MessageStatusReceived, sms.Address
+		if sms.Read != 0 {
+			msg.ReadAt = sms.Date
+		}
+	case backupTypeSent:
+		msg.Type, msg.Status, msg.To = models.MessageTypeOutgoing, models.MessageStatusSent, sms.Address
+	case backupTypeOutbox, backupTypeFailed, backupTypeQueued:
+		// 未确认发送成功的记录按失败处理
+		msg.Type, msg.Status, msg.To = models.MessageTypeOutgoing, models.MessageStatusFailed, sms.Address
+	case backupTypeDraft:
+		return nil, errImportSkip
+	default:
+		return nil, &importRecordError{reason: fmt.Sprintf("未知的短信类型 %d", sms.Type)}
+	}
+	return msg, nil
+}
+
+// fillAddressFromPeer 记录只包含 peer 时补全对应的号码字段
+func fillAddressFromPeer(msg *models.TextMessage, peer string) {
+	if peer == "" {
+		return
+	}
+	switch msg.Type {
+	case models.MessageTypeIncoming:
+		if msg.From == "" {
+			msg.From = peer
+		}
+	case models.MessageTypeOutgoing:
+		if msg.To == "" {
+			msg.To = peer
+		}
+	}
+}
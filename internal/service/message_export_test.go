@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"go.uber.org/zap"
+)
+
+func newExportTestService(t *testing.T) *TextMessageService {
+	db := setupTestDB(t)
+	return NewTextMessageService(zap.NewNop(), repo.NewTextMessageRepo(db), repo.NewConversationStateRepo(db))
+}
+
+func TestTextMessageService_ExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newExportTestService(t)
+
+	messages := []models.TextMessage{
+		{ID: "m1", From: "10086", Content: "您的验证码为 123456", Type: models.MessageTypeIncoming, Status: models.MessageStatusReceived, DeviceID: "dev-a", CreatedAt: 1000},
+		{ID: "m2", To: "10086", Content: "多行\n内容, \"引号\" <tag>", Type: models.MessageTypeOutgoing, Status: models.MessageStatusSent, DeviceID: "dev-a", CreatedAt: 2000},
+		{ID: "m3", To: "95588", Content: "失败", Type: models.MessageTypeOutgoing, Status: models.MessageStatusFailed, DeviceID: "dev-b", CreatedAt: 3000},
+	}
+	for i := range messages {
+		if err := source.Save(ctx, &messages[i]); err != nil {
+			t.Fatalf("Failed to save message: %v", err)
+		}
+	}
+
+	for _, format := range []ExportFormat{ExportFormatCSV, ExportFormatNDJSON, ExportFormatXML} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			exported, err := source.Export(ctx, format, repo.MessageExportFilter{DeviceID: "dev-a"}, &buf)
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			if exported != 2 {
+				t.Fatalf("Expected 2 exported messages, got %d", exported)
+			}
+
+			target := newExportTestService(t)
+			data := buf.Bytes()
+			result, err := target.Import(ctx, format, bytes.NewReader(data), ImportOptions{DeviceID: "imported"})
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if result.Total != 2 || result.Imported != 2 || result.Failed != 0 {
+				t.Errorf("Unexpected import result: %+v", result)
+			}
+
+			page, err := target.GetConversationMessages(ctx, "10086", "", 0)
+			if err != nil {
+				t.Fatalf("GetConversationMessages failed: %v", err)
+			}
+			if len(page.Items) != 2 || page.Items[1].Content != messages[1].Content || page.Items[0].OTPCode != "123456" {
+				t.Errorf("Unexpected imported messages: %+v", page.Items)
+			}
+
+			// 再次导入全部去重
+			again, err := target.Import(ctx, format, bytes.NewReader(data), ImportOptions{})
+			if err != nil {
+				t.Fatalf("Re-import failed: %v", err)
+			}
+			if again.Imported != 0 || again.Duplicates != 2 {
+				t.Errorf("Expected all duplicates on re-import, got %+v", again)
+			}
+		})
+	}
+}
+
+func TestTextMessageService_ImportSMSBackupXML(t *testing.T) {
+	ctx := context.Background()
+	svc := newExportTestService(t)
+
+	data := `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>
+<smses count="4">
+  <sms protocol="0" address="+86 138-0013-8000" date="1700000000000" type="1" body="hello" read="0" status="-1" />
+  <sms protocol="0" address="+8613800138000" date="1700000001000" type="2" body="hi" read="1" status="-1" />
+  <sms protocol="0" address="+8613800138000" date="1700000002000" type="3" body="draft" read="1" status="-1" />
+  <mms date="1700000003000" msg_box="1" address="10086"><parts><part seq="0" text="mms" /></parts></mms>
+  <sms protocol="0" address="" date="1700000004000" type="1" body="no address" read="1" status="-1" />
+</smses>`
+
+	result, err := svc.Import(ctx, ExportFormatXML, strings.NewReader(data), ImportOptions{DeviceID: "old-phone", DeviceName: "旧手机"})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Total != 4 || result.Imported != 2 || result.Skipped != 1 || result.Failed != 1 || len(result.Errors) != 1 {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+
+	page, err := svc.GetConversations(ctx, ConversationQuery{})
+	if err != nil {
+		t.Fatalf("GetConversations failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Peer != "+8613800138000" || page.Items[0].UnreadCount != 1 {
+		t.Fatalf("Unexpected conversations: %+v", page.Items)
+	}
+	if page.Items[0].LastMessage.DeviceName != "旧手机" {
+		t.Errorf("Expected default device name, got %q", page.Items[0].LastMessage.DeviceName)
+	}
+}
+
+func TestTextMessageService_ImportInvalidFile(t *testing.T) {
+	ctx := context.Background()
+	svc := newExportTestService(t)
+
+	if _, err := svc.Import(ctx, ExportFormatCSV, strings.NewReader("id,from\n1,10086\n"), ImportOptions{}); !errors.Is(err, ErrInvalidImportFile) {
+		t.Errorf("Expected ErrInvalidImportFile for missing columns, got %v", err)
+	}
+
+	result, err := svc.Import(ctx, ExportFormatNDJSON, strings.NewReader("{bad json}\n\n{\"type\":\"incoming\",\"from\":\"10086\",\"content\":\"ok\",\"createdAt\":1}\n"), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Failed != 1 || result.Imported != 1 {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+
+	if _, err := ParseExportFormat("pdf"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+}
@@ -14,9 +14,15 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Starktomy/smshub/internal/metrics"
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/tracing"
 	"github.com/valyala/fasttemplate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gopkg.in/gomail.v2"
 )
@@ -27,6 +33,7 @@ type Notifier struct {
 	httpClient     *http.Client
 	proxyClients   map[string]*http.Client // 缓存代理客户端
 	proxyClientsMu sync.Mutex
+	pending        atomic.Int64 // 正在发送的通知数量
 }
 
 func NewNotifier(logger *zap.Logger) *Notifier {
@@ -39,6 +46,11 @@ func NewNotifier(logger *zap.Logger) *Notifier {
 	}
 }
 
+// Pending 返回已提交但还没有发送完成的通知数量
+func (n *Notifier) Pending() int64 {
+	return n.pending.Load()
+}
+
 // NotificationMessage 通用通知消息（支持短信、来电等）
 type NotificationMessage struct {
 	Type      string // "sms" 或 "call"
@@ -72,6 +84,56 @@ func (m NotificationMessage) String() string {
 	}
 }
 
+// NotifyChannels 发送通知到所有启用的渠道
+func (n *Notifier) NotifyChannels(ctx context.Context, channels []models.NotificationChannelConfig, msg NotificationMessage) {
+	// 格式化消息
+	message := msg.String()
+
+	for _, channel := range channels {
+		if channel.Enabled {
+			n.pending.Add(1)
+		}
+	}
+
+	for _, channel := range channels {
+		if !channel.Enabled {
+			continue
+		}
+
+		channelCtx, span := tracing.Tracer.Start(ctx, "notification."+channel.Type, trace.WithAttributes(
+			attribute.String("notification.channel", channel.Type),
+			attribute.String("notification.type", msg.Type),
+		))
+		var sendErr error
+		switch channel.Type {
+		case "dingtalk":
+			sendErr = n.SendDingTalkByConfig(channelCtx, channel.Config, message)
+		case "wecom":
+			sendErr = n.SendWeComByConfig(channelCtx, channel.Config, message)
+		case "feishu":
+			sendErr = n.SendFeishuByConfig(channelCtx, channel.Config, message)
+		case "webhook":
+			sendErr = n.SendWebhookByConfig(channelCtx, channel.Config, msg)
+		case "email":
+			sendErr = n.SendEmail(channelCtx, channel.Config, msg)
+		case "telegram":
+			sendErr = n.sendTelegramByConfig(channelCtx, channel.Config, message)
+		}
+		tracing.End(span, sendErr)
+
+		n.pending.Add(-1)
+		metrics.NotificationAttempts.WithLabelValues(channel.Type).Inc()
+		if sendErr != nil {
+			metrics.NotificationFailures.WithLabelValues(channel.Type).Inc()
+			n.logger.Error("发送通知失败",
+				zap.String("type", channel.Type),
+				zap.Error(sendErr))
+		} else {
+			n.logger.Info("通知发送成功", zap.String("type", channel.Type))
+		}
+	}
+}
+
 // sendDingTalk 发送钉钉通知
 func (n *Notifier) sendDingTalk(ctx context.Context, webhook, secret, message string) error {
 	// 构造钉钉消息体
@@ -6,27 +6,65 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
 	"github.com/coreos/go-oidc/v3/oidc"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
 )
 
+const (
+	// oidcStateTTL 登录请求 state 的有效期
+	oidcStateTTL = 10 * time.Minute
+	// oidcDiscoveryTimeout 单次获取 Provider 配置的超时时间
+	oidcDiscoveryTimeout = 10 * time.Second
+	// oidcRetryInitialDelay 获取 Provider 配置失败后首次重试的间隔，之后每次翻倍
+	oidcRetryInitialDelay = 5 * time.Second
+	// oidcRetryMaxDelay 重试间隔上限
+	oidcRetryMaxDelay = 5 * time.Minute
+)
+
+var (
+	// ErrOIDCUnavailable 暂时无法连接 IdP
+	ErrOIDCUnavailable = errors.New("OIDC 服务暂不可用，请稍后重试")
+	// ErrOIDCForbidden IdP 认证通过，但不满足允许登录的条件
+	ErrOIDCForbidden = errors.New("该账号不允许登录")
+)
+
 // OIDCService OIDC 认证服务
 type OIDCService struct {
-	logger       *zap.Logger
-	config       *config.OIDCConfig
-	provider     *oidc.Provider
-	oauth2Config oauth2.Config
-	verifier     *oidc.IDTokenVerifier
-	stateMu      sync.RWMutex
-	stateStore   map[string]time.Time
+	logger *zap.Logger
+	config *config.OIDCConfig
+
+	clientMu   sync.RWMutex
+	client     *oidcClient
+	discoverMu sync.Mutex
+
+	stateMu    sync.RWMutex
+	stateStore map[string]oidcState
 }
 
-// NewOIDCService 创建 OIDC 服务
+// oidcClient 从 Provider 发现配置生成的客户端
+type oidcClient struct {
+	oauth2Config  oauth2.Config
+	verifier      *oidc.IDTokenVerifier
+	endSessionURL string // end_session_endpoint，IdP 不支持时为空
+}
+
+// oidcState 登录请求的 state，保存 nonce 和 PKCE verifier
+type oidcState struct {
+	expiresAt time.Time
+	nonce     string
+	verifier  string
+}
+
+// NewOIDCService 创建 OIDC 服务，启动时无法连接 IdP 会在后台重试，不会禁用 OIDC
 func NewOIDCService(logger *zap.Logger, appConfig *config.AppConfig) *OIDCService {
 	if appConfig.OIDC == nil || !appConfig.OIDC.Enabled {
 		logger.Info("OIDC 认证未启用")
@@ -38,8 +76,8 @@ func NewOIDCService(logger *zap.Logger, appConfig *config.AppConfig) *OIDCServic
 
 	oidcConfig := appConfig.OIDC
 
-	// 验证配置
-	if oidcConfig.Issuer == "" || oidcConfig.ClientID == "" || oidcConfig.ClientSecret == "" {
+	// 验证配置，启用 PKCE 的公共客户端可以没有 ClientSecret
+	if oidcConfig.Issuer == "" || oidcConfig.ClientID == "" || (oidcConfig.ClientSecret == "" && !oidcConfig.PKCE) {
 		logger.Error("OIDC 配置不完整，OIDC 认证将被禁用")
 		return &OIDCService{
 			logger: logger,
@@ -47,111 +85,218 @@ func NewOIDCService(logger *zap.Logger, appConfig *config.AppConfig) *OIDCServic
 		}
 	}
 
-	ctx := context.Background()
+	s := &OIDCService{
+		logger:     logger,
+		config:     oidcConfig,
+		stateStore: make(map[string]oidcState),
+	}
+	if _, err := s.discover(context.Background()); err != nil {
+		logger.Warn("初始化 OIDC Provider 失败，将在后台重试", zap.String("issuer", oidcConfig.Issuer), zap.Error(err))
+		go s.retryDiscovery()
+	}
+	return s
+}
+
+// IsEnabled 检查 OIDC 是否启用
+func (s *OIDCService) IsEnabled() bool {
+	return s.config != nil && s.config.Enabled
+}
 
-	// 初始化 OIDC Provider
-	provider, err := oidc.NewProvider(ctx, oidcConfig.Issuer)
+// discover 获取 Provider 配置并创建客户端，已经成功时直接返回
+func (s *OIDCService) discover(ctx context.Context) (*oidcClient, error) {
+	s.discoverMu.Lock()
+	defer s.discoverMu.Unlock()
+
+	if client := s.currentClient(); client != nil {
+		return client, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, oidcDiscoveryTimeout)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, s.config.Issuer)
 	if err != nil {
-		logger.Error("初始化 OIDC Provider 失败，OIDC 认证将被禁用", zap.Error(err))
-		return &OIDCService{
-			logger: logger,
-			config: nil,
-		}
+		return nil, err
+	}
+	var metadata struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&metadata); err != nil {
+		s.logger.Warn("解析 OIDC Provider 配置失败", zap.Error(err))
 	}
 
-	// 配置 OAuth2
-	oauth2Config := oauth2.Config{
-		ClientID:     oidcConfig.ClientID,
-		ClientSecret: oidcConfig.ClientSecret,
-		RedirectURL:  oidcConfig.RedirectURL,
-		Endpoint:     provider.Endpoint(),
-		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	client := &oidcClient{
+		oauth2Config: oauth2.Config{
+			ClientID:     s.config.ClientID,
+			ClientSecret: s.config.ClientSecret,
+			RedirectURL:  s.config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       s.scopes(),
+		},
+		verifier:      provider.Verifier(&oidc.Config{ClientID: s.config.ClientID}),
+		endSessionURL: metadata.EndSessionEndpoint,
 	}
+	s.clientMu.Lock()
+	s.client = client
+	s.clientMu.Unlock()
+
+	s.logger.Info("OIDC 服务初始化成功", zap.String("issuer", s.config.Issuer), zap.Bool("pkce", s.config.PKCE))
+	return client, nil
+}
 
-	// 创建 ID Token 验证器
-	verifier := provider.Verifier(&oidc.Config{ClientID: oidcConfig.ClientID})
+// retryDiscovery 按指数退避在后台重试获取 Provider 配置，直到成功
+func (s *OIDCService) retryDiscovery() {
+	delay := oidcRetryInitialDelay
+	for {
+		time.Sleep(delay)
+		_, err := s.discover(context.Background())
+		if err == nil {
+			return
+		}
+		delay = min(delay*2, oidcRetryMaxDelay)
+		s.logger.Warn("初始化 OIDC Provider 失败", zap.Duration("retryAfter", delay), zap.Error(err))
+	}
+}
 
-	logger.Info("OIDC 服务初始化成功", zap.String("issuer", oidcConfig.Issuer))
+// currentClient 返回已初始化的客户端，尚未成功时返回 nil
+func (s *OIDCService) currentClient() *oidcClient {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+	return s.client
+}
 
-	return &OIDCService{
-		logger:       logger,
-		config:       oidcConfig,
-		provider:     provider,
-		oauth2Config: oauth2Config,
-		verifier:     verifier,
-		stateStore:   make(map[string]time.Time),
+// getClient 返回客户端，尚未初始化成功时立即尝试一次
+func (s *OIDCService) getClient(ctx context.Context) (*oidcClient, error) {
+	if client := s.currentClient(); client != nil {
+		return client, nil
+	}
+	client, err := s.discover(ctx)
+	if err != nil {
+		s.logger.Warn("初始化 OIDC Provider 失败", zap.Error(err))
+		return nil, ErrOIDCUnavailable
 	}
+	return client, nil
 }
 
-// IsEnabled 检查 OIDC 是否启用
-func (s *OIDCService) IsEnabled() bool {
-	return s.config != nil && s.config.Enabled
+// scopes 请求的 scope，总是包含 openid
+func (s *OIDCService) scopes() []string {
+	if len(s.config.Scopes) == 0 {
+		return []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	scopes := []string{oidc.ScopeOpenID}
+	for _, scope := range s.config.Scopes {
+		if scope != "" && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
 }
 
 // GenerateAuthURL 生成认证 URL
-func (s *OIDCService) GenerateAuthURL() (string, string, error) {
+func (s *OIDCService) GenerateAuthURL(ctx context.Context) (string, string, error) {
 	if !s.IsEnabled() {
 		return "", "", errors.New("OIDC 未启用")
 	}
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return "", "", err
+	}
 
-	// 生成随机 state
-	state, err := s.generateState()
+	// 生成随机 state 和 nonce
+	state, err := randomString()
 	if err != nil {
 		return "", "", fmt.Errorf("生成 state 失败: %w", err)
 	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", fmt.Errorf("生成 nonce 失败: %w", err)
+	}
+	entry := oidcState{
+		expiresAt: time.Now().Add(oidcStateTTL),
+		nonce:     nonce,
+	}
+	opts := []oauth2.AuthCodeOption{oidc.Nonce(nonce)}
+	if s.config.PKCE {
+		entry.verifier = oauth2.GenerateVerifier()
+		opts = append(opts, oauth2.S256ChallengeOption(entry.verifier))
+	}
 
 	// 存储 state（有效期 10 分钟）
 	s.stateMu.Lock()
-	s.stateStore[state] = time.Now().Add(10 * time.Minute)
+	s.stateStore[state] = entry
 	s.stateMu.Unlock()
 
 	// 清理过期的 state
 	s.cleanExpiredStates()
 
-	authURL := s.oauth2Config.AuthCodeURL(state)
+	authURL := client.oauth2Config.AuthCodeURL(state, opts...)
 	return authURL, state, nil
 }
 
-// ExchangeCode 交换授权码获取 token 和用户信息
-func (s *OIDCService) ExchangeCode(ctx context.Context, code, state string) (string, string, error) {
+// OIDCIdentity OIDC 认证后的用户身份
+type OIDCIdentity struct {
+	Username string
+	Nickname string
+	Groups   []string
+	IDToken  string // 原始 ID Token，退出 IdP 会话时使用
+}
+
+// ExchangeCode 交换授权码获取 token 和用户信息，不满足允许登录的条件时返回 ErrOIDCForbidden
+func (s *OIDCService) ExchangeCode(ctx context.Context, code, state string) (*OIDCIdentity, error) {
 	if !s.IsEnabled() {
-		return "", "", errors.New("OIDC 未启用")
+		return nil, errors.New("OIDC 未启用")
 	}
 
 	// 验证并删除已使用的 state
-	if !s.validateAndDeleteState(state) {
-		return "", "", errors.New("无效的 state")
+	entry, ok := s.validateAndDeleteState(state)
+	if !ok {
+		return nil, errors.New("无效的 state")
+	}
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	// 交换授权码
-	oauth2Token, err := s.oauth2Config.Exchange(ctx, code)
+	var opts []oauth2.AuthCodeOption
+	if entry.verifier != "" {
+		opts = append(opts, oauth2.VerifierOption(entry.verifier))
+	}
+	oauth2Token, err := client.oauth2Config.Exchange(ctx, code, opts...)
 	if err != nil {
-		return "", "", fmt.Errorf("交换授权码失败: %w", err)
+		return nil, fmt.Errorf("交换授权码失败: %w", err)
 	}
 
 	// 提取 ID Token
 	rawIDToken, ok := oauth2Token.Extra("id_token").(string)
 	if !ok {
-		return "", "", errors.New("未获取到 ID Token")
+		return nil, errors.New("未获取到 ID Token")
 	}
 
 	// 验证 ID Token
-	idToken, err := s.verifier.Verify(ctx, rawIDToken)
+	idToken, err := client.verifier.Verify(ctx, rawIDToken)
 	if err != nil {
-		return "", "", fmt.Errorf("验证 ID Token 失败: %w", err)
+		return nil, fmt.Errorf("验证 ID Token 失败: %w", err)
+	}
+	if entry.nonce != "" && idToken.Nonce != entry.nonce {
+		return nil, errors.New("ID Token 的 nonce 不匹配")
 	}
 
 	// 提取用户信息
 	var claims struct {
 		Email             string `json:"email"`
-		EmailVerified     bool   `json:"email_verified"`
+		EmailVerified     *bool  `json:"email_verified"`
 		Name              string `json:"name"`
 		PreferredUsername string `json:"preferred_username"`
 	}
 
 	if err := idToken.Claims(&claims); err != nil {
-		return "", "", fmt.Errorf("解析 claims 失败: %w", err)
+		return nil, fmt.Errorf("解析 claims 失败: %w", err)
 	}
+	var rawClaims map[string]any
+	if err := idToken.Claims(&rawClaims); err != nil {
+		return nil, fmt.Errorf("解析 claims 失败: %w", err)
+	}
+	groups := claimStrings(claimValue(rawClaims, s.groupsClaim()))
 
 	// 确定用户标识（优先使用 email，其次 preferred_username，最后使用 subject）
 	username := claims.Email
@@ -162,6 +307,15 @@ func (s *OIDCService) ExchangeCode(ctx context.Context, code, state string) (str
 		username = idToken.Subject
 	}
 
+	if err := s.authorize(claims.Email, claims.EmailVerified, groups); err != nil {
+		s.logger.Warn("OIDC 用户不允许登录",
+			zap.String("username", username),
+			zap.String("subject", idToken.Subject),
+			zap.Strings("groups", groups),
+			zap.Error(err))
+		return nil, err
+	}
+
 	nickname := claims.Name
 	if nickname == "" {
 		nickname = username
@@ -170,13 +324,133 @@ func (s *OIDCService) ExchangeCode(ctx context.Context, code, state string) (str
 	s.logger.Info("OIDC 认证成功",
 		zap.String("username", username),
 		zap.String("nickname", nickname),
-		zap.String("subject", idToken.Subject))
+		zap.String("subject", idToken.Subject),
+		zap.Strings("groups", groups))
+
+	return &OIDCIdentity{
+		Username: username,
+		Nickname: nickname,
+		Groups:   groups,
+		IDToken:  rawIDToken,
+	}, nil
+}
 
-	return username, nickname, nil
+// authorize 检查邮箱域名和分组是否满足登录条件
+// 限制邮箱域名时必须有 email_verified: true，否则未验证的邮箱可以冒充允许的域名并作为用户名接管已有账号
+func (s *OIDCService) authorize(email string, emailVerified *bool, groups []string) error {
+	if len(s.config.AllowedDomains) > 0 {
+		if emailVerified == nil || !*emailVerified {
+			return fmt.Errorf("%w: 邮箱未验证", ErrOIDCForbidden)
+		}
+		at := strings.LastIndex(email, "@")
+		if at < 0 || !slices.ContainsFunc(s.config.AllowedDomains, func(domain string) bool {
+			return strings.EqualFold(strings.TrimPrefix(domain, "@"), email[at+1:])
+		}) {
+			return fmt.Errorf("%w: 邮箱域名不在允许范围内", ErrOIDCForbidden)
+		}
+	}
+	if len(s.config.RequiredGroups) > 0 && !slices.ContainsFunc(groups, func(group string) bool {
+		return slices.Contains(s.config.RequiredGroups, group)
+	}) {
+		return fmt.Errorf("%w: 不属于允许登录的分组", ErrOIDCForbidden)
+	}
+	return nil
+}
+
+// LogoutURL 生成退出 IdP 会话的地址（RP-initiated logout），未启用或 IdP 不支持时返回空字符串
+func (s *OIDCService) LogoutURL(idToken string) string {
+	if !s.IsEnabled() || !s.config.RPLogout {
+		return ""
+	}
+	client := s.currentClient()
+	if client == nil || client.endSessionURL == "" {
+		return ""
+	}
+	logoutURL, err := url.Parse(client.endSessionURL)
+	if err != nil {
+		s.logger.Warn("解析 end_session_endpoint 失败", zap.String("url", client.endSessionURL), zap.Error(err))
+		return ""
+	}
+	query := logoutURL.Query()
+	query.Set("client_id", s.config.ClientID)
+	if idToken != "" {
+		query.Set("id_token_hint", idToken)
+	}
+	if s.config.PostLogoutRedirectURL != "" {
+		query.Set("post_logout_redirect_uri", s.config.PostLogoutRedirectURL)
+	}
+	logoutURL.RawQuery = query.Encode()
+	return logoutURL.String()
+}
+
+// MapRole 根据分组映射角色，匹配多个分组时取最高角色，都不匹配时使用默认角色
+func (s *OIDCService) MapRole(groups []string) models.UserRole {
+	role := models.RoleViewer
+	if s.config != nil && models.UserRole(s.config.DefaultRole).Valid() {
+		role = models.UserRole(s.config.DefaultRole)
+	}
+	if s.config == nil {
+		return role
+	}
+
+	matched := false
+	for _, group := range groups {
+		mapped := models.UserRole(s.config.RoleMapping[group])
+		if !mapped.Valid() {
+			continue
+		}
+		if !matched || mapped.Allows(role) {
+			role = mapped
+			matched = true
+		}
+	}
+	return role
+}
+
+// groupsClaim 分组所在的 claim 名称
+func (s *OIDCService) groupsClaim() string {
+	if s.config != nil && s.config.GroupsClaim != "" {
+		return s.config.GroupsClaim
+	}
+	return "groups"
+}
+
+// claimValue 读取 claim，名称本身不存在时按 . 分隔的嵌套路径查找，如 Keycloak 的 realm_access.roles
+func claimValue(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+	var value any = claims
+	for _, key := range strings.Split(name, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// claimStrings 把字符串或字符串数组类型的 claim 转为字符串切片
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
 }
 
-// generateState 生成随机 state
-func (s *OIDCService) generateState() (string, error) {
+// randomString 生成随机 state 和 nonce
+func randomString() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return "", err
@@ -185,16 +459,16 @@ func (s *OIDCService) generateState() (string, error) {
 }
 
 // validateAndDeleteState 验证并删除 state（原子操作）
-func (s *OIDCService) validateAndDeleteState(state string) bool {
+func (s *OIDCService) validateAndDeleteState(state string) (oidcState, bool) {
 	s.stateMu.Lock()
 	defer s.stateMu.Unlock()
 
-	expiresAt, exists := s.stateStore[state]
+	entry, exists := s.stateStore[state]
 	if !exists {
-		return false
+		return entry, false
 	}
 	delete(s.stateStore, state)
-	return time.Now().Before(expiresAt)
+	return entry, time.Now().Before(entry.expiresAt)
 }
 
 // cleanExpiredStates 清理过期的 state
@@ -203,8 +477,8 @@ func (s *OIDCService) cleanExpiredStates() {
 	defer s.stateMu.Unlock()
 
 	now := time.Now()
-	for state, expiresAt := range s.stateStore {
-		if now.After(expiresAt) {
+	for state, entry := range s.stateStore {
+		if now.After(entry.expiresAt) {
 			delete(s.stateStore, state)
 		}
 	}
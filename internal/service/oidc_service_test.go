@@ -1,14 +1,24 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"go.uber.org/zap"
 )
 
 func TestOIDCStateStoreConcurrency(t *testing.T) {
 	svc := &OIDCService{
-		stateStore: make(map[string]time.Time),
+		stateStore: make(map[string]oidcState),
 	}
 
 	// 并发写入 state
@@ -20,7 +30,7 @@ func TestOIDCStateStoreConcurrency(t *testing.T) {
 			defer wg.Done()
 			svc.stateMu.Lock()
 			state := "state-" + time.Now().String() + "-" + string(rune(idx))
-			svc.stateStore[state] = time.Now().Add(10 * time.Minute)
+			svc.stateStore[state] = oidcState{expiresAt: time.Now().Add(10 * time.Minute)}
 			states[idx] = state
 			svc.stateMu.Unlock()
 		}(i)
@@ -42,43 +52,43 @@ func TestOIDCStateStoreConcurrency(t *testing.T) {
 
 func TestOIDCValidateAndDeleteState(t *testing.T) {
 	svc := &OIDCService{
-		stateStore: make(map[string]time.Time),
+		stateStore: make(map[string]oidcState),
 	}
 
 	// 添加一个有效 state
-	svc.stateStore["valid-state"] = time.Now().Add(10 * time.Minute)
+	svc.stateStore["valid-state"] = oidcState{expiresAt: time.Now().Add(10 * time.Minute)}
 	// 添加一个过期 state
-	svc.stateStore["expired-state"] = time.Now().Add(-1 * time.Minute)
+	svc.stateStore["expired-state"] = oidcState{expiresAt: time.Now().Add(-1 * time.Minute)}
 
 	// 验证有效 state
-	if !svc.validateAndDeleteState("valid-state") {
+	if _, ok := svc.validateAndDeleteState("valid-state"); !ok {
 		t.Error("应该验证通过有效的 state")
 	}
 
 	// 验证 state 已被删除
-	if svc.validateAndDeleteState("valid-state") {
+	if _, ok := svc.validateAndDeleteState("valid-state"); ok {
 		t.Error("已使用的 state 不应再次验证通过")
 	}
 
 	// 验证过期 state
-	if svc.validateAndDeleteState("expired-state") {
+	if _, ok := svc.validateAndDeleteState("expired-state"); ok {
 		t.Error("过期的 state 不应验证通过")
 	}
 
 	// 验证不存在的 state
-	if svc.validateAndDeleteState("nonexistent") {
+	if _, ok := svc.validateAndDeleteState("nonexistent"); ok {
 		t.Error("不存在的 state 不应验证通过")
 	}
 }
 
 func TestOIDCCleanExpiredStates(t *testing.T) {
 	svc := &OIDCService{
-		stateStore: make(map[string]time.Time),
+		stateStore: make(map[string]oidcState),
 	}
 
-	svc.stateStore["valid"] = time.Now().Add(10 * time.Minute)
-	svc.stateStore["expired1"] = time.Now().Add(-1 * time.Minute)
-	svc.stateStore["expired2"] = time.Now().Add(-5 * time.Minute)
+	svc.stateStore["valid"] = oidcState{expiresAt: time.Now().Add(10 * time.Minute)}
+	svc.stateStore["expired1"] = oidcState{expiresAt: time.Now().Add(-1 * time.Minute)}
+	svc.stateStore["expired2"] = oidcState{expiresAt: time.Now().Add(-5 * time.Minute)}
 
 	svc.cleanExpiredStates()
 
@@ -90,3 +100,111 @@ func TestOIDCCleanExpiredStates(t *testing.T) {
 		t.Error("有效的 state 不应被清理")
 	}
 }
+
+func TestOIDCDiscoveryRetryAndPKCE(t *testing.T) {
+	// IdP 启动时不可用，之后恢复
+	var available atomic.Bool
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+			"end_session_endpoint":   server.URL + "/logout",
+		})
+	}))
+	defer server.Close()
+
+	svc := NewOIDCService(zap.NewNop(), &config.AppConfig{OIDC: &config.OIDCConfig{
+		Enabled:               true,
+		Issuer:                server.URL,
+		ClientID:              "smshub",
+		RedirectURL:           "http://localhost:8080/oidc/callback",
+		PKCE:                  true,
+		Scopes:                []string{"email", "groups"},
+		RPLogout:              true,
+		PostLogoutRedirectURL: "http://localhost:8080/login",
+	}})
+	if !svc.IsEnabled() {
+		t.Fatal("IdP 暂时不可用时不应禁用 OIDC")
+	}
+	if _, _, err := svc.GenerateAuthURL(context.Background()); !errors.Is(err, ErrOIDCUnavailable) {
+		t.Fatalf("Expected ErrOIDCUnavailable, got %v", err)
+	}
+
+	available.Store(true)
+	authURL, state, err := svc.GenerateAuthURL(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateAuthURL failed: %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		t.Errorf("Expected PKCE challenge in %s", authURL)
+	}
+	if query.Get("scope") != "openid email groups" {
+		t.Errorf("Unexpected scope %q", query.Get("scope"))
+	}
+	entry := svc.stateStore[state]
+	if entry.verifier == "" || entry.nonce == "" || query.Get("nonce") != entry.nonce {
+		t.Errorf("state 应保存 PKCE verifier 和 nonce: %+v", entry)
+	}
+
+	logoutURL, _ := url.Parse(svc.LogoutURL("id-token"))
+	if logoutURL == nil || logoutURL.Path != "/logout" || logoutURL.Query().Get("id_token_hint") != "id-token" ||
+		logoutURL.Query().Get("post_logout_redirect_uri") != "http://localhost:8080/login" {
+		t.Errorf("Unexpected logout url %v", logoutURL)
+	}
+}
+
+func TestOIDCAuthorize(t *testing.T) {
+	svc := &OIDCService{config: &config.OIDCConfig{
+		AllowedDomains: []string{"example.com", "@corp.example.org"},
+		RequiredGroups: []string{"smshub-users"},
+	}}
+	verified, unverified := true, false
+
+	for _, tt := range []struct {
+		email    string
+		verified *bool
+		groups   []string
+		allowed  bool
+	}{
+		{"alice@example.com", &verified, []string{"smshub-users"}, true},
+		{"bob@CORP.example.org", &verified, []string{"other", "smshub-users"}, true},
+		{"carol@example.com", &unverified, []string{"smshub-users"}, false},
+		{"mallory@example.com", nil, []string{"smshub-users"}, false},
+		{"dave@evil.com", &verified, []string{"smshub-users"}, false},
+		{"eve@example.com.evil.com", &verified, []string{"smshub-users"}, false},
+		{"", nil, []string{"smshub-users"}, false},
+		{"frank@example.com", &verified, []string{"other"}, false},
+	} {
+		err := svc.authorize(tt.email, tt.verified, tt.groups)
+		if tt.allowed && err != nil {
+			t.Errorf("%s should be allowed: %v", tt.email, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrOIDCForbidden) {
+			t.Errorf("%s should be forbidden, got %v", tt.email, err)
+		}
+	}
+
+	// 嵌套的分组 claim
+	claims := map[string]any{
+		"realm_access":               map[string]any{"roles": []any{"smshub-admins"}},
+		"https://example.com/groups": []any{"ops"},
+	}
+	if got := claimStrings(claimValue(claims, "realm_access.roles")); len(got) != 1 || got[0] != "smshub-admins" {
+		t.Errorf("claimValue nested = %v", got)
+	}
+	if got := claimStrings(claimValue(claims, "https://example.com/groups")); len(got) != 1 || got[0] != "ops" {
+		t.Errorf("claimValue dotted name = %v", got)
+	}
+	if claimValue(claims, "missing.path") != nil {
+		t.Error("missing claim should be nil")
+	}
+}
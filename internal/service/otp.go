@@ -0,0 +1,81 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// otpKeywords 验证码短信关键词（小写）
+var otpKeywords = []string{
+	"验证码", "校验码", "确认码", "动态码", "动态密码", "激活码",
+	"驗證碼", "確認碼", "動態碼",
+	"verification code", "security code", "passcode", "otp",
+}
+
+// 单独的 code 含义太宽泛（postcode、promo code、area code 等），只在紧挨着验证码数字时识别
+var (
+	codeKeywordPattern = regexp.MustCompile(`\bcode\b`)
+	// codeAfterPattern code 之后的数字，如 "code: 1234"、"code is 1234"、"code: use 1234"
+	codeAfterPattern = regexp.MustCompile(`^\W{0,3}(?:(?:is|use|was)\W{1,3})?([0-9]{4,8})\b`)
+	// codeBeforePattern code 之前的数字，如 "1234 is your code"、"1234 is your login code"
+	codeBeforePattern = regexp.MustCompile(`\b([0-9]{4,8})\W{1,3}is your (?:[a-z]+ )?$`)
+	// codePrevWordPattern code 前面的单词
+	codePrevWordPattern = regexp.MustCompile(`([a-z]+)[\s-]*$`)
+)
+
+// codeNonOTPQualifiers 这些单词后面的 code 不是验证码
+var codeNonOTPQualifiers = map[string]bool{
+	"promo": true, "promotion": true, "promotional": true, "discount": true, "coupon": true, "voucher": true,
+	"gift": true, "referral": true, "invite": true, "invitation": true, "area": true, "country": true,
+	"zip": true, "postal": true, "post": true, "qr": true, "bar": true, "source": true, "dress": true,
+	"error": true, "status": true, "tracking": true, "product": true, "order": true,
+}
+
+// otpCodePattern 4-8 位独立数字
+var otpCodePattern = regexp.MustCompile(`\b[0-9]{4,8}\b`)
+
+// ExtractOTP 从短信内容中识别验证码，未识别到返回空字符串
+// 优先取关键词之后最近的数字，其次取关键词之前最近的数字
+func ExtractOTP(content string) string {
+	lower := strings.ToLower(content)
+
+	keywordAt := -1
+	for _, keyword := range otpKeywords {
+		if i := strings.Index(lower, keyword); i >= 0 && (keywordAt < 0 || i < keywordAt) {
+			keywordAt = i
+		}
+	}
+	if keywordAt < 0 {
+		return extractCodeOTP(lower)
+	}
+
+	matches := otpCodePattern.FindAllStringIndex(lower, -1)
+	var before []int
+	for _, m := range matches {
+		if m[0] >= keywordAt {
+			return lower[m[0]:m[1]]
+		}
+		before = m
+	}
+	if before != nil {
+		return lower[before[0]:before[1]]
+	}
+	return ""
+}
+
+// extractCodeOTP 识别紧挨着单独关键词 code 的验证码数字
+func extractCodeOTP(lower string) string {
+	for _, loc := range codeKeywordPattern.FindAllStringIndex(lower, -1) {
+		before := lower[:loc[0]]
+		if m := codePrevWordPattern.FindStringSubmatch(before); m != nil && codeNonOTPQualifiers[m[1]] {
+			continue
+		}
+		if m := codeAfterPattern.FindStringSubmatch(lower[loc[1]:]); m != nil {
+			return m[1]
+		}
+		if m := codeBeforePattern.FindStringSubmatch(before); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package service
+
+import "testing"
+
+func TestExtractOTP(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"中文验证码", "【某银行】您的验证码为 482913，5分钟内有效。", "482913"},
+		{"验证码在关键词前", "123456 是您的登录校验码，请勿泄露", "123456"},
+		{"英文验证码", "Your verification code is 7781. Do not share it.", "7781"},
+		{"Code 大写", "G-Code: use 90210 to sign in", "90210"},
+		{"繁体验证码", "您的驗證碼是 0042，請於10分鐘內輸入", "0042"},
+		{"无关键词", "您本月话费余额 1234 元", ""},
+		{"关键词但无数字", "请勿向他人透露验证码", ""},
+		{"忽略手机号", "验证码已发送至 13800138000", ""},
+		{"code 在数字后", "884411 is your login code", "884411"},
+		{"code is", "Your code is 5521", "5521"},
+		{"邮编", "Your postcode 10001 has been updated", ""},
+		{"优惠码", "Use promo code 2024 to get 20% off", ""},
+		{"区号", "Please include the area code 0755 when dialing", ""},
+		{"code 远离数字", "Use the code on the back of your card, balance 1234", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractOTP(tt.content); got != tt.want {
+				t.Errorf("ExtractOTP(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -16,8 +17,19 @@ import (
 const (
 	// PropertyIDNotificationChannels 通知渠道配置的固定 ID
 	PropertyIDNotificationChannels = "notification_channels"
+	// PropertyIDMessageRetention 短信保留策略的固定 ID
+	PropertyIDMessageRetention = "message_retention"
+	// PropertyIDMessageRetentionStatus 短信清理运行状态的固定 ID
+	PropertyIDMessageRetentionStatus = "message_retention_status"
+	// PropertyIDJWTSecret 自动生成的 JWT 密钥的固定 ID，不能通过属性接口读写
+	PropertyIDJWTSecret = "jwt_secret"
 )
 
+// IsInternalProperty 是否为内部属性，内部属性不能通过属性接口读写
+func IsInternalProperty(id string) bool {
+	return id == PropertyIDJWTSecret
+}
+
 type PropertyService struct {
 	repo   *repo.PropertyRepo
 	logger *zap.Logger
@@ -102,6 +114,27 @@ func (s *PropertyService) GetNotificationChannelConfigs(ctx context.Context) ([]
 	return allChannels, nil
 }
 
+// EnsureJWTSecret 读取自动生成的 JWT 密钥，不存在时生成并保存，重启后已签发的令牌仍然有效
+func (s *PropertyService) EnsureJWTSecret(ctx context.Context) (string, error) {
+	var secret string
+	err := s.GetValue(ctx, PropertyIDJWTSecret, &secret)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if secret != "" {
+		return secret, nil
+	}
+
+	if secret, err = GenerateSecret(); err != nil {
+		return "", err
+	}
+	if err := s.Set(ctx, PropertyIDJWTSecret, "JWT 密钥", secret); err != nil {
+		return "", err
+	}
+	s.logger.Info("已生成 JWT 密钥并保存到数据库")
+	return secret, nil
+}
+
 // defaultPropertyConfig 默认配置项定义
 type defaultPropertyConfig struct {
 	ID    string
@@ -118,6 +151,11 @@ func (s *PropertyService) InitializeDefaultConfigs(ctx context.Context) error {
 			Name:  "通知渠道配置",
 			Value: []models.NotificationChannelConfig{},
 		},
+		{
+			ID:    PropertyIDMessageRetention,
+			Name:  "短信保留策略",
+			Value: models.MessageRetentionConfig{ExemptPinned: true, TrashDays: 30},
+		},
 	}
 
 	// 遍历并初始化每个配置
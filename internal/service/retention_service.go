@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// retentionCronSpec 每天凌晨 4 点执行清理
+	retentionCronSpec = "0 4 * * *"
+	// retentionDeleteBatchSize 每批删除条数
+	retentionDeleteBatchSize = 1000
+)
+
+// RetentionRuleReport 单条保留规则的清理统计
+type RetentionRuleReport struct {
+	Scope  string `json:"scope"`  // 规则范围：global、device:<id>、type:<type>、otp
+	Days   int    `json:"days"`   // 保留天数
+	Before int64  `json:"before"` // 早于该时间（时间戳毫秒）的短信将被清理
+	Count  int64  `json:"count"`  // 命中条数（不同规则之间可能重叠）
+}
+
+// RetentionReport 清理报告
+type RetentionReport struct {
+	DryRun   bool                  `json:"dryRun"`   // 是否为预览（不实际删除）
+	RunAt    int64                 `json:"runAt"`    // 执行时间（时间戳毫秒）
+	Rules    []RetentionRuleReport `json:"rules"`    // 各规则统计
+	Total    int64                 `json:"total"`    // 过期短信总数（已去重）
+	Deleted  int64                 `json:"deleted"`  // 实际删除条数
+	Trash    int64                 `json:"trash"`    // 回收站中到期的短信数（非预览时已永久删除）
+	Vacuumed bool                  `json:"vacuumed"` // 是否执行了 VACUUM
+}
+
+// RetentionService 短信保留策略与自动清理
+type RetentionService struct {
+	logger          *zap.Logger
+	repo            *repo.TextMessageRepo
+	propertyService *PropertyService
+	cron            *cron.Cron
+	// 防止定时清理与手动清理并发执行
+	mu sync.Mutex
+}
+
+// NewRetentionService 创建短信清理服务实例
+func NewRetentionService(logger *zap.Logger, db *gorm.DB, propertyService *PropertyService) *RetentionService {
+	return &RetentionService{
+		logger:          logger,
+		repo:            repo.NewTextMessageRepo(db),
+		propertyService: propertyService,
+	}
+}
+
+// Start 启动每日清理任务
+func (s *RetentionService) Start(ctx context.Context) error {
+	s.cron = cron.New()
+	_, err := s.cron.AddFunc(retentionCronSpec, func() {
+		if _, err := s.runScheduled(context.Background()); err != nil {
+			s.logger.Error("自动清理短信失败", zap.Error(err))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("添加清理任务失败: %w", err)
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop 停止清理任务
+func (s *RetentionService) Stop() {
+	if s.cron != nil {
+		s.cron.Stop()
+		s.logger.Info("短信清理服务已停止")
+	}
+}
+
+// GetConfig 获取保留策略
+func (s *RetentionService) GetConfig(ctx context.Context) (*models.MessageRetentionConfig, error) {
+	var cfg models.MessageRetentionConfig
+	if err := s.propertyService.GetValue(ctx, PropertyIDMessageRetention, &cfg); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &cfg, nil
+		}
+		return nil, fmt.Errorf("获取短信保留策略失败: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Preview 按当前策略预览清理结果，不删除数据
+func (s *RetentionService) Preview(ctx context.Context) (*RetentionReport, error) {
+	cfg, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.prune(ctx, cfg, time.Now(), true)
+}
+
+// Run 立即按当前策略执行清理（不受 enabled 开关限制）
+func (s *RetentionService) Run(ctx context.Context) (*RetentionReport, error) {
+	cfg, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(ctx, cfg, time.Now())
+}
+
+// runScheduled 定时清理：仅在启用时删除过期短信，回收站清理和 VACUUM 按各自配置独立执行
+func (s *RetentionService) runScheduled(ctx context.Context) (*RetentionReport, error) {
+	cfg, err := s.GetConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		cfg = &models.MessageRetentionConfig{TrashDays: cfg.TrashDays, VacuumIntervalDays: cfg.VacuumIntervalDays}
+	}
+	return s.execute(ctx, cfg, time.Now())
+}
+
+// execute 删除过期短信、按间隔执行 VACUUM 并记录运行状态
+func (s *RetentionService) execute(ctx context.Context, cfg *models.MessageRetentionConfig, now time.Time) (*RetentionReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report, err := s.prune(ctx, cfg, now, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var status models.MessageRetentionStatus
+	if err := s.propertyService.GetValue(ctx, PropertyIDMessageRetentionStatus, &status); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取清理状态失败: %w", err)
+	}
+
+	vacuumInterval := time.Duration(cfg.VacuumIntervalDays) * 24 * time.Hour
+	if cfg.VacuumIntervalDays > 0 && now.Sub(time.UnixMilli(status.LastVacuumAt)) >= vacuumInterval {
+		vacuumed, err := s.repo.Vacuum(ctx)
+		if err != nil {
+			s.logger.Error("VACUUM 数据库失败", zap.Error(err))
+		} else if vacuumed {
+			report.Vacuumed = true
+			status.LastVacuumAt = now.UnixMilli()
+			s.logger.Info("VACUUM 数据库完成")
+		}
+	}
+
+	status.LastRunAt = report.RunAt
+	status.LastDeleted = report.Deleted + report.Trash
+	if err := s.propertyService.Set(ctx, PropertyIDMessageRetentionStatus, "短信清理状态", status); err != nil {
+		s.logger.Error("保存清理状态失败", zap.Error(err))
+	}
+
+	return report, nil
+}
+
+// prune 统计（dryRun）或删除过期短信及回收站中到期的短信
+func (s *RetentionService) prune(ctx context.Context, cfg *models.MessageRetentionConfig, now time.Time, dryRun bool) (*RetentionReport, error) {
+	report := &RetentionReport{
+		DryRun: dryRun,
+		RunAt:  now.UnixMilli(),
+		Rules:  []RetentionRuleReport{},
+	}
+
+	if cfg.TrashDays > 0 {
+		trashBefore := now.Add(-time.Duration(cfg.TrashDays) * 24 * time.Hour)
+		var err error
+		if dryRun {
+			report.Trash, err = s.repo.CountTrash(ctx, trashBefore)
+		} else {
+			report.Trash, err = s.repo.PurgeTrash(ctx, trashBefore, retentionDeleteBatchSize)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("清理回收站失败: %w", err)
+		}
+		if report.Trash > 0 && !dryRun {
+			s.logger.Info("清理回收站完成", zap.Int64("purged", report.Trash))
+		}
+	}
+
+	scopes, rules := buildRetentionRules(cfg, now)
+	for i, rule := range rules {
+		count, err := s.repo.CountExpired(ctx, rules[i:i+1], cfg.ExemptPinned)
+		if err != nil {
+			return nil, fmt.Errorf("统计过期短信失败: %w", err)
+		}
+		scopes[i].Before = rule.Before
+		scopes[i].Count = count
+		report.Rules = append(report.Rules, scopes[i])
+	}
+
+	total, err := s.repo.CountExpired(ctx, rules, cfg.ExemptPinned)
+	if err != nil {
+		return nil, fmt.Errorf("统计过期短信失败: %w", err)
+	}
+	report.Total = total
+	if dryRun || total == 0 {
+		return report, nil
+	}
+
+	deleted, err := s.repo.DeleteExpired(ctx, rules, cfg.ExemptPinned, retentionDeleteBatchSize)
+	if err != nil {
+		s.logger.Error("删除过期短信失败", zap.Error(err), zap.Int64("deleted", deleted))
+		return nil, fmt.Errorf("删除过期短信失败: %w", err)
+	}
+	report.Deleted = deleted
+	s.logger.Info("清理过期短信完成", zap.Int64("deleted", deleted))
+	return report, nil
+}
+
+// buildRetentionRules 将保留策略转换为互不覆盖的清理规则
+// 设备规则优先于类型规则，类型规则优先于全局规则；验证码规则额外生效
+func buildRetentionRules(cfg *models.MessageRetentionConfig, now time.Time) ([]RetentionRuleReport, []repo.RetentionRule) {
+	before := func(days int) int64 {
+		return now.Add(-time.Duration(days) * 24 * time.Hour).UnixMilli()
+	}
+
+	var scopes []RetentionRuleReport
+	var rules []repo.RetentionRule
+
+	// 配置了单独规则的设备和类型（包括 0 天即永久保留）不再适用上层规则
+	deviceIDs := make([]string, 0, len(cfg.DeviceDays))
+	for deviceID := range cfg.DeviceDays {
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+	sort.Strings(deviceIDs)
+	types := make([]models.MessageType, 0, len(cfg.TypeDays))
+	for messageType := range cfg.TypeDays {
+		types = append(types, messageType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	for _, deviceID := range deviceIDs {
+		if days := cfg.DeviceDays[deviceID]; days > 0 {
+			scopes = append(scopes, RetentionRuleReport{Scope: "device:" + deviceID, Days: days})
+			rules = append(rules, repo.RetentionRule{DeviceID: deviceID, Before: before(days)})
+		}
+	}
+	for _, messageType := range types {
+		if days := cfg.TypeDays[messageType]; days > 0 {
+			scopes = append(scopes, RetentionRuleReport{Scope: "type:" + string(messageType), Days: days})
+			rules = append(rules, repo.RetentionRule{Type: messageType, ExcludeDeviceIDs: deviceIDs, Before: before(days)})
+		}
+	}
+	if cfg.Days > 0 {
+		scopes = append(scopes, RetentionRuleReport{Scope: "global", Days: cfg.Days})
+		rules = append(rules, repo.RetentionRule{ExcludeDeviceIDs: deviceIDs, ExcludeTypes: types, Before: before(cfg.Days)})
+	}
+	if cfg.OTPDays > 0 {
+		scopes = append(scopes, RetentionRuleReport{Scope: "otp", Days: cfg.OTPDays})
+		rules = append(rules, repo.RetentionRule{OTPOnly: true, Before: before(cfg.OTPDays)})
+	}
+
+	return scopes, rules
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"go.uber.org/zap"
+)
+
+func TestRetentionService_PreviewAndRun(t *testing.T) {
+	db := setupTestDB(t)
+	logger := zap.NewNop()
+	propertyService := NewPropertyService(logger, db)
+	msgRepo := repo.NewTextMessageRepo(db)
+	stateRepo := repo.NewConversationStateRepo(db)
+	svc := NewRetentionService(logger, db, propertyService)
+	ctx := context.Background()
+
+	daysAgo := func(days int) int64 {
+		return time.Now().Add(-time.Duration(days)*24*time.Hour - time.Minute).UnixMilli()
+	}
+	messages := []models.TextMessage{
+		// 全局规则 30 天
+		{ID: "old-global", From: "10010", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: daysAgo(40)},
+		{ID: "new-global", From: "10010", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: daysAgo(10)},
+		// 发送记录单独保留 7 天
+		{ID: "old-outgoing", To: "10010", Type: models.MessageTypeOutgoing, DeviceID: "dev-a", CreatedAt: daysAgo(8)},
+		// dev-b 永久保留，优先于类型和全局规则
+		{ID: "keep-device", To: "10010", Type: models.MessageTypeOutgoing, DeviceID: "dev-b", CreatedAt: daysAgo(400)},
+		// 验证码保留 1 天，对所有设备生效
+		{ID: "old-otp", From: "95588", Type: models.MessageTypeIncoming, DeviceID: "dev-b", OTPCode: "1234", CreatedAt: daysAgo(2)},
+		// 置顶会话豁免
+		{ID: "pinned", From: "10086", Type: models.MessageTypeIncoming, DeviceID: "dev-a", CreatedAt: daysAgo(100)},
+	}
+	for i := range messages {
+		if err := msgRepo.Create(ctx, &messages[i]); err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+	}
+	if err := stateRepo.Save(ctx, &models.ConversationState{Peer: "10086", Pinned: true}); err != nil {
+		t.Fatalf("Failed to pin conversation: %v", err)
+	}
+
+	cfg := models.MessageRetentionConfig{
+		Enabled:      true,
+		Days:         30,
+		DeviceDays:   map[string]int{"dev-b": 0},
+		TypeDays:     map[models.MessageType]int{models.MessageTypeOutgoing: 7},
+		OTPDays:      1,
+		ExemptPinned: true,
+	}
+	if err := propertyService.Set(ctx, PropertyIDMessageRetention, "短信保留策略", cfg); err != nil {
+		t.Fatalf("Failed to save retention config: %v", err)
+	}
+
+	t.Run("Preview", func(t *testing.T) {
+		report, err := svc.Preview(ctx)
+		if err != nil {
+			t.Fatalf("Preview failed: %v", err)
+		}
+		if !report.DryRun || report.Total != 3 || report.Deleted != 0 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+		counts := map[string]int64{}
+		for _, rule := range report.Rules {
+			counts[rule.Scope] = rule.Count
+		}
+		if counts["type:outgoing"] != 1 || counts["global"] != 1 || counts["otp"] != 1 {
+			t.Errorf("Unexpected rule counts: %v", counts)
+		}
+
+		total, err := msgRepo.CountAll(ctx)
+		if err != nil || total != int64(len(messages)) {
+			t.Errorf("Preview must not delete messages, got %d (%v)", total, err)
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		report, err := svc.Run(ctx)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if report.DryRun || report.Deleted != 3 {
+			t.Errorf("Unexpected report: %+v", report)
+		}
+
+		for _, id := range []string{"new-global", "keep-device", "pinned"} {
+			if exists, err := msgRepo.ExistsById(ctx, id); err != nil || !exists {
+				t.Errorf("Expected %s to be kept (err=%v)", id, err)
+			}
+		}
+		for _, id := range []string{"old-global", "old-outgoing", "old-otp"} {
+			if exists, _ := msgRepo.ExistsById(ctx, id); exists {
+				t.Errorf("Expected %s to be deleted", id)
+			}
+		}
+
+		var status models.MessageRetentionStatus
+		if err := propertyService.GetValue(ctx, PropertyIDMessageRetentionStatus, &status); err != nil {
+			t.Fatalf("Failed to read status: %v", err)
+		}
+		if status.LastDeleted != 3 || status.LastRunAt == 0 {
+			t.Errorf("Unexpected status: %+v", status)
+		}
+	})
+}
+
+func TestRetentionService_ScheduledSkipsWhenDisabled(t *testing.T) {
+	db := setupTestDB(t)
+	logger := zap.NewNop()
+	propertyService := NewPropertyService(logger, db)
+	svc := NewRetentionService(logger, db, propertyService)
+	ctx := context.Background()
+
+	msgRepo := repo.NewTextMessageRepo(db)
+	old := time.Now().Add(-100 * 24 * time.Hour).UnixMilli()
+	if err := msgRepo.Create(ctx, &models.TextMessage{ID: "old", From: "10010", Type: models.MessageTypeIncoming, CreatedAt: old}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if err := propertyService.Set(ctx, PropertyIDMessageRetention, "短信保留策略", models.MessageRetentionConfig{Days: 30}); err != nil {
+		t.Fatalf("Failed to save retention config: %v", err)
+	}
+
+	report, err := svc.runScheduled(ctx)
+	if err != nil {
+		t.Fatalf("runScheduled failed: %v", err)
+	}
+	if report.Deleted != 0 || len(report.Rules) != 0 {
+		t.Errorf("Disabled policy should not delete, got %+v", report)
+	}
+	if exists, _ := msgRepo.ExistsById(ctx, "old"); !exists {
+		t.Error("Message should be kept when policy disabled")
+	}
+}
+
+func TestRetentionService_PurgesTrash(t *testing.T) {
+	db := setupTestDB(t)
+	logger := zap.NewNop()
+	propertyService := NewPropertyService(logger, db)
+	msgRepo := repo.NewTextMessageRepo(db)
+	svc := NewRetentionService(logger, db, propertyService)
+	ctx := context.Background()
+
+	for _, id := range []string{"old-trash", "new-trash"} {
+		if err := msgRepo.Create(ctx, &models.TextMessage{ID: id, From: "10010", Type: models.MessageTypeIncoming, CreatedAt: 1000}); err != nil {
+			t.Fatalf("Failed to create message: %v", err)
+		}
+		if _, err := msgRepo.SoftDeleteById(ctx, id); err != nil {
+			t.Fatalf("Failed to delete message: %v", err)
+		}
+	}
+	// 模拟 40 天前删除
+	if err := db.Unscoped().Model(&models.TextMessage{}).Where("id = ?", "old-trash").
+		Update("deleted_at", time.Now().Add(-40*24*time.Hour)).Error; err != nil {
+		t.Fatalf("Failed to update deleted_at: %v", err)
+	}
+	// 未启用保留策略时回收站清理仍然生效
+	if err := propertyService.Set(ctx, PropertyIDMessageRetention, "短信保留策略", models.MessageRetentionConfig{TrashDays: 30}); err != nil {
+		t.Fatalf("Failed to save retention config: %v", err)
+	}
+
+	report, err := svc.runScheduled(ctx)
+	if err != nil {
+		t.Fatalf("runScheduled failed: %v", err)
+	}
+	if report.Trash != 1 {
+		t.Errorf("Expected 1 trashed message purged, got %d", report.Trash)
+	}
+	if count, _ := msgRepo.CountTrash(ctx, time.Time{}); count != 1 {
+		t.Errorf("Expected 1 message left in trash, got %d", count)
+	}
+}
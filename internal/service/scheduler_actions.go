@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/valyala/fasttemplate"
+	"go.uber.org/zap"
+)
+
+// TaskDeviceAuto 前端表示自动选择设备的 deviceId
+const TaskDeviceAuto = "auto"
+
+// taskAction 返回任务的执行动作，旧任务没有动作类型时为发送短信
+func taskAction(task models.ScheduledTask) models.TaskActionType {
+	if task.ActionType == "" {
+		return models.TaskActionSMS
+	}
+	return task.ActionType
+}
+
+// checkTaskDevice 检查受限用户是否可以让任务使用该设备
+// 任务按绑定的设备限制查看和管理，受限用户创建的 Webhook 任务同样需要绑定可访问的设备
+func checkTaskDevice(ctx context.Context, task models.ScheduledTask) error {
+	return checkSendDevice(ctx, task.DeviceID)
+}
+
+// taskDevice 执行任务使用的设备，单设备模式下 id 为空
+type taskDevice struct {
+	id   string
+	name string
+	sim  string
+}
+
+// resolveTaskDevice 确定执行任务的设备：指定设备、自动选择的在线设备或单设备模式
+// 旧版本前端保存的 deviceId 为 auto，同样按自动选择处理
+func (s *SchedulerService) resolveTaskDevice(ctx context.Context, task models.ScheduledTask) (taskDevice, error) {
+	if task.DeviceID != "" && task.DeviceID != TaskDeviceAuto {
+		if s.deviceManager == nil {
+			return taskDevice{}, fmt.Errorf("设备不在线: %s", task.DeviceID)
+		}
+		device, err := s.deviceManager.GetDevice(ctx, task.DeviceID)
+		if err != nil {
+			return taskDevice{}, fmt.Errorf("获取设备失败: %w", err)
+		}
+		return taskDevice{id: device.ID, name: device.Name, sim: device.PhoneNumber}, nil
+	}
+	if s.deviceManager != nil && s.deviceManager.GetOnlineDeviceCount() > 0 {
+		device, err := s.deviceManager.selectDevice(ctx, StrategyAuto, nil)
+		if err != nil {
+			return taskDevice{}, err
+		}
+		return taskDevice{id: device.ID, name: device.Name, sim: device.PhoneNumber}, nil
+	}
+	if s.serialService == nil {
+		return taskDevice{}, fmt.Errorf("没有可用的设备")
+	}
+	device := taskDevice{name: s.serialService.GetDeviceName()}
+	if status, err := s.serialService.GetStatus(); err == nil {
+		device.sim = status.Mobile.Number
+	}
+	return device, nil
+}
+
+// runAction 执行任务动作，返回执行记录（发送短信时每个收件人一条）
+func (s *SchedulerService) runAction(ctx context.Context, task models.ScheduledTask) []*models.ScheduledTaskRun {
+	if taskAction(task) == models.TaskActionWebhook {
+		return []*models.ScheduledTaskRun{timedRun(func(run *models.ScheduledTaskRun) error {
+			output, err := s.callTaskWebhook(ctx, task)
+			run.Output = output
+			return err
+		})}
+	}
+
+	device, err := s.resolveTaskDevice(ctx, task)
+	if err != nil {
+		return []*models.ScheduledTaskRun{failedRun(err)}
+	}
+
+	switch taskAction(task) {
+	case models.TaskActionSMS:
+		return s.sendTaskSMS(ctx, task, device)
+	case models.TaskActionFlymode:
+		return []*models.ScheduledTaskRun{timedRun(func(run *models.ScheduledTaskRun) error {
+			run.DeviceID = device.id
+			if device.id != "" {
+				return s.deviceManager.SetDeviceFlymode(ctx, device.id, task.Flymode)
+			}
+			return s.serialService.SetFlymode(task.Flymode)
+		})}
+	case models.TaskActionReboot:
+		return []*models.ScheduledTaskRun{timedRun(func(run *models.ScheduledTaskRun) error {
+			run.DeviceID = device.id
+			if device.id != "" {
+				return s.deviceManager.RebootDevice(ctx, device.id)
+			}
+			return s.serialService.RebootMcu()
+		})}
+	case models.TaskActionUSSD:
+		return []*models.ScheduledTaskRun{timedRun(func(run *models.ScheduledTaskRun) (err error) {
+			run.DeviceID = device.id
+			if device.id != "" {
+				run.Output, err = s.deviceManager.SendUSSDByDevice(ctx, device.id, task.UssdCode)
+			} else {
+				run.Output, err = s.serialService.SendUSSD(ctx, task.UssdCode)
+			}
+			return err
+		})}
+	}
+	return []*models.ScheduledTaskRun{failedRun(fmt.Errorf("不支持的动作类型: %s", task.ActionType))}
+}
+
+// sendTaskSMS 向任务的所有收件人发送短信
+func (s *SchedulerService) sendTaskSMS(ctx context.Context, task models.ScheduledTask, device taskDevice) []*models.ScheduledTaskRun {
+	recipients, err := s.taskRecipients(ctx, task)
+	if err != nil {
+		return []*models.ScheduledTaskRun{failedRun(err)}
+	}
+	if len(recipients) == 0 {
+		return []*models.ScheduledTaskRun{failedRun(fmt.Errorf("没有收件人"))}
+	}
+
+	// 单设备模式下如果是飞行模式，取消飞行模式，再等待 30 秒后发送短信
+	if device.id == "" && s.serialService.FlyMode() {
+		s.logger.Info("当前为飞行模式，取消飞行模式后等待 30 秒")
+		if err := s.serialService.SetFlymode(false); err != nil {
+			s.logger.Error("取消飞行模式失败", zap.Error(err))
+			return []*models.ScheduledTaskRun{failedRun(fmt.Errorf("取消飞行模式失败: %w", err))}
+		}
+		s.logger.Info("取消飞行模式成功")
+		time.Sleep(30 * time.Second)
+		s.logger.Info("等待 30 秒后发送短信")
+	}
+
+	now := time.Now()
+	runs := make([]*models.ScheduledTaskRun, 0, len(recipients))
+	for _, recipient := range recipients {
+		content := renderTaskTemplate(task.Content, taskTemplateVars(task, device, recipient, now), nil)
+		runs = append(runs, timedRun(func(run *models.ScheduledTaskRun) (err error) {
+			run.DeviceID = device.id
+			run.Recipient = recipient
+			if device.id != "" {
+				run.MsgID, err = s.deviceManager.SendSMSByDevice(ctx, device.id, recipient, content)
+			} else {
+				run.MsgID, err = s.serialService.SendSMS(ctx, recipient, content)
+			}
+			return err
+		}))
+	}
+	return runs
+}
+
+// taskRecipients 合并任务的目标号码、其他收件人和联系人分组，按出现顺序去重
+func (s *SchedulerService) taskRecipients(ctx context.Context, task models.ScheduledTask) ([]string, error) {
+	numbers := append([]string{task.PhoneNumber}, task.Recipients...)
+	if task.ContactGroup != "" {
+		contacts, err := s.contactRepo.FindByGroup(ctx, task.ContactGroup)
+		if err != nil {
+			return nil, fmt.Errorf("获取联系人分组失败: %w", err)
+		}
+		for _, contact := range contacts {
+			numbers = append(numbers, contact.PhoneNumber)
+		}
+	}
+
+	seen := make(map[string]bool, len(numbers))
+	recipients := make([]string, 0, len(numbers))
+	for _, number := range numbers {
+		number = strings.TrimSpace(number)
+		if number == "" || seen[number] {
+			continue
+		}
+		seen[number] = true
+		recipients = append(recipients, number)
+	}
+	return recipients, nil
+}
+
+// callTaskWebhook 调用任务配置的 Webhook，返回响应状态
+func (s *SchedulerService) callTaskWebhook(ctx context.Context, task models.ScheduledTask) (string, error) {
+	method := strings.ToUpper(task.WebhookMethod)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	// 请求体通常为 JSON，变量按 JSON 字符串转义
+	vars := taskTemplateVars(task, taskDevice{}, "", time.Now())
+	body := renderTaskTemplate(task.WebhookBody, vars, func(v string) string {
+		b, _ := json.Marshal(v)
+		return string(b[1 : len(b)-1])
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, defaultContextTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, task.WebhookURL, strings.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建 Webhook 请求失败: %w", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("调用 Webhook 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.Status, fmt.Errorf("Webhook 返回错误状态: %s", resp.Status)
+	}
+	return resp.Status, nil
+}
+
+// taskTemplateVars 任务内容模板变量
+func taskTemplateVars(task models.ScheduledTask, device taskDevice, recipient string, now time.Time) map[string]string {
+	return map[string]string{
+		"date":     now.Format(time.DateOnly),
+		"time":     now.Format("15:04"),
+		"datetime": now.Format(time.DateTime),
+		"device":   device.name,
+		"sim":      device.sim,
+		"counter":  strconv.FormatInt(task.RunCount, 10),
+		"task":     task.Name,
+		"phone":    recipient,
+	}
+}
+
+// renderTaskTemplate 替换模板中的 {{变量}}，未知变量保持原样
+func renderTaskTemplate(tpl string, vars map[string]string, escape func(string) string) string {
+	t, err := fasttemplate.NewTemplate(tpl, "{{", "}}")
+	if err != nil {
+		return tpl
+	}
+	return t.ExecuteFuncString(func(w io.Writer, tag string) (int, error) {
+		v, ok := vars[strings.TrimSpace(tag)]
+		if !ok {
+			return w.Write([]byte("{{" + tag + "}}"))
+		}
+		if escape != nil {
+			v = escape(v)
+		}
+		return w.Write([]byte(v))
+	})
+}
+
+// timedRun 执行动作并记录耗时和结果
+func timedRun(action func(run *models.ScheduledTaskRun) error) *models.ScheduledTaskRun {
+	start := time.Now()
+	run := &models.ScheduledTaskRun{Status: models.LastRunStatusUnknown, CreatedAt: start.UnixMilli()}
+	if err := action(run); err != nil {
+		run.Status = models.LastRunStatusFailed
+		run.Error = err.Error()
+	} else if run.MsgID == "" {
+		// 没有短信回执的动作执行完即成功
+		run.Status = models.LastRunStatusSuccess
+	}
+	run.DurationMs = time.Since(start).Milliseconds()
+	return run
+}
+
+// failedRun 执行前就失败的记录
+func failedRun(err error) *models.ScheduledTaskRun {
+	return &models.ScheduledTaskRun{
+		Status:    models.LastRunStatusFailed,
+		Error:     err.Error(),
+		CreatedAt: time.Now().UnixMilli(),
+	}
+}
@@ -2,9 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Starktomy/smshub/internal/metrics"
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/repo"
 	"github.com/go-orz/orz"
@@ -20,8 +25,22 @@ type SchedulerService struct {
 	logger        *zap.Logger
 	cron          *cron.Cron
 	repo          *repo.ScheduledTaskRepo
+	runRepo       *repo.ScheduledTaskRunRepo
+	contactRepo   *repo.ContactRepo
+	httpClient    *http.Client
 	serialService *SerialService
 	deviceManager *DeviceManager
+
+	// 连续失败告警，未设置时只记录日志
+	notifier        *Notifier
+	propertyService *PropertyService
+
+	// 调度触发前检查是否跳过本次执行，返回跳过原因
+	taskSkipper func(ctx context.Context, task models.ScheduledTask) string
+
+	mu      sync.Mutex
+	entries map[string]cron.EntryID // 任务ID -> cron 条目
+	running atomic.Bool             // 调度是否在运行
 }
 
 // NewSchedulerService 创建定时任务服务实例
@@ -34,16 +53,38 @@ func NewSchedulerService(
 	return &SchedulerService{
 		logger:        logger,
 		repo:          repo.NewScheduledTaskRepo(db),
+		runRepo:       repo.NewScheduledTaskRunRepo(db),
+		contactRepo:   repo.NewContactRepo(db),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
 		serialService: serialService,
 		deviceManager: deviceManager,
+		entries:       make(map[string]cron.EntryID),
 	}
 }
 
+// SetNotifier 设置连续失败告警使用的通知服务
+func (s *SchedulerService) SetNotifier(notifier *Notifier, propertyService *PropertyService) {
+	s.notifier = notifier
+	s.propertyService = propertyService
+}
+
+// SetTaskSkipper 设置调度触发前的跳过检查，手动触发不受影响
+func (s *SchedulerService) SetTaskSkipper(skipper func(ctx context.Context, task models.ScheduledTask) string) {
+	s.taskSkipper = skipper
+}
+
 // ==================== 任务管理方法 ====================
 
-// GetAll 获取所有定时任务
+// GetAll 获取 ctx 设备范围内的所有定时任务
 func (s *SchedulerService) GetAll(ctx context.Context) ([]models.ScheduledTask, error) {
-	return s.repo.FindAll(ctx)
+	tasks, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		tasks[i].NextRunAt = s.nextRunAt(tasks[i].ID)
+	}
+	return tasks, nil
 }
 
 // GetAllEnabled 获取所有启用的定时任务
@@ -51,42 +92,86 @@ func (s *SchedulerService) GetAllEnabled(ctx context.Context) ([]models.Schedule
 	return s.repo.FindAllEnabled(ctx)
 }
 
-// GetById 根据ID获取定时任务
+// GetById 根据ID获取定时任务，不在 ctx 设备范围内时返回 gorm.ErrRecordNotFound
 func (s *SchedulerService) GetById(ctx context.Context, id string) (*models.ScheduledTask, error) {
 	task, err := s.repo.FindById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	task.NextRunAt = s.nextRunAt(task.ID)
 	return &task, nil
 }
 
 // Create 创建定时任务
 func (s *SchedulerService) Create(ctx context.Context, task *models.ScheduledTask) error {
+	if err := checkTaskDevice(ctx, *task); err != nil {
+		return err
+	}
 	now := time.Now().UnixMilli()
 	task.ID = uuid.New().String()
 	task.CreatedAt = now
 	task.UpdatedAt = now
-	return s.repo.Create(ctx, task)
+	if err := s.repo.Create(ctx, task); err != nil {
+		return err
+	}
+	s.schedule(*task)
+	task.NextRunAt = s.nextRunAt(task.ID)
+	return nil
 }
 
 // Update 更新定时任务
 func (s *SchedulerService) Update(ctx context.Context, task *models.ScheduledTask) error {
+	if err := checkTaskDevice(ctx, *task); err != nil {
+		return err
+	}
 	existingTask, err := s.GetById(ctx, task.ID)
 	if err != nil {
 		return err
 	}
 	existingTask.Name = task.Name
 	existingTask.Enabled = task.Enabled
+	existingTask.DeviceID = task.DeviceID
 	existingTask.IntervalDays = task.IntervalDays
 	existingTask.PhoneNumber = task.PhoneNumber
 	existingTask.Content = task.Content
-
-	return s.repo.Save(ctx, existingTask)
+	existingTask.ScheduleType = task.ScheduleType
+	existingTask.CronExpr = task.CronExpr
+	existingTask.Timezone = task.Timezone
+	existingTask.IntervalValue = task.IntervalValue
+	existingTask.IntervalUnit = task.IntervalUnit
+	existingTask.RunAt = task.RunAt
+	existingTask.MonthWeek = task.MonthWeek
+	existingTask.Weekday = task.Weekday
+	existingTask.TimeOfDay = task.TimeOfDay
+	existingTask.FailureAlertThreshold = task.FailureAlertThreshold
+	existingTask.ActionType = task.ActionType
+	existingTask.Recipients = task.Recipients
+	existingTask.ContactGroup = task.ContactGroup
+	existingTask.Flymode = task.Flymode
+	existingTask.UssdCode = task.UssdCode
+	existingTask.WebhookURL = task.WebhookURL
+	existingTask.WebhookMethod = task.WebhookMethod
+	existingTask.WebhookBody = task.WebhookBody
+
+	if err := s.repo.Save(ctx, existingTask); err != nil {
+		return err
+	}
+	s.schedule(*existingTask)
+	existingTask.NextRunAt = s.nextRunAt(existingTask.ID)
+	*task = *existingTask
+	return nil
 }
 
 // Delete 删除定时任务
 func (s *SchedulerService) Delete(ctx context.Context, id string) error {
-	return s.repo.DeleteById(ctx, id)
+	if _, err := s.repo.FindById(ctx, id); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteById(ctx, id); err != nil {
+		return err
+	}
+	s.unschedule(id)
+	return s.runRepo.DeleteByTask(ctx, id)
 }
 
 // TriggerTask 立即触发执行指定的任务
@@ -98,155 +183,210 @@ func (s *SchedulerService) TriggerTask(ctx context.Context, id string) error {
 	}
 
 	// 执行任务
-	if err := s.executeTask(*task); err != nil {
-		return fmt.Errorf("执行任务失败: %w", err)
+	err = s.executeTask(*task, models.TaskRunTriggerManual)
+
+	// 固定间隔从本次执行时间重新计算
+	if latest, findErr := s.repo.FindById(ctx, id); findErr == nil {
+		s.schedule(latest)
 	}
 
+	if err != nil {
+		return fmt.Errorf("执行任务失败: %w", err)
+	}
 	return nil
 }
 
 // ==================== 调度相关方法 ====================
 
-// Start 启动定时任务服务
+// Start 启动定时任务服务，为每个启用的任务注册独立的调度
 func (s *SchedulerService) Start(ctx context.Context) error {
-	s.cron = cron.New()
-
-	// 添加每天执行一次的检查任务（每天早上8点执行）
-	_, err := s.cron.AddFunc("0 8 * * *", func() {
-		s.logger.Info("开始检查定时任务")
-		if err := s.checkAndExecuteTasks(); err != nil {
-			s.logger.Error("检查并执行定时任务失败", zap.Error(err))
-		}
-	})
+	tasks, err := s.GetAllEnabled(ctx)
 	if err != nil {
-		return fmt.Errorf("添加检查任务失败: %w", err)
+		return fmt.Errorf("获取启用的定时任务失败: %w", err)
+	}
+
+	s.mu.Lock()
+	s.cron = cron.New()
+	s.mu.Unlock()
+	for _, task := range tasks {
+		s.schedule(task)
 	}
 
 	// 启动 cron
 	s.cron.Start()
+	s.running.Store(true)
 
-	s.logger.Info("定时任务服务启动成功")
+	s.logger.Info("定时任务服务启动成功", zap.Int("tasks", len(tasks)))
 	return nil
 }
 
+// Running 定时任务调度是否在运行
+func (s *SchedulerService) Running() bool {
+	return s.running.Load()
+}
+
 // Stop 停止定时任务服务
 func (s *SchedulerService) Stop() {
 	if s.cron != nil {
+		s.running.Store(false)
 		s.cron.Stop()
 		s.logger.Info("定时任务服务已停止")
 	}
 }
 
-// checkAndExecuteTasks 检查并执行满足条件的任务
-func (s *SchedulerService) checkAndExecuteTasks() error {
-	ctx := context.Background()
-
-	// 获取所有启用的任务
-	tasks, err := s.GetAllEnabled(ctx)
-	if err != nil {
-		s.logger.Error("获取启用的定时任务失败", zap.Error(err))
-		return err
+// schedule 注册或替换任务的调度，任务停用或已无后续执行时只移除旧调度
+// 跳过检查在每次触发时由 runScheduledTask 执行，这里不检查，避免任务被移出调度后不再执行
+func (s *SchedulerService) schedule(task models.ScheduledTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cron == nil {
+		return
+	}
+	if entryID, ok := s.entries[task.ID]; ok {
+		s.cron.Remove(entryID)
+		delete(s.entries, task.ID)
+	}
+	if !task.Enabled {
+		return
 	}
 
-	now := time.Now()
-	for _, task := range tasks {
-		// 检查是否需要执行
-		if s.shouldExecuteTask(task, now) {
-			s.logger.Info("任务满足执行条件",
-				zap.String("id", task.ID),
-				zap.String("name", task.Name),
-				zap.Int("intervalDays", task.IntervalDays))
-
-			if err := s.executeTask(task); err != nil {
-				s.logger.Error("执行定时任务失败",
-					zap.String("id", task.ID),
-					zap.String("name", task.Name),
-					zap.Error(err))
-			}
-		}
+	// 兼容只有 intervalDays 的旧数据
+	if err := NormalizeSchedule(&task); err != nil {
+		s.logger.Error("定时任务调度配置无效", zap.String("id", task.ID), zap.String("name", task.Name), zap.Error(err))
+		return
+	}
+	schedule, _ := newTaskSchedule(task)
+	if schedule == nil {
+		return
 	}
 
-	return nil
+	id := task.ID
+	job := cron.NewChain(cron.SkipIfStillRunning(cron.DiscardLogger)).Then(cron.FuncJob(func() {
+		s.runScheduledTask(id)
+	}))
+	s.entries[id] = s.cron.Schedule(schedule, job)
 }
 
-// shouldExecuteTask 判断任务是否应该执行
-func (s *SchedulerService) shouldExecuteTask(task models.ScheduledTask, now time.Time) bool {
-	// 如果从未执行过，则执行
-	if task.LastRunAt <= 0 {
-		return true
+// unschedule 移除任务的调度
+func (s *SchedulerService) unschedule(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entryID, ok := s.entries[id]; ok {
+		s.cron.Remove(entryID)
+		delete(s.entries, id)
 	}
+}
 
-	// 计算距离上次执行的天数
-	lastRun := time.UnixMilli(task.LastRunAt)
-	daysSinceLastRun := int(now.Sub(lastRun).Hours() / 24)
+// nextRunAt 获取任务下次执行时间（时间戳毫秒），没有调度时返回 0
+func (s *SchedulerService) nextRunAt(id string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entryID, ok := s.entries[id]
+	if !ok {
+		return 0
+	}
+	next := s.cron.Entry(entryID).Next
+	if next.IsZero() {
+		return 0
+	}
+	return next.UnixMilli()
+}
 
-	// 如果上次执行失败，1天后就可以重试
-	if task.LastRunStatus == models.LastRunStatusFailed {
-		return daysSinceLastRun >= 1
+// runScheduledTask 调度触发时执行任务，执行前重新读取任务以使用最新配置
+func (s *SchedulerService) runScheduledTask(id string) {
+	task, err := s.repo.FindById(context.Background(), id)
+	if err != nil {
+		s.logger.Error("获取定时任务失败", zap.String("id", id), zap.Error(err))
+		return
+	}
+	if !task.Enabled {
+		return
+	}
+	if s.taskSkipper != nil {
+		if reason := s.taskSkipper(context.Background(), task); reason != "" {
+			metrics.SchedulerRuns.WithLabelValues(string(taskAction(task)), string(models.TaskRunTriggerScheduled), "skipped").Inc()
+			s.logger.Info("跳过定时任务",
+				zap.String("id", task.ID),
+				zap.String("name", task.Name),
+				zap.String("reason", reason))
+			return
+		}
 	}
 
-	// 如果满足间隔天数条件，则执行
-	return daysSinceLastRun >= task.IntervalDays
+	s.logger.Info("任务满足执行条件",
+		zap.String("id", task.ID),
+		zap.String("name", task.Name),
+		zap.String("scheduleType", string(task.ScheduleType)))
+
+	if err := s.executeTask(task, models.TaskRunTriggerScheduled); err != nil {
+		s.logger.Error("执行定时任务失败",
+			zap.String("id", task.ID),
+			zap.String("name", task.Name),
+			zap.Error(err))
+	}
 }
 
-// executeTask 执行任务
-func (s *SchedulerService) executeTask(task models.ScheduledTask) error {
+// executeTask 按动作类型执行任务并记录执行结果
+func (s *SchedulerService) executeTask(task models.ScheduledTask, trigger models.TaskRunTrigger) error {
 	s.logger.Info("执行定时任务",
 		zap.String("id", task.ID),
 		zap.String("name", task.Name),
-		zap.String("phone", task.PhoneNumber),
-		zap.String("content", task.Content),
+		zap.String("trigger", string(trigger)),
+		zap.String("action", string(taskAction(task))),
 		zap.String("deviceId", task.DeviceID))
 
 	ctx := context.Background()
-
-	var msgId string
-	var err error
-
-	// 如果指定了设备ID，使用设备管理器发送
-	if task.DeviceID != "" && s.deviceManager != nil {
-		msgId, err = s.deviceManager.SendSMSByDevice(task.DeviceID, task.PhoneNumber, task.Content)
-	} else if s.deviceManager != nil && s.deviceManager.GetOnlineDeviceCount() > 0 {
-		// 使用设备管理器自动选择设备发送
-		msgId, _, err = s.deviceManager.SendSMS(task.PhoneNumber, task.Content, StrategyAuto)
+	if count, err := s.repo.IncrementRunCount(ctx, task.ID); err != nil {
+		s.logger.Warn("更新定时任务执行次数失败", zap.String("id", task.ID), zap.Error(err))
 	} else {
-		// 回退到单设备模式
-		flyMode := s.serialService.FlyMode()
-		// 如果是飞行模式，取消飞行模式，再等待 30 秒后发送短信
-		if flyMode {
-			s.logger.Info("当前为飞行模式，取消飞行模式后等待 30 秒")
-			// 取消飞行模式
-			if err := s.serialService.SetFlymode(false); err != nil {
-				s.logger.Error("取消飞行模式失败", zap.Error(err))
-				return err
-			}
-			s.logger.Info("取消飞行模式成功")
-			// 等待 30 秒
-			time.Sleep(30 * time.Second)
-			s.logger.Info("等待 30 秒后发送短信")
-		}
+		task.RunCount = count
+	}
 
-		// 发送短信
-		msgId, err = s.serialService.SendSMS(task.PhoneNumber, task.Content)
+	runs := s.runAction(ctx, task)
+
+	var errs []error
+	var lastMsgId string
+	for _, run := range runs {
+		run.ID = uuid.New().String()
+		run.TaskID = task.ID
+		run.Execution = task.RunCount
+		run.Trigger = trigger
+		run.ActionType = taskAction(task)
+		if createErr := s.runRepo.Create(ctx, run); createErr != nil {
+			s.logger.Warn("保存定时任务执行记录失败", zap.String("id", task.ID), zap.Error(createErr))
+		}
+		if run.Status == models.LastRunStatusFailed {
+			errs = append(errs, errors.New(run.Error))
+		}
+		if run.MsgID != "" {
+			lastMsgId = run.MsgID
+		}
 	}
 
-	if err != nil {
-		s.logger.Error("定时任务发送短信失败",
+	if err := errors.Join(errs...); err != nil {
+		metrics.SchedulerRuns.WithLabelValues(string(taskAction(task)), string(trigger), "failed").Inc()
+		s.logger.Error("定时任务执行失败",
 			zap.String("id", task.ID),
 			zap.String("name", task.Name),
 			zap.Error(err))
-		if updateErr := s.UpdateLastRun(ctx, task.ID, msgId, models.LastRunStatusFailed); updateErr != nil {
+		if updateErr := s.UpdateLastRun(ctx, task.ID, lastMsgId, models.LastRunStatusFailed); updateErr != nil {
 			s.logger.Warn("更新定时任务状态失败", zap.String("id", task.ID), zap.Error(updateErr))
 		}
+		s.checkConsecutiveFailures(ctx, task.ID)
 		return err
 	}
 
+	metrics.SchedulerRuns.WithLabelValues(string(taskAction(task)), string(trigger), "success").Inc()
 	s.logger.Info("定时任务执行成功",
 		zap.String("id", task.ID),
 		zap.String("name", task.Name))
 
-	// 更新任务的 LastRunAt 字段到数据库
-	if err := s.UpdateLastRun(ctx, task.ID, msgId, models.LastRunStatusUnknown); err != nil {
+	// 短信等待回执更新最终状态，其他动作执行完即成功
+	status := models.LastRunStatusSuccess
+	if taskAction(task) == models.TaskActionSMS {
+		status = models.LastRunStatusUnknown
+	}
+	if err := s.UpdateLastRun(ctx, task.ID, lastMsgId, status); err != nil {
 		s.logger.Warn("更新定时任务状态失败", zap.String("id", task.ID), zap.Error(err))
 	}
 
@@ -261,6 +401,96 @@ func (s *SchedulerService) UpdateLastRun(ctx context.Context, id, msgId string,
 	})
 }
 
+// UpdateLastRunStatusByMsgId 根据短信回执更新任务和执行记录的最终状态
 func (s *SchedulerService) UpdateLastRunStatusByMsgId(ctx context.Context, msgId string, status models.LastRunStatus) error {
-	return s.repo.UpdateLastRunStatusByMsgId(ctx, msgId, status)
+	if err := s.repo.UpdateLastRunStatusByMsgId(ctx, msgId, status); err != nil {
+		return err
+	}
+	run, err := s.runRepo.UpdateStatusByMsgId(ctx, msgId, status)
+	if err != nil || run.ID == "" || run.Execution == 0 {
+		return err
+	}
+	if status != models.LastRunStatusFailed || run.Status == models.LastRunStatusFailed {
+		return nil
+	}
+	// 同一次执行已有失败记录时已经统计过，多个收件人的失败回执不重复告警
+	failed, err := s.runRepo.CountFailedInExecution(ctx, run.TaskID, run.Execution)
+	if err != nil {
+		return err
+	}
+	if failed == 1 {
+		s.checkConsecutiveFailures(ctx, run.TaskID)
+	}
+	return nil
+}
+
+// GetRuns 按时间倒序游标分页获取任务的执行记录
+func (s *SchedulerService) GetRuns(ctx context.Context, taskID, cursor string, limit int) (*Page[models.ScheduledTaskRun], error) {
+	if _, err := s.repo.FindById(ctx, taskID); err != nil {
+		return nil, err
+	}
+	after, err := repo.DecodeCursor(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	limit = normalizePageLimit(limit)
+
+	runs, err := s.runRepo.FindByTask(ctx, taskID, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("获取执行记录失败: %w", err)
+	}
+
+	page := &Page[models.ScheduledTaskRun]{Items: runs}
+	if len(runs) > limit {
+		page.Items = runs[:limit]
+		last := runs[limit-1]
+		page.NextCursor = repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	if page.Items == nil {
+		page.Items = []models.ScheduledTaskRun{}
+	}
+	return page, nil
+}
+
+// checkConsecutiveFailures 连续失败的执行次数刚好达到任务的告警阈值时发送通知，之后继续失败不重复告警
+// 按执行统计，一次执行向多个收件人发送时只算一次
+func (s *SchedulerService) checkConsecutiveFailures(ctx context.Context, taskID string) {
+	task, err := s.repo.FindById(ctx, taskID)
+	if err != nil || task.FailureAlertThreshold <= 0 {
+		return
+	}
+	executions, err := s.runRepo.FindRecentExecutionFailures(ctx, taskID, task.FailureAlertThreshold+1)
+	if err != nil {
+		s.logger.Warn("获取定时任务执行记录失败", zap.String("id", taskID), zap.Error(err))
+		return
+	}
+	failures := 0
+	for _, failed := range executions {
+		if !failed {
+			break
+		}
+		failures++
+	}
+	if failures != task.FailureAlertThreshold {
+		return
+	}
+
+	s.logger.Warn("定时任务连续执行失败",
+		zap.String("id", task.ID),
+		zap.String("name", task.Name),
+		zap.Int("failures", failures))
+	if s.notifier == nil || s.propertyService == nil {
+		return
+	}
+	channels, err := s.propertyService.GetNotificationChannelConfigs(ctx)
+	if err != nil {
+		s.logger.Error("获取通知渠道配置失败", zap.Error(err))
+		return
+	}
+	go s.notifier.NotifyChannels(context.Background(), channels, NotificationMessage{
+		Type:      "sms",
+		From:      "定时任务",
+		Content:   fmt.Sprintf("定时任务「%s」已连续 %d 次执行失败", task.Name, failures),
+		Timestamp: time.Now().Unix(),
+	})
 }
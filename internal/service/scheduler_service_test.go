@@ -2,9 +2,17 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/Starktomy/smshub/config"
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/repo"
 	"go.uber.org/zap"
@@ -42,6 +50,7 @@ func TestSchedulerService_CRUD(t *testing.T) {
 
 	// 3. Update
 	task.Name = "Updated Task"
+	task.DeviceID = "device-1"
 	if err := svc.Update(ctx, task); err != nil {
 		t.Fatalf("Update failed: %v", err)
 	}
@@ -49,6 +58,9 @@ func TestSchedulerService_CRUD(t *testing.T) {
 	if updated.Name != "Updated Task" {
 		t.Errorf("Expected Name 'Updated Task', got %s", updated.Name)
 	}
+	if updated.DeviceID != "device-1" {
+		t.Errorf("Expected DeviceID 'device-1', got %s", updated.DeviceID)
+	}
 
 	// 4. GetAll
 	tasks, err := svc.GetAll(ctx)
@@ -69,55 +81,240 @@ func TestSchedulerService_CRUD(t *testing.T) {
 	}
 }
 
-func TestSchedulerService_ShouldExecuteTask(t *testing.T) {
-	svc := &SchedulerService{}
-	now := time.Now()
+func TestNormalizeSchedule(t *testing.T) {
+	// 旧版本请求只有 intervalDays
+	task := models.ScheduledTask{IntervalDays: 90}
+	if err := NormalizeSchedule(&task); err != nil {
+		t.Fatalf("NormalizeSchedule failed: %v", err)
+	}
+	if task.ScheduleType != models.ScheduleTypeInterval || task.IntervalValue != 90 || task.IntervalUnit != models.IntervalUnitDays {
+		t.Errorf("Expected interval 90 days, got %s %d %s", task.ScheduleType, task.IntervalValue, task.IntervalUnit)
+	}
+
+	invalid := []models.ScheduledTask{
+		{},
+		{ScheduleType: models.ScheduleTypeCron, CronExpr: "* *"},
+		{ScheduleType: models.ScheduleTypeCron, CronExpr: "0 8 * * *", Timezone: "Mars/Base"},
+		{ScheduleType: models.ScheduleTypeInterval, IntervalValue: 0, IntervalUnit: models.IntervalUnitHours},
+		{ScheduleType: models.ScheduleTypeInterval, IntervalValue: 1, IntervalUnit: "weeks"},
+		{ScheduleType: models.ScheduleTypeOnce},
+		{ScheduleType: models.ScheduleTypeMonthWeekday, MonthWeek: 6, TimeOfDay: "08:00"},
+		{ScheduleType: models.ScheduleTypeMonthWeekday, MonthWeek: 1, Weekday: 7, TimeOfDay: "08:00"},
+		{ScheduleType: models.ScheduleTypeMonthWeekday, MonthWeek: 1, TimeOfDay: "8am"},
+	}
+	for _, task := range invalid {
+		if err := NormalizeSchedule(&task); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Expected ErrInvalidSchedule for %+v, got %v", task, err)
+		}
+	}
+}
+
+func TestTaskSchedule_Next(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	// cron 表达式按指定时区计算
+	schedule, err := newTaskSchedule(models.ScheduledTask{ScheduleType: models.ScheduleTypeCron, CronExpr: "0 8 * * *", Timezone: "Asia/Shanghai"})
+	if err != nil {
+		t.Fatalf("newTaskSchedule failed: %v", err)
+	}
+	if got, want := schedule.Next(now), time.Date(2024, 1, 11, 8, 0, 0, 0, shanghai); !got.Equal(want) {
+		t.Errorf("cron: expected %v, got %v", want, got)
+	}
+
+	// 固定间隔：已错过立即执行，之后顺延一个间隔
+	schedule, _ = newTaskSchedule(models.ScheduledTask{
+		ScheduleType:  models.ScheduleTypeInterval,
+		IntervalValue: 30,
+		IntervalUnit:  models.IntervalUnitMinutes,
+		LastRunAt:     now.Add(-time.Hour).UnixMilli(),
+	})
+	if got := schedule.Next(now); !got.Equal(now) {
+		t.Errorf("interval: expected overdue task to run now, got %v", got)
+	}
+	if got := schedule.Next(now); !got.Equal(now.Add(30 * time.Minute)) {
+		t.Errorf("interval: expected next run after 30 minutes, got %v", got)
+	}
+	schedule, _ = newTaskSchedule(models.ScheduledTask{
+		ScheduleType:  models.ScheduleTypeInterval,
+		IntervalValue: 2,
+		IntervalUnit:  models.IntervalUnitHours,
+		CreatedAt:     now.Add(-time.Hour).UnixMilli(),
+	})
+	if got := schedule.Next(now); !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("interval: expected first run 2 hours after creation, got %v", got)
+	}
+
+	// 一次性任务只触发一次，执行过后不再调度
+	runAt := now.Add(time.Hour)
+	schedule, _ = newTaskSchedule(models.ScheduledTask{ScheduleType: models.ScheduleTypeOnce, RunAt: runAt.UnixMilli()})
+	if got := schedule.Next(now); !got.Equal(runAt) {
+		t.Errorf("once: expected %v, got %v", runAt, got)
+	}
+	if got := schedule.Next(runAt); !got.IsZero() {
+		t.Errorf("once: expected no further runs, got %v", got)
+	}
+	if schedule, _ := newTaskSchedule(models.ScheduledTask{ScheduleType: models.ScheduleTypeOnce, RunAt: runAt.UnixMilli(), LastRunAt: runAt.UnixMilli()}); schedule != nil {
+		t.Error("once: expected no schedule after task has run")
+	}
 
+	// 每月第 N 个星期几
 	tests := []struct {
-		name     string
-		task     models.ScheduledTask
-		expected bool
+		week    int
+		weekday time.Weekday
+		want    time.Time
 	}{
-		{
-			name: "Never run before",
-			task: models.ScheduledTask{
-				LastRunAt: 0,
-			},
-			expected: true,
-		},
-		{
-			name: "Interval met",
-			task: models.ScheduledTask{
-				LastRunAt:    now.AddDate(0, 0, -2).UnixMilli(), // 2 days ago
-				IntervalDays: 1,
-			},
-			expected: true,
-		},
-		{
-			name: "Interval not met",
-			task: models.ScheduledTask{
-				LastRunAt:    now.AddDate(0, 0, -1).UnixMilli(), // 1 day ago
-				IntervalDays: 2,
-			},
-			expected: false,
-		},
-		{
-			name: "Failed retry after 1 day",
-			task: models.ScheduledTask{
-				LastRunAt:     now.AddDate(0, 0, -1).UnixMilli(), // 1 day ago
-				IntervalDays:  7,
-				LastRunStatus: models.LastRunStatusFailed,
-			},
-			expected: true,
-		},
+		{1, time.Monday, time.Date(2024, 2, 5, 9, 30, 0, 0, shanghai)},      // 1 月第一个周一已过
+		{3, time.Monday, time.Date(2024, 1, 15, 9, 30, 0, 0, shanghai)},     // 本月
+		{-1, time.Wednesday, time.Date(2024, 1, 31, 9, 30, 0, 0, shanghai)}, // 最后一个周三
+		{5, time.Thursday, time.Date(2024, 2, 29, 9, 30, 0, 0, shanghai)},   // 1 月只有 4 个周四之后的下一个
 	}
-
 	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := svc.shouldExecuteTask(tt.task, now); got != tt.expected {
-				t.Errorf("shouldExecuteTask() = %v, want %v", got, tt.expected)
-			}
+		schedule, err := newTaskSchedule(models.ScheduledTask{
+			ScheduleType: models.ScheduleTypeMonthWeekday,
+			MonthWeek:    tt.week,
+			Weekday:      int(tt.weekday),
+			TimeOfDay:    "09:30",
+			Timezone:     "Asia/Shanghai",
 		})
+		if err != nil {
+			t.Fatalf("newTaskSchedule failed: %v", err)
+		}
+		if got := schedule.Next(now); !got.Equal(tt.want) {
+			t.Errorf("month_weekday %d %s: expected %v, got %v", tt.week, tt.weekday, tt.want, got)
+		}
+	}
+}
+
+func TestSchedulerService_Schedule(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewSchedulerService(zap.NewNop(), db, nil, nil)
+	ctx := context.Background()
+
+	existing := &models.ScheduledTask{Name: "Existing", Enabled: true, IntervalDays: 1, PhoneNumber: "10086", Content: "hi"}
+	if err := svc.Create(ctx, existing); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if existing.NextRunAt != 0 {
+		t.Error("Expected no nextRunAt before scheduler starts")
+	}
+
+	if err := svc.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer svc.Stop()
+
+	fetched, _ := svc.GetById(ctx, existing.ID)
+	if want := time.UnixMilli(existing.CreatedAt).Add(24 * time.Hour).UnixMilli(); fetched.NextRunAt != want {
+		t.Errorf("Expected nextRunAt %d, got %d", want, fetched.NextRunAt)
+	}
+
+	// 新建任务立即注册
+	task := &models.ScheduledTask{
+		Name:         "Cron",
+		Enabled:      true,
+		ScheduleType: models.ScheduleTypeCron,
+		CronExpr:     "0 0 1 1 *",
+		PhoneNumber:  "10086",
+		Content:      "hi",
+	}
+	if err := svc.Create(ctx, task); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if task.NextRunAt <= time.Now().UnixMilli() {
+		t.Errorf("Expected nextRunAt in the future, got %d", task.NextRunAt)
+	}
+
+	// 修改调度后替换原有条目
+	task.CronExpr = "*/5 * * * *"
+	if err := svc.Update(ctx, task); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if task.NextRunAt > time.Now().Add(5*time.Minute).UnixMilli() {
+		t.Errorf("Expected nextRunAt within 5 minutes, got %d", task.NextRunAt)
+	}
+	if entries := len(svc.cron.Entries()); entries != 2 {
+		t.Errorf("Expected 2 cron entries, got %d", entries)
+	}
+
+	// 停用后移除调度
+	task.Enabled = false
+	if err := svc.Update(ctx, task); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if task.NextRunAt != 0 {
+		t.Errorf("Expected no nextRunAt for disabled task, got %d", task.NextRunAt)
+	}
+
+	if err := svc.Delete(ctx, existing.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if entries := len(svc.cron.Entries()); entries != 0 {
+		t.Errorf("Expected no cron entries, got %d", entries)
+	}
+}
+
+func TestSchedulerService_SkipperKeepsSchedule(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewSchedulerService(zap.NewNop(), db, nil, nil)
+	ctx := context.Background()
+
+	// 模拟保号任务的设备最近有活动，每次检查都跳过
+	var checks atomic.Int32
+	svc.SetTaskSkipper(func(ctx context.Context, task models.ScheduledTask) string {
+		checks.Add(1)
+		return "最近有活动"
+	})
+
+	task := &models.ScheduledTask{
+		Name:         "保号",
+		Enabled:      true,
+		ScheduleType: models.ScheduleTypeCron,
+		CronExpr:     "0 10 * * *",
+		PhoneNumber:  "10086",
+		Content:      "hi",
+	}
+	if err := svc.Create(ctx, task); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := svc.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer svc.Stop()
+
+	// 启动和修改时不检查，任务保持注册
+	fetched, err := svc.GetById(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("GetById failed: %v", err)
+	}
+	if fetched.NextRunAt == 0 {
+		t.Error("Expected task to stay scheduled after Start")
+	}
+	task.CronExpr = "0 11 * * *"
+	if err := svc.Update(ctx, task); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if task.NextRunAt == 0 {
+		t.Error("Expected task to stay scheduled after Update")
+	}
+	if checks.Load() != 0 {
+		t.Errorf("Expected no skip checks while scheduling, got %d", checks.Load())
+	}
+
+	// 触发时跳过，不执行也不移除调度
+	svc.runScheduledTask(task.ID)
+	if checks.Load() != 1 {
+		t.Errorf("Expected 1 skip check at fire time, got %d", checks.Load())
+	}
+	runs, err := svc.GetRuns(ctx, task.ID, "", 10)
+	if err != nil {
+		t.Fatalf("GetRuns failed: %v", err)
+	}
+	if len(runs.Items) != 0 {
+		t.Errorf("Expected skipped task not to run, got %d runs", len(runs.Items))
+	}
+	if next := svc.nextRunAt(task.ID); next == 0 {
+		t.Error("Expected task to stay scheduled after being skipped")
 	}
 }
 
@@ -162,3 +359,246 @@ func TestSchedulerService_UpdateLastRun(t *testing.T) {
 		t.Errorf("Expected LastMsgId 'msg-123', got %s", updated.LastMsgId)
 	}
 }
+
+func TestSchedulerService_RunHistory(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	var alerts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alerts.Add(1)
+	}))
+	defer server.Close()
+
+	propertyService := NewPropertyService(zap.NewNop(), db)
+	propertyService.Set(ctx, PropertyIDNotificationChannels, "通知渠道", []models.NotificationChannelConfig{
+		{Type: "webhook", Enabled: true, Config: map[string]interface{}{"url": server.URL, "body": `{"text":"{{content}}"}`}},
+	})
+
+	// 没有可用设备，每次执行都失败
+	svc := NewSchedulerService(zap.NewNop(), db, nil, nil)
+	svc.SetNotifier(NewNotifier(zap.NewNop()), propertyService)
+	task := &models.ScheduledTask{Name: "Keep alive", Enabled: true, IntervalDays: 30, PhoneNumber: "10086", Content: "hi", FailureAlertThreshold: 2}
+	if err := svc.Create(ctx, task); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := svc.TriggerTask(ctx, task.ID); err == nil {
+			t.Fatal("Expected trigger to fail without devices")
+		}
+	}
+
+	page, err := svc.GetRuns(ctx, task.ID, "", 2)
+	if err != nil {
+		t.Fatalf("GetRuns failed: %v", err)
+	}
+	if len(page.Items) != 2 || page.NextCursor == "" {
+		t.Fatalf("Expected 2 runs with next cursor, got %d %q", len(page.Items), page.NextCursor)
+	}
+	run := page.Items[0]
+	if run.Trigger != models.TaskRunTriggerManual || run.Status != models.LastRunStatusFailed || run.Error == "" {
+		t.Errorf("Unexpected run: %+v", run)
+	}
+	page, _ = svc.GetRuns(ctx, task.ID, page.NextCursor, 2)
+	if len(page.Items) != 1 || page.NextCursor != "" {
+		t.Errorf("Expected 1 remaining run, got %d %q", len(page.Items), page.NextCursor)
+	}
+	if _, err := svc.GetRuns(ctx, task.ID, "bad", 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+
+	// 第 2 次失败时告警，第 3 次不重复告警
+	time.Sleep(200 * time.Millisecond)
+	if got := alerts.Load(); got != 1 {
+		t.Errorf("Expected 1 alert, got %d", got)
+	}
+
+	// 短信回执更新执行记录的最终状态
+	db.Create(&models.ScheduledTaskRun{ID: "run-1", TaskID: task.ID, MsgID: "msg-1", Status: models.LastRunStatusUnknown, CreatedAt: time.Now().UnixMilli() + 1000})
+	if err := svc.UpdateLastRunStatusByMsgId(ctx, "msg-1", models.LastRunStatusSuccess); err != nil {
+		t.Fatalf("UpdateLastRunStatusByMsgId failed: %v", err)
+	}
+	page, _ = svc.GetRuns(ctx, task.ID, "", 1)
+	if page.Items[0].ID != "run-1" || page.Items[0].Status != models.LastRunStatusSuccess {
+		t.Errorf("Expected run-1 to be success, got %+v", page.Items[0])
+	}
+
+	// 删除任务时删除执行记录
+	svc.Delete(ctx, task.ID)
+	var count int64
+	db.Model(&models.ScheduledTaskRun{}).Where("task_id = ?", task.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected runs to be deleted, got %d", count)
+	}
+}
+
+func TestSchedulerService_FailureAlertPerExecution(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+
+	var alerts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alerts.Add(1)
+	}))
+	defer server.Close()
+
+	propertyService := NewPropertyService(zap.NewNop(), db)
+	propertyService.Set(ctx, PropertyIDNotificationChannels, "通知渠道", []models.NotificationChannelConfig{
+		{Type: "webhook", Enabled: true, Config: map[string]interface{}{"url": server.URL, "body": `{"text":"{{content}}"}`}},
+	})
+
+	// 单设备模式，串口写入失败时每个收件人都发送失败
+	var writeFails atomic.Bool
+	textMsgService := NewTextMessageService(zap.NewNop(), repo.NewTextMessageRepo(db), repo.NewConversationStateRepo(db))
+	serialService := NewSerialService(zap.NewNop(), config.SerialConfig{}, textMsgService, nil, nil)
+	serialService.port = &mockSerialPort{writeFunc: func(p []byte) (int, error) {
+		if writeFails.Load() {
+			return 0, errors.New("write failed")
+		}
+		return len(p), nil
+	}}
+	svc := NewSchedulerService(zap.NewNop(), db, serialService, nil)
+	svc.SetNotifier(NewNotifier(zap.NewNop()), propertyService)
+
+	// 三个收件人，阈值 2：每次执行产生 3 条失败记录，但只算一次失败
+	writeFails.Store(true)
+	task := &models.ScheduledTask{Name: "群发", IntervalDays: 1, PhoneNumber: "10086", Recipients: []string{"10010", "10000"}, Content: "hi", FailureAlertThreshold: 2}
+	svc.Create(ctx, task)
+	svc.TriggerTask(ctx, task.ID)
+	time.Sleep(100 * time.Millisecond)
+	if got := alerts.Load(); got != 0 {
+		t.Fatalf("Expected no alert after the first failed execution, got %d", got)
+	}
+	svc.TriggerTask(ctx, task.ID)
+	svc.TriggerTask(ctx, task.ID)
+	time.Sleep(200 * time.Millisecond)
+	if got := alerts.Load(); got != 1 {
+		t.Fatalf("Expected 1 alert after 3 failed executions, got %d", got)
+	}
+	page, _ := svc.GetRuns(ctx, task.ID, "", 20)
+	if len(page.Items) != 9 || page.Items[0].Execution != 3 || page.Items[8].Execution != 1 {
+		t.Errorf("Expected 9 runs in 3 executions, got %+v", page.Items)
+	}
+
+	// 阈值 1：发送成功后三个收件人的失败回执只告警一次
+	writeFails.Store(false)
+	receipts := &models.ScheduledTask{Name: "回执", IntervalDays: 1, PhoneNumber: "10086", Recipients: []string{"10010", "10000"}, Content: "hi", FailureAlertThreshold: 1}
+	svc.Create(ctx, receipts)
+	if err := svc.TriggerTask(ctx, receipts.ID); err != nil {
+		t.Fatalf("TriggerTask failed: %v", err)
+	}
+	page, _ = svc.GetRuns(ctx, receipts.ID, "", 20)
+	if len(page.Items) != 3 {
+		t.Fatalf("Expected 3 runs, got %d", len(page.Items))
+	}
+	for _, run := range page.Items {
+		if err := svc.UpdateLastRunStatusByMsgId(ctx, run.MsgID, models.LastRunStatusFailed); err != nil {
+			t.Fatalf("UpdateLastRunStatusByMsgId failed: %v", err)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	if got := alerts.Load(); got != 2 {
+		t.Errorf("Expected 1 alert for the failed receipts, got %d", got-1)
+	}
+}
+
+func TestRenderTaskTemplate(t *testing.T) {
+	vars := map[string]string{"device": "设备1", "counter": "3", "phone": `"quoted"`}
+	if got := renderTaskTemplate("第{{counter}}次 {{ device }} {{unknown}}", vars, nil); got != "第3次 设备1 {{unknown}}" {
+		t.Errorf("Unexpected render result: %s", got)
+	}
+	escape := func(v string) string { return strings.ReplaceAll(v, `"`, `\"`) }
+	if got := renderTaskTemplate(`{"to":"{{phone}}"}`, vars, escape); got != `{"to":"\"quoted\""}` {
+		t.Errorf("Unexpected escaped result: %s", got)
+	}
+}
+
+func TestSchedulerService_Actions(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	textMsgService := NewTextMessageService(zap.NewNop(), repo.NewTextMessageRepo(db), repo.NewConversationStateRepo(db))
+
+	// 单设备模式，模拟串口：USSD 请求立即回复
+	serialService := NewSerialService(zap.NewNop(), config.SerialConfig{}, textMsgService, nil, nil)
+	serialService.port = &mockSerialPort{writeFunc: func(p []byte) (int, error) {
+		data := strings.TrimSuffix(strings.TrimPrefix(string(p), "CMD_START:"), ":CMD_END\r\n")
+		var cmd map[string]any
+		json.Unmarshal([]byte(data), &cmd)
+		if cmd["action"] == "send_ussd" {
+			go serialService.handleUSSDResult(&ParsedMessage{Payload: map[string]any{
+				"request_id": cmd["request_id"],
+				"success":    true,
+				"response":   "余额 10.00 元",
+			}})
+		}
+		return len(p), nil
+	}}
+	svc := NewSchedulerService(zap.NewNop(), db, serialService, nil)
+
+	db.Create(&models.Contact{ID: "c1", PhoneNumber: "10010", GroupName: "family"})
+	db.Create(&models.Contact{ID: "c2", PhoneNumber: "10086", GroupName: "family"})
+
+	// 多收件人，重复号码只发送一次
+	sms := &models.ScheduledTask{
+		Name:         "群发",
+		IntervalDays: 1,
+		PhoneNumber:  "10086",
+		Recipients:   []string{"10000", " "},
+		ContactGroup: "family",
+		Content:      "{{task}} 第{{counter}}次 {{phone}}",
+	}
+	svc.Create(ctx, sms)
+	if err := svc.TriggerTask(ctx, sms.ID); err != nil {
+		t.Fatalf("TriggerTask failed: %v", err)
+	}
+	var msgs []models.TextMessage
+	db.Order("to_number").Find(&msgs)
+	if len(msgs) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(msgs))
+	}
+	if msgs[0].To != "10000" || msgs[0].Content != "群发 第1次 10000" {
+		t.Errorf("Unexpected message: %s %s", msgs[0].To, msgs[0].Content)
+	}
+	page, _ := svc.GetRuns(ctx, sms.ID, "", 10)
+	if len(page.Items) != 3 || page.Items[0].Recipient == "" || page.Items[0].MsgID == "" {
+		t.Errorf("Expected a run per recipient, got %+v", page.Items)
+	}
+
+	// USSD 回复记录在执行输出中
+	ussd := &models.ScheduledTask{Name: "查余额", IntervalDays: 1, ActionType: models.TaskActionUSSD, UssdCode: "*100#"}
+	svc.Create(ctx, ussd)
+	if err := svc.TriggerTask(ctx, ussd.ID); err != nil {
+		t.Fatalf("TriggerTask failed: %v", err)
+	}
+	page, _ = svc.GetRuns(ctx, ussd.ID, "", 10)
+	if len(page.Items) != 1 || page.Items[0].Output != "余额 10.00 元" || page.Items[0].Status != models.LastRunStatusSuccess {
+		t.Errorf("Unexpected USSD run: %+v", page.Items)
+	}
+
+	// Webhook 请求体按模板渲染
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	webhook := &models.ScheduledTask{
+		Name:         `say "hi"`,
+		IntervalDays: 1,
+		ActionType:   models.TaskActionWebhook,
+		WebhookURL:   server.URL,
+		WebhookBody:  `{"task":"{{task}}","n":{{counter}}}`,
+	}
+	svc.Create(ctx, webhook)
+	if err := svc.TriggerTask(ctx, webhook.ID); err != nil {
+		t.Fatalf("TriggerTask failed: %v", err)
+	}
+	if body != `{"task":"say \"hi\"","n":1}` {
+		t.Errorf("Unexpected webhook body: %s", body)
+	}
+	page, _ = svc.GetRuns(ctx, webhook.ID, "", 10)
+	if page.Items[0].Output != "202 Accepted" {
+		t.Errorf("Unexpected webhook output: %s", page.Items[0].Output)
+	}
+}
@@ -30,6 +30,12 @@ func (s *SerialService) handleIncomingCall(msg *ParsedMessage) {
 	notificationCtx, notificationCancel := context.WithTimeout(context.Background(), defaultContextTimeout)
 	defer notificationCancel()
 
+	// 免打扰会话的来电同样不通知
+	if s.textMsgService != nil && s.textMsgService.IsConversationMuted(notificationCtx, call.From) {
+		s.logger.Info("会话已开启免打扰，跳过来电通知", zap.String("from", call.From))
+		return
+	}
+
 	notifMsg := NotificationMessage{
 		Type:      "call",
 		From:      call.From,
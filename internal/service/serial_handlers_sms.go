@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Starktomy/smshub/internal/metrics"
 	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -44,13 +48,19 @@ func (s *SerialService) handleIncomingSMS(msg *ParsedMessage) {
 		return
 	}
 
+	metrics.SMSReceived.WithLabelValues(s.metricsDevice()).Inc()
 	s.logger.Info("收到新短信",
 		zap.String("from", sms.From),
 		zap.String("content", sms.Content),
 		zap.Int64("timestamp", sms.Timestamp))
 
+	spanCtx, span := tracing.Tracer.Start(context.Background(), "sms.receive", trace.WithAttributes(
+		attribute.String("device.id", s.deviceID),
+	))
+	defer span.End()
+
 	// 保存短信记录 - 使用带超时的 context
-	ctx, cancel := context.WithTimeout(context.Background(), defaultContextTimeout)
+	ctx, cancel := context.WithTimeout(spanCtx, defaultContextTimeout)
 	defer cancel()
 
 	record := &models.TextMessage{
@@ -69,8 +79,14 @@ func (s *SerialService) handleIncomingSMS(msg *ParsedMessage) {
 		s.logger.Error("保存短信记录失败", zap.Error(err))
 	}
 
+	// 免打扰会话只记录不通知
+	if s.textMsgService.IsConversationMuted(ctx, sms.From) {
+		s.logger.Info("会话已开启免打扰，跳过通知", zap.String("from", sms.From))
+		return
+	}
+
 	// 异步发送通知 - 使用新的 context 避免被父 context 取消
-	notificationCtx, notificationCancel := context.WithTimeout(context.Background(), defaultContextTimeout)
+	notificationCtx, notificationCancel := context.WithTimeout(spanCtx, defaultContextTimeout)
 	defer notificationCancel()
 	go s.sendNotification(notificationCtx, sms)
 }
@@ -97,39 +113,7 @@ func (s *SerialService) sendNotificationMessage(ctx context.Context, msg Notific
 		return
 	}
 
-	// 格式化消息
-	message := msg.String()
-
-	// 发送到所有启用的渠道
-	for _, channel := range channels {
-		if !channel.Enabled {
-			continue
-		}
-
-		var sendErr error
-		switch channel.Type {
-		case "dingtalk":
-			sendErr = s.notifier.SendDingTalkByConfig(ctx, channel.Config, message)
-		case "wecom":
-			sendErr = s.notifier.SendWeComByConfig(ctx, channel.Config, message)
-		case "feishu":
-			sendErr = s.notifier.SendFeishuByConfig(ctx, channel.Config, message)
-		case "webhook":
-			sendErr = s.notifier.SendWebhookByConfig(ctx, channel.Config, msg)
-		case "email":
-			sendErr = s.notifier.SendEmail(ctx, channel.Config, msg)
-		case "telegram":
-			sendErr = s.notifier.sendTelegramByConfig(ctx, channel.Config, message)
-		}
-
-		if sendErr != nil {
-			s.logger.Error("发送通知失败",
-				zap.String("type", channel.Type),
-				zap.Error(sendErr))
-		} else {
-			s.logger.Info("通知发送成功", zap.String("type", channel.Type))
-		}
-	}
+	s.notifier.NotifyChannels(ctx, channels, msg)
 }
 
 // handleSMSSendResult 处理短信发送结果
@@ -137,14 +121,25 @@ func (s *SerialService) handleSMSSendResult(msg *ParsedMessage) {
 	success, _ := msg.Payload["success"].(bool)
 	to, _ := msg.Payload["to"].(string)
 	requestID, _ := msg.Payload["request_id"].(string)
+	sendSpan := s.observeSMSResult(requestID, success)
 
 	if requestID == "" {
 		s.logger.Warn("收到短信发送结果但缺少 request_id", zap.Any("msg", msg.Payload))
 		return
 	}
 
+	// 发送结果异步到达，单独生成 span 并关联到发送短信的 span
+	ctx, span := tracing.Tracer.Start(context.Background(), "sms.send_result",
+		trace.WithLinks(trace.Link{SpanContext: sendSpan}),
+		trace.WithAttributes(
+			attribute.String("device.id", s.deviceID),
+			attribute.String("sms.request_id", requestID),
+			attribute.Bool("sms.success", success),
+		))
+	defer span.End()
+
 	// 使用带超时的 context
-	ctx, cancel := context.WithTimeout(context.Background(), defaultContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, defaultContextTimeout)
 	defer cancel()
 
 	var status models.MessageStatus
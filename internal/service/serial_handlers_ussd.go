@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ussdTimeout 等待 USSD 回复的超时时间，运营商回复通常需要数秒
+const ussdTimeout = 60 * time.Second
+
+// ErrUSSDUnsupported 设备固件不支持 USSD
+var ErrUSSDUnsupported = errors.New("设备固件不支持 USSD")
+
+// ussdResult USSD 请求结果
+type ussdResult struct {
+	response string
+	err      error
+}
+
+// SendUSSD 发送 USSD 请求并等待运营商回复
+func (s *SerialService) SendUSSD(ctx context.Context, code string) (string, error) {
+	requestID := uuid.NewString()
+	ch := make(chan ussdResult, 1)
+	s.ussdPending.Store(requestID, ch)
+	defer s.ussdPending.Delete(requestID)
+
+	cmd := map[string]any{
+		"action":     "send_ussd",
+		"code":       code,
+		"request_id": requestID,
+	}
+	if err := s.sendJSONCommand(ctx, cmd); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ussdTimeout)
+	defer cancel()
+	select {
+	case result := <-ch:
+		return result.response, result.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("等待 USSD 回复超时")
+	}
+}
+
+// handleUSSDResult 处理 USSD 回复
+func (s *SerialService) handleUSSDResult(msg *ParsedMessage) {
+	requestID, _ := msg.Payload["request_id"].(string)
+	success, _ := msg.Payload["success"].(bool)
+	response, _ := msg.Payload["response"].(string)
+	errMsg, _ := msg.Payload["msg"].(string)
+
+	s.logger.Info("收到 USSD 回复",
+		zap.String("request_id", requestID),
+		zap.Bool("success", success),
+		zap.String("response", response))
+
+	v, ok := s.ussdPending.Load(requestID)
+	if !ok {
+		return
+	}
+	result := ussdResult{response: response}
+	if !success {
+		switch errMsg {
+		case "unsupported":
+			result.err = ErrUSSDUnsupported
+		case "":
+			result.err = fmt.Errorf("USSD 请求失败")
+		default:
+			result.err = fmt.Errorf("USSD 请求失败: %s", errMsg)
+		}
+	}
+	select {
+	case v.(chan ussdResult) <- result:
+	default:
+	}
+}
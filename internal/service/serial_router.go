@@ -1,6 +1,9 @@
 package service
 
-import "go.uber.org/zap"
+import (
+	"github.com/Starktomy/smshub/internal/metrics"
+	"go.uber.org/zap"
+)
 
 type messageHandler func(*ParsedMessage)
 
@@ -14,6 +17,7 @@ func (s *SerialService) initMessageHandlers() {
 		"phone_number_response":     s.handlePhoneNumberResponse,
 		"cmd_response":              s.handleCommandResponse,
 		"sms_send_result":           s.handleSMSSendResult,
+		"ussd_result":               s.handleUSSDResult,
 		"sim_event":                 s.handleSIMEvent,
 		"warning":                   s.handleWarningMessage,
 		"error":                     s.handleErrorMessage,
@@ -25,9 +29,12 @@ func (s *SerialService) initMessageHandlers() {
 func (s *SerialService) routeMessage(msg *ParsedMessage) {
 	handler, ok := s.handlers[msg.Type]
 	if !ok {
+		// 未知类型统一计数，避免设备上报任意类型导致指标数量失控
+		metrics.SerialFrames.WithLabelValues(s.metricsDevice(), "unknown").Inc()
 		s.logger.Debug("未知消息类型", zap.String("type", msg.Type), zap.String("data", msg.JSON))
 		return
 	}
 
+	metrics.SerialFrames.WithLabelValues(s.metricsDevice(), msg.Type).Inc()
 	handler(msg)
 }
@@ -13,11 +13,15 @@ import (
 	"time"
 
 	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/metrics"
 	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/tracing"
 	"github.com/go-orz/cache"
 	"github.com/google/uuid"
 	"github.com/jpillora/backoff"
 	"go.bug.st/serial"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -30,6 +34,8 @@ const (
 	CacheTTL = 5 * time.Minute
 	// 默认读取超时
 	defaultReadTimeout = 5 * time.Second
+	// 超过该时间仍未收到发送结果的短信不再统计发送耗时
+	smsPendingTTL = 10 * time.Minute
 )
 
 type ScheduledTaskStatusUpdater func(ctx context.Context, msgID string, status models.LastRunStatus) error
@@ -59,6 +65,12 @@ type SerialService struct {
 	// 设备的飞行模式查询永远返回 false，无奈只能在应用层处理
 	flyMode atomic.Bool
 
+	// 等待中的 USSD 请求，request_id -> chan ussdResult
+	ussdPending sync.Map
+
+	// 等待发送结果的短信，request_id -> 写入命令的时间，用于统计发送耗时
+	smsPending sync.Map
+
 	// 多设备支持
 	deviceID   string // 设备ID
 	deviceName string // 设备名称
@@ -135,7 +147,7 @@ func (s *SerialService) Start() {
 		Jitter: true,
 	}
 
-	for {
+	for attempt := 0; ; attempt++ {
 		select {
 		case <-s.stopCh:
 			s.logger.Info("串口服务停止")
@@ -143,6 +155,9 @@ func (s *SerialService) Start() {
 		default:
 		}
 
+		if attempt > 0 {
+			metrics.SerialReconnects.WithLabelValues(s.metricsDevice()).Inc()
+		}
 		err := s.runOnce(b.Reset)
 
 		// 连接失败或断开，使用 backoff 重试
@@ -183,6 +198,14 @@ func (s *SerialService) GetDeviceName() string {
 	return s.deviceName
 }
 
+// metricsDevice 指标中的设备标签，单设备模式没有设备ID时使用 default
+func (s *SerialService) metricsDevice() string {
+	if s.deviceID == "" {
+		return "default"
+	}
+	return s.deviceID
+}
+
 // setConnected 设置连接状态
 func (s *SerialService) setConnected(connected bool) {
 	s.mu.Lock()
@@ -435,7 +458,7 @@ func (s *SerialService) RequestCacheUpdate() {
 	s.logger.Debug("发送缓存更新请求")
 
 	// 发送获取设备状态命令（包含移动网络信息）
-	if err := s.sendJSONCommand(map[string]string{"action": "get_status"}); err != nil {
+	if err := s.sendJSONCommand(context.Background(), map[string]string{"action": "get_status"}); err != nil {
 		s.logger.Error("发送设备状态请求失败", zap.Error(err))
 	}
 }
@@ -449,9 +472,11 @@ func (s *SerialService) processReceivedData(data string) {
 			return
 		}
 		if errors.Is(err, errMissingType) {
+			metrics.SerialParseErrors.WithLabelValues(s.metricsDevice(), "missing_type").Inc()
 			s.logger.Warn("消息类型缺失", zap.String("data", data))
 			return
 		}
+		metrics.SerialParseErrors.WithLabelValues(s.metricsDevice(), "invalid_json").Inc()
 		s.logger.Error("解析串口消息失败", zap.Error(err), zap.String("data", data))
 		return
 	}
@@ -459,11 +484,18 @@ func (s *SerialService) processReceivedData(data string) {
 	s.routeMessage(msg)
 }
 
-// SendSMS 发送短信
-func (s *SerialService) SendSMS(to, content string) (string, error) {
-	// 先保存发送记录，状态为 "sending"
-	ctx := context.Background()
-	msgID := uuid.NewString()
+// SendSMS 发送短信，返回的消息 ID 同时作为命令的 request_id，设备回执 sms_send_result 时带回
+func (s *SerialService) SendSMS(ctx context.Context, to, content string) (msgID string, err error) {
+	ctx, span := tracing.Tracer.Start(ctx, "sms.send", trace.WithAttributes(
+		attribute.String("device.id", s.deviceID),
+		attribute.String("device.name", s.deviceName),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	// 先保存发送记录，状态为 "sending"；调用方的请求结束后仍要完成写入
+	ctx = context.WithoutCancel(ctx)
+	msgID = uuid.NewString()
+	span.SetAttributes(attribute.String("sms.request_id", msgID))
 	msg := &models.TextMessage{
 		ID:         msgID,
 		From:       "", // 发送方是本机
@@ -489,7 +521,10 @@ func (s *SerialService) SendSMS(to, content string) (string, error) {
 		"request_id": msgID,
 	}
 
-	if err := s.sendJSONCommand(cmd); err != nil {
+	s.trackSMSPending(ctx, msgID)
+	if err := s.sendJSONCommand(ctx, cmd); err != nil {
+		s.smsPending.Delete(msgID)
+		metrics.SMSFailed.WithLabelValues(s.metricsDevice()).Inc()
 		s.logger.Error("发送短信命令失败", zap.Error(err))
 		// 更新状态为失败
 		if updateErr := s.textMsgService.UpdateStatusById(ctx, msgID, models.MessageStatusFailed); updateErr != nil {
@@ -503,6 +538,43 @@ func (s *SerialService) SendSMS(to, content string) (string, error) {
 	return msgID, nil
 }
 
+// smsPendingSend 等待发送结果的短信
+type smsPendingSend struct {
+	startedAt time.Time
+	span      trace.SpanContext // 发送短信的 span，收到结果时关联
+}
+
+// trackSMSPending 记录短信命令的写入时间和 span，同时清理长时间没有收到结果的记录
+func (s *SerialService) trackSMSPending(ctx context.Context, msgID string) {
+	now := time.Now()
+	s.smsPending.Range(func(key, value any) bool {
+		if now.Sub(value.(smsPendingSend).startedAt) > smsPendingTTL {
+			s.smsPending.Delete(key)
+		}
+		return true
+	})
+	s.smsPending.Store(msgID, smsPendingSend{startedAt: now, span: trace.SpanContextFromContext(ctx)})
+}
+
+// observeSMSResult 统计短信发送结果和从写入命令到收到结果的耗时，返回发送时的 span 用于关联
+func (s *SerialService) observeSMSResult(requestID string, success bool) trace.SpanContext {
+	device := s.metricsDevice()
+	result := "success"
+	if success {
+		metrics.SMSSent.WithLabelValues(device).Inc()
+	} else {
+		result = "failed"
+		metrics.SMSFailed.WithLabelValues(device).Inc()
+	}
+	value, ok := s.smsPending.LoadAndDelete(requestID)
+	if !ok {
+		return trace.SpanContext{}
+	}
+	pending := value.(smsPendingSend)
+	metrics.SMSSendLatency.WithLabelValues(device, result).Observe(time.Since(pending.startedAt).Seconds())
+	return pending.span
+}
+
 // GetStatus 获取设备状态（从缓存读取，包含 mobile 信息和串口连接状态）
 func (s *SerialService) GetStatus() (*StatusData, error) {
 	// 获取连接信息
@@ -539,7 +611,7 @@ func (s *SerialService) SetFlymode(enabled bool) error {
 		"action":  "set_flymode",
 		"enabled": enabled,
 	}
-	if err := s.sendJSONCommand(cmd); err != nil {
+	if err := s.sendJSONCommand(context.Background(), cmd); err != nil {
 		return err
 	}
 	// 注意：不要在这里立即更新 flyMode，设备响应会在 handleStatusResponse 中更新
@@ -550,7 +622,7 @@ func (s *SerialService) SetFlymode(enabled bool) error {
 // RebootMcu 重启模块
 func (s *SerialService) RebootMcu() error {
 	cmd := map[string]string{"action": "reboot_mcu"}
-	if err := s.sendJSONCommand(cmd); err != nil {
+	if err := s.sendJSONCommand(context.Background(), cmd); err != nil {
 		return err
 	}
 	// 重启后，飞行模式默认关闭
@@ -559,7 +631,13 @@ func (s *SerialService) RebootMcu() error {
 }
 
 // sendJSONCommand 发送JSON命令到设备
-func (s *SerialService) sendJSONCommand(cmd any) error {
+func (s *SerialService) sendJSONCommand(ctx context.Context, cmd any) (err error) {
+	_, span := tracing.Tracer.Start(ctx, "serial.write", trace.WithAttributes(
+		attribute.String("device.id", s.deviceID),
+		attribute.String("serial.action", commandAction(cmd)),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	if s.port == nil {
 		return fmt.Errorf("串口未连接")
 	}
@@ -588,6 +666,18 @@ func (s *SerialService) sendJSONCommand(cmd any) error {
 	return nil
 }
 
+// commandAction 返回命令的 action 字段
+func commandAction(cmd any) string {
+	switch c := cmd.(type) {
+	case map[string]string:
+		return c["action"]
+	case map[string]any:
+		action, _ := c["action"].(string)
+		return action
+	}
+	return ""
+}
+
 // isTimeoutError 检查错误是否为超时错误
 func isTimeoutError(err error) bool {
 	if err == nil {
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"go.bug.st/serial"
 	"go.uber.org/zap"
 )
@@ -147,3 +150,24 @@ func TestSerialService_HandleHeartbeatResponse(t *testing.T) {
 	svc.handleHeartbeat(parsedMsg)
 	// 注意：根据重构逻辑，心跳包不再更新飞行模式，因此这里不再验证 flyMode 状态的变化
 }
+
+func TestSerialService_SMSResultMetrics(t *testing.T) {
+	svc := &SerialService{logger: zap.NewNop(), deviceID: "metrics-test"}
+
+	svc.trackSMSPending(context.Background(), "msg-1")
+	svc.observeSMSResult("msg-1", true)
+	svc.observeSMSResult("msg-2", false) // 没有发送记录时只计数
+
+	if v := testutil.ToFloat64(metrics.SMSSent.WithLabelValues("metrics-test")); v != 1 {
+		t.Errorf("发送成功数应为 1，实际 %v", v)
+	}
+	if v := testutil.ToFloat64(metrics.SMSFailed.WithLabelValues("metrics-test")); v != 1 {
+		t.Errorf("发送失败数应为 1，实际 %v", v)
+	}
+	if count := testutil.CollectAndCount(metrics.SMSSendLatency, "smshub_sms_send_latency_seconds"); count != 1 {
+		t.Errorf("应只记录一次发送耗时，实际 %d", count)
+	}
+	if _, ok := svc.smsPending.Load("msg-1"); ok {
+		t.Error("收到结果后应删除等待记录")
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultAccessTokenMinutes 访问令牌默认有效期
+	defaultAccessTokenMinutes = 15
+	// defaultSessionHours 会话（刷新令牌）默认有效期，7 天
+	defaultSessionHours = 168
+)
+
+var (
+	// ErrInvalidToken 访问令牌无效、过期或会话已失效
+	ErrInvalidToken = errors.New("无效的token")
+	// ErrInvalidRefreshToken 刷新令牌无效或已过期
+	ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期")
+)
+
+// JWTClaims JWT 声明
+type JWTClaims struct {
+	UserID    string `json:"userId"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// ClientInfo 登录和刷新时的客户端信息
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// TokenPair 访问令牌和刷新令牌
+type TokenPair struct {
+	Token            string `json:"token"`            // 访问令牌
+	ExpiresAt        int64  `json:"expiresAt"`        // 访问令牌过期时间（时间戳毫秒）
+	RefreshToken     string `json:"refreshToken"`     // 刷新令牌，每次刷新后更换
+	RefreshExpiresAt int64  `json:"refreshExpiresAt"` // 刷新令牌过期时间（时间戳毫秒）
+	SessionID        string `json:"sessionId"`        // 会话ID
+}
+
+// SessionService 登录会话服务：签发短期访问令牌，服务端保存并轮换刷新令牌
+type SessionService struct {
+	logger     *zap.Logger
+	repo       *repo.SessionRepo
+	userRepo   *repo.UserRepo
+	secret     []byte
+	accessTTL  time.Duration
+	sessionTTL time.Duration
+}
+
+// NewSessionService 创建会话服务实例，jwtConfig.Secret 不能为空
+func NewSessionService(logger *zap.Logger, db *gorm.DB, jwtConfig config.JWTConfig) *SessionService {
+	if jwtConfig.Secret == "" {
+		logger.Fatal("JWT secret cannot be empty")
+	}
+	if len(jwtConfig.Secret) < 32 {
+		logger.Warn("JWT secret is too short, should be at least 32 characters for security")
+	}
+	accessMinutes := jwtConfig.AccessTokenMinutes
+	if accessMinutes <= 0 {
+		accessMinutes = defaultAccessTokenMinutes
+	}
+	sessionHours := jwtConfig.ExpiresHours
+	if sessionHours <= 0 {
+		sessionHours = defaultSessionHours
+	}
+
+	return &SessionService{
+		logger:     logger,
+		repo:       repo.NewSessionRepo(db),
+		userRepo:   repo.NewUserRepo(db),
+		secret:     []byte(jwtConfig.Secret),
+		accessTTL:  time.Duration(accessMinutes) * time.Minute,
+		sessionTTL: time.Duration(sessionHours) * time.Hour,
+	}
+}
+
+// Issue 为登录成功的用户创建会话并签发令牌
+func (s *SessionService) Issue(ctx context.Context, user *models.User, client ClientInfo) (*TokenPair, error) {
+	return s.issue(ctx, user, client, "")
+}
+
+// IssueOIDC 为 OIDC 登录的用户创建会话，保存 ID Token 用于退出 IdP 会话
+func (s *SessionService) IssueOIDC(ctx context.Context, user *models.User, client ClientInfo, idToken string) (*TokenPair, error) {
+	return s.issue(ctx, user, client, idToken)
+}
+
+// issue 创建会话并签发令牌
+func (s *SessionService) issue(ctx context.Context, user *models.User, client ClientInfo, idToken string) (*TokenPair, error) {
+	// 顺便清理过期会话
+	if _, err := s.repo.DeleteExpired(ctx, time.Now().UnixMilli()); err != nil {
+		s.logger.Warn("清理过期会话失败", zap.Error(err))
+	}
+
+	refreshToken, hash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &models.Session{
+		ID:               uuid.NewString(),
+		UserID:           user.ID,
+		RefreshTokenHash: hash,
+		IP:               client.IP,
+		UserAgent:        client.UserAgent,
+		CreatedAt:        now.UnixMilli(),
+		LastUsedAt:       now.UnixMilli(),
+		ExpiresAt:        now.Add(s.sessionTTL).UnixMilli(),
+		IDToken:          idToken,
+	}
+	if err := s.repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return s.tokenPair(user, session, refreshToken)
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌立即失效
+// 已轮换的刷新令牌再次使用说明可能已泄露，整个会话会被撤销
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, *models.User, error) {
+	hash := hashToken(refreshToken)
+	session, err := s.repo.FindByRefreshTokenHash(ctx, hash)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if reused, err := s.repo.FindByPreviousTokenHash(ctx, hash); err == nil {
+			s.logger.Warn("刷新令牌被重复使用，撤销会话", zap.String("session", reused.ID), zap.String("userId", reused.UserID))
+			if err := s.repo.DeleteById(ctx, reused.ID); err != nil {
+				return nil, nil, err
+			}
+		}
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if session.ExpiresAt <= now.UnixMilli() {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	user, err := s.userRepo.FindById(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	session.IP = client.IP
+	session.UserAgent = client.UserAgent
+	session.LastUsedAt = now.UnixMilli()
+	rotated, err := s.repo.Rotate(ctx, session.ID, hash, newHash, map[string]any{
+		"ip":           session.IP,
+		"user_agent":   session.UserAgent,
+		"last_used_at": session.LastUsedAt,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if !rotated {
+		// 并发刷新时另一个请求已经轮换了令牌
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	pair, err := s.tokenPair(&user, session, newToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pair, &user, nil
+}
+
+// Authenticate 验证访问令牌并检查会话仍然有效，返回当前用户和会话ID
+func (s *SessionService) Authenticate(ctx context.Context, accessToken string) (*models.User, string, error) {
+	claims, err := s.ParseAccessToken(accessToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if claims.SessionID == "" {
+		// 旧版本签发的令牌没有会话，需要重新登录
+		return nil, "", ErrInvalidToken
+	}
+	session, err := s.repo.FindById(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrInvalidToken
+		}
+		return nil, "", err
+	}
+	if session.UserID != claims.UserID || session.ExpiresAt <= time.Now().UnixMilli() {
+		return nil, "", ErrInvalidToken
+	}
+	user, err := s.userRepo.FindById(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrInvalidToken
+		}
+		return nil, "", err
+	}
+	return &user, session.ID, nil
+}
+
+// ParseAccessToken 校验访问令牌的签名和有效期
+func (s *SessionService) ParseAccessToken(tokenString string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		// 验证签名方法
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("无效的签名方法")
+		}
+		return s.secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// List 获取用户未过期的会话
+func (s *SessionService) List(ctx context.Context, userID string) ([]models.Session, error) {
+	return s.repo.FindActiveByUser(ctx, userID, time.Now().UnixMilli())
+}
+
+// Get 获取用户的会话
+func (s *SessionService) Get(ctx context.Context, userID, sessionID string) (*models.Session, error) {
+	session, err := s.repo.FindById(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &session, nil
+}
+
+// Revoke 撤销用户的单个会话，该会话的访问令牌和刷新令牌立即失效
+func (s *SessionService) Revoke(ctx context.Context, userID, sessionID string) error {
+	affected, err := s.repo.DeleteByUserAndId(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeAll 撤销用户的所有会话
+func (s *SessionService) RevokeAll(ctx context.Context, userID string) error {
+	return s.repo.DeleteByUser(ctx, userID)
+}
+
+// tokenPair 为会话签发访问令牌
+func (s *SessionService) tokenPair(user *models.User, session *models.Session, refreshToken string) (*TokenPair, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.accessTTL)
+	if sessionEnd := time.UnixMilli(session.ExpiresAt); expiresAt.After(sessionEnd) {
+		expiresAt = sessionEnd
+	}
+	claims := &JWTClaims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    "pika",
+			Subject:   user.Username,
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		s.logger.Error("生成token失败", zap.Error(err))
+		return nil, errors.New("生成token失败")
+	}
+	return &TokenPair{
+		Token:            token,
+		ExpiresAt:        expiresAt.UnixMilli(),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+	}, nil
+}
+
+// newRefreshToken 生成随机刷新令牌，返回令牌和保存到数据库的哈希
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken 计算刷新令牌的 SHA-256
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateSecret 生成 32 字节的随机密钥（base64）
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
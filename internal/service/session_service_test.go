@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestSessionServiceRefresh(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserService(zap.NewNop(), db)
+	sessions := NewSessionService(zap.NewNop(), db, config.JWTConfig{Secret: "very-long-secret-key-at-least-32-bytes"})
+
+	user, err := users.Create(ctx, &CreateUserRequest{Username: "alice", Password: "alice-password", Role: models.RoleOperator})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	pair, err := sessions.Issue(ctx, user, ClientInfo{IP: "10.0.0.1", UserAgent: "curl"})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	// 刷新后旧的刷新令牌失效，访问令牌仍属于同一会话
+	refreshed, refreshedUser, err := sessions.Refresh(ctx, pair.RefreshToken, ClientInfo{IP: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if refreshedUser.ID != user.ID || refreshed.SessionID != pair.SessionID {
+		t.Errorf("Expected same user and session, got %s %s", refreshedUser.ID, refreshed.SessionID)
+	}
+	if refreshed.RefreshToken == pair.RefreshToken {
+		t.Error("刷新令牌应当轮换")
+	}
+	if _, sessionID, err := sessions.Authenticate(ctx, refreshed.Token); err != nil || sessionID != pair.SessionID {
+		t.Errorf("Authenticate failed: %v %s", err, sessionID)
+	}
+	list, _ := sessions.List(ctx, user.ID)
+	if len(list) != 1 || list[0].IP != "10.0.0.2" {
+		t.Errorf("Expected 1 session from 10.0.0.2, got %+v", list)
+	}
+
+	// 重复使用已轮换的刷新令牌会撤销整个会话
+	if _, _, err := sessions.Refresh(ctx, pair.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected ErrInvalidRefreshToken on reuse, got %v", err)
+	}
+	if _, _, err := sessions.Refresh(ctx, refreshed.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("会话撤销后刷新令牌应当失效, got %v", err)
+	}
+	if _, _, err := sessions.Authenticate(ctx, refreshed.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("会话撤销后访问令牌应当失效, got %v", err)
+	}
+}
+
+func TestSessionServiceRevoke(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserService(zap.NewNop(), db)
+	sessions := NewSessionService(zap.NewNop(), db, config.JWTConfig{Secret: "very-long-secret-key-at-least-32-bytes"})
+
+	user, err := users.Create(ctx, &CreateUserRequest{Username: "bob", Password: "bob-password", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	first, _ := sessions.Issue(ctx, user, ClientInfo{})
+	second, _ := sessions.Issue(ctx, user, ClientInfo{})
+
+	// 只能撤销自己的会话
+	if err := sessions.Revoke(ctx, "other-user", first.SessionID); err == nil {
+		t.Error("不能撤销其他用户的会话")
+	}
+	if err := sessions.Revoke(ctx, user.ID, first.SessionID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, _, err := sessions.Authenticate(ctx, first.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken after revoke, got %v", err)
+	}
+	if _, _, err := sessions.Authenticate(ctx, second.Token); err != nil {
+		t.Errorf("其他会话不受影响: %v", err)
+	}
+
+	// 重置密码撤销所有会话
+	if _, err := users.ResetPassword(ctx, user.ID, ""); err != nil {
+		t.Fatalf("ResetPassword failed: %v", err)
+	}
+	if _, _, err := sessions.Authenticate(ctx, second.Token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Expected ErrInvalidToken after password reset, got %v", err)
+	}
+
+	// 过期的会话不能刷新
+	expired, _ := sessions.Issue(ctx, user, ClientInfo{})
+	if err := db.Model(&models.Session{}).Where("id = ?", expired.SessionID).
+		Update("expires_at", time.Now().Add(-time.Minute).UnixMilli()).Error; err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, _, err := sessions.Refresh(ctx, expired.RefreshToken, ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Expected ErrInvalidRefreshToken for expired session, got %v", err)
+	}
+}
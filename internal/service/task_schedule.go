@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrInvalidSchedule 定时任务调度配置无效
+var ErrInvalidSchedule = errors.New("调度配置无效")
+
+// NormalizeSchedule 校验定时任务的调度配置
+// 未指定 scheduleType 的旧版本请求按 intervalDays 转换为按天间隔
+func NormalizeSchedule(task *models.ScheduledTask) error {
+	if task.ScheduleType == "" && task.IntervalDays > 0 {
+		task.ScheduleType = models.ScheduleTypeInterval
+		task.IntervalValue = task.IntervalDays
+		task.IntervalUnit = models.IntervalUnitDays
+	}
+	if task.ScheduleType == models.ScheduleTypeInterval && task.IntervalUnit == models.IntervalUnitDays {
+		task.IntervalDays = task.IntervalValue
+	}
+	_, err := newTaskSchedule(*task)
+	return err
+}
+
+// newTaskSchedule 根据任务配置创建调度，一次性任务已执行过时返回 nil
+func newTaskSchedule(task models.ScheduledTask) (cron.Schedule, error) {
+	switch task.ScheduleType {
+	case models.ScheduleTypeCron:
+		spec := task.CronExpr
+		if spec == "" {
+			return nil, fmt.Errorf("%w: cron 表达式不能为空", ErrInvalidSchedule)
+		}
+		if task.Timezone != "" {
+			if _, err := time.LoadLocation(task.Timezone); err != nil {
+				return nil, fmt.Errorf("%w: 未知时区 %s", ErrInvalidSchedule, task.Timezone)
+			}
+			spec = "CRON_TZ=" + task.Timezone + " " + spec
+		}
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%w: cron 表达式错误: %v", ErrInvalidSchedule, err)
+		}
+		return schedule, nil
+
+	case models.ScheduleTypeInterval:
+		var unit time.Duration
+		switch task.IntervalUnit {
+		case models.IntervalUnitMinutes:
+			unit = time.Minute
+		case models.IntervalUnitHours:
+			unit = time.Hour
+		case models.IntervalUnitDays:
+			unit = 24 * time.Hour
+		default:
+			return nil, fmt.Errorf("%w: 间隔单位只能是 minutes、hours 或 days", ErrInvalidSchedule)
+		}
+		if task.IntervalValue <= 0 {
+			return nil, fmt.Errorf("%w: 执行间隔必须大于0", ErrInvalidSchedule)
+		}
+		// 从上次执行时间开始计算，从未执行过则从创建时间开始
+		anchor := task.LastRunAt
+		if anchor <= 0 {
+			anchor = task.CreatedAt
+		}
+		every := time.Duration(task.IntervalValue) * unit
+		return &intervalSchedule{every: every, first: time.UnixMilli(anchor).Add(every)}, nil
+
+	case models.ScheduleTypeOnce:
+		if task.RunAt <= 0 {
+			return nil, fmt.Errorf("%w: 执行时间不能为空", ErrInvalidSchedule)
+		}
+		if task.LastRunAt >= task.RunAt {
+			return nil, nil
+		}
+		return &onceSchedule{at: time.UnixMilli(task.RunAt)}, nil
+
+	case models.ScheduleTypeMonthWeekday:
+		if task.MonthWeek == 0 || task.MonthWeek < -1 || task.MonthWeek > 5 {
+			return nil, fmt.Errorf("%w: 第几周只能是 1-5 或 -1（最后一周）", ErrInvalidSchedule)
+		}
+		if task.Weekday < 0 || task.Weekday > 6 {
+			return nil, fmt.Errorf("%w: 星期只能是 0-6", ErrInvalidSchedule)
+		}
+		clock, err := time.Parse("15:04", task.TimeOfDay)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 执行时刻格式应为 HH:MM", ErrInvalidSchedule)
+		}
+		loc := time.Local
+		if task.Timezone != "" {
+			if loc, err = time.LoadLocation(task.Timezone); err != nil {
+				return nil, fmt.Errorf("%w: 未知时区 %s", ErrInvalidSchedule, task.Timezone)
+			}
+		}
+		return monthWeekdaySchedule{
+			week:    task.MonthWeek,
+			weekday: time.Weekday(task.Weekday),
+			hour:    clock.Hour(),
+			minute:  clock.Minute(),
+			loc:     loc,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: 不支持的调度方式 %q", ErrInvalidSchedule, task.ScheduleType)
+}
+
+// intervalSchedule 固定间隔调度
+// 首次计算时返回上次执行时间加间隔（已错过则立即执行），之后每次执行后顺延一个间隔
+type intervalSchedule struct {
+	every   time.Duration
+	first   time.Time
+	started bool
+}
+
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	if !s.started {
+		s.started = true
+		if s.first.After(t) {
+			return s.first
+		}
+		return t
+	}
+	return t.Add(s.every)
+}
+
+// onceSchedule 一次性调度，执行后不再触发
+type onceSchedule struct {
+	at   time.Time
+	done bool
+}
+
+func (s *onceSchedule) Next(t time.Time) time.Time {
+	if s.done {
+		return time.Time{}
+	}
+	s.done = true
+	return s.at
+}
+
+// monthWeekdaySchedule 每月第 N 个星期几调度，week 为 -1 表示最后一个
+type monthWeekdaySchedule struct {
+	week    int
+	weekday time.Weekday
+	hour    int
+	minute  int
+	loc     *time.Location
+}
+
+func (s monthWeekdaySchedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc)
+	// 第 5 个星期几不是每个月都有，最多向后查找一年
+	for i := 0; i <= 12; i++ {
+		first := time.Date(t.Year(), t.Month()+time.Month(i), 1, 0, 0, 0, 0, s.loc)
+		day, ok := s.day(first)
+		if !ok {
+			continue
+		}
+		next := time.Date(first.Year(), first.Month(), day, s.hour, s.minute, 0, 0, s.loc)
+		if next.After(t) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// day 返回指定月份中满足条件的日期
+func (s monthWeekdaySchedule) day(first time.Time) (int, bool) {
+	last := first.AddDate(0, 1, -1)
+	if s.week == -1 {
+		return last.Day() - (int(last.Weekday())-int(s.weekday)+7)%7, true
+	}
+	day := 1 + (int(s.weekday)-int(first.Weekday())+7)%7 + (s.week-1)*7
+	return day, day <= last.Day()
+}
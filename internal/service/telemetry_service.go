@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// telemetryCronSpec 每 5 分钟汇总并清理一次
+	telemetryCronSpec = "*/5 * * * *"
+	// telemetrySampleInterval 每台设备原始采样的最小间隔，状态上报更频繁时丢弃多余的采样
+	telemetrySampleInterval = time.Minute
+
+	telemetryRawRetention    = 24 * time.Hour
+	telemetry5MinRetention   = 30 * 24 * time.Hour
+	telemetryHourlyRetention = 365 * 24 * time.Hour
+)
+
+// ErrInvalidTelemetryQuery 遥测查询参数错误
+var ErrInvalidTelemetryQuery = errors.New("遥测查询参数错误")
+
+// telemetryMetrics 可查询的指标，顺序即默认返回顺序
+var telemetryMetrics = []struct {
+	name  string
+	value func(*models.DeviceTelemetry) float64
+}{
+	{"signalLevel", func(t *models.DeviceTelemetry) float64 { return t.SignalLevel }},
+	{"rssi", func(t *models.DeviceTelemetry) float64 { return t.Rssi }},
+	{"rsrp", func(t *models.DeviceTelemetry) float64 { return t.Rsrp }},
+	{"rsrq", func(t *models.DeviceTelemetry) float64 { return t.Rsrq }},
+	{"csq", func(t *models.DeviceTelemetry) float64 { return t.Csq }},
+	{"lac", func(t *models.DeviceTelemetry) float64 { return float64(t.Lac) }},
+	{"cid", func(t *models.DeviceTelemetry) float64 { return float64(t.Cid) }},
+	{"memKb", func(t *models.DeviceTelemetry) float64 { return t.MemKb }},
+	{"uptime", func(t *models.DeviceTelemetry) float64 { return float64(t.Uptime) }},
+}
+
+// TelemetryPoint 单个时间点的指标值
+type TelemetryPoint struct {
+	Timestamp int64              `json:"timestamp"` // 采样时间或汇总区间开始时间（时间戳毫秒）
+	Samples   int                `json:"samples"`   // 汇总的原始采样数
+	Values    map[string]float64 `json:"values"`    // 指标名 -> 值
+}
+
+// TelemetrySeries 遥测查询结果
+type TelemetrySeries struct {
+	DeviceID   string                     `json:"deviceId"`
+	Resolution models.TelemetryResolution `json:"resolution"`
+	From       int64                      `json:"from"`
+	To         int64                      `json:"to"`
+	Metrics    []string                   `json:"metrics"`
+	Points     []TelemetryPoint           `json:"points"`
+}
+
+// TelemetryService 设备遥测数据采集、汇总和清理
+// 原始采样保留 24 小时，5 分钟平均保留 30 天，1 小时平均保留一年
+type TelemetryService struct {
+	logger     *zap.Logger
+	repo       *repo.DeviceTelemetryRepo
+	deviceRepo *repo.DeviceRepo
+	cron       *cron.Cron
+
+	// 每台设备最近一次原始采样时间
+	lastSample   map[string]time.Time
+	lastSampleMu sync.Mutex
+	// 防止汇总任务并发执行
+	mu sync.Mutex
+}
+
+// NewTelemetryService 创建设备遥测服务实例
+func NewTelemetryService(logger *zap.Logger, db *gorm.DB) *TelemetryService {
+	return &TelemetryService{
+		logger:     logger,
+		repo:       repo.NewDeviceTelemetryRepo(db),
+		deviceRepo: repo.NewDeviceRepo(db),
+		lastSample: make(map[string]time.Time),
+	}
+}
+
+// Start 启动定时汇总和清理任务
+func (s *TelemetryService) Start(ctx context.Context) error {
+	s.cron = cron.New()
+	_, err := s.cron.AddFunc(telemetryCronSpec, func() {
+		if err := s.Rollup(context.Background(), time.Now()); err != nil {
+			s.logger.Error("汇总设备遥测数据失败", zap.Error(err))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("添加遥测汇总任务失败: %w", err)
+	}
+	s.cron.Start()
+	return nil
+}
+
+// Stop 停止汇总任务
+func (s *TelemetryService) Stop() {
+	if s.cron != nil {
+		s.cron.Stop()
+		s.logger.Info("设备遥测服务已停止")
+	}
+}
+
+// Record 记录设备状态上报中的信号、基站和内存数据，作为 DeviceManager 的 TelemetryRecorder
+func (s *TelemetryService) Record(deviceID string, status *StatusData) {
+	if err := s.record(context.Background(), deviceID, status, time.Now()); err != nil {
+		s.logger.Error("记录设备遥测数据失败", zap.String("deviceId", deviceID), zap.Error(err))
+	}
+}
+
+func (s *TelemetryService) record(ctx context.Context, deviceID string, status *StatusData, now time.Time) error {
+	s.lastSampleMu.Lock()
+	if last, ok := s.lastSample[deviceID]; ok && now.Sub(last) < telemetrySampleInterval {
+		s.lastSampleMu.Unlock()
+		return nil
+	}
+	s.lastSample[deviceID] = now
+	s.lastSampleMu.Unlock()
+
+	sample := models.DeviceTelemetry{
+		ID:          uuid.NewString(),
+		DeviceID:    deviceID,
+		Resolution:  models.TelemetryResolutionRaw,
+		Timestamp:   now.UnixMilli(),
+		Samples:     1,
+		SignalLevel: float64(status.Mobile.SignalLevel),
+		Rssi:        float64(status.Mobile.Rssi),
+		Rsrp:        float64(status.Mobile.Rsrp),
+		Rsrq:        status.Mobile.Rsrq,
+		Csq:         float64(status.Mobile.Csq),
+		Lac:         status.Mobile.Lac,
+		Cid:         status.Mobile.Cid,
+		MemKb:       float64(status.MemKb),
+		Uptime:      status.Mobile.Uptime,
+	}
+	return s.repo.Create(ctx, &sample)
+}
+
+// Rollup 将已结束区间的原始采样汇总为 5 分钟平均，5 分钟平均汇总为 1 小时平均，然后清理过期数据
+func (s *TelemetryService) Rollup(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.rollup(ctx, models.TelemetryResolutionRaw, models.TelemetryResolution5Min, 5*time.Minute, now); err != nil {
+		return err
+	}
+	if err := s.rollup(ctx, models.TelemetryResolution5Min, models.TelemetryResolutionHourly, time.Hour, now); err != nil {
+		return err
+	}
+
+	retentions := []struct {
+		resolution models.TelemetryResolution
+		keep       time.Duration
+	}{
+		{models.TelemetryResolutionRaw, telemetryRawRetention},
+		{models.TelemetryResolution5Min, telemetry5MinRetention},
+		{models.TelemetryResolutionHourly, telemetryHourlyRetention},
+	}
+	for _, r := range retentions {
+		deleted, err := s.repo.DeleteBefore(ctx, r.resolution, now.Add(-r.keep).UnixMilli())
+		if err != nil {
+			return fmt.Errorf("清理过期遥测数据失败: %w", err)
+		}
+		if deleted > 0 {
+			s.logger.Debug("已清理过期遥测数据",
+				zap.String("resolution", string(r.resolution)),
+				zap.Int64("deleted", deleted))
+		}
+	}
+	return nil
+}
+
+// rollup 按设备汇总 source 中上次汇总之后、当前区间之前的数据
+// 在 Go 中分组计算，避免依赖各数据库不同的时间函数
+func (s *TelemetryService) rollup(ctx context.Context, source, target models.TelemetryResolution, bucket time.Duration, now time.Time) error {
+	deviceIDs, err := s.repo.FindDeviceIDs(ctx, source)
+	if err != nil {
+		return fmt.Errorf("查询遥测设备失败: %w", err)
+	}
+
+	bucketMs := bucket.Milliseconds()
+	end := now.UnixMilli() / bucketMs * bucketMs
+	for _, deviceID := range deviceIDs {
+		latest, err := s.repo.LatestTimestamp(ctx, deviceID, target)
+		if err != nil {
+			return fmt.Errorf("查询最近汇总时间失败: %w", err)
+		}
+		var start int64
+		if latest > 0 {
+			start = latest + bucketMs
+		}
+		if start >= end {
+			continue
+		}
+
+		rows, err := s.repo.FindRange(ctx, deviceID, source, start, end)
+		if err != nil {
+			return fmt.Errorf("查询遥测数据失败: %w", err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		var aggregated []models.DeviceTelemetry
+		for i := 0; i < len(rows); {
+			bucketStart := rows[i].Timestamp / bucketMs * bucketMs
+			j := i
+			for j < len(rows) && rows[j].Timestamp < bucketStart+bucketMs {
+				j++
+			}
+			aggregated = append(aggregated, aggregateTelemetry(rows[i:j], deviceID, target, bucketStart))
+			i = j
+		}
+		if err := s.repo.CreateBatch(ctx, aggregated); err != nil {
+			return fmt.Errorf("保存汇总遥测数据失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// aggregateTelemetry 按采样数加权计算平均值，LAC/CID 和开机时长取最后一个值
+func aggregateTelemetry(rows []models.DeviceTelemetry, deviceID string, resolution models.TelemetryResolution, timestamp int64) models.DeviceTelemetry {
+	last := rows[len(rows)-1]
+	result := models.DeviceTelemetry{
+		ID:         uuid.NewString(),
+		DeviceID:   deviceID,
+		Resolution: resolution,
+		Timestamp:  timestamp,
+		Lac:        last.Lac,
+		Cid:        last.Cid,
+		Uptime:     last.Uptime,
+	}
+	for _, row := range rows {
+		weight := float64(max(row.Samples, 1))
+		result.Samples += max(row.Samples, 1)
+		result.SignalLevel += row.SignalLevel * weight
+		result.Rssi += row.Rssi * weight
+		result.Rsrp += row.Rsrp * weight
+		result.Rsrq += row.Rsrq * weight
+		result.Csq += row.Csq * weight
+		result.MemKb += row.MemKb * weight
+	}
+	total := float64(result.Samples)
+	result.SignalLevel /= total
+	result.Rssi /= total
+	result.Rsrp /= total
+	result.Rsrq /= total
+	result.Csq /= total
+	result.MemKb /= total
+	return result
+}
+
+// Query 查询设备在 [from, to) 内的遥测数据
+// metric 为逗号分隔的指标名，为空时返回全部指标；resolution 为空时按 from 距今的时间选择仍保留数据的最细精度
+func (s *TelemetryService) Query(ctx context.Context, deviceID, metric, resolution string, from, to int64) (*TelemetrySeries, error) {
+	// 设备不存在或不在授权范围内时返回 gorm.ErrRecordNotFound
+	if _, err := s.deviceRepo.FindById(ctx, deviceID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if to <= 0 {
+		to = now.UnixMilli()
+	}
+	if from <= 0 {
+		from = to - telemetryRawRetention.Milliseconds()
+	}
+	if from >= to {
+		return nil, fmt.Errorf("%w: from 必须早于 to", ErrInvalidTelemetryQuery)
+	}
+
+	res := models.TelemetryResolution(resolution)
+	switch res {
+	case "":
+		res = autoTelemetryResolution(from, now)
+	case models.TelemetryResolutionRaw, models.TelemetryResolution5Min, models.TelemetryResolutionHourly:
+	default:
+		return nil, fmt.Errorf("%w: 未知精度 %s", ErrInvalidTelemetryQuery, resolution)
+	}
+
+	selected, err := parseTelemetryMetrics(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.FindRange(ctx, deviceID, res, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &TelemetrySeries{
+		DeviceID:   deviceID,
+		Resolution: res,
+		From:       from,
+		To:         to,
+		Points:     make([]TelemetryPoint, 0, len(rows)),
+	}
+	for _, m := range selected {
+		series.Metrics = append(series.Metrics, telemetryMetrics[m].name)
+	}
+	for i := range rows {
+		point := TelemetryPoint{
+			Timestamp: rows[i].Timestamp,
+			Samples:   rows[i].Samples,
+			Values:    make(map[string]float64, len(selected)),
+		}
+		for _, m := range selected {
+			point.Values[telemetryMetrics[m].name] = telemetryMetrics[m].value(&rows[i])
+		}
+		series.Points = append(series.Points, point)
+	}
+	return series, nil
+}
+
+// autoTelemetryResolution 选择能覆盖 from 的最细精度
+func autoTelemetryResolution(from int64, now time.Time) models.TelemetryResolution {
+	switch {
+	case from >= now.Add(-telemetryRawRetention).UnixMilli():
+		return models.TelemetryResolutionRaw
+	case from >= now.Add(-telemetry5MinRetention).UnixMilli():
+		return models.TelemetryResolution5Min
+	default:
+		return models.TelemetryResolutionHourly
+	}
+}
+
+// parseTelemetryMetrics 解析逗号分隔的指标名，返回 telemetryMetrics 中的下标
+func parseTelemetryMetrics(metric string) ([]int, error) {
+	if strings.TrimSpace(metric) == "" {
+		all := make([]int, len(telemetryMetrics))
+		for i := range telemetryMetrics {
+			all[i] = i
+		}
+		return all, nil
+	}
+
+	var selected []int
+	seen := make(map[int]bool)
+	for _, name := range strings.Split(metric, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		index := -1
+		for i, m := range telemetryMetrics {
+			if m.name == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("%w: 未知指标 %s", ErrInvalidTelemetryQuery, name)
+		}
+		if !seen[index] {
+			seen[index] = true
+			selected = append(selected, index)
+		}
+	}
+	return selected, nil
+}
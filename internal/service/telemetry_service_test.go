@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestTelemetryService_RecordRollupAndQuery(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewTelemetryService(zap.NewNop(), db)
+	telemetryRepo := repo.NewDeviceTelemetryRepo(db)
+	ctx := context.Background()
+
+	device := &models.Device{ID: "dev-1", Name: "Device 1", SerialPort: "/dev/ttyUSB0"}
+	if err := repo.NewDeviceRepo(db).Create(ctx, device); err != nil {
+		t.Fatalf("Failed to create device: %v", err)
+	}
+
+	status := func(rssi, lac int) *StatusData {
+		s := &StatusData{MemKb: 100}
+		s.Mobile.Rssi = rssi
+		s.Mobile.Lac = lac
+		return s
+	}
+
+	// 10:00 - 10:05 区间内两个有效采样，10:00:30 的采样间隔不足 1 分钟被丢弃
+	base := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+	samples := []struct {
+		offset time.Duration
+		rssi   int
+		lac    int
+	}{
+		{0, -70, 1},
+		{30 * time.Second, -100, 1},
+		{2 * time.Minute, -80, 2},
+		{6 * time.Minute, -90, 3},
+	}
+	for _, s := range samples {
+		if err := svc.record(ctx, device.ID, status(s.rssi, s.lac), base.Add(s.offset)); err != nil {
+			t.Fatalf("Failed to record telemetry: %v", err)
+		}
+	}
+
+	// 只有已结束的区间会被汇总
+	if err := svc.Rollup(ctx, base.Add(7*time.Minute)); err != nil {
+		t.Fatalf("Rollup failed: %v", err)
+	}
+	rows, err := telemetryRepo.FindRange(ctx, device.ID, models.TelemetryResolution5Min, 0, base.Add(time.Hour).UnixMilli())
+	if err != nil {
+		t.Fatalf("FindRange failed: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("Expected 1 5-minute bucket, got %d", len(rows))
+	}
+	if rows[0].Timestamp != base.UnixMilli() || rows[0].Samples != 2 || rows[0].Rssi != -75 || rows[0].Lac != 2 {
+		t.Errorf("Unexpected 5-minute bucket: %+v", rows[0])
+	}
+
+	// 再次汇总不会重复生成已有区间，1 小时区间结束后生成小时平均
+	if err := svc.Rollup(ctx, base.Add(time.Hour)); err != nil {
+		t.Fatalf("Rollup failed: %v", err)
+	}
+	rows, err = telemetryRepo.FindRange(ctx, device.ID, models.TelemetryResolution5Min, 0, base.Add(time.Hour).UnixMilli())
+	if err != nil {
+		t.Fatalf("FindRange failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 5-minute buckets, got %d", len(rows))
+	}
+	hourly, err := telemetryRepo.FindRange(ctx, device.ID, models.TelemetryResolutionHourly, 0, base.Add(time.Hour).UnixMilli())
+	if err != nil {
+		t.Fatalf("FindRange failed: %v", err)
+	}
+	if len(hourly) != 1 || hourly[0].Samples != 3 || hourly[0].Rssi != -80 || hourly[0].Lac != 3 {
+		t.Fatalf("Unexpected hourly buckets: %+v", hourly)
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		series, err := svc.Query(ctx, device.ID, "rssi,lac", "", base.UnixMilli(), base.Add(time.Hour).UnixMilli())
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if series.Resolution != models.TelemetryResolutionRaw {
+			t.Errorf("Expected raw resolution, got %s", series.Resolution)
+		}
+		if len(series.Points) != 3 {
+			t.Fatalf("Expected 3 raw points, got %d", len(series.Points))
+		}
+		if len(series.Points[0].Values) != 2 || series.Points[0].Values["rssi"] != -70 || series.Points[0].Values["lac"] != 1 {
+			t.Errorf("Unexpected point values: %+v", series.Points[0].Values)
+		}
+
+		series, err = svc.Query(ctx, device.ID, "", "1h", base.UnixMilli(), base.Add(time.Hour).UnixMilli())
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if len(series.Points) != 1 || len(series.Metrics) != len(telemetryMetrics) {
+			t.Errorf("Unexpected hourly series: %+v", series)
+		}
+	})
+
+	t.Run("InvalidQuery", func(t *testing.T) {
+		if _, err := svc.Query(ctx, device.ID, "unknown", "", 0, 0); !errors.Is(err, ErrInvalidTelemetryQuery) {
+			t.Errorf("Expected ErrInvalidTelemetryQuery for unknown metric, got %v", err)
+		}
+		if _, err := svc.Query(ctx, device.ID, "", "1d", 0, 0); !errors.Is(err, ErrInvalidTelemetryQuery) {
+			t.Errorf("Expected ErrInvalidTelemetryQuery for unknown resolution, got %v", err)
+		}
+		if _, err := svc.Query(ctx, "missing", "", "", 0, 0); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound for missing device, got %v", err)
+		}
+		scoped := repo.WithDeviceScope(ctx, []string{"other"})
+		if _, err := svc.Query(scoped, device.ID, "", "", 0, 0); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound outside device scope, got %v", err)
+		}
+	})
+
+	t.Run("Retention", func(t *testing.T) {
+		// 两天后原始采样过期，5 分钟和小时数据仍保留
+		if err := svc.Rollup(ctx, base.Add(48*time.Hour)); err != nil {
+			t.Fatalf("Rollup failed: %v", err)
+		}
+		for _, c := range []struct {
+			resolution models.TelemetryResolution
+			want       int
+		}{
+			{models.TelemetryResolutionRaw, 0},
+			{models.TelemetryResolution5Min, 2},
+			{models.TelemetryResolutionHourly, 1},
+		} {
+			rows, err := telemetryRepo.FindRange(ctx, device.ID, c.resolution, 0, base.Add(48*time.Hour).UnixMilli())
+			if err != nil {
+				t.Fatalf("FindRange failed: %v", err)
+			}
+			if len(rows) != c.want {
+				t.Errorf("Expected %d %s rows, got %d", c.want, c.resolution, len(rows))
+			}
+		}
+	})
+}
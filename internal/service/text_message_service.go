@@ -4,27 +4,36 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sort"
+	"html"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/repo"
 
+	"github.com/go-orz/cache"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // TextMessageService 短信服务
 type TextMessageService struct {
-	repo   *repo.TextMessageRepo
-	logger *zap.Logger
+	repo      *repo.TextMessageRepo
+	stateRepo *repo.ConversationStateRepo
+	logger    *zap.Logger
+	// 清空短信的一次性确认令牌
+	clearTokens cache.Cache[string, bool]
 }
 
 // NewTextMessageService 创建短信服务实例
-func NewTextMessageService(logger *zap.Logger, repo *repo.TextMessageRepo) *TextMessageService {
+func NewTextMessageService(logger *zap.Logger, repo *repo.TextMessageRepo, stateRepo *repo.ConversationStateRepo) *TextMessageService {
 	return &TextMessageService{
-		repo:   repo,
-		logger: logger,
+		repo:        repo,
+		stateRepo:   stateRepo,
+		logger:      logger,
+		clearTokens: cache.New[string, bool](clearTokenTTL),
 	}
 }
 
@@ -34,6 +43,7 @@ type Stats struct {
 	IncomingCount int64 `json:"incomingCount"`
 	OutgoingCount int64 `json:"outgoingCount"`
 	TodayCount    int64 `json:"todayCount"`
+	UnreadCount   int64 `json:"unreadCount"`
 }
 
 // Conversation 会话信息
@@ -41,12 +51,38 @@ type Conversation struct {
 	Peer         string              `json:"peer"`         // 对方号码
 	LastMessage  *models.TextMessage `json:"lastMessage"`  // 最后一条消息
 	MessageCount int64               `json:"messageCount"` // 消息总数
-	UnreadCount  int64               `json:"unreadCount"`  // 未读数量（暂时为0）
-	LastTime     int64               `json:"-"`            // 最后消息时间（用于排序，不暴露给前端）
+	UnreadCount  int64               `json:"unreadCount"`  // 未读数量
+	Pinned       bool                `json:"pinned"`       // 是否置顶
+	Archived     bool                `json:"archived"`     // 是否归档
+	Muted        bool                `json:"muted"`        // 是否免打扰
+}
+
+// ConversationQuery 会话列表查询参数
+type ConversationQuery struct {
+	Archived bool  // 是否查询已归档会话
+	Pinned   *bool // 是否置顶（为空不过滤）
+	Cursor   string
+	Limit    int
+}
+
+// ConversationStatePatch 会话状态更新，为空的字段保持不变
+type ConversationStatePatch struct {
+	Pinned   *bool `json:"pinned"`
+	Archived *bool `json:"archived"`
+	Muted    *bool `json:"muted"`
+}
+
+// UnreadCounts 未读统计
+type UnreadCounts struct {
+	Total   int64                    `json:"total"`
+	Devices []repo.DeviceUnreadCount `json:"devices"`
 }
 
 // Save 保存短信记录
 func (s *TextMessageService) Save(ctx context.Context, msg *models.TextMessage) error {
+	if msg.Type == models.MessageTypeIncoming && msg.OTPCode == "" {
+		msg.OTPCode = ExtractOTP(msg.Content)
+	}
 	if err := s.repo.Save(ctx, msg); err != nil {
 		s.logger.Error("保存短信记录失败", zap.Error(err), zap.String("id", msg.ID))
 		return fmt.Errorf("保存短信记录失败: %w", err)
@@ -67,9 +103,9 @@ func (s *TextMessageService) Get(ctx context.Context, id string) (*models.TextMe
 	return &msg, nil
 }
 
-// Delete 删除单条短信记录
+// Delete 删除单条短信记录（移入回收站）
 func (s *TextMessageService) Delete(ctx context.Context, id string) error {
-	if err := s.repo.DeleteById(ctx, id); err != nil {
+	if _, err := s.repo.SoftDeleteById(ctx, id); err != nil {
 		s.logger.Error("删除短信记录失败", zap.Error(err), zap.String("id", id))
 		return fmt.Errorf("删除短信记录失败: %w", err)
 	}
@@ -77,14 +113,26 @@ func (s *TextMessageService) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// Clear 清空所有短信记录
-func (s *TextMessageService) Clear(ctx context.Context) error {
-	db := s.repo.GetDB(ctx)
-	if err := db.Where("1 = 1").Delete(&models.TextMessage{}).Error; err != nil {
+// IssueClearToken 生成清空短信所需的一次性确认令牌，返回令牌及过期时间（时间戳毫秒）
+func (s *TextMessageService) IssueClearToken() (string, int64) {
+	token := uuid.NewString()
+	s.clearTokens.Set(token, true, clearTokenTTL)
+	return token, time.Now().Add(clearTokenTTL).UnixMilli()
+}
+
+// Clear 清空所有短信记录（移入回收站），需要先通过 IssueClearToken 获取确认令牌
+func (s *TextMessageService) Clear(ctx context.Context, token string) error {
+	if _, ok := s.clearTokens.Get(token); token == "" || !ok {
+		return ErrInvalidConfirmToken
+	}
+	s.clearTokens.Delete(token)
+
+	deleted, err := s.repo.SoftDeleteAll(ctx)
+	if err != nil {
 		s.logger.Error("清空短信记录失败", zap.Error(err))
 		return fmt.Errorf("清空短信记录失败: %w", err)
 	}
-	s.logger.Info("清空短信记录成功")
+	s.logger.Info("清空短信记录成功", zap.Int64("deleted_count", deleted))
 	return nil
 }
 
@@ -99,17 +147,19 @@ func (s *TextMessageService) GetStats(ctx context.Context) (*Stats, error) {
 		TotalCount    int64
 		IncomingCount int64
 		OutgoingCount int64
+		UnreadCount   int64
 	}
 
 	result := countResult{}
 	todayStart := time.Now().Truncate(24 * time.Hour).UnixMilli()
 
 	// 使用 CASE WHEN 表达式在单次查询中获取所有计数
-	if err := db.Model(&models.TextMessage{}).
+	if err := repo.ScopeDevices(ctx, db.Model(&models.TextMessage{}), "device_id").
 		Select(`
 			COUNT(*) as total_count,
 			COUNT(CASE WHEN type = 'incoming' THEN 1 END) as incoming_count,
-			COUNT(CASE WHEN type = 'outgoing' THEN 1 END) as outgoing_count
+			COUNT(CASE WHEN type = 'outgoing' THEN 1 END) as outgoing_count,
+			COUNT(CASE WHEN type = 'incoming' AND read_at = 0 THEN 1 END) as unread_count
 		`).
 		Scan(&result).Error; err != nil {
 		return nil, fmt.Errorf("统计失败: %w", err)
@@ -118,9 +168,10 @@ func (s *TextMessageService) GetStats(ctx context.Context) (*Stats, error) {
 	stats.TotalCount = result.TotalCount
 	stats.IncomingCount = result.IncomingCount
 	stats.OutgoingCount = result.OutgoingCount
+	stats.UnreadCount = result.UnreadCount
 
 	// 今日数量单独查询（因为需要动态计算日期）
-	if err := db.Model(&models.TextMessage{}).
+	if err := repo.ScopeDevices(ctx, db.Model(&models.TextMessage{}), "device_id").
 		Where("created_at >= ?", todayStart).
 		Count(&stats.TodayCount).Error; err != nil {
 		return nil, fmt.Errorf("统计今日数量失败: %w", err)
@@ -135,119 +186,472 @@ func (s *TextMessageService) UpdateStatusById(ctx context.Context, id string, st
 	})
 }
 
-// GetConversations 获取会话列表（按对方号码分组）
-func (s *TextMessageService) GetConversations(ctx context.Context) ([]*Conversation, error) {
-	db := s.repo.GetDB(ctx)
+// GetConversations 获取会话列表（按最后消息时间倒序游标分页）
+func (s *TextMessageService) GetConversations(ctx context.Context, q ConversationQuery) (*Page[*Conversation], error) {
+	after, err := repo.DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	limit := normalizePageLimit(q.Limit)
+
+	// 多取一条用于判断是否还有下一页
+	rows, err := s.repo.FindConversations(ctx, repo.ConversationFilter{
+		Archived: q.Archived,
+		Pinned:   q.Pinned,
+		Cursor:   after,
+		Limit:    limit + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取会话列表失败: %w", err)
+	}
 
-	// 使用 UNION 将 incoming 和 outgoing 查询合并为一次
-	// 先获取每个 peer 的消息数和最后消息时间
-	type peerSummary struct {
-		Peer         string
-		MessageCount int64
-		LastTime     int64
-	}
-
-	// 使用 UNION ALL 合并查询（比 UNION 快，因为不进行去重）
-	unionQuery := `
-		SELECT from_number as peer, COUNT(*) as message_count, MAX(created_at) as last_time
-		FROM text_messages
-		WHERE type = 'incoming' AND from_number != ''
-		GROUP BY from_number
-		UNION ALL
-		SELECT to_number as peer, COUNT(*) as message_count, MAX(created_at) as last_time
-		FROM text_messages
-		WHERE type = 'outgoing' AND to_number != ''
-		GROUP BY to_number
-	`
-
-	var summaries []peerSummary
-	if err := db.Raw(unionQuery).Scan(&summaries).Error; err != nil {
-		return nil, fmt.Errorf("获取会话统计失败: %w", err)
-	}
-
-	// 合并统计结果（合并相同 peer 的数据）
-	peerMap := make(map[string]*Conversation)
-	for _, s := range summaries {
-		if conv, exists := peerMap[s.Peer]; exists {
-			conv.MessageCount += s.MessageCount
-			if s.LastTime > conv.LastMessage.CreatedAt {
-				conv.LastTime = s.LastTime
-			}
-		} else {
-			peerMap[s.Peer] = &Conversation{
-				Peer:         s.Peer,
-				MessageCount: s.MessageCount,
-				LastTime:     s.LastTime,
-			}
-		}
+	page := &Page[*Conversation]{Items: make([]*Conversation, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		page.NextCursor = repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
 	}
 
-	// 批量获取每个 peer 的最后一条消息（使用子查询优化）
-	for peer, conv := range peerMap {
-		var lastMsg models.TextMessage
-		// 使用子查询获取每个 peer 的最后消息
-		subQuery := `
-			SELECT * FROM text_messages
-			WHERE (type = 'incoming' AND from_number = ?) OR (type = 'outgoing' AND to_number = ?)
-			ORDER BY created_at DESC
-			LIMIT 1
-		`
-		if err := db.Raw(subQuery, peer, peer).First(&lastMsg).Error; err != nil {
-			continue
-		}
-		conv.LastMessage = &lastMsg
-		conv.UnreadCount = 0 // 暂时不实现未读计数
+	for _, row := range rows {
+		lastMsg := row.TextMessage
+		page.Items = append(page.Items, &Conversation{
+			Peer:         row.Peer,
+			LastMessage:  &lastMsg,
+			MessageCount: row.MessageCount,
+			UnreadCount:  row.UnreadCount,
+			Pinned:       row.Pinned,
+			Archived:     row.Archived,
+			Muted:        row.Muted,
+		})
+	}
+
+	return page, nil
+}
+
+// GetConversationMessages 获取指定会话的消息
+// 每页为游标之前（更早）的最新 limit 条，页内按时间正序排列，nextCursor 指向更早的消息
+func (s *TextMessageService) GetConversationMessages(ctx context.Context, peer string, cursor string, limit int) (*Page[models.TextMessage], error) {
+	before, err := repo.DecodeCursor(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	limit = normalizePageLimit(limit)
+
+	peer = models.NormalizePeer(peer)
+	messages, err := s.repo.FindConversationMessages(ctx, peer, before, limit+1)
+	if err != nil {
+		s.logger.Error("获取会话消息失败", zap.Error(err), zap.String("peer", peer))
+		return nil, fmt.Errorf("获取会话消息失败: %w", err)
+	}
+
+	page := &Page[models.TextMessage]{}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		oldest := messages[limit-1]
+		page.NextCursor = repo.Cursor{CreatedAt: oldest.CreatedAt, ID: oldest.ID}.Encode()
 	}
 
-	// 转换为切片
-	conversations := make([]*Conversation, 0, len(peerMap))
-	for _, conv := range peerMap {
-		if conv.LastMessage != nil {
-			conversations = append(conversations, conv)
+	// 查询结果为倒序，翻转为正序便于按聊天顺序展示
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	page.Items = messages
+	if page.Items == nil {
+		page.Items = []models.TextMessage{}
+	}
+
+	return page, nil
+}
+
+// DeleteConversation 删除整个会话（与某个联系人的所有消息，移入回收站）
+// 会话状态（如免打扰）保留，之后收到该号码的短信仍然生效
+func (s *TextMessageService) DeleteConversation(ctx context.Context, peer string) error {
+	peer = models.NormalizePeer(peer)
+	deleted, err := s.repo.DeleteByPeer(ctx, peer)
+	if err != nil {
+		s.logger.Error("删除会话失败", zap.Error(err), zap.String("peer", peer))
+		return fmt.Errorf("删除会话失败: %w", err)
+	}
+
+	s.logger.Info("删除会话成功", zap.String("peer", peer), zap.Int64("deleted_count", deleted))
+	return nil
+}
+
+// MarkConversationRead 将会话中的未读短信标记为已读，返回标记条数
+func (s *TextMessageService) MarkConversationRead(ctx context.Context, peer string) (int64, error) {
+	peer = models.NormalizePeer(peer)
+	updated, err := s.repo.MarkReadByPeer(ctx, peer, time.Now().UnixMilli())
+	if err != nil {
+		s.logger.Error("标记会话已读失败", zap.Error(err), zap.String("peer", peer))
+		return 0, fmt.Errorf("标记会话已读失败: %w", err)
+	}
+	return updated, nil
+}
+
+// MarkAllRead 将所有未读短信标记为已读，deviceID 不为空时只处理该设备
+func (s *TextMessageService) MarkAllRead(ctx context.Context, deviceID string) (int64, error) {
+	updated, err := s.repo.MarkAllRead(ctx, deviceID, time.Now().UnixMilli())
+	if err != nil {
+		s.logger.Error("全部标记已读失败", zap.Error(err), zap.String("device_id", deviceID))
+		return 0, fmt.Errorf("全部标记已读失败: %w", err)
+	}
+	s.logger.Info("全部标记已读", zap.String("device_id", deviceID), zap.Int64("updated", updated))
+	return updated, nil
+}
+
+// GetUnreadCounts 获取未读总数及各设备未读数
+func (s *TextMessageService) GetUnreadCounts(ctx context.Context) (*UnreadCounts, error) {
+	devices, err := s.repo.CountUnreadByDevice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("统计未读短信失败: %w", err)
+	}
+
+	counts := &UnreadCounts{Devices: devices}
+	if counts.Devices == nil {
+		counts.Devices = []repo.DeviceUnreadCount{}
+	}
+	for _, device := range devices {
+		counts.Total += device.Count
+	}
+	return counts, nil
+}
+
+// UpdateConversationState 更新会话置顶、归档、免打扰状态
+// 会话状态按号码全局生效（免打扰对所有设备的通知有效），受限用户只能修改可访问设备上有短信的会话，否则返回 gorm.ErrRecordNotFound
+func (s *TextMessageService) UpdateConversationState(ctx context.Context, peer string, patch *ConversationStatePatch) (*models.ConversationState, error) {
+	peer = models.NormalizePeer(peer)
+	if _, restricted := repo.DeviceScopeFrom(ctx); restricted {
+		exists, err := s.repo.HasPeer(ctx, peer)
+		if err != nil {
+			return nil, fmt.Errorf("获取会话失败: %w", err)
 		}
+		if !exists {
+			return nil, gorm.ErrRecordNotFound
+		}
+	}
+	state, err := s.stateRepo.FindByPeer(ctx, peer)
+	if err != nil {
+		return nil, fmt.Errorf("获取会话状态失败: %w", err)
 	}
 
-	// 按最后消息时间倒序排序
-	sort.Slice(conversations, func(i, j int) bool {
-		return conversations[i].LastMessage.CreatedAt > conversations[j].LastMessage.CreatedAt
-	})
+	if patch.Pinned != nil {
+		state.Pinned = *patch.Pinned
+	}
+	if patch.Archived != nil {
+		state.Archived = *patch.Archived
+	}
+	if patch.Muted != nil {
+		state.Muted = *patch.Muted
+	}
 
-	return conversations, nil
+	if err := s.stateRepo.Save(ctx, &state); err != nil {
+		s.logger.Error("保存会话状态失败", zap.Error(err), zap.String("peer", peer))
+		return nil, fmt.Errorf("保存会话状态失败: %w", err)
+	}
+	s.logger.Info("更新会话状态",
+		zap.String("peer", peer),
+		zap.Bool("pinned", state.Pinned),
+		zap.Bool("archived", state.Archived),
+		zap.Bool("muted", state.Muted))
+	return &state, nil
 }
 
-// GetConversationMessages 获取指定会话的所有消息
-func (s *TextMessageService) GetConversationMessages(ctx context.Context, peer string) ([]models.TextMessage, error) {
-	db := s.repo.GetDB(ctx)
+// IsConversationMuted 判断会话是否免打扰，查询失败时按未免打扰处理以免漏发通知
+func (s *TextMessageService) IsConversationMuted(ctx context.Context, peer string) bool {
+	muted, err := s.stateRepo.IsMuted(ctx, models.NormalizePeer(peer))
+	if err != nil {
+		s.logger.Error("查询会话免打扰状态失败", zap.Error(err), zap.String("peer", peer))
+		return false
+	}
+	return muted
+}
 
-	var messages []models.TextMessage
+// TrashItem 回收站条目
+type TrashItem struct {
+	models.TextMessage
+	DeletedAt int64 `json:"deletedAt"` // 删除时间（时间戳毫秒）
+}
 
-	// 查询条件：(type=incoming AND from_number=peer) OR (type=outgoing AND to_number=peer)
-	if err := db.Where("(type = ? AND from_number = ?) OR (type = ? AND to_number = ?)",
-		models.MessageTypeIncoming, peer,
-		models.MessageTypeOutgoing, peer,
-	).Order("created_at ASC").Find(&messages).Error; err != nil {
-		s.logger.Error("获取会话消息失败", zap.Error(err), zap.String("peer", peer))
-		return nil, fmt.Errorf("获取会话消息失败: %w", err)
+// GetTrash 按时间倒序游标分页获取回收站中的短信
+func (s *TextMessageService) GetTrash(ctx context.Context, cursor string, limit int) (*Page[TrashItem], error) {
+	after, err := repo.DecodeCursor(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
 	}
+	limit = normalizePageLimit(limit)
 
-	return messages, nil
+	msgs, err := s.repo.FindTrash(ctx, after, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("获取回收站失败: %w", err)
+	}
+
+	page := &Page[TrashItem]{Items: make([]TrashItem, 0, len(msgs))}
+	if len(msgs) > limit {
+		msgs = msgs[:limit]
+		last := msgs[limit-1]
+		page.NextCursor = repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	for _, msg := range msgs {
+		page.Items = append(page.Items, TrashItem{
+			TextMessage: msg,
+			DeletedAt:   msg.DeletedAt.Time.UnixMilli(),
+		})
+	}
+	return page, nil
 }
 
-// DeleteConversation 删除整个会话（与某个联系人的所有消息）
-func (s *TextMessageService) DeleteConversation(ctx context.Context, peer string) error {
-	db := s.repo.GetDB(ctx)
+// Restore 从回收站恢复单条短信，返回恢复条数
+func (s *TextMessageService) Restore(ctx context.Context, id string) (int64, error) {
+	restored, err := s.repo.RestoreById(ctx, id)
+	if err != nil {
+		s.logger.Error("恢复短信失败", zap.Error(err), zap.String("id", id))
+		return 0, fmt.Errorf("恢复短信失败: %w", err)
+	}
+	return restored, nil
+}
 
-	// 删除条件：(type=incoming AND from_number=peer) OR (type=outgoing AND to_number=peer)
-	result := db.Where("(type = ? AND from_number = ?) OR (type = ? AND to_number = ?)",
-		models.MessageTypeIncoming, peer,
-		models.MessageTypeOutgoing, peer,
-	).Delete(&models.TextMessage{})
+// RestoreConversation 从回收站恢复会话的所有短信，返回恢复条数
+func (s *TextMessageService) RestoreConversation(ctx context.Context, peer string) (int64, error) {
+	peer = models.NormalizePeer(peer)
+	restored, err := s.repo.RestoreByPeer(ctx, peer)
+	if err != nil {
+		s.logger.Error("恢复会话失败", zap.Error(err), zap.String("peer", peer))
+		return 0, fmt.Errorf("恢复会话失败: %w", err)
+	}
+	s.logger.Info("恢复会话成功", zap.String("peer", peer), zap.Int64("restored", restored))
+	return restored, nil
+}
+
+// RestoreAll 恢复回收站中的所有短信，返回恢复条数
+func (s *TextMessageService) RestoreAll(ctx context.Context) (int64, error) {
+	restored, err := s.repo.RestoreAll(ctx)
+	if err != nil {
+		s.logger.Error("恢复回收站失败", zap.Error(err))
+		return 0, fmt.Errorf("恢复回收站失败: %w", err)
+	}
+	s.logger.Info("恢复回收站成功", zap.Int64("restored", restored))
+	return restored, nil
+}
 
-	if result.Error != nil {
-		s.logger.Error("删除会话失败", zap.Error(result.Error), zap.String("peer", peer))
-		return fmt.Errorf("删除会话失败: %w", result.Error)
+// EmptyTrash 清空回收站（永久删除），返回删除条数
+func (s *TextMessageService) EmptyTrash(ctx context.Context) (int64, error) {
+	purged, err := s.repo.PurgeTrash(ctx, time.Time{}, trashPurgeBatchSize)
+	if err != nil {
+		s.logger.Error("清空回收站失败", zap.Error(err), zap.Int64("purged", purged))
+		return purged, fmt.Errorf("清空回收站失败: %w", err)
 	}
+	s.logger.Info("清空回收站成功", zap.Int64("purged", purged))
+	return purged, nil
+}
+
+const (
+	// defaultPageLimit 分页默认返回条数
+	defaultPageLimit = 20
+	// maxPageLimit 分页最大返回条数
+	maxPageLimit = 100
+	// snippetContextRunes LIKE 回退时摘要保留的上下文字数
+	snippetContextRunes = 24
+	// otpBackfillBatchSize 验证码回填每批处理条数
+	otpBackfillBatchSize = 500
+	// trashPurgeBatchSize 回收站每批永久删除条数
+	trashPurgeBatchSize = 1000
+	// clearTokenTTL 清空确认令牌有效期
+	clearTokenTTL = 5 * time.Minute
+)
+
+// ErrInvalidCursor 分页游标无效
+var ErrInvalidCursor = errors.New("无效的分页游标")
+
+// ErrInvalidConfirmToken 清空确认令牌无效或已过期
+var ErrInvalidConfirmToken = errors.New("确认令牌无效或已过期")
+
+// Page 游标分页结果
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"` // 为空表示没有更多数据
+}
+
+// normalizePageLimit 规范化分页条数
+func normalizePageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		return maxPageLimit
+	}
+	return limit
+}
+
+// SearchRequest 短信搜索请求
+type SearchRequest struct {
+	Keyword  string
+	DeviceID string
+	Peer     string
+	Type     models.MessageType
+	Status   models.MessageStatus
+	StartAt  int64
+	EndAt    int64
+	HasOTP   *bool
+	Cursor   string
+	Limit    int
+}
+
+// SearchItem 搜索结果条目
+type SearchItem struct {
+	models.TextMessage
+	Snippet string `json:"snippet"` // 高亮摘要，命中词以 <mark></mark> 包裹
+}
+
+// InitSearchIndex 初始化全文索引，不支持时回退到 LIKE 搜索
+func (s *TextMessageService) InitSearchIndex(ctx context.Context) error {
+	enabled, err := s.repo.EnsureSearchIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("初始化全文索引失败: %w", err)
+	}
+	if enabled {
+		s.logger.Info("短信全文索引已启用")
+	} else {
+		s.logger.Info("当前数据库不支持 FTS5，短信搜索使用 LIKE 查询")
+	}
+	return nil
+}
 
-	s.logger.Info("删除会话成功", zap.String("peer", peer), zap.Int64("deleted_count", result.RowsAffected))
+// BackfillOTPCodes 为历史接收短信补充验证码识别结果
+func (s *TextMessageService) BackfillOTPCodes(ctx context.Context) error {
+	var after *repo.Cursor
+	var updated int
+	for {
+		msgs, err := s.repo.FindOTPCandidates(ctx, otpKeywords, after, otpBackfillBatchSize)
+		if err != nil {
+			return fmt.Errorf("查询待识别短信失败: %w", err)
+		}
+		for _, msg := range msgs {
+			code := ExtractOTP(msg.Content)
+			if code == "" {
+				continue
+			}
+			if err := s.repo.UpdateColumnsById(ctx, msg.ID, map[string]interface{}{"otp_code": code}); err != nil {
+				return fmt.Errorf("更新验证码失败: %w", err)
+			}
+			updated++
+		}
+		if len(msgs) < otpBackfillBatchSize {
+			break
+		}
+		last := msgs[len(msgs)-1]
+		after = &repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	if updated > 0 {
+		s.logger.Info("历史短信验证码识别完成", zap.Int("updated", updated))
+	}
 	return nil
 }
+
+// Search 全文搜索短信
+func (s *TextMessageService) Search(ctx context.Context, req *SearchRequest) (*Page[SearchItem], error) {
+	cursor, err := repo.DecodeCursor(req.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	limit := normalizePageLimit(req.Limit)
+
+	// 多取一条用于判断是否还有下一页
+	hits, err := s.repo.Search(ctx, repo.MessageSearchFilter{
+		Keyword:  req.Keyword,
+		DeviceID: req.DeviceID,
+		Peer:     req.Peer,
+		Type:     req.Type,
+		Status:   req.Status,
+		StartAt:  req.StartAt,
+		EndAt:    req.EndAt,
+		HasOTP:   req.HasOTP,
+		Cursor:   cursor,
+		Limit:    limit + 1,
+	})
+	if err != nil {
+		s.logger.Error("搜索短信失败", zap.Error(err), zap.String("keyword", req.Keyword))
+		return nil, fmt.Errorf("搜索短信失败: %w", err)
+	}
+
+	result := &Page[SearchItem]{Items: make([]SearchItem, 0, len(hits))}
+	if len(hits) > limit {
+		hits = hits[:limit]
+		last := hits[limit-1]
+		result.NextCursor = repo.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+
+	terms := strings.Fields(req.Keyword)
+	for _, hit := range hits {
+		snippet := hit.Snippet
+		if snippet == "" && len(terms) > 0 {
+			snippet = buildSnippet(hit.Content, terms)
+		}
+		result.Items = append(result.Items, SearchItem{
+			TextMessage: hit.TextMessage,
+			Snippet:     renderSnippet(snippet),
+		})
+	}
+
+	return result, nil
+}
+
+// buildSnippet LIKE 回退时在 Go 侧生成摘要，标记所有命中词
+func buildSnippet(content string, terms []string) string {
+	lower := strings.ToLower(content)
+
+	// 定位第一个命中位置，截取其前后上下文
+	first := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, strings.ToLower(term)); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 || len(lower) != len(content) {
+		// 大小写转换改变了字节长度时无法对齐位置，直接返回原文
+		return content
+	}
+
+	start := first
+	for n := 0; start > 0 && n < snippetContextRunes; n++ {
+		_, size := utf8.DecodeLastRuneInString(content[:start])
+		start -= size
+	}
+	end := first
+	for n := 0; end < len(content) && n < snippetContextRunes*2; n++ {
+		_, size := utf8.DecodeRuneInString(content[end:])
+		end += size
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	window, windowLower := content[start:end], lower[start:end]
+	for i := 0; i < len(window); {
+		matched := 0
+		for _, term := range terms {
+			t := strings.ToLower(term)
+			if strings.HasPrefix(windowLower[i:], t) && len(t) > matched {
+				matched = len(t)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(repo.HighlightStart + window[i:i+matched] + repo.HighlightEnd)
+			i += matched
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(window[i:])
+		b.WriteString(window[i : i+size])
+		i += size
+	}
+	if end < len(content) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// renderSnippet 转义 HTML 后将高亮占位符替换为 <mark> 标签
+func renderSnippet(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	return strings.NewReplacer(repo.HighlightStart, "<mark>", repo.HighlightEnd, "</mark>").Replace(escaped)
+}
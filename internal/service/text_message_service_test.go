@@ -2,19 +2,22 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/Starktomy/smshub/internal/models"
 	"github.com/Starktomy/smshub/internal/repo"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 func TestTextMessageService_Conversations(t *testing.T) {
 	db := setupTestDB(t)
 	msgRepo := repo.NewTextMessageRepo(db)
 	logger := zap.NewExample()
-	svc := NewTextMessageService(logger, msgRepo)
+	svc := NewTextMessageService(logger, msgRepo, repo.NewConversationStateRepo(db))
 	ctx := context.Background()
 
 	// 准备测试数据
@@ -58,10 +61,11 @@ func TestTextMessageService_Conversations(t *testing.T) {
 
 	// 测试 GetConversations
 	t.Run("GetConversations", func(t *testing.T) {
-		convs, err := svc.GetConversations(ctx)
+		page, err := svc.GetConversations(ctx, ConversationQuery{})
 		if err != nil {
 			t.Fatalf("GetConversations failed: %v", err)
 		}
+		convs := page.Items
 
 		if len(convs) != 2 {
 			t.Errorf("Expected 2 conversations, got %d", len(convs))
@@ -87,12 +91,12 @@ func TestTextMessageService_Conversations(t *testing.T) {
 
 	// 测试 GetConversationMessages
 	t.Run("GetConversationMessages", func(t *testing.T) {
-		msgs, err := svc.GetConversationMessages(ctx, "10086")
+		page, err := svc.GetConversationMessages(ctx, "10086", "", 0)
 		if err != nil {
 			t.Fatalf("GetConversationMessages failed: %v", err)
 		}
-		if len(msgs) != 2 {
-			t.Errorf("Expected 2 messages, got %d", len(msgs))
+		if len(page.Items) != 2 {
+			t.Errorf("Expected 2 messages, got %d", len(page.Items))
 		}
 	})
 
@@ -104,12 +108,402 @@ func TestTextMessageService_Conversations(t *testing.T) {
 		}
 
 		// 验证删除
-		msgs, err := svc.GetConversationMessages(ctx, "10086")
+		page, err := svc.GetConversationMessages(ctx, "10086", "", 0)
 		if err != nil {
 			t.Fatalf("GetConversationMessages after delete failed: %v", err)
 		}
-		if len(msgs) != 0 {
-			t.Errorf("Expected 0 messages after delete, got %d", len(msgs))
+		if len(page.Items) != 0 {
+			t.Errorf("Expected 0 messages after delete, got %d", len(page.Items))
+		}
+	})
+}
+
+func TestTextMessageService_Search(t *testing.T) {
+	db := setupTestDB(t)
+	msgRepo := repo.NewTextMessageRepo(db)
+	svc := NewTextMessageService(zap.NewNop(), msgRepo, repo.NewConversationStateRepo(db))
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour).UnixMilli()
+	for i, content := range []string{
+		"您的验证码为 482913，5分钟内有效",
+		"<b>余额</b>提醒：您的余额不足",
+		"余额查询结果：12.50 元",
+	} {
+		msg := &models.TextMessage{
+			ID:        fmt.Sprintf("search-%d", i),
+			From:      "10086",
+			Content:   content,
+			Type:      models.MessageTypeIncoming,
+			Status:    models.MessageStatusReceived,
+			CreatedAt: base + int64(i),
+		}
+		if err := svc.Save(ctx, msg); err != nil {
+			t.Fatalf("Failed to save message: %v", err)
+		}
+	}
+
+	t.Run("OTPDetectedOnSave", func(t *testing.T) {
+		hasOTP := true
+		result, err := svc.Search(ctx, &SearchRequest{HasOTP: &hasOTP})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(result.Items) != 1 || result.Items[0].OTPCode != "482913" {
+			t.Errorf("Expected one OTP message with code 482913, got %+v", result.Items)
+		}
+	})
+
+	t.Run("SnippetEscapedAndHighlighted", func(t *testing.T) {
+		result, err := svc.Search(ctx, &SearchRequest{Keyword: "余额", Limit: 1})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(result.Items) != 1 {
+			t.Fatalf("Expected 1 item, got %d", len(result.Items))
+		}
+		if result.Items[0].ID != "search-2" {
+			t.Errorf("Expected newest message first, got %s", result.Items[0].ID)
+		}
+		if result.NextCursor == "" {
+			t.Fatal("Expected next cursor")
+		}
+
+		next, err := svc.Search(ctx, &SearchRequest{Keyword: "余额", Limit: 1, Cursor: result.NextCursor})
+		if err != nil {
+			t.Fatalf("Search next page failed: %v", err)
+		}
+		want := "&lt;b&gt;<mark>余额</mark>&lt;/b&gt;提醒：您的<mark>余额</mark>不足"
+		if len(next.Items) != 1 || next.Items[0].Snippet != want {
+			t.Errorf("Expected snippet %q, got %+v", want, next.Items)
+		}
+		if next.NextCursor != "" {
+			t.Errorf("Expected no more pages, got cursor %s", next.NextCursor)
+		}
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		if _, err := svc.Search(ctx, &SearchRequest{Cursor: "%%%"}); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+
+	t.Run("BackfillOTPCodes", func(t *testing.T) {
+		// 模拟升级前未识别验证码的历史数据
+		if err := msgRepo.Create(ctx, &models.TextMessage{
+			ID: "legacy", From: "95588", Content: "Your verification code is 7781",
+			Type: models.MessageTypeIncoming, CreatedAt: base - 1,
+		}); err != nil {
+			t.Fatalf("Failed to create legacy message: %v", err)
+		}
+		if err := svc.BackfillOTPCodes(ctx); err != nil {
+			t.Fatalf("BackfillOTPCodes failed: %v", err)
+		}
+		msg, err := svc.Get(ctx, "legacy")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if msg.OTPCode != "7781" {
+			t.Errorf("Expected backfilled OTP 7781, got %q", msg.OTPCode)
+		}
+	})
+}
+
+func TestTextMessageService_ConversationPagination(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewTextMessageService(zap.NewNop(), repo.NewTextMessageRepo(db), repo.NewConversationStateRepo(db))
+	ctx := context.Background()
+
+	// 3 个会话，每个会话 3 条消息，时间交错
+	peers := []string{"10010", "10086", "95588"}
+	for i := 0; i < 9; i++ {
+		peer := peers[i%3]
+		msg := &models.TextMessage{
+			ID:        fmt.Sprintf("page-%d", i),
+			Content:   fmt.Sprintf("message %d", i),
+			CreatedAt: int64(1000 + i),
+		}
+		if i%2 == 0 {
+			msg.Type, msg.From = models.MessageTypeIncoming, peer
+		} else {
+			msg.Type, msg.To = models.MessageTypeOutgoing, peer
+		}
+		if err := svc.Save(ctx, msg); err != nil {
+			t.Fatalf("Failed to save message: %v", err)
+		}
+	}
+
+	t.Run("Conversations", func(t *testing.T) {
+		first, err := svc.GetConversations(ctx, ConversationQuery{Limit: 2})
+		if err != nil {
+			t.Fatalf("GetConversations failed: %v", err)
+		}
+		if len(first.Items) != 2 || first.NextCursor == "" {
+			t.Fatalf("Expected 2 conversations with next cursor, got %d (%q)", len(first.Items), first.NextCursor)
+		}
+		if first.Items[0].Peer != "95588" || first.Items[0].LastMessage.ID != "page-8" || first.Items[0].MessageCount != 3 {
+			t.Errorf("Unexpected first conversation: %+v", first.Items[0])
+		}
+		if first.Items[1].Peer != "10086" {
+			t.Errorf("Expected second conversation 10086, got %s", first.Items[1].Peer)
+		}
+
+		second, err := svc.GetConversations(ctx, ConversationQuery{Cursor: first.NextCursor, Limit: 2})
+		if err != nil {
+			t.Fatalf("GetConversations next page failed: %v", err)
+		}
+		if len(second.Items) != 1 || second.Items[0].Peer != "10010" || second.NextCursor != "" {
+			t.Errorf("Unexpected second page: %+v", second)
+		}
+	})
+
+	t.Run("Messages", func(t *testing.T) {
+		first, err := svc.GetConversationMessages(ctx, "10010", "", 2)
+		if err != nil {
+			t.Fatalf("GetConversationMessages failed: %v", err)
+		}
+		// 最新的两条，页内按时间正序
+		if len(first.Items) != 2 || first.Items[0].ID != "page-3" || first.Items[1].ID != "page-6" {
+			t.Fatalf("Unexpected first page: %+v", first.Items)
+		}
+
+		older, err := svc.GetConversationMessages(ctx, "10010", first.NextCursor, 2)
+		if err != nil {
+			t.Fatalf("GetConversationMessages older page failed: %v", err)
+		}
+		if len(older.Items) != 1 || older.Items[0].ID != "page-0" || older.NextCursor != "" {
+			t.Errorf("Unexpected older page: %+v", older)
+		}
+	})
+}
+
+func TestTextMessageService_ReadStateAndConversationState(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewTextMessageService(zap.NewNop(), repo.NewTextMessageRepo(db), repo.NewConversationStateRepo(db))
+	ctx := context.Background()
+
+	for i, from := range []string{"138-0013-8000", "13800138000", "10086"} {
+		msg := &models.TextMessage{
+			ID:        fmt.Sprintf("read-%d", i),
+			From:      from,
+			Content:   "hello",
+			Type:      models.MessageTypeIncoming,
+			Status:    models.MessageStatusReceived,
+			DeviceID:  "dev-1",
+			CreatedAt: int64(1000 + i),
+		}
+		if err := svc.Save(ctx, msg); err != nil {
+			t.Fatalf("Failed to save message: %v", err)
+		}
+	}
+
+	t.Run("NormalizedPeerGrouped", func(t *testing.T) {
+		page, err := svc.GetConversations(ctx, ConversationQuery{})
+		if err != nil {
+			t.Fatalf("GetConversations failed: %v", err)
+		}
+		if len(page.Items) != 2 {
+			t.Fatalf("Expected 2 conversations, got %d", len(page.Items))
+		}
+		if page.Items[1].Peer != "13800138000" || page.Items[1].UnreadCount != 2 {
+			t.Errorf("Unexpected conversation: %+v", page.Items[1])
+		}
+	})
+
+	t.Run("MarkConversationRead", func(t *testing.T) {
+		updated, err := svc.MarkConversationRead(ctx, "138 0013 8000")
+		if err != nil {
+			t.Fatalf("MarkConversationRead failed: %v", err)
+		}
+		if updated != 2 {
+			t.Errorf("Expected 2 messages marked read, got %d", updated)
+		}
+
+		counts, err := svc.GetUnreadCounts(ctx)
+		if err != nil {
+			t.Fatalf("GetUnreadCounts failed: %v", err)
+		}
+		if counts.Total != 1 || len(counts.Devices) != 1 || counts.Devices[0].Count != 1 {
+			t.Errorf("Unexpected unread counts: %+v", counts)
+		}
+
+		stats, err := svc.GetStats(ctx)
+		if err != nil {
+			t.Fatalf("GetStats failed: %v", err)
+		}
+		if stats.UnreadCount != 1 {
+			t.Errorf("Expected 1 unread in stats, got %d", stats.UnreadCount)
+		}
+	})
+
+	t.Run("MarkAllRead", func(t *testing.T) {
+		if _, err := svc.MarkAllRead(ctx, ""); err != nil {
+			t.Fatalf("MarkAllRead failed: %v", err)
+		}
+		counts, err := svc.GetUnreadCounts(ctx)
+		if err != nil {
+			t.Fatalf("GetUnreadCounts failed: %v", err)
+		}
+		if counts.Total != 0 || len(counts.Devices) != 0 {
+			t.Errorf("Expected no unread messages, got %+v", counts)
+		}
+	})
+
+	t.Run("ConversationState", func(t *testing.T) {
+		muted, pinned := true, true
+		state, err := svc.UpdateConversationState(ctx, "138-0013-8000", &ConversationStatePatch{Muted: &muted})
+		if err != nil {
+			t.Fatalf("UpdateConversationState failed: %v", err)
+		}
+		if state.Peer != "13800138000" || !state.Muted || state.Pinned {
+			t.Errorf("Unexpected state: %+v", state)
+		}
+
+		// 未提供的字段保持不变
+		state, err = svc.UpdateConversationState(ctx, "13800138000", &ConversationStatePatch{Pinned: &pinned})
+		if err != nil {
+			t.Fatalf("UpdateConversationState failed: %v", err)
+		}
+		if !state.Muted || !state.Pinned {
+			t.Errorf("Expected muted and pinned, got %+v", state)
+		}
+
+		if !svc.IsConversationMuted(ctx, "(138) 0013 8000") {
+			t.Error("Expected conversation to be muted")
+		}
+		if svc.IsConversationMuted(ctx, "10086") {
+			t.Error("Expected 10086 not muted")
+		}
+
+		page, err := svc.GetConversations(ctx, ConversationQuery{Pinned: &pinned})
+		if err != nil {
+			t.Fatalf("GetConversations failed: %v", err)
+		}
+		if len(page.Items) != 1 || !page.Items[0].Pinned || !page.Items[0].Muted {
+			t.Errorf("Expected one pinned conversation, got %+v", page.Items)
+		}
+	})
+
+	t.Run("DeviceScope", func(t *testing.T) {
+		muted := true
+		// 受限用户不能修改其他设备上或不存在的会话
+		other := repo.WithDeviceScope(ctx, []string{"dev-2"})
+		if _, err := svc.UpdateConversationState(other, "10086", &ConversationStatePatch{Muted: &muted}); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound outside device scope, got %v", err)
+		}
+		scoped := repo.WithDeviceScope(ctx, []string{"dev-1"})
+		if _, err := svc.UpdateConversationState(scoped, "10000", &ConversationStatePatch{Muted: &muted}); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Expected ErrRecordNotFound for unknown peer, got %v", err)
+		}
+		if svc.IsConversationMuted(ctx, "10086") || svc.IsConversationMuted(ctx, "10000") {
+			t.Error("Expected rejected updates not to mute")
+		}
+		if _, err := svc.UpdateConversationState(scoped, "10086", &ConversationStatePatch{Muted: &muted}); err != nil {
+			t.Fatalf("UpdateConversationState failed: %v", err)
+		}
+		if !svc.IsConversationMuted(ctx, "10086") {
+			t.Error("Expected 10086 muted")
+		}
+	})
+}
+
+func TestTextMessageService_Trash(t *testing.T) {
+	db := setupTestDB(t)
+	svc := NewTextMessageService(zap.NewNop(), repo.NewTextMessageRepo(db), repo.NewConversationStateRepo(db))
+	ctx := context.Background()
+
+	for i, from := range []string{"10086", "10086", "95588"} {
+		msg := &models.TextMessage{
+			ID:        fmt.Sprintf("trash-%d", i),
+			From:      from,
+			Content:   "余额提醒",
+			Type:      models.MessageTypeIncoming,
+			CreatedAt: int64(1000 + i),
+		}
+		if err := svc.Save(ctx, msg); err != nil {
+			t.Fatalf("Failed to save message: %v", err)
+		}
+	}
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		if err := svc.Delete(ctx, "trash-2"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if err := svc.DeleteConversation(ctx, "10086"); err != nil {
+			t.Fatalf("DeleteConversation failed: %v", err)
+		}
+
+		if _, err := svc.Get(ctx, "trash-2"); err == nil {
+			t.Error("Deleted message should not be returned by Get")
+		}
+		stats, err := svc.GetStats(ctx)
+		if err != nil {
+			t.Fatalf("GetStats failed: %v", err)
+		}
+		if stats.TotalCount != 0 {
+			t.Errorf("Expected 0 messages outside trash, got %d", stats.TotalCount)
+		}
+		result, err := svc.Search(ctx, &SearchRequest{Keyword: "余额"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(result.Items) != 0 {
+			t.Errorf("Search should not return deleted messages, got %d", len(result.Items))
+		}
+
+		page, err := svc.GetTrash(ctx, "", 2)
+		if err != nil {
+			t.Fatalf("GetTrash failed: %v", err)
+		}
+		if len(page.Items) != 2 || page.Items[0].ID != "trash-2" || page.Items[0].DeletedAt == 0 || page.NextCursor == "" {
+			t.Errorf("Unexpected trash page: %+v", page)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		restored, err := svc.RestoreConversation(ctx, "10086")
+		if err != nil {
+			t.Fatalf("RestoreConversation failed: %v", err)
+		}
+		if restored != 2 {
+			t.Errorf("Expected 2 restored, got %d", restored)
+		}
+		conversations, err := svc.GetConversations(ctx, ConversationQuery{})
+		if err != nil {
+			t.Fatalf("GetConversations failed: %v", err)
+		}
+		if len(conversations.Items) != 1 || conversations.Items[0].Peer != "10086" || conversations.Items[0].MessageCount != 2 {
+			t.Errorf("Unexpected conversations after restore: %+v", conversations.Items)
+		}
+	})
+
+	t.Run("ClearRequiresToken", func(t *testing.T) {
+		if err := svc.Clear(ctx, ""); err != ErrInvalidConfirmToken {
+			t.Errorf("Expected ErrInvalidConfirmToken, got %v", err)
+		}
+		token, expiresAt := svc.IssueClearToken()
+		if token == "" || expiresAt <= time.Now().UnixMilli() {
+			t.Fatalf("Unexpected token %q expiring at %d", token, expiresAt)
+		}
+		if err := svc.Clear(ctx, token); err != nil {
+			t.Fatalf("Clear failed: %v", err)
+		}
+		// 令牌只能使用一次
+		if err := svc.Clear(ctx, token); err != ErrInvalidConfirmToken {
+			t.Errorf("Expected token to be single-use, got %v", err)
+		}
+	})
+
+	t.Run("EmptyTrash", func(t *testing.T) {
+		purged, err := svc.EmptyTrash(ctx)
+		if err != nil {
+			t.Fatalf("EmptyTrash failed: %v", err)
+		}
+		if purged != 3 {
+			t.Errorf("Expected 3 purged, got %d", purged)
+		}
+		if restored, _ := svc.RestoreAll(ctx); restored != 0 {
+			t.Errorf("Expected nothing to restore after purge, got %d", restored)
 		}
 	})
 }
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/Starktomy/smshub/internal/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultTOTPIssuer 验证器应用中显示的默认发行方
+	defaultTOTPIssuer = "SMSHub"
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+	// totpSkew 允许前后各一个时间步（30 秒）的时钟误差
+	totpSkew = 1
+)
+
+var (
+	// ErrTOTPRequired 已启用两步验证，需要提供验证码
+	ErrTOTPRequired = errors.New("需要两步验证码")
+	// ErrInvalidTOTP 验证码或恢复码错误
+	ErrInvalidTOTP = errors.New("两步验证码错误")
+	// ErrTOTPEnabled 已经启用两步验证
+	ErrTOTPEnabled = errors.New("已启用两步验证")
+	// ErrTOTPNotEnabled 未启用两步验证
+	ErrTOTPNotEnabled = errors.New("未启用两步验证")
+	// ErrTOTPNotSetup 启用前需要先生成密钥
+	ErrTOTPNotSetup = errors.New("请先生成两步验证密钥")
+	// ErrTOTPNotLocalUser OIDC 用户的两步验证由身份提供方负责
+	ErrTOTPNotLocalUser = errors.New("OIDC 用户的两步验证由身份提供方管理")
+)
+
+// TOTPSetup 两步验证密钥，用验证器应用扫描 URL 或手动输入密钥
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"` // otpauth:// 地址，可生成二维码
+}
+
+// TOTPService 本地用户的两步验证（TOTP）和恢复码
+type TOTPService struct {
+	logger *zap.Logger
+	repo   *repo.UserRepo
+	issuer string
+	now    func() time.Time
+}
+
+// NewTOTPService 创建两步验证服务实例，issuer 为空时使用 SMSHub
+func NewTOTPService(logger *zap.Logger, db *gorm.DB, issuer string) *TOTPService {
+	if issuer == "" {
+		issuer = defaultTOTPIssuer
+	}
+	return &TOTPService{
+		logger: logger,
+		repo:   repo.NewUserRepo(db),
+		issuer: issuer,
+		now:    time.Now,
+	}
+}
+
+// Setup 生成新的待确认密钥，确认验证码后才会启用
+func (s *TOTPService) Setup(ctx context.Context, userID string) (*TOTPSetup, error) {
+	user, err := s.localUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	if err := s.repo.Save(ctx, &user); err != nil {
+		return nil, err
+	}
+	return &TOTPSetup{
+		Secret: secret,
+		URL:    util.TOTPURL(s.issuer, user.Username, secret),
+	}, nil
+}
+
+// Enable 使用验证码确认密钥并启用两步验证，返回恢复码（只显示一次）
+func (s *TOTPService) Enable(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.localUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotSetup
+	}
+	step, ok := util.ValidateTOTP(user.TOTPSecret, normalizeCode(code), s.now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidTOTP
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	if err := s.repo.Save(ctx, &user); err != nil {
+		return nil, err
+	}
+	s.logger.Info("启用两步验证", zap.String("username", user.Username))
+	return codes, nil
+}
+
+// Disable 验证密码和验证码（或恢复码）后关闭两步验证
+func (s *TOTPService) Disable(ctx context.Context, userID, password, code string) error {
+	user, err := s.localUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	if err := s.Verify(ctx, &user, code); err != nil {
+		return err
+	}
+	return s.clear(ctx, &user)
+}
+
+// RegenerateRecoveryCodes 验证验证码后生成新的恢复码，旧恢复码全部失效
+func (s *TOTPService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.localUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPNotEnabled
+	}
+	if err := s.Verify(ctx, &user, code); err != nil {
+		return nil, err
+	}
+	// Verify 可能更新了时间步或恢复码，重新读取后再保存
+	if user, err = s.repo.FindById(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.RecoveryCodes = hashes
+	if err := s.repo.Save(ctx, &user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Reset 管理员为丢失验证器的用户关闭两步验证
+func (s *TOTPService) Reset(ctx context.Context, userID string) error {
+	user, err := s.repo.FindById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.clear(ctx, &user)
+}
+
+// Verify 校验登录时提交的 6 位验证码或恢复码，同一验证码只能使用一次，恢复码使用后删除
+func (s *TOTPService) Verify(ctx context.Context, user *models.User, code string) error {
+	code = normalizeCode(code)
+	if code == "" {
+		return ErrTOTPRequired
+	}
+
+	if step, ok := util.ValidateTOTP(user.TOTPSecret, code, s.now(), totpSkew); ok {
+		updated, err := s.repo.UpdateTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			s.logger.Warn("两步验证码被重复使用", zap.String("username", user.Username))
+			return ErrInvalidTOTP
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	// 不是有效的验证码时按恢复码处理
+	hash := hashToken(code)
+	hashes := strings.Split(user.RecoveryCodes, ",")
+	for i, h := range hashes {
+		if h == "" || h != hash {
+			continue
+		}
+		remaining := strings.Join(append(hashes[:i:i], hashes[i+1:]...), ",")
+		updated, err := s.repo.UpdateRecoveryCodes(ctx, user.ID, user.RecoveryCodes, remaining)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return ErrInvalidTOTP
+		}
+		user.RecoveryCodes = remaining
+		s.logger.Info("使用恢复码登录", zap.String("username", user.Username), zap.Int("remaining", len(hashes)-1))
+		return nil
+	}
+	return ErrInvalidTOTP
+}
+
+// localUser 查找本地用户，OIDC 用户的两步验证由身份提供方负责
+func (s *TOTPService) localUser(ctx context.Context, userID string) (models.User, error) {
+	user, err := s.repo.FindById(ctx, userID)
+	if err != nil {
+		return user, err
+	}
+	if user.Source != models.UserSourceLocal {
+		return user, ErrTOTPNotLocalUser
+	}
+	return user, nil
+}
+
+// clear 清除两步验证密钥和恢复码
+func (s *TOTPService) clear(ctx context.Context, user *models.User) error {
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = ""
+	if err := s.repo.Save(ctx, user); err != nil {
+		return err
+	}
+	s.logger.Info("关闭两步验证", zap.String("username", user.Username))
+	return nil
+}
+
+// newRecoveryCodes 生成恢复码，返回明文（xxxxx-xxxxx）和逗号分隔的哈希
+func newRecoveryCodes() ([]string, string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		code := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+	return codes, strings.Join(hashes, ","), nil
+}
+
+// normalizeCode 去掉空格和连字符，恢复码不区分大小写
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/util"
+	"go.uber.org/zap"
+)
+
+func TestTOTPServiceLogin(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	users := NewUserService(zap.NewNop(), db)
+	totp := NewTOTPService(zap.NewNop(), db, "")
+	now := time.Now()
+	totp.now = func() time.Time { return now }
+	accounts := NewAccountService(zap.NewNop(), &OIDCService{}, users,
+		NewSessionService(zap.NewNop(), db, config.JWTConfig{Secret: "very-long-secret-key-at-least-32-bytes"}),
+		totp, NewLoginGuard(zap.NewNop(), config.LoginConfig{}))
+
+	user, err := users.Create(ctx, &CreateUserRequest{Username: "alice", Password: "alice-password", Role: models.RoleOperator})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// 生成密钥后需要验证码确认才会启用
+	setup, err := totp.Setup(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if _, err := accounts.Login(ctx, "alice", "alice-password", "", ClientInfo{}); err != nil {
+		t.Fatalf("未确认的密钥不应影响登录: %v", err)
+	}
+	if _, err := totp.Enable(ctx, user.ID, "000000"); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected ErrInvalidTOTP, got %v", err)
+	}
+	code := func(offset time.Duration) string {
+		c, err := util.TOTPCode(setup.Secret, util.TOTPStep(now.Add(offset)))
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		return c
+	}
+	recoveryCodes, err := totp.Enable(ctx, user.ID, code(-30*time.Second))
+	if err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d", recoveryCodeCount, len(recoveryCodes))
+	}
+
+	// 启用后登录需要验证码
+	if _, err := accounts.Login(ctx, "alice", "alice-password", "", ClientInfo{}); !errors.Is(err, ErrTOTPRequired) {
+		t.Errorf("Expected ErrTOTPRequired, got %v", err)
+	}
+	if _, err := accounts.Login(ctx, "alice", "alice-password", "123456", ClientInfo{}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected ErrInvalidTOTP, got %v", err)
+	}
+	// 启用时使用过的验证码不能再次使用
+	if _, err := accounts.Login(ctx, "alice", "alice-password", code(-30*time.Second), ClientInfo{}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected replayed code to fail, got %v", err)
+	}
+	if _, err := accounts.Login(ctx, "alice", "alice-password", code(0), ClientInfo{}); err != nil {
+		t.Fatalf("Login with TOTP failed: %v", err)
+	}
+
+	// 恢复码只能使用一次，不区分大小写
+	recovery := recoveryCodes[0]
+	if _, err := accounts.Login(ctx, "alice", "alice-password", " "+recovery+" ", ClientInfo{}); err != nil {
+		t.Fatalf("Login with recovery code failed: %v", err)
+	}
+	if _, err := accounts.Login(ctx, "alice", "alice-password", recovery, ClientInfo{}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected used recovery code to fail, got %v", err)
+	}
+
+	// 重新生成恢复码后旧恢复码失效
+	newCodes, err := totp.RegenerateRecoveryCodes(ctx, user.ID, recoveryCodes[1])
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes failed: %v", err)
+	}
+	if _, err := accounts.Login(ctx, "alice", "alice-password", recoveryCodes[2], ClientInfo{}); !errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("Expected old recovery code to fail, got %v", err)
+	}
+
+	// 关闭需要密码和验证码
+	if err := totp.Disable(ctx, user.ID, "wrong-password", newCodes[0]); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+	if err := totp.Disable(ctx, user.ID, "alice-password", newCodes[0]); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if _, err := accounts.Login(ctx, "alice", "alice-password", "", ClientInfo{}); err != nil {
+		t.Errorf("关闭两步验证后应能直接登录: %v", err)
+	}
+}
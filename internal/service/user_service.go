@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Starktomy/smshub/internal/models"
+	"github.com/Starktomy/smshub/internal/repo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// minPasswordLength 密码最小长度
+const minPasswordLength = 8
+
+var (
+	// ErrInvalidUser 用户信息无效
+	ErrInvalidUser = errors.New("用户名不能为空且角色必须为 admin、operator 或 viewer")
+	// ErrUserExists 用户名已存在
+	ErrUserExists = errors.New("用户名已存在")
+	// ErrWeakPassword 密码太短
+	ErrWeakPassword = errors.New("密码至少需要 8 位")
+	// ErrWrongPassword 原密码错误
+	ErrWrongPassword = errors.New("原密码错误")
+	// ErrNotLocalUser OIDC 用户没有本地密码
+	ErrNotLocalUser = errors.New("OIDC 用户不能设置密码")
+	// ErrLastAdmin 不能删除或降级最后一个管理员
+	ErrLastAdmin = errors.New("至少需要保留一个管理员")
+	// ErrDeleteSelf 不能删除自己
+	ErrDeleteSelf = errors.New("不能删除当前登录的用户")
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+)
+
+// CreateUserRequest 创建用户请求
+type CreateUserRequest struct {
+	Username string          `json:"username"`
+	Nickname string          `json:"nickname"`
+	Password string          `json:"password"`
+	Role     models.UserRole `json:"role"`
+}
+
+// UpdateUserRequest 更新用户请求，为空的字段不修改
+type UpdateUserRequest struct {
+	Nickname *string          `json:"nickname"`
+	Role     *models.UserRole `json:"role"`
+}
+
+// UserService 用户管理服务
+type UserService struct {
+	logger      *zap.Logger
+	repo        *repo.UserRepo
+	grantRepo   *repo.DeviceGrantRepo
+	sessionRepo *repo.SessionRepo
+}
+
+// NewUserService 创建用户服务实例
+func NewUserService(logger *zap.Logger, db *gorm.DB) *UserService {
+	return &UserService{
+		logger:      logger,
+		repo:        repo.NewUserRepo(db),
+		grantRepo:   repo.NewDeviceGrantRepo(db),
+		sessionRepo: repo.NewSessionRepo(db),
+	}
+}
+
+// Bootstrap 首次启动时把配置文件中的用户导入为管理员，用户表不为空时不做任何操作
+func (s *UserService) Bootstrap(ctx context.Context, users map[string]string) error {
+	count, err := s.repo.Count(ctx)
+	if err != nil {
+		return err
+	}
+	if count > 0 || len(users) == 0 {
+		return nil
+	}
+
+	for username, hashedPassword := range users {
+		user := &models.User{
+			ID:       uuid.NewString(),
+			Username: username,
+			Nickname: username,
+			Password: hashedPassword,
+			Role:     models.RoleAdmin,
+			Source:   models.UserSourceLocal,
+		}
+		if err := s.repo.Create(ctx, user); err != nil {
+			return err
+		}
+		s.logger.Info("从配置文件导入管理员", zap.String("username", username))
+	}
+	return nil
+}
+
+// List 获取所有用户
+func (s *UserService) List(ctx context.Context) ([]models.User, error) {
+	return s.repo.FindAll(ctx)
+}
+
+// GetById 根据ID获取用户
+func (s *UserService) GetById(ctx context.Context, id string) (*models.User, error) {
+	user, err := s.repo.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByUsername 根据用户名获取用户
+func (s *UserService) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.repo.FindByUsername(ctx, username)
+}
+
+// HasLocalUsers 是否存在可以使用密码登录的用户
+func (s *UserService) HasLocalUsers(ctx context.Context) (bool, error) {
+	count, err := s.repo.CountBySource(ctx, models.UserSourceLocal)
+	return count > 0, err
+}
+
+// Create 创建本地用户
+func (s *UserService) Create(ctx context.Context, req *CreateUserRequest) (*models.User, error) {
+	username := strings.TrimSpace(req.Username)
+	if username == "" || !req.Role.Valid() {
+		return nil, ErrInvalidUser
+	}
+	hashedPassword, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.FindByUsername(ctx, username); err == nil {
+		return nil, ErrUserExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	nickname := strings.TrimSpace(req.Nickname)
+	if nickname == "" {
+		nickname = username
+	}
+	user := &models.User{
+		ID:       uuid.NewString(),
+		Username: username,
+		Nickname: nickname,
+		Password: hashedPassword,
+		Role:     req.Role,
+		Source:   models.UserSourceLocal,
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Update 更新用户昵称和角色，不允许把最后一个管理员降级
+func (s *UserService) Update(ctx context.Context, id string, req *UpdateUserRequest) (*models.User, error) {
+	user, err := s.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Nickname != nil {
+		user.Nickname = strings.TrimSpace(*req.Nickname)
+	}
+	if req.Role != nil && *req.Role != user.Role {
+		if !req.Role.Valid() {
+			return nil, ErrInvalidUser
+		}
+		if err := s.ensureNotLastAdmin(ctx, user); err != nil {
+			return nil, err
+		}
+		user.Role = *req.Role
+	}
+	if err := s.repo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Delete 删除用户及其设备授权和登录会话，不允许删除自己和最后一个管理员
+func (s *UserService) Delete(ctx context.Context, id, currentUsername string) error {
+	user, err := s.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Username == currentUsername {
+		return ErrDeleteSelf
+	}
+	if err := s.ensureNotLastAdmin(ctx, user); err != nil {
+		return err
+	}
+	if err := s.grantRepo.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteById(ctx, id)
+}
+
+// ChangePassword 用户修改自己的密码，需要验证原密码，成功后撤销除当前会话 sessionID 以外的所有会话
+func (s *UserService) ChangePassword(ctx context.Context, username, sessionID, oldPassword, newPassword string) error {
+	user, err := s.repo.FindByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if user.Source != models.UserSourceLocal {
+		return ErrNotLocalUser
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)) != nil {
+		return ErrWrongPassword
+	}
+	if err := s.setPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+	return s.sessionRepo.DeleteByUserExcept(ctx, user.ID, sessionID)
+}
+
+// ResetPassword 管理员重置用户密码并撤销其所有会话，password 为空时生成随机密码，返回新密码
+func (s *UserService) ResetPassword(ctx context.Context, id, password string) (string, error) {
+	user, err := s.GetById(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if user.Source != models.UserSourceLocal {
+		return "", ErrNotLocalUser
+	}
+	if password == "" {
+		if password, err = randomPassword(); err != nil {
+			return "", err
+		}
+	}
+	if err := s.setPassword(ctx, user, password); err != nil {
+		return "", err
+	}
+	if err := s.sessionRepo.DeleteByUser(ctx, id); err != nil {
+		return "", err
+	}
+	s.logger.Info("重置用户密码", zap.String("username", user.Username))
+	return password, nil
+}
+
+// Authenticate 验证本地用户的用户名和密码，成功时记录登录时间
+// 启用两步验证的用户在验证码通过后由调用方通过 RecordLogin 记录
+func (s *UserService) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := s.repo.FindByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Debug("用户不存在", zap.String("username", username))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if user.Source != models.UserSourceLocal || user.Password == "" {
+		s.logger.Debug("非本地用户不能使用密码登录", zap.String("username", username))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		s.logger.Debug("密码验证失败", zap.String("username", username), zap.Error(err))
+		return nil, ErrInvalidCredentials
+	}
+	if !user.TOTPEnabled {
+		s.touchLastLogin(ctx, user)
+	}
+	return user, nil
+}
+
+// RecordLogin 记录登录时间，失败不影响登录
+func (s *UserService) RecordLogin(ctx context.Context, user *models.User) {
+	s.touchLastLogin(ctx, user)
+}
+
+// SyncOIDCUser OIDC 登录时创建或更新用户，角色以分组映射结果为准
+func (s *UserService) SyncOIDCUser(ctx context.Context, username, nickname string, role models.UserRole) (*models.User, error) {
+	user, err := s.repo.FindByUsername(ctx, username)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user = &models.User{
+			ID:       uuid.NewString(),
+			Username: username,
+			Nickname: nickname,
+			Role:     role,
+			Source:   models.UserSourceOIDC,
+		}
+		if err := s.repo.Create(ctx, user); err != nil {
+			return nil, err
+		}
+		s.logger.Info("创建 OIDC 用户", zap.String("username", username), zap.String("role", string(role)))
+	case err != nil:
+		return nil, err
+	case user.Source != models.UserSourceOIDC:
+		// 不允许 OIDC 身份接管同名的本地账号
+		return nil, ErrUserExists
+	default:
+		user.Nickname = nickname
+		user.Role = role
+		if err := s.repo.Save(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	s.touchLastLogin(ctx, user)
+	return user, nil
+}
+
+// ensureNotLastAdmin 用户是管理员且是唯一的管理员时返回 ErrLastAdmin
+func (s *UserService) ensureNotLastAdmin(ctx context.Context, user *models.User) error {
+	if user.Role != models.RoleAdmin {
+		return nil
+	}
+	count, err := s.repo.CountByRole(ctx, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// setPassword 校验并保存新密码
+func (s *UserService) setPassword(ctx context.Context, user *models.User, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	return s.repo.Save(ctx, user)
+}
+
+// touchLastLogin 记录登录时间，失败不影响登录
+func (s *UserService) touchLastLogin(ctx context.Context, user *models.User) {
+	user.LastLogin = time.Now().UnixMilli()
+	if err := s.repo.UpdateLastLogin(ctx, user.ID, user.LastLogin); err != nil {
+		s.logger.Warn("更新登录时间失败", zap.String("username", user.Username), zap.Error(err))
+	}
+}
+
+// hashPassword 校验密码长度并生成 bcrypt 哈希
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", ErrWeakPassword
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// randomPassword 生成 16 位随机密码
+func randomPassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/models"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserService(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	svc := NewUserService(zap.NewNop(), db)
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("admin-password"), bcrypt.MinCost)
+	if err := svc.Bootstrap(ctx, map[string]string{"admin": string(hashed)}); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	// 用户表不为空时不再导入
+	if err := svc.Bootstrap(ctx, map[string]string{"other": string(hashed)}); err != nil {
+		t.Fatalf("Bootstrap failed: %v", err)
+	}
+	users, _ := svc.List(ctx)
+	if len(users) != 1 || users[0].Username != "admin" || users[0].Role != models.RoleAdmin {
+		t.Fatalf("Expected only bootstrapped admin, got %+v", users)
+	}
+	admin := users[0]
+
+	// 创建用户
+	if _, err := svc.Create(ctx, &CreateUserRequest{Username: "viewer", Password: "short", Role: models.RoleViewer}); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("Expected ErrWeakPassword, got %v", err)
+	}
+	if _, err := svc.Create(ctx, &CreateUserRequest{Username: "viewer", Password: "viewer-password", Role: "root"}); !errors.Is(err, ErrInvalidUser) {
+		t.Errorf("Expected ErrInvalidUser, got %v", err)
+	}
+	viewer, err := svc.Create(ctx, &CreateUserRequest{Username: "viewer", Password: "viewer-password", Role: models.RoleViewer})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := svc.Create(ctx, &CreateUserRequest{Username: "viewer", Password: "viewer-password", Role: models.RoleViewer}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+
+	// 登录
+	if _, err := svc.Authenticate(ctx, "viewer", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+	user, err := svc.Authenticate(ctx, "viewer", "viewer-password")
+	if err != nil || user.LastLogin == 0 {
+		t.Fatalf("Authenticate failed: %v %+v", err, user)
+	}
+
+	// 修改密码需要原密码，成功后只保留当前会话
+	expiresAt := time.Now().Add(time.Hour).UnixMilli()
+	for _, id := range []string{"current", "other"} {
+		db.Create(&models.Session{ID: id, UserID: viewer.ID, RefreshTokenHash: id, ExpiresAt: expiresAt})
+	}
+	db.Create(&models.Session{ID: "admin-session", UserID: admin.ID, RefreshTokenHash: "admin-session", ExpiresAt: expiresAt})
+	if err := svc.ChangePassword(ctx, "viewer", "current", "wrong-password", "new-password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Expected ErrWrongPassword, got %v", err)
+	}
+	if err := svc.ChangePassword(ctx, "viewer", "current", "viewer-password", "new-password"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "viewer", "new-password"); err != nil {
+		t.Errorf("新密码应能登录: %v", err)
+	}
+	var sessionIDs []string
+	db.Model(&models.Session{}).Order("id").Pluck("id", &sessionIDs)
+	if len(sessionIDs) != 2 || sessionIDs[0] != "admin-session" || sessionIDs[1] != "current" {
+		t.Errorf("Expected only current and other users' sessions to remain, got %v", sessionIDs)
+	}
+
+	// 重置密码，为空时生成随机密码
+	password, err := svc.ResetPassword(ctx, viewer.ID, "")
+	if err != nil || len(password) < minPasswordLength {
+		t.Fatalf("ResetPassword failed: %v %q", err, password)
+	}
+	if _, err := svc.Authenticate(ctx, "viewer", password); err != nil {
+		t.Errorf("重置后的密码应能登录: %v", err)
+	}
+
+	// 最后一个管理员不能降级或删除，也不能删除自己
+	role := models.RoleOperator
+	if _, err := svc.Update(ctx, admin.ID, &UpdateUserRequest{Role: &role}); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+	if err := svc.Delete(ctx, admin.ID, "viewer"); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("Expected ErrLastAdmin, got %v", err)
+	}
+	if err := svc.Delete(ctx, admin.ID, "admin"); !errors.Is(err, ErrDeleteSelf) {
+		t.Errorf("Expected ErrDeleteSelf, got %v", err)
+	}
+
+	// 提升为管理员后可以降级原管理员
+	role = models.RoleAdmin
+	if _, err := svc.Update(ctx, viewer.ID, &UpdateUserRequest{Role: &role}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	role = models.RoleOperator
+	updated, err := svc.Update(ctx, admin.ID, &UpdateUserRequest{Role: &role})
+	if err != nil || updated.Role != models.RoleOperator {
+		t.Errorf("Update failed: %v %+v", err, updated)
+	}
+}
+
+func TestUserServiceSyncOIDCUser(t *testing.T) {
+	ctx := context.Background()
+	svc := NewUserService(zap.NewNop(), setupTestDB(t))
+
+	user, err := svc.SyncOIDCUser(ctx, "alice@example.com", "Alice", models.RoleOperator)
+	if err != nil {
+		t.Fatalf("SyncOIDCUser failed: %v", err)
+	}
+	if user.Source != models.UserSourceOIDC || user.Role != models.RoleOperator {
+		t.Errorf("创建的 OIDC 用户不正确: %+v", user)
+	}
+
+	// 再次登录时按最新的分组映射更新角色
+	user, err = svc.SyncOIDCUser(ctx, "alice@example.com", "Alice", models.RoleViewer)
+	if err != nil || user.Role != models.RoleViewer {
+		t.Errorf("Expected role viewer, got %v %+v", err, user)
+	}
+
+	// OIDC 用户不能使用密码
+	if _, err := svc.ResetPassword(ctx, user.ID, ""); !errors.Is(err, ErrNotLocalUser) {
+		t.Errorf("Expected ErrNotLocalUser, got %v", err)
+	}
+	if _, err := svc.Authenticate(ctx, "alice@example.com", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	// 不能接管同名的本地账号
+	if _, err := svc.Create(ctx, &CreateUserRequest{Username: "bob", Password: "bob-password", Role: models.RoleViewer}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := svc.SyncOIDCUser(ctx, "bob", "Bob", models.RoleAdmin); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Expected ErrUserExists, got %v", err)
+	}
+}
+
+func TestOIDCMapRole(t *testing.T) {
+	svc := &OIDCService{config: &config.OIDCConfig{
+		RoleMapping: map[string]string{
+			"smshub-admins": "admin",
+			"smshub-ops":    "operator",
+			"broken":        "root",
+		},
+	}}
+
+	for _, tt := range []struct {
+		groups []string
+		want   models.UserRole
+	}{
+		{nil, models.RoleViewer},
+		{[]string{"other"}, models.RoleViewer},
+		{[]string{"broken"}, models.RoleViewer},
+		{[]string{"smshub-ops"}, models.RoleOperator},
+		{[]string{"smshub-ops", "smshub-admins"}, models.RoleAdmin},
+		{[]string{"smshub-admins", "smshub-ops"}, models.RoleAdmin},
+	} {
+		if got := svc.MapRole(tt.groups); got != tt.want {
+			t.Errorf("MapRole(%v) = %s, want %s", tt.groups, got, tt.want)
+		}
+	}
+
+	// 没有匹配分组时使用默认角色
+	svc.config.DefaultRole = "operator"
+	if got := svc.MapRole([]string{"other"}); got != models.RoleOperator {
+		t.Errorf("Expected default role operator, got %s", got)
+	}
+	if got := claimStrings([]any{"a", 1, "b"}); len(got) != 2 || got[1] != "b" {
+		t.Errorf("claimStrings = %v", got)
+	}
+}
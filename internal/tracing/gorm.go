@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormSpanKey 保存在 gorm.Statement 中的 span
+const gormSpanKey = "smshub:tracing_span"
+
+// GormPlugin 为数据库写操作（创建、更新、删除）生成 span，父 span 取自 db.WithContext 传入的 context
+// 查询不生成 span，避免列表页等读操作产生大量 span
+type GormPlugin struct{}
+
+// Name 实现 gorm.Plugin
+func (GormPlugin) Name() string {
+	return "smshub:tracing"
+}
+
+// Initialize 实现 gorm.Plugin
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	return errors.Join(
+		callback.Create().Before("gorm:create").Register("tracing:before_create", startSpan("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", startSpan("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+	)
+}
+
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+			// 没有父 span 的写操作（后台任务、心跳更新等）不单独生成 trace
+			return
+		}
+		_, span := Tracer.Start(ctx, "db."+operation+" "+db.Statement.Table,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", db.Dialector.Name()),
+				attribute.String("db.operation", operation),
+				attribute.String("db.sql.table", db.Statement.Table),
+			))
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	span.SetAttributes(attribute.Int64("db.rows_affected", db.Statement.RowsAffected))
+	End(span, db.Error)
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type tracingRecord struct {
+	ID   int
+	Name string
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	dsn := fmt.Sprintf("file:tracing_%d?mode=memory&cache=shared", time.Now().UnixNano())
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&tracingRecord{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有父 span 的写操作不生成 span
+	if err := db.Create(&tracingRecord{ID: 1, Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n := len(recorder.Ended()); n != 0 {
+		t.Fatalf("没有父 span 时不应生成 span，实际 %d 个", n)
+	}
+
+	ctx, parent := Tracer.Start(context.Background(), "parent")
+	if err := db.WithContext(ctx).Create(&tracingRecord{ID: 2, Name: "b"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Model(&tracingRecord{}).Where("id = ?", 2).Update("name", "c").Error; err != nil {
+		t.Fatal(err)
+	}
+	var records []tracingRecord
+	if err := db.WithContext(ctx).Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Delete(&tracingRecord{}, 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	var names []string
+	for _, span := range spans {
+		if span.Name() == "parent" {
+			continue
+		}
+		names = append(names, span.Name())
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s 的父 span 不正确", span.Name())
+		}
+		if !hasAttribute(span.Attributes(), attribute.Int64("db.rows_affected", 1)) {
+			t.Errorf("%s 缺少影响行数: %v", span.Name(), span.Attributes())
+		}
+	}
+	want := []string{"db.create tracing_records", "db.update tracing_records", "db.delete tracing_records"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("span 应为 %v（查询不生成 span），实际 %v", want, names)
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+// Package tracing 初始化 OpenTelemetry 链路追踪并通过 OTLP 导出
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Starktomy/smshub/config"
+	"github.com/Starktomy/smshub/internal/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultServiceName 未配置时的服务名称
+	defaultServiceName = "smshub"
+	// instrumentationName 应用自身埋点使用的 tracer 名称
+	instrumentationName = "github.com/Starktomy/smshub"
+)
+
+// Tracer 应用埋点使用的 tracer，未启用链路追踪时为空实现
+var Tracer = otel.Tracer(instrumentationName)
+
+// Setup 按配置创建 OTLP 导出器并设置全局 TracerProvider，返回的函数在退出时刷新并关闭导出器
+// 未启用时不做任何处理，埋点产生的 span 直接丢弃
+func Setup(ctx context.Context, tracingConfig config.TracingConfig, logger *zap.Logger) (func(context.Context) error, error) {
+	if !tracingConfig.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, tracingConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServiceName(tracingConfig)),
+		semconv.ServiceVersion(version.GetVersion()),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := tracingConfig.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("链路追踪导出失败", zap.Error(err))
+	}))
+
+	logger.Info("链路追踪已启用",
+		zap.String("protocol", protocol(tracingConfig)),
+		zap.String("endpoint", tracingConfig.Endpoint),
+		zap.Float64("sampleRatio", ratio))
+	return provider.Shutdown, nil
+}
+
+// newExporter 按协议创建 OTLP 导出器，Endpoint 为空时使用导出器的默认地址
+func newExporter(ctx context.Context, tracingConfig config.TracingConfig) (*otlptrace.Exporter, error) {
+	switch protocol(tracingConfig) {
+	case "grpc":
+		opts := []otlptracegrpc.Option{}
+		if tracingConfig.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(tracingConfig.Endpoint))
+		}
+		if tracingConfig.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(tracingConfig.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(tracingConfig.Headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "http":
+		opts := []otlptracehttp.Option{}
+		if tracingConfig.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(tracingConfig.Endpoint))
+		}
+		if tracingConfig.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(tracingConfig.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(tracingConfig.Headers))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("不支持的 OTLP 协议: %s", tracingConfig.Protocol)
+	}
+}
+
+// ServiceName 返回配置的服务名称，未配置时为 smshub
+func ServiceName(tracingConfig config.TracingConfig) string {
+	if tracingConfig.ServiceName == "" {
+		return defaultServiceName
+	}
+	return tracingConfig.ServiceName
+}
+
+func protocol(tracingConfig config.TracingConfig) string {
+	if tracingConfig.Protocol == "" {
+		return "grpc"
+	}
+	return tracingConfig.Protocol
+}
+
+// End 结束 span，err 不为空时记录错误并标记失败
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -183,6 +183,15 @@ function process_uart_command(cmd_data)
         log.info("CMD", "重启模块")
         pm.reboot()
         send_to_uart({type = "cmd_response", action = "reboot_mcu", result = "ok"})
+
+    elseif cmd_data.action == "send_ussd" then
+        -- 当前固件未提供 USSD 接口，直接回复不支持，避免上位机等待超时
+        send_to_uart({
+            type = "ussd_result",
+            request_id = cmd_data.request_id,
+            success = false,
+            msg = "unsupported"
+        })
     else
         send_to_uart({type = "error", msg = "unknown command"})
     end
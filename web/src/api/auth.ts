@@ -3,12 +3,16 @@ import apiClient from './client';
 export interface LoginRequest {
     username: string;
     password: string;
+    // 两步验证码或恢复码，启用两步验证的用户需要提供
+    code?: string;
 }
 
 export interface LoginResponse {
     token: string;
     username: string;
     expiresAt: number;
+    refreshToken: string;
+    refreshExpiresAt: number;
 }
 
 // 认证配置
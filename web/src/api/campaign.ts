@@ -0,0 +1,102 @@
+// 定时群发
+import apiClient from "@/api/client.ts";
+
+export type CampaignStatus = 'scheduled' | 'running' | 'paused' | 'completed' | 'cancelled';
+
+export type CampaignRecipientStatus = 'queued' | 'sent' | 'delivered' | 'failed' | 'cancelled';
+
+export interface CampaignProgress {
+    total: number;
+    queued: number;
+    sent: number;
+    delivered: number;
+    failed: number;
+    cancelled: number;
+}
+
+export interface Campaign {
+    id: string;
+    name: string;
+    content: string;
+    deviceId?: string;
+    strategy?: string;
+    startAt: number;
+    quietStart?: string;
+    quietEnd?: string;
+    timezone?: string;
+    ratePerMinute: number;
+    status: CampaignStatus;
+    startedAt: number;
+    finishedAt: number;
+    createdAt: number;
+    updatedAt: number;
+    progress: CampaignProgress;
+}
+
+export interface CampaignRecipient {
+    id: string;
+    campaignId: string;
+    seq: number;
+    phoneNumber: string;
+    vars?: Record<string, string>;
+    status: CampaignRecipientStatus;
+    msgId: string;
+    deviceId: string;
+    error: string;
+    sentAt: number;
+}
+
+export interface CampaignRecipientPage {
+    items: CampaignRecipient[];
+    nextCursor?: string;
+}
+
+export type CreateCampaignRequest = Pick<Campaign, 'name' | 'content' | 'deviceId' | 'strategy' | 'startAt' | 'quietStart' | 'quietEnd' | 'timezone' | 'ratePerMinute'> & {
+    recipients?: { phoneNumber: string; vars?: Record<string, string> }[];
+    csv?: string;
+};
+
+// 获取群发活动列表
+export const getCampaigns = () => {
+    return apiClient.get<Campaign[]>('/campaigns');
+};
+
+// 获取群发活动详情
+export const getCampaign = (id: string) => {
+    return apiClient.get<Campaign>(`/campaigns/${id}`);
+};
+
+// 创建群发活动
+export const createCampaign = (req: CreateCampaignRequest) => {
+    return apiClient.post<Campaign>('/campaigns', req);
+};
+
+// 读取 CSV 文件创建群发活动
+export const uploadCampaign = async (req: CreateCampaignRequest, file: File) => {
+    return createCampaign({...req, csv: await file.text()});
+};
+
+// 获取群发活动收件人
+export const getCampaignRecipients = (id: string, status?: CampaignRecipientStatus, cursor?: string, limit = 50) => {
+    return apiClient.get<CampaignRecipientPage>(`/campaigns/${id}/recipients`, {params: {status, cursor, limit}});
+};
+
+// 暂停群发活动
+export const pauseCampaign = (id: string) => {
+    return apiClient.post<{ message: string }>(`/campaigns/${id}/pause`, {});
+};
+
+// 恢复群发活动
+export const resumeCampaign = (id: string) => {
+    return apiClient.post<{ message: string }>(`/campaigns/${id}/resume`, {});
+};
+
+// 取消群发活动
+export const cancelCampaign = (id: string) => {
+    return apiClient.post<{ message: string }>(`/campaigns/${id}/cancel`, {});
+};
+
+// 删除群发活动
+export const deleteCampaign = (id: string) => {
+    return apiClient.delete<{ message: string }>(`/campaigns/${id}`);
+};
@@ -7,8 +7,39 @@ interface RequestOptions extends RequestInit {
     params?: Record<string, any>;
 }
 
+// 接口错误，保留响应状态码和错误响应体
+export class ApiError extends Error {
+    readonly status: number;
+    // eslint-disable-next-line @typescript-eslint/no-explicit-any
+    readonly data?: any;
+
+    // eslint-disable-next-line @typescript-eslint/no-explicit-any
+    constructor(message: string, status: number, data?: any) {
+        super(message);
+        this.name = 'ApiError';
+        this.status = status;
+        this.data = data;
+    }
+}
+
+// 不需要刷新令牌的接口，这些接口返回 401 时直接交给调用方处理
+const NO_REFRESH_PATHS = ['/login', '/auth/refresh'];
+
+// 清除本地登录状态并跳转到登录页面
+const redirectToLogin = () => {
+    localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
+    localStorage.removeItem('username');
+
+    if (typeof window !== 'undefined') {
+        window.location.href = '/login';
+    }
+};
+
 class ApiClient {
     private readonly baseURL: string;
+    // 正在进行的刷新请求，多个请求同时遇到 401 时共用同一次刷新
+    private refreshing: Promise<boolean> | null = null;
 
     constructor(baseURL: string) {
         this.baseURL = baseURL;
@@ -29,9 +60,45 @@ class ApiClient {
         return url.toString();
     }
 
+    // 使用刷新令牌换取新的访问令牌，成功返回 true
+    private refreshToken(): Promise<boolean> {
+        if (!this.refreshing) {
+            this.refreshing = this.doRefresh().finally(() => {
+                this.refreshing = null;
+            });
+        }
+        return this.refreshing;
+    }
+
+    private async doRefresh(): Promise<boolean> {
+        const refreshToken = localStorage.getItem('refreshToken');
+        if (!refreshToken) {
+            return false;
+        }
+        try {
+            const response = await fetch(this.buildURL('/auth/refresh'), {
+                method: 'POST',
+                headers: {'Content-Type': 'application/json'},
+                body: JSON.stringify({refreshToken}),
+            });
+            if (!response.ok) {
+                return false;
+            }
+            const data = await response.json();
+            localStorage.setItem('token', data.token);
+            localStorage.setItem('refreshToken', data.refreshToken);
+            localStorage.setItem('username', data.username);
+            return true;
+        } catch (error) {
+            console.error('刷新令牌失败:', error);
+            return false;
+        }
+    }
+
     private async request<T>(
         path: string,
-        options: RequestOptions = {}
+        options: RequestOptions = {},
+        retried = false
     ): Promise<T> {
         const {params, ...fetchOptions} = options;
 
@@ -52,24 +119,22 @@ class ApiClient {
                 headers,
             });
 
-            // 处理未授权
-            if (response.status === 401) {
-                // 清除 localStorage 中的 token
-                localStorage.removeItem('token');
-                localStorage.removeItem('username');
-
-                // 跳转到登录页面
-                if (typeof window !== 'undefined') {
-                    window.location.href = '/login';
+            // 处理未授权，访问令牌过期时先尝试刷新并重试一次
+            if (response.status === 401 && !NO_REFRESH_PATHS.includes(path)) {
+                if (!retried && await this.refreshToken()) {
+                    return this.request<T>(path, options, true);
                 }
+                redirectToLogin();
                 throw new Error('未授权，请重新登录');
             }
 
             // 处理错误响应
             if (!response.ok) {
                 let errorMessage = `HTTP ${response.status}: ${response.statusText}`;
+                // eslint-disable-next-line @typescript-eslint/no-explicit-any
+                let errorJson: any;
                 try {
-                    const errorJson = await response.json();
+                    errorJson = await response.json();
                     if (errorJson && errorJson.error) {
                         errorMessage = errorJson.error;
                     } else if (errorJson && errorJson.message) {
@@ -80,7 +145,7 @@ class ApiClient {
                     const errorText = await response.text();
                     if (errorText) errorMessage = errorText;
                 }
-                throw new Error(errorMessage);
+                throw new ApiError(errorMessage, response.status, errorJson);
             }
 
             // 解析 JSON 响应
@@ -41,22 +41,69 @@ export interface BatchSendRequest {
   content: string;
   deviceId?: string;
   strategy?: 'auto' | 'round_robin' | 'random' | 'signal_best';
+  callbackUrl?: string;
 }
 
-export interface BatchSendResult {
+export interface BatchJobResult {
+  seq: number;
   recipient: string;
+  status: 'pending' | 'success' | 'failed';
   messageId: string;
   deviceId: string;
-  success: boolean;
   error?: string;
+  updatedAt: number;
+}
+
+export interface BatchJob {
+  id: string;
+  content: string;
+  deviceId: string;
+  strategy: string;
+  callbackUrl?: string;
+  callbackStatus?: string;
+  status: 'pending' | 'running' | 'completed';
+  total: number;
+  pending: number;
+  succeeded: number;
+  failed: number;
+  finishedAt: number;
+  createdAt: number;
+  updatedAt: number;
+  results?: BatchJobResult[];
 }
 
 export interface DiscoverResponse {
   ports: string[];
 }
 
-export interface BatchSendResponse {
-  results: BatchSendResult[];
+export interface KeepAlivePolicy {
+  deviceId: string;
+  enabled: boolean;
+  activity: 'sms' | 'ussd';
+  intervalDays: number;
+  leadDays: number;
+  phoneNumber?: string;
+  content?: string;
+  ussdCode?: string;
+  checkTime?: string;
+  taskId?: string;
+  createdAt?: number;
+  updatedAt?: number;
+}
+
+export interface KeepAliveStatus {
+  deviceId: string;
+  deviceName: string;
+  phoneNumber: string;
+  enabled: boolean;
+  activity: 'sms' | 'ussd';
+  intervalDays: number;
+  leadDays: number;
+  taskId: string;
+  lastActivityAt: number;
+  expiresAt: number;
+  daysUntilExpiry: number | null;
+  nextCheckAt: number;
 }
 
 export const devicesApi = {
@@ -79,11 +126,19 @@ export const devicesApi = {
   getGroups: () => apiClient.get<{ groups: string[] }>('/devices/groups'),
   getStats: () => apiClient.get<Record<string, number>>('/devices/stats'),
 
+  // Keep-alive
+  getKeepAlive: (id: string) => apiClient.get<KeepAlivePolicy>(`/devices/${id}/keep-alive`),
+  saveKeepAlive: (id: string, data: Omit<KeepAlivePolicy, 'deviceId' | 'taskId' | 'createdAt' | 'updatedAt'>) =>
+    apiClient.put<KeepAlivePolicy>(`/devices/${id}/keep-alive`, data),
+  deleteKeepAlive: (id: string) => apiClient.delete(`/devices/${id}/keep-alive`),
+  keepAliveDashboard: () => apiClient.get<KeepAliveStatus[]>('/keep-alive'),
+
   // SMS
   sendSMS: (id: string, to: string, content: string) =>
     apiClient.post(`/devices/${id}/sms`, { to, content }),
   autoSendSMS: (to: string, content: string, strategy?: string) =>
     apiClient.post('/sms/send', { to, content, strategy }),
   batchSend: (data: BatchSendRequest) =>
-    apiClient.post<BatchSendResponse>('/sms/batch', data),
+    apiClient.post<BatchJob>('/sms/batch', data),
+  getBatchJob: (id: string) => apiClient.get<BatchJob>(`/sms/batch/${id}`),
 };
@@ -1,5 +1,20 @@
 import apiClient from './client';
-import type {Stats, Conversation, TextMessage} from './types';
+import type {Stats, Conversation, ConversationStatePatch, CursorPage, TextMessage, TrashItem, UnreadCounts} from './types';
+
+// 单页最大条数（与服务端上限一致）
+const PAGE_LIMIT = 100;
+
+// 沿游标依次拉取所有分页
+const fetchAllPages = async <T>(path: string): Promise<T[]> => {
+    const items: T[] = [];
+    let cursor: string | undefined;
+    do {
+        const page = await apiClient.get<CursorPage<T>>(path, {params: {cursor, limit: PAGE_LIMIT}});
+        items.push(...page.items);
+        cursor = page.nextCursor;
+    } while (cursor);
+    return items;
+};
 
 // 获取统计信息
 export const getStats = (): Promise<Stats> => {
@@ -8,12 +23,41 @@ export const getStats = (): Promise<Stats> => {
 
 // 获取会话列表（按对方号码分组）
 export const getConversations = (): Promise<Conversation[]> => {
-    return apiClient.get('/messages/conversations');
+    return fetchAllPages<Conversation>('/messages/conversations');
+};
+
+// 获取指定会话的所有消息（按时间正序）
+export const getConversationMessages = async (peer: string): Promise<TextMessage[]> => {
+    // 每页按时间正序，但游标指向更早的消息，因此需要倒序拼接
+    const path = `/messages/conversations/${encodeURIComponent(peer)}/messages`;
+    const pages: TextMessage[][] = [];
+    let cursor: string | undefined;
+    do {
+        const page = await apiClient.get<CursorPage<TextMessage>>(path, {params: {cursor, limit: PAGE_LIMIT}});
+        pages.unshift(page.items);
+        cursor = page.nextCursor;
+    } while (cursor);
+    return pages.flat();
+};
+
+// 会话标记已读
+export const markConversationRead = (peer: string) => {
+    return apiClient.post(`/messages/conversations/${encodeURIComponent(peer)}/read`);
+};
+
+// 全部标记已读（可按设备过滤）
+export const markAllRead = (deviceId?: string) => {
+    return apiClient.post('/messages/read-all', undefined, {params: {deviceId}});
+};
+
+// 获取未读统计
+export const getUnreadCounts = (): Promise<UnreadCounts> => {
+    return apiClient.get('/messages/unread');
 };
 
-// 获取指定会话的所有消息
-export const getConversationMessages = (peer: string): Promise<TextMessage[]> => {
-    return apiClient.get(`/messages/conversations/${encodeURIComponent(peer)}/messages`);
+// 设置会话置顶、归档、免打扰
+export const updateConversationState = (peer: string, patch: ConversationStatePatch) => {
+    return apiClient.put(`/messages/conversations/${encodeURIComponent(peer)}/state`, patch);
 };
 
 // 删除单条短信
@@ -26,7 +70,33 @@ export const deleteConversation = (peer: string) => {
     return apiClient.delete(`/messages/conversations/${encodeURIComponent(peer)}`);
 };
 
-// 清空所有短信
-export const clearMessages = () => {
-    return apiClient.delete('/messages');
+// 清空所有短信（移入回收站，需先获取一次性确认令牌）
+export const clearMessages = async () => {
+    const {token} = await apiClient.post<{token: string; expiresAt: number}>('/messages/clear-token');
+    return apiClient.delete('/messages', {params: {confirm: token}});
+};
+
+// 获取回收站（按时间倒序分页）
+export const getTrash = (cursor?: string): Promise<CursorPage<TrashItem>> => {
+    return apiClient.get('/messages/trash', {params: {cursor, limit: PAGE_LIMIT}});
+};
+
+// 恢复单条短信
+export const restoreMessage = (id: string) => {
+    return apiClient.post(`/messages/${id}/restore`);
+};
+
+// 恢复整个会话
+export const restoreConversation = (peer: string) => {
+    return apiClient.post(`/messages/conversations/${encodeURIComponent(peer)}/restore`);
+};
+
+// 恢复回收站中的所有短信
+export const restoreAll = () => {
+    return apiClient.post('/messages/trash/restore');
+};
+
+// 清空回收站（永久删除）
+export const emptyTrash = () => {
+    return apiClient.delete('/messages/trash');
 };
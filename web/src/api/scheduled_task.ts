@@ -3,6 +3,12 @@ import apiClient from "@/api/client.ts";
 
 export type LastRunStatus = 'unknown' | 'success' | 'failed';
 
+export type ScheduleType = 'cron' | 'interval' | 'once' | 'month_weekday';
+
+export type IntervalUnit = 'minutes' | 'hours' | 'days';
+
+export type TaskActionType = 'sms' | 'flymode' | 'reboot' | 'ussd' | 'webhook';
+
 export interface ScheduledTask {
     id: string;
     name: string;
@@ -11,12 +17,52 @@ export interface ScheduledTask {
     phoneNumber: string;
     content: string;
     deviceId?: string;
+    scheduleType?: ScheduleType;
+    cronExpr?: string;
+    timezone?: string;
+    intervalValue?: number;
+    intervalUnit?: IntervalUnit;
+    runAt?: number;
+    monthWeek?: number;
+    weekday?: number;
+    timeOfDay?: string;
+    nextRunAt?: number;
+    failureAlertThreshold?: number;
+    actionType?: TaskActionType;
+    recipients?: string[];
+    contactGroup?: string;
+    flymode?: boolean;
+    ussdCode?: string;
+    webhookUrl?: string;
+    webhookMethod?: string;
+    webhookBody?: string;
+    runCount?: number;
     createdAt?: number;
     lastRunAt?: number;
     lastMsgId?: string;
     lastRunStatus?: LastRunStatus;
 }
 
+export interface ScheduledTaskRun {
+    id: string;
+    taskId: string;
+    trigger: 'scheduled' | 'manual';
+    actionType: TaskActionType;
+    deviceId: string;
+    recipient: string;
+    output: string;
+    msgId: string;
+    status: LastRunStatus;
+    error: string;
+    durationMs: number;
+    createdAt: number;
+}
+
+export interface ScheduledTaskRunPage {
+    items: ScheduledTaskRun[];
+    nextCursor?: string;
+}
+
 // 定时任务 API (RESTful)
 // 获取所有定时任务
 export const getScheduledTasks = () => {
@@ -29,12 +75,12 @@ export const getScheduledTask = (id: string) => {
 };
 
 // 创建定时任务
-export const createScheduledTask = (task: Omit<ScheduledTask, 'id' | 'createdAt' | 'lastRunAt'>) => {
+export const createScheduledTask = (task: Omit<ScheduledTask, 'id' | 'createdAt' | 'lastRunAt' | 'nextRunAt'>) => {
     return apiClient.post<ScheduledTask>('/scheduled-tasks', task);
 };
 
 // 更新定时任务
-export const updateScheduledTask = (id: string, task: Omit<ScheduledTask, 'id' | 'createdAt' | 'lastRunAt'>) => {
+export const updateScheduledTask = (id: string, task: Omit<ScheduledTask, 'id' | 'createdAt' | 'lastRunAt' | 'nextRunAt'>) => {
     return apiClient.put<ScheduledTask>(`/scheduled-tasks/${id}`, task);
 };
 
@@ -46,4 +92,9 @@ export const deleteScheduledTask = (id: string) => {
 // 立即触发定时任务
 export const triggerScheduledTask = (id: string) => {
     return apiClient.post<{ message: string }>(`/scheduled-tasks/${id}/trigger`, {});
-};
\ No newline at end of file
+};
+
+// 获取定时任务执行记录
+export const getScheduledTaskRuns = (id: string, cursor?: string, limit = 20) => {
+    return apiClient.get<ScheduledTaskRunPage>(`/scheduled-tasks/${id}/runs`, {params: {cursor, limit}});
+};
@@ -11,6 +11,15 @@ export interface TextMessage {
     updatedAt: number;
     deviceId?: string;      // 关联设备ID
     deviceName?: string;    // 设备名称
+    peer?: string;          // 会话对方号码
+    otpCode?: string;       // 识别出的验证码
+    readAt?: number;        // 已读时间（0 表示未读）
+}
+
+// 游标分页结果
+export interface CursorPage<T> {
+    items: T[];
+    nextCursor?: string;    // 为空表示没有更多数据
 }
 
 // 查询结果
@@ -25,6 +34,7 @@ export interface Stats {
     incomingCount: number;
     outgoingCount: number;
     todayCount: number;
+    unreadCount?: number;
 }
 
 // 发送短信请求
@@ -81,4 +91,25 @@ export interface Conversation {
     lastMessage: TextMessage;  // 最后一条消息
     messageCount: number;      // 消息总数
     unreadCount: number;       // 未读数量
+    pinned?: boolean;          // 是否置顶
+    archived?: boolean;        // 是否归档
+    muted?: boolean;           // 是否免打扰
+}
+
+// 会话状态更新（未提供的字段保持不变）
+export interface ConversationStatePatch {
+    pinned?: boolean;
+    archived?: boolean;
+    muted?: boolean;
+}
+
+// 回收站条目
+export interface TrashItem extends TextMessage {
+    deletedAt: number;         // 删除时间
+}
+
+// 未读统计
+export interface UnreadCounts {
+    total: number;
+    devices: {deviceId: string; deviceName: string; count: number}[];
 }
@@ -48,6 +48,7 @@ export default function Layout() {
     const handleLogout = () => {
         // 清除 localStorage
         localStorage.removeItem('token');
+        localStorage.removeItem('refreshToken');
         localStorage.removeItem('username');
 
         toast.success('已退出登录');
@@ -1,7 +1,7 @@
-import {useState} from 'react';
+import {useEffect, useState} from 'react';
 import {useQuery, useMutation} from '@tanstack/react-query';
 import {devicesApi} from '@/api/devices';
-import type {BatchSendRequest, BatchSendResult} from '@/api/devices';
+import type {BatchSendRequest} from '@/api/devices';
 import {Card, CardContent, CardHeader, CardTitle} from '@/components/ui/card';
 import {Button} from '@/components/ui/button';
 import {Textarea} from '@/components/ui/textarea';
@@ -20,7 +20,7 @@ export default function BatchSend() {
     const [content, setContent] = useState('');
     const [deviceId, setDeviceId] = useState('auto');
     const [strategy, setStrategy] = useState<'auto' | 'round_robin' | 'random' | 'signal_best'>('auto');
-    const [results, setResults] = useState<BatchSendResult[]>([]);
+    const [jobId, setJobId] = useState('');
 
     // 获取设备列表
     const {data: devices} = useQuery({
@@ -28,18 +28,31 @@ export default function BatchSend() {
         queryFn: devicesApi.list,
     });
 
-    // 批量发送
+    // 批量发送在后台执行，提交后轮询任务进度直到完成
+    const {data: job} = useQuery({
+        queryKey: ['batchJob', jobId],
+        queryFn: () => devicesApi.getBatchJob(jobId),
+        enabled: jobId !== '',
+        refetchInterval: (query) => (query.state.data?.status === 'completed' ? false : 1000),
+    });
+    const results = job?.results?.filter((r) => r.status !== 'pending') || [];
+
+    useEffect(() => {
+        if (job?.status !== 'completed') {
+            return;
+        }
+        if (job.failed === 0) {
+            toast.success(`全部发送成功 (${job.succeeded} 条)`);
+        } else {
+            toast.warning(`发送完成: ${job.succeeded} 成功, ${job.failed} 失败`);
+        }
+    }, [job?.id, job?.status]);
+
     const batchSendMutation = useMutation({
         mutationFn: (data: BatchSendRequest) => devicesApi.batchSend(data),
         onSuccess: (data) => {
-            setResults(data.results || []);
-            const successCount = data.results?.filter((r) => r.success).length || 0;
-            const failCount = (data.results?.length || 0) - successCount;
-            if (failCount === 0) {
-                toast.success(`全部发送成功 (${successCount} 条)`);
-            } else {
-                toast.warning(`发送完成: ${successCount} 成功, ${failCount} 失败`);
-            }
+            setJobId(data.id);
+            toast.success(`已提交批量发送任务 (${data.total} 条)`);
         },
         onError: () => {
             toast.error('批量发送失败');
@@ -205,13 +218,13 @@ export default function BatchSend() {
                                     <div
                                         key={index}
                                         className={`flex items-center justify-between p-3 rounded-lg ${
-                                            result.success
+                                            result.status === 'success'
                                                 ? 'bg-green-50 border border-green-200'
                                                 : 'bg-red-50 border border-red-200'
                                         }`}
                                     >
                                         <div className="flex items-center gap-2">
-                                            {result.success ? (
+                                            {result.status === 'success' ? (
                                                 <CheckCircle className="w-4 h-4 text-green-500" />
                                             ) : (
                                                 <XCircle className="w-4 h-4 text-red-500" />
@@ -221,7 +234,7 @@ export default function BatchSend() {
                                             </span>
                                         </div>
                                         <div className="text-xs text-gray-500">
-                                            {result.success ? (
+                                            {result.status === 'success' ? (
                                                 <span className="text-green-600">已发送</span>
                                             ) : (
                                                 <span className="text-red-600">{result.error}</span>
@@ -236,13 +249,13 @@ export default function BatchSend() {
                         {results.length > 0 && (
                             <div className="mt-4 pt-4 border-t flex justify-center gap-6 text-sm">
                                 <div className="text-green-600">
-                                    成功: {results.filter((r) => r.success).length}
+                                    成功: {results.filter((r) => r.status === 'success').length}
                                 </div>
                                 <div className="text-red-600">
-                                    失败: {results.filter((r) => !r.success).length}
+                                    失败: {results.filter((r) => r.status === 'failed').length}
                                 </div>
                                 <div className="text-gray-500">
-                                    总计: {results.length}
+                                    总计: {job?.total ?? results.length}
                                 </div>
                             </div>
                         )}
@@ -3,13 +3,16 @@ import {useNavigate} from 'react-router-dom';
 import {Card, CardContent, CardHeader, CardTitle} from '@/components/ui/card';
 import {Button} from '@/components/ui/button';
 import {Input} from '@/components/ui/input';
-import {Lock, User} from 'lucide-react';
+import {KeyRound, Lock, User} from 'lucide-react';
 import {getAuthConfig, getOIDCAuthURL, login as loginApi, type AuthConfig} from '@/api/auth';
+import {ApiError} from '@/api/client';
 import {toast} from 'sonner';
 
 export default function Login() {
     const [username, setUsername] = useState('');
     const [password, setPassword] = useState('');
+    const [code, setCode] = useState('');
+    const [totpRequired, setTotpRequired] = useState(false);
     const [loading, setLoading] = useState(false);
     const [authConfig, setAuthConfig] = useState<AuthConfig | null>(null);
     const [configLoading, setConfigLoading] = useState(true);
@@ -32,21 +35,31 @@ export default function Login() {
     const handleLogin = async (event: FormEvent<HTMLFormElement>) => {
         event.preventDefault();
 
-        if (loading || !username || !password) {
+        if (loading || !username || !password || (totpRequired && !code)) {
             return;
         }
 
         setLoading(true);
         try {
-            const response = await loginApi({username, password});
+            const response = await loginApi({username, password, code: totpRequired ? code : undefined});
 
             // 保存到 localStorage
             localStorage.setItem('token', response.token);
+            localStorage.setItem('refreshToken', response.refreshToken);
             localStorage.setItem('username', response.username);
 
             toast.success('登录成功');
             navigate('/');
         } catch (error) {
+            // 启用了两步验证，显示验证码输入框
+            if (error instanceof ApiError && error.data?.totpRequired) {
+                if (!totpRequired) {
+                    setTotpRequired(true);
+                    toast.info('请输入两步验证码');
+                    return;
+                }
+                setCode('');
+            }
             toast.error('登录失败：' + (error instanceof Error ? error.message : '未知错误'));
         } finally {
             setLoading(false);
@@ -65,7 +78,7 @@ export default function Login() {
         }
     };
 
-    const submitDisabled = loading || !username || !password;
+    const submitDisabled = loading || !username || !password || (totpRequired && !code);
 
     return (
         <div
@@ -133,6 +146,31 @@ export default function Login() {
                                         </div>
                                     </div>
 
+                                    {totpRequired && (
+                                        <div className="space-y-2">
+                                            <label htmlFor="code"
+                                                   className="text-sm font-medium text-slate-800 block">
+                                                两步验证码
+                                            </label>
+                                            <div className="relative">
+                                                <KeyRound
+                                                    className="absolute left-3 top-1/2 -translate-y-1/2 w-4 h-4 text-slate-400"/>
+                                                <Input
+                                                    id="code"
+                                                    type="text"
+                                                    placeholder="请输入验证码或恢复码"
+                                                    value={code}
+                                                    onChange={(event) => setCode(event.target.value.trim())}
+                                                    className="pl-10 h-11"
+                                                    disabled={loading}
+                                                    required
+                                                    autoFocus
+                                                    autoComplete="one-time-code"
+                                                />
+                                            </div>
+                                        </div>
+                                    )}
+
                                     <Button
                                         type="submit"
                                         className="w-full h-11 text-base font-medium cursor-pointer"
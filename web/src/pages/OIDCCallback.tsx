@@ -32,10 +32,11 @@ const OIDCCallback = () => {
 
             try {
                 const response = await oidcLogin(code, state);
-                const {token, username} = response;
+                const {token, refreshToken, username} = response;
 
                 // 保存到 localStorage
                 localStorage.setItem('token', token);
+                localStorage.setItem('refreshToken', refreshToken);
                 localStorage.setItem('username', username);
 
                 toast.success('登录成功');
@@ -41,6 +41,25 @@ interface TaskFormData {
     deviceId: string;
 }
 
+const intervalUnitLabels: Record<string, string> = {minutes: '分钟', hours: '小时', days: '天'};
+const weekdayLabels = ['日', '一', '二', '三', '四', '五', '六'];
+
+// 调度方式描述
+const describeSchedule = (task: ScheduledTask) => {
+    switch (task.scheduleType) {
+        case 'cron':
+            return `${task.cronExpr}${task.timezone ? ` (${task.timezone})` : ''}`;
+        case 'interval':
+            return `每 ${task.intervalValue} ${intervalUnitLabels[task.intervalUnit || 'days']}`;
+        case 'once':
+            return `${new Date(task.runAt || 0).toLocaleString('zh-CN')} 执行一次`;
+        case 'month_weekday':
+            return `每月${task.monthWeek === -1 ? '最后一个' : `第 ${task.monthWeek} 个`}星期${weekdayLabels[task.weekday || 0]} ${task.timeOfDay}`;
+        default:
+            return `每 ${task.intervalDays} 天`;
+    }
+};
+
 export default function ScheduledTasksConfig() {
     const queryClient = useQueryClient();
     const [dialogOpen, setDialogOpen] = useState(false);
@@ -119,7 +138,7 @@ export default function ScheduledTasksConfig() {
 
     // 更新任务 mutation
     const updateMutation = useMutation({
-        mutationFn: ({id, task}: { id: string; task: TaskFormData }) =>
+        mutationFn: ({id, task}: { id: string; task: Parameters<typeof updateScheduledTask>[1] }) =>
             updateScheduledTask(id, task),
         onSuccess: () => {
             queryClient.invalidateQueries({queryKey: ['scheduledTasks']});
@@ -205,6 +224,10 @@ export default function ScheduledTasksConfig() {
         });
     };
 
+    // 表单只编辑按天间隔，其他调度方式（通过 API 配置）编辑时原样保留
+    const keepSchedule = !!editingTask?.scheduleType &&
+        !(editingTask.scheduleType === 'interval' && editingTask.intervalUnit === 'days');
+
     // 提交表单
     const handleSubmit = () => {
         // 验证必填字段
@@ -212,22 +235,47 @@ export default function ScheduledTasksConfig() {
             toast.warning('请输入任务名称');
             return;
         }
-        if (!formData.intervalDays || formData.intervalDays <= 0) {
+        if (!keepSchedule && (!formData.intervalDays || formData.intervalDays <= 0)) {
             toast.warning('请输入有效的执行间隔天数（必须大于0）');
             return;
         }
-        if (!formData.phoneNumber.trim()) {
+        // 通过 API 配置的非短信动作不需要号码和内容
+        const smsAction = !editingTask?.actionType || editingTask.actionType === 'sms';
+        if (smsAction && !formData.phoneNumber.trim() && !editingTask?.recipients?.length && !editingTask?.contactGroup) {
             toast.warning('请输入目标手机号');
             return;
         }
-        if (!formData.content.trim()) {
+        if (smsAction && !formData.content.trim()) {
             toast.warning('请输入短信内容');
             return;
         }
 
         if (editingTask) {
             // 更新任务
-            updateMutation.mutate({id: editingTask.id, task: formData});
+            const schedule = keepSchedule ? {
+                scheduleType: editingTask.scheduleType,
+                cronExpr: editingTask.cronExpr,
+                timezone: editingTask.timezone,
+                intervalValue: editingTask.intervalValue,
+                intervalUnit: editingTask.intervalUnit,
+                runAt: editingTask.runAt,
+                monthWeek: editingTask.monthWeek,
+                weekday: editingTask.weekday,
+                timeOfDay: editingTask.timeOfDay,
+            } : {};
+            // 表单不编辑的动作和告警配置原样保留
+            const preserved = {
+                failureAlertThreshold: editingTask.failureAlertThreshold,
+                actionType: editingTask.actionType,
+                recipients: editingTask.recipients,
+                contactGroup: editingTask.contactGroup,
+                flymode: editingTask.flymode,
+                ussdCode: editingTask.ussdCode,
+                webhookUrl: editingTask.webhookUrl,
+                webhookMethod: editingTask.webhookMethod,
+                webhookBody: editingTask.webhookBody,
+            };
+            updateMutation.mutate({id: editingTask.id, task: {...preserved, ...formData, ...schedule}});
         } else {
             // 创建任务
             createMutation.mutate(formData);
@@ -318,9 +366,14 @@ export default function ScheduledTasksConfig() {
                                         <Clock size={14} className="text-gray-400 mt-0.5 flex-shrink-0"/>
                                         <div className="flex-1 min-w-0">
                                             <span
-                                                className="text-xs text-gray-400 font-medium block mb-0.5">执行间隔</span>
+                                                className="text-xs text-gray-400 font-medium block mb-0.5">执行计划</span>
                                             <span
-                                                className="text-sm text-gray-700 font-semibold">每 {task.intervalDays} 天</span>
+                                                className="text-sm text-gray-700 font-semibold">{describeSchedule(task)}</span>
+                                            {task.enabled && task.nextRunAt ? (
+                                                <span className="text-xs text-gray-400 block mt-0.5">
+                                                    下次执行：{new Date(task.nextRunAt).toLocaleString('zh-CN')}
+                                                </span>
+                                            ) : null}
                                         </div>
                                     </div>
 